@@ -52,6 +52,10 @@ func init() {
 func main() {
 	flag.Parse()
 
+	if flag.Arg(0) == validateCmd {
+		validateAndExit()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	// Enables Control+C termination
 	go func() {
@@ -0,0 +1,79 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/ctl/validate"
+	v4 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4"
+)
+
+const validateCmd = "validate"
+
+// runValidate checks the agent, integrations and logging configuration files, printing the found
+// issues to stderr and the generated fluent-bit configuration to stdout. It returns the exit code.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(validateCmd, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configFile := flags.String("config", "", "Agent configuration file [Optional] (default locations otherwise)")
+	integrationsPath := flags.String("integrations", "", "Integrations configuration file or directory [Optional] (agent config otherwise)")
+	loggingDir := flags.String("logging", "", "Logging configuration directory [Optional] (agent config otherwise)")
+	printFB := flags.Bool("print-fluentbit", true, "Print the generated fluent-bit configuration")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, issues := validate.Agent(*configFile)
+	if cfg == nil {
+		printIssues(stderr, issues)
+		return 1
+	}
+
+	integrationsPaths := cfg.PluginInstanceDirs
+	if *integrationsPath != "" {
+		integrationsPaths = []string{*integrationsPath}
+	}
+	// integrations are looked up the same way the agent does
+	il := v4.NewInstancesLookup(v4.NewManagerConfig(
+		cfg.Log.VerboseEnabled(),
+		cfg.Features,
+		cfg.PassthroughEnvironment,
+		integrationsPaths,
+		v4.DefinitionFolders(cfg),
+	))
+	issues = append(issues, validate.Integrations(integrationsPaths, il, cfg.PassthroughEnvironment)...)
+
+	if *loggingDir != "" {
+		cfg.LoggingConfigsDir = *loggingDir
+	}
+	fbConfig, logIssues := validate.Logs(config.NewLogForward(cfg, config.Troubleshoot{}))
+	issues = append(issues, logIssues...)
+
+	if *printFB && fbConfig != "" {
+		fmt.Fprintln(stdout, fbConfig)
+	}
+
+	if len(issues) > 0 {
+		printIssues(stderr, issues)
+		return 1
+	}
+	fmt.Fprintln(stderr, "Configuration is valid.")
+	return 0
+}
+
+func printIssues(w io.Writer, issues []validate.Issue) {
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue.String())
+	}
+	fmt.Fprintf(w, "%d configuration issue(s) found.\n", len(issues))
+}
+
+func validateAndExit() {
+	os.Exit(runValidate(flag.Args()[1:], os.Stdout, os.Stderr))
+}
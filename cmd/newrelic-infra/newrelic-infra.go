@@ -25,7 +25,6 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/cmd/newrelic-infra/dnschecks"
 	"github.com/newrelic/infrastructure-agent/pkg/disk"
-	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 	http2 "github.com/newrelic/infrastructure-agent/pkg/http"
 	logFilter "github.com/newrelic/infrastructure-agent/pkg/log/filter"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/cloud"
@@ -37,14 +36,13 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel"
 	ccBackoff "github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/backoff"
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/diagnostics"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/fflag"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/runintegration"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/service"
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/stopintegration"
 	"github.com/newrelic/infrastructure-agent/internal/agent/status"
-	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
-	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/v3legacy"
 	"github.com/newrelic/infrastructure-agent/internal/socketapi"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/track"
@@ -282,7 +280,7 @@ var aslog = wlog.WithComponent("AgentService").WithFields(logrus.Fields{
 })
 
 func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
-	pluginSourceDirs := getPluginSourceDirs(c)
+	pluginSourceDirs := v4.DefinitionFolders(c)
 
 	v4ManagerConfig := v4.NewManagerConfig(
 		c.Log.VerboseEnabled(),
@@ -299,7 +297,7 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	ccSvcURL := fmt.Sprintf("%s%s", cmdChannelURL, c.CommandChannelEndpoint)
 	caClient := commandapi.NewClient(ccSvcURL, c.License, userAgent, httpClient.Do)
 	ffManager := feature_flags.NewManager(c.Features)
-	il := newInstancesLookup(v4ManagerConfig)
+	il := v4.NewInstancesLookup(v4ManagerConfig)
 
 	fatal := func(err error, message string) {
 		aslog.WithError(err).Error(message)
@@ -364,6 +362,15 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	wlog.Instrument(instruments.Measure)
 
 	metricsSenderConfig := dm.NewConfig(c.DMIngestURL(), c.Fedramp, c.License, time.Duration(c.DMSubmissionPeriod)*time.Second, c.MaxMetricBatchEntitiesCount, c.MaxMetricBatchEntitiesQueue)
+	if c.PersistentQueueEnabled {
+		dmQueue, err := agent.OpenDimensionalMetricsQueue(agt.GetContext().Context(), c)
+		if err != nil {
+			alog.WithError(err).Warn("cannot open dimensional metrics persistent queue, metrics will only be retried in memory")
+		} else {
+			defer dmQueue.Close()
+			metricsSenderConfig.PersistentQueue = dmQueue
+		}
+	}
 	dmSender, err := dm.NewDMSender(metricsSenderConfig, transport, agt.Context.IdContext().AgentIdentity)
 	if err != nil {
 		return err
@@ -399,6 +406,23 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	ffHandler := cmdchannel.NewCmdHandler("set_feature_flag", ffHandle.Handle)
 	riHandler := runintegration.NewHandler(definitionQ, il, dmEmitter, wlog.WithComponent("runintegration.Handler"))
 	siHandler := stopintegration.NewHandler(tracker, il, dmEmitter, wlog.WithComponent("stopintegration.Handler"))
+
+	timeoutD, timeoutErr := time.ParseDuration(c.StartupConnectionTimeout)
+	statusReporter := status.NewReporter(agt.Context.Ctx, wlog.WithComponent("status.Reporter"), c.StatusEndpoints, timeoutD, transport, agt.Context.AgentIdnOrEmpty, agt.Context.EntityKey, integrationManager.IntegrationsStatus, c.License, userAgent)
+
+	diagCfg := diagnostics.Config{
+		Dir:          c.DiagnosticsDir,
+		Endpoint:     c.DiagnosticsEndpoint,
+		LogFile:      c.GetLogFile(),
+		DataDir:      agentDataDir(c),
+		AgentVersion: buildVersion,
+	}
+	diagSources := diagnostics.Sources{
+		Config:       c.PublicFields,
+		Status:       statusReporter,
+		Integrations: integrationManager.IntegrationsStatus,
+	}
+	cdHandler := diagnostics.NewHandler(diagCfg, diagSources, httpClient.Do, wlog.WithComponent("diagnostics.Handler"))
 	// Command channel service
 	ccService := service.NewService(
 		caClient,
@@ -408,6 +432,7 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		ffHandler,
 		riHandler,
 		siHandler,
+		cdHandler,
 	)
 	initCmdResponse, err := ccService.InitialFetch(agt.Context.Ctx)
 	if err != nil {
@@ -415,17 +440,14 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	}
 
 	if c.StatusServerEnabled || c.HTTPServerEnabled {
-		rlog := wlog.WithComponent("status.Reporter")
-		timeoutD, err := time.ParseDuration(c.StartupConnectionTimeout)
-		if err != nil {
+		if timeoutErr != nil {
 			// This should never happen, as the correct format is checked during NormalizeConfig.
-			aslog.WithError(err).Error("invalid startup_connection_timeout value, cannot run status server")
+			aslog.WithError(timeoutErr).Error("invalid startup_connection_timeout value, cannot run status server")
 		} else {
-			rep := status.NewReporter(agt.Context.Ctx, rlog, c.StatusEndpoints, timeoutD, transport, agt.Context.AgentIdnOrEmpty, agt.Context.EntityKey, c.License, userAgent)
-
-			apiSrv, err := httpapi.NewServer(rep, integrationEmitter)
+			apiSrv, err := httpapi.NewServer(statusReporter, integrationEmitter)
 			if c.HTTPServerEnabled {
 				apiSrv.Ingest.Enable(c.HTTPServerHost, c.HTTPServerPort)
+				apiSrv.Ingest.OTLPMaxPayloadSize(c.HTTPServerOTLPMaxPayloadSize)
 			}
 
 			if c.HTTPServerCert != "" && c.HTTPServerKey != "" {
@@ -449,7 +471,13 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	}
 
 	if c.TCPServerEnabled {
-		go socketapi.NewServer(integrationEmitter, c.TCPServerPort).Serve(agt.Context.Ctx)
+		socketCfg := socketapi.Config{
+			Port:           c.TCPServerPort,
+			SocketPath:     c.TCPServerSocketPath,
+			MaxConnections: c.TCPServerMaxConnections,
+			MaxPayloadSize: c.TCPServerMaxPayloadSize,
+		}
+		go socketapi.NewServer(integrationEmitter, socketCfg).Serve(agt.Context.Ctx)
 	}
 
 	// Start all plugins we want the agent to run.
@@ -511,6 +539,14 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 	return agt.Run()
 }
 
+// agentDataDir returns the directory where the agent stores its data, as the inventory delta store.
+func agentDataDir(c *config.Config) string {
+	if c.AppDataDir != "" {
+		return filepath.Join(c.AppDataDir, "data")
+	}
+	return filepath.Join(c.AgentDir, "data")
+}
+
 // initInstrumentation will spawn a server and expose agent metrics through prometheus exporter.
 // By default is disabled and it only will be enabled if host:port are provided.
 // Using instrumentation.SetupPrometheusIntegrationConfig it will create prometheus
@@ -549,27 +585,6 @@ func initInstrumentation(ctx context2.Context, agentMetricsEndpoint string) (ins
 	return instruments, nil
 }
 
-// newInstancesLookup creates an instance lookup that:
-// - looks in the v3 legacy definitions repository for defined commands
-// - looks in the definition folders (and bin/ subfolders) for executable names
-func newInstancesLookup(cfg v4.ManagerConfig) integration.InstancesLookup {
-	const executablesSubFolder = "bin"
-
-	var execFolders []string
-	for _, df := range cfg.DefinitionFolders {
-		execFolders = append(execFolders, df)
-		execFolders = append(execFolders, filepath.Join(df, executablesSubFolder))
-	}
-	legacyDefinedCommands := v3legacy.NewDefinitionsRepo(v3legacy.LegacyConfig{
-		DefinitionFolders: cfg.DefinitionFolders,
-		Verbose:           cfg.Verbose,
-	})
-	return integration.InstancesLookup{
-		Legacy: legacyDefinedCommands.NewDefinitionCommand,
-		ByName: files.Executables{Folders: execFolders}.Path,
-	}
-}
-
 // configureLogFormat checks the config and sets the log format accordingly.
 func configureLogFormat(cfg config.LogConfig) {
 	// get default logrus formatter
@@ -717,17 +732,6 @@ func checkEndpointReachable(
 	return
 }
 
-func getPluginSourceDirs(ac *config.Config) []string {
-	pluginSourceDirs := []string{
-		ac.CustomPluginInstallationDir,
-		filepath.Join(ac.AgentDir, "custom-integrations"),
-		filepath.Join(ac.AgentDir, config.DefaultIntegrationsDir),
-		filepath.Join(ac.AgentDir, "bundled-plugins"),
-		filepath.Join(ac.AgentDir, "plugins"),
-	}
-	return helpers.RemoveEmptyAndDuplicateEntries(pluginSourceDirs)
-}
-
 // executeIntegrationsDryRunMode is used for dry-run mode. It will read the integration config files,
 // execute all the integrations and print the output to stdout.
 func executeIntegrationsDryRunMode(configPath string, ac *config.Config) {
@@ -743,7 +747,7 @@ func executeIntegrationsDryRunMode(configPath string, ac *config.Config) {
 		ac.Features,
 		ac.PassthroughEnvironment,
 		integrationConfigPaths,
-		getPluginSourceDirs(ac),
+		v4.DefinitionFolders(ac),
 	)
 
 	var definitionQ chan integration.Definition
@@ -772,7 +776,7 @@ func executeIntegrationsDryRunMode(configPath string, ac *config.Config) {
 		v4ManagerConfig,
 		cfgLoader,
 		integrationEmitter,
-		newInstancesLookup(v4ManagerConfig),
+		v4.NewInstancesLookup(v4ManagerConfig),
 		definitionQ,
 		configEntryQ,
 		tracker,
@@ -0,0 +1,408 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package diagnostics provides a command channel handler collecting an on-demand troubleshooting
+// bundle, to be stored locally or submitted to an endpoint.
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel"
+	"github.com/newrelic/infrastructure-agent/internal/agent/status"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/commandapi"
+	backendhttp "github.com/newrelic/infrastructure-agent/pkg/backend/http"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	cmdName = "collect_diagnostics"
+
+	// DefaultMaxLogLines is the default amount of recent agent log lines added to the bundle.
+	DefaultMaxLogLines = 1000
+	// DefaultMaxBundles is the default amount of bundles kept in the output directory.
+	DefaultMaxBundles = 5
+
+	// bundle entries bigger than this are truncated
+	maxEntryBytes = 4 * 1024 * 1024
+	// maximum amount of bytes read from the end of the log file
+	maxLogTailBytes = 2 * 1024 * 1024
+	// maximum amount of data directory files listed
+	maxDataDirEntries = 5000
+
+	bundlePrefix = "diagnostics-"
+	bundleExt    = ".tar.gz"
+	truncatedMsg = "\n... truncated\n"
+)
+
+// Errors
+var (
+	ErrNoDestination  = errors.New("diagnostics bundle destination is not configured")
+	ErrAlreadyRunning = errors.New("diagnostics bundle collection already running")
+)
+
+// Config diagnostics bundle configuration.
+type Config struct {
+	// Dir stores the bundles into this directory, when set.
+	Dir string
+	// Endpoint submits the bundles through an HTTP POST to this URL, when set.
+	Endpoint string
+	// LogFile is the agent log file the recent lines are read from.
+	LogFile string
+	// DataDir is the agent data directory holding the inventory delta store.
+	DataDir string
+	// AgentVersion is added to the bundle metadata.
+	AgentVersion string
+	// MaxLogLines limits the amount of recent log lines. Zero means DefaultMaxLogLines.
+	MaxLogLines int
+	// MaxBundles limits the amount of bundles kept in Dir. Zero means DefaultMaxBundles.
+	MaxBundles int
+}
+
+// Sources provide the runtime agent information added to the bundle. Any of them may be nil.
+type Sources struct {
+	Config       func() (map[string]string, error)
+	Status       status.Reporter
+	Integrations func() []runner.InstanceStatus
+}
+
+// Metadata describes the bundle.
+type Metadata struct {
+	AgentVersion string    `json:"agent_version"`
+	Hostname     string    `json:"hostname"`
+	OS           string    `json:"os"`
+	Arch         string    `json:"arch"`
+	CollectedAt  time.Time `json:"collected_at"`
+	CommandHash  string    `json:"command_hash,omitempty"`
+	Errors       []string  `json:"errors,omitempty"`
+}
+
+type entry struct {
+	name    string
+	content []byte
+}
+
+// collector gathers the bundle entries.
+type collector struct {
+	cfg        Config
+	sources    Sources
+	httpClient backendhttp.Client
+	now        func() time.Time
+	running    int32
+}
+
+// NewHandler creates a cmd-channel handler for collect-diagnostics requests.
+func NewHandler(cfg Config, sources Sources, httpClient backendhttp.Client, l log.Entry) *cmdchannel.CmdHandler {
+	if cfg.MaxLogLines <= 0 {
+		cfg.MaxLogLines = DefaultMaxLogLines
+	}
+	if cfg.MaxBundles <= 0 {
+		cfg.MaxBundles = DefaultMaxBundles
+	}
+	c := &collector{
+		cfg:        cfg,
+		sources:    sources,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+
+	handleF := func(ctx context.Context, cmd commandapi.Command, initialFetch bool) error {
+		if cfg.Dir == "" && cfg.Endpoint == "" {
+			return ErrNoDestination
+		}
+		if !atomic.CompareAndSwapInt32(&c.running, 0, 1) {
+			return ErrAlreadyRunning
+		}
+		defer atomic.StoreInt32(&c.running, 0)
+
+		l.WithField("hash", cmd.Hash).Info("Collecting diagnostics bundle.")
+		bundle, err := c.bundle(cmd.Hash)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Dir != "" {
+			path, err := c.store(bundle)
+			if err != nil {
+				return err
+			}
+			l.WithField("file", path).Info("Diagnostics bundle stored.")
+		}
+		if cfg.Endpoint != "" {
+			if err = c.submit(ctx, bundle); err != nil {
+				return err
+			}
+			l.WithField("endpoint", redactURL(cfg.Endpoint)).Info("Diagnostics bundle submitted.")
+		}
+		return nil
+	}
+
+	return cmdchannel.NewCmdHandler(cmdName, handleF)
+}
+
+// bundle returns the gzipped tarball holding all the collected entries. Sources failing are reported
+// within the metadata entry rather than failing the whole bundle.
+func (c *collector) bundle(cmdHash string) ([]byte, error) {
+	meta := Metadata{
+		AgentVersion: c.cfg.AgentVersion,
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		CollectedAt:  c.now().UTC(),
+		CommandHash:  cmdHash,
+	}
+	meta.Hostname, _ = os.Hostname()
+
+	var entries []entry
+	add := func(name string, content []byte, err error) {
+		if err != nil {
+			meta.Errors = append(meta.Errors, fmt.Sprintf("%s: %s", name, err))
+			return
+		}
+		entries = append(entries, entry{name: name, content: truncate(content)})
+	}
+
+	add(c.config())
+	add(c.status())
+	add(c.integrations())
+	add(c.logs())
+	add(goroutines())
+	add(c.deltaStore())
+
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	entries = append([]entry{{name: "metadata.json", content: metaJSON}}, entries...)
+
+	return archive(entries, meta.CollectedAt)
+}
+
+func (c *collector) config() (string, []byte, error) {
+	const name = "config.json"
+	if c.sources.Config == nil {
+		return name, nil, errors.New("not available")
+	}
+	fields, err := c.sources.Config()
+	if err != nil {
+		return name, nil, err
+	}
+	fields = helpers.ObfuscateSensitiveDataFromMap(fields)
+	for k, v := range fields {
+		fields[k] = redactURL(helpers.ObfuscateSensitiveDataFromString(v))
+	}
+	content, err := json.MarshalIndent(fields, "", "  ")
+	return name, content, err
+}
+
+func (c *collector) status() (string, []byte, error) {
+	const name = "status.json"
+	if c.sources.Status == nil {
+		return name, nil, errors.New("not available")
+	}
+	report, err := c.sources.Status.Report()
+	if err != nil {
+		return name, nil, err
+	}
+	content, err := json.MarshalIndent(report, "", "  ")
+	return name, content, err
+}
+
+func (c *collector) integrations() (string, []byte, error) {
+	const name = "integrations.json"
+	if c.sources.Integrations == nil {
+		return name, nil, errors.New("not available")
+	}
+	content, err := json.MarshalIndent(c.sources.Integrations(), "", "  ")
+	return name, content, err
+}
+
+// logs returns the most recent agent log lines.
+func (c *collector) logs() (string, []byte, error) {
+	const name = "agent.log"
+	if c.cfg.LogFile == "" {
+		return name, nil, errors.New("agent is not logging to a file")
+	}
+	f, err := os.Open(c.cfg.LogFile)
+	if err != nil {
+		return name, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return name, nil, err
+	}
+	offset := info.Size() - maxLogTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return name, nil, err
+	}
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return name, nil, err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if offset > 0 && len(lines) > 0 {
+		// first line is likely partial
+		lines = lines[1:]
+	}
+	if len(lines) > c.cfg.MaxLogLines {
+		lines = lines[len(lines)-c.cfg.MaxLogLines:]
+	}
+	return name, []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func goroutines() (string, []byte, error) {
+	const name = "goroutines.txt"
+	var buf bytes.Buffer
+	err := pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	return name, buf.Bytes(), err
+}
+
+// deltaStore returns the size of the files within the agent data directory, where the inventory delta
+// store is kept, and the total size per top level directory.
+func (c *collector) deltaStore() (string, []byte, error) {
+	const name = "delta_store.json"
+	if c.cfg.DataDir == "" {
+		return name, nil, errors.New("not available")
+	}
+
+	type report struct {
+		Dir       string           `json:"dir"`
+		Totals    map[string]int64 `json:"totals"`
+		Files     map[string]int64 `json:"files"`
+		Truncated bool             `json:"truncated,omitempty"`
+	}
+	r := report{Dir: c.cfg.DataDir, Totals: map[string]int64{}, Files: map[string]int64{}}
+
+	err := filepath.Walk(c.cfg.DataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			// unreadable entries are skipped
+			return nil
+		}
+		rel, err := filepath.Rel(c.cfg.DataDir, path)
+		if err != nil {
+			return nil
+		}
+		top := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+		r.Totals[top] += info.Size()
+		if len(r.Files) < maxDataDirEntries {
+			r.Files[filepath.ToSlash(rel)] = info.Size()
+		} else {
+			r.Truncated = true
+		}
+		return nil
+	})
+	if err != nil {
+		return name, nil, err
+	}
+	content, err := json.MarshalIndent(r, "", "  ")
+	return name, content, err
+}
+
+func truncate(content []byte) []byte {
+	if len(content) <= maxEntryBytes {
+		return content
+	}
+	return append(content[:maxEntryBytes-len(truncatedMsg)], truncatedMsg...)
+}
+
+func archive(entries []entry, modTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    0o600,
+			Size:    int64(len(e.content)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// store writes the bundle into the output directory, removing the oldest bundles over the limit.
+func (c *collector) store(bundle []byte) (string, error) {
+	if err := os.MkdirAll(c.cfg.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("cannot create diagnostics directory: %w", err)
+	}
+	path := filepath.Join(c.cfg.Dir, bundlePrefix+c.now().UTC().Format("20060102T150405.000Z")+bundleExt)
+	if err := ioutil.WriteFile(path, bundle, 0o600); err != nil {
+		return "", fmt.Errorf("cannot write diagnostics bundle: %w", err)
+	}
+
+	bundles, err := filepath.Glob(filepath.Join(c.cfg.Dir, bundlePrefix+"*"+bundleExt))
+	if err == nil && len(bundles) > c.cfg.MaxBundles {
+		// names sort chronologically
+		sort.Strings(bundles)
+		for _, old := range bundles[:len(bundles)-c.cfg.MaxBundles] {
+			_ = os.Remove(old)
+		}
+	}
+	return path, nil
+}
+
+func (c *collector) submit(ctx context.Context, bundle []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.Endpoint, bytes.NewReader(bundle))
+	if err != nil {
+		return fmt.Errorf("cannot create diagnostics request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := c.httpClient(req)
+	if err != nil {
+		return fmt.Errorf("cannot submit diagnostics bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("diagnostics bundle was not accepted: %s", resp.Status)
+	}
+	return nil
+}
+
+// redactURL hides the password of values holding an URL with user info.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil || u.Host == "" {
+		return value
+	}
+	return u.Redacted()
+}
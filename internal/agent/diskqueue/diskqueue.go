@@ -0,0 +1,528 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package diskqueue provides a FIFO queue persisted as append-only segment files, so queued records
+// survive agent restarts. Records are removed from the queue once they are acknowledged, or dropped
+// when they exceed the configured size or age limits.
+package diskqueue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSegmentBytes is the default size limit of a single segment file.
+	DefaultSegmentBytes = 4 * 1024 * 1024
+
+	segmentExt = ".seg"
+	cursorFile = "cursor"
+	// record header: payload length (uint32), payload CRC32 (uint32) and write time in unix nanoseconds (int64)
+	headerSize = 16
+)
+
+var (
+	// ErrEmpty is returned when there are no records to read.
+	ErrEmpty = errors.New("queue is empty")
+	// ErrNotPeeked is returned when acknowledging without reading a record first.
+	ErrNotPeeked = errors.New("no record to acknowledge")
+
+	errCorrupted = errors.New("corrupted record")
+)
+
+// Config disk queue configuration.
+type Config struct {
+	// Dir is the directory holding the queue files.
+	Dir string
+	// MaxBytes limits the on-disk size of the queue, oldest segments are dropped when exceeded. Zero means no limit.
+	MaxBytes int64
+	// MaxAge drops records older than this duration when read. Zero means no limit.
+	MaxAge time.Duration
+	// SegmentBytes limits the size of each segment file. Zero means DefaultSegmentBytes.
+	SegmentBytes int64
+}
+
+type segment struct {
+	seq     uint64
+	size    int64 // valid bytes
+	records int
+}
+
+// Queue is a FIFO queue of byte records persisted on disk. It's safe for concurrent use.
+type Queue struct {
+	cfg  Config
+	lock sync.Mutex
+	now  func() time.Time
+
+	segments []*segment // oldest first, the last one is written
+	writer   *os.File
+
+	// read cursor
+	reader     *os.File
+	readSeq    uint64
+	readOffset int64
+	readIndex  int // records read from the cursor segment
+	peekedLen  int64
+
+	pending int
+	size    int64
+	dropped uint64
+	notify  chan struct{}
+}
+
+// Open opens the queue stored into the configured directory, creating it when missing.
+func Open(cfg Config) (*Queue, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("missing queue directory")
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = DefaultSegmentBytes
+	}
+	// keep a few segments within the size limit, so dropping one doesn't empty the queue
+	if cfg.MaxBytes > 0 && cfg.SegmentBytes > cfg.MaxBytes/4 {
+		cfg.SegmentBytes = cfg.MaxBytes / 4
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create queue directory: %w", err)
+	}
+
+	q := &Queue{
+		cfg:    cfg,
+		now:    time.Now,
+		notify: make(chan struct{}, 1),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load rebuilds the queue state from the segment files and the persisted read cursor.
+func (q *Queue) load() error {
+	seqs, err := q.segmentSeqs()
+	if err != nil {
+		return err
+	}
+	cursorSeq, cursorOffset := q.readCursor()
+
+	for _, seq := range seqs {
+		if seq < cursorSeq {
+			// already consumed, but removal was interrupted
+			_ = os.Remove(q.segmentPath(seq))
+			continue
+		}
+		seg, err := q.scanSegment(seq)
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, seg)
+		q.size += seg.size
+	}
+
+	if len(q.segments) == 0 {
+		next := cursorSeq
+		if len(seqs) > 0 && seqs[len(seqs)-1] >= next {
+			next = seqs[len(seqs)-1] + 1
+		}
+		if next == 0 {
+			next = 1
+		}
+		q.segments = []*segment{{seq: next}}
+	}
+
+	head := q.segments[0]
+	q.readSeq = head.seq
+	if head.seq == cursorSeq && cursorOffset <= head.size {
+		if q.readIndex, err = q.countRecords(head.seq, cursorOffset); err != nil {
+			return err
+		}
+		q.readOffset = cursorOffset
+	}
+
+	for _, seg := range q.segments {
+		q.pending += seg.records
+	}
+	q.pending -= q.readIndex
+
+	last := q.segments[len(q.segments)-1]
+	q.writer, err = os.OpenFile(q.segmentPath(last.seq), os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open queue segment: %w", err)
+	}
+	// discard any partially written record
+	if err = q.writer.Truncate(last.size); err != nil {
+		return fmt.Errorf("cannot truncate queue segment: %w", err)
+	}
+	if _, err = q.writer.Seek(last.size, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek queue segment: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) segmentSeqs() ([]uint64, error) {
+	files, err := ioutil.ReadDir(q.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read queue directory: %w", err)
+	}
+	var seqs []uint64
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// scanSegment validates the records of a segment. Records after a corrupted or partially written
+// one are ignored.
+func (q *Queue) scanSegment(seq uint64) (*segment, error) {
+	f, err := os.Open(q.segmentPath(seq))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open queue segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat queue segment: %w", err)
+	}
+
+	seg := &segment{seq: seq}
+	for {
+		n, _, _, err := readRecord(f, seg.size, info.Size())
+		if err != nil {
+			break
+		}
+		seg.size += n
+		seg.records++
+	}
+	return seg, nil
+}
+
+// countRecords returns the amount of records in the segment before the passed offset.
+func (q *Queue) countRecords(seq uint64, offset int64) (int, error) {
+	f, err := os.Open(q.segmentPath(seq))
+	if err != nil {
+		return 0, fmt.Errorf("cannot open queue segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("cannot stat queue segment: %w", err)
+	}
+
+	var pos int64
+	count := 0
+	for pos < offset {
+		n, _, _, err := readRecord(f, pos, info.Size())
+		if err != nil {
+			break
+		}
+		pos += n
+		count++
+	}
+	return count, nil
+}
+
+// Push appends a record to the queue.
+func (q *Queue) Push(payload []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	recordLen := int64(headerSize + len(payload))
+	last := q.segments[len(q.segments)-1]
+	if last.size > 0 && last.size+recordLen > q.cfg.SegmentBytes {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+
+	record := make([]byte, recordLen)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	binary.BigEndian.PutUint64(record[8:16], uint64(q.now().UnixNano()))
+	copy(record[headerSize:], payload)
+
+	if _, err := q.writer.Write(record); err != nil {
+		// leave the segment consistent for the next write
+		_ = q.writer.Truncate(last.size)
+		_, _ = q.writer.Seek(last.size, io.SeekStart)
+		return fmt.Errorf("cannot write queue record: %w", err)
+	}
+	if err := q.writer.Sync(); err != nil {
+		return fmt.Errorf("cannot sync queue segment: %w", err)
+	}
+	last.size += recordLen
+	last.records++
+	q.size += recordLen
+	q.pending++
+
+	q.enforceMaxBytes()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *Queue) rotate() error {
+	if err := q.writer.Close(); err != nil {
+		return fmt.Errorf("cannot close queue segment: %w", err)
+	}
+	seg := &segment{seq: q.segments[len(q.segments)-1].seq + 1}
+	w, err := os.OpenFile(q.segmentPath(seg.seq), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot create queue segment: %w", err)
+	}
+	q.writer = w
+	q.segments = append(q.segments, seg)
+	return nil
+}
+
+// enforceMaxBytes drops the oldest segments until the queue fits within the size limit.
+// The segment being written is never dropped.
+func (q *Queue) enforceMaxBytes() {
+	for q.cfg.MaxBytes > 0 && q.size > q.cfg.MaxBytes && len(q.segments) > 1 {
+		head := q.segments[0]
+		unread := head.records
+		if head.seq == q.readSeq {
+			unread -= q.readIndex
+		}
+		q.dropped += uint64(unread)
+		q.pending -= unread
+		q.removeHead()
+	}
+}
+
+// removeHead deletes the oldest segment and moves the read cursor to the next one.
+func (q *Queue) removeHead() {
+	head := q.segments[0]
+	if q.reader != nil {
+		_ = q.reader.Close()
+		q.reader = nil
+	}
+	_ = os.Remove(q.segmentPath(head.seq))
+	q.size -= head.size
+	q.segments = q.segments[1:]
+
+	q.readSeq = q.segments[0].seq
+	q.readOffset = 0
+	q.readIndex = 0
+	q.peekedLen = 0
+	_ = q.writeCursor()
+}
+
+// Peek returns the oldest record without removing it. ErrEmpty is returned when there are no records.
+// Records older than the configured max age are dropped.
+func (q *Queue) Peek() ([]byte, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		head := q.segments[0]
+		if q.readOffset >= head.size {
+			if len(q.segments) == 1 {
+				return nil, ErrEmpty
+			}
+			q.removeHead()
+			continue
+		}
+
+		if q.reader == nil {
+			r, err := os.Open(q.segmentPath(head.seq))
+			if err != nil {
+				return nil, fmt.Errorf("cannot open queue segment: %w", err)
+			}
+			q.reader = r
+		}
+
+		n, payload, written, err := readRecord(q.reader, q.readOffset, head.size)
+		if err != nil {
+			// skip the rest of a corrupted segment
+			unread := head.records - q.readIndex
+			q.dropped += uint64(unread)
+			q.pending -= unread
+			q.readOffset = head.size
+			q.readIndex = head.records
+			if len(q.segments) == 1 {
+				return nil, fmt.Errorf("cannot read queue record: %w", err)
+			}
+			continue
+		}
+
+		if q.cfg.MaxAge > 0 && q.now().Sub(written) > q.cfg.MaxAge {
+			q.dropped++
+			q.advance(n)
+			continue
+		}
+
+		q.peekedLen = n
+		return payload, nil
+	}
+}
+
+// Ack removes the record returned by the last Peek call.
+func (q *Queue) Ack() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.peekedLen == 0 {
+		return ErrNotPeeked
+	}
+	q.advance(q.peekedLen)
+	return q.writeCursor()
+}
+
+// Skip drops the unread records of the segment being read, which is removed. It's meant for segments which
+// cannot be read, so the records pushed afterwards are still delivered.
+func (q *Queue) Skip() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.segments) == 1 {
+		// the segment being written is never removed
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	head := q.segments[0]
+	unread := head.records
+	if head.seq == q.readSeq {
+		unread -= q.readIndex
+	}
+	q.dropped += uint64(unread)
+	q.pending -= unread
+	q.removeHead()
+	return nil
+}
+
+func (q *Queue) advance(recordLen int64) {
+	q.readOffset += recordLen
+	q.readIndex++
+	q.pending--
+	q.peekedLen = 0
+}
+
+// Notify returns a channel receiving a value after records are pushed.
+func (q *Queue) Notify() <-chan struct{} {
+	return q.notify
+}
+
+// Len returns the amount of records in the queue.
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.pending
+}
+
+// Size returns the size in bytes of the queue files.
+func (q *Queue) Size() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.size
+}
+
+// Dropped returns the amount of records dropped because of the size or age limits, or corruption.
+func (q *Queue) Dropped() uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dropped
+}
+
+// Close releases the queue files. Queued records are kept for the next Open.
+func (q *Queue) Close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.reader != nil {
+		_ = q.reader.Close()
+		q.reader = nil
+	}
+	if err := q.writeCursor(); err != nil {
+		return err
+	}
+	return q.writer.Close()
+}
+
+func (q *Queue) segmentPath(seq uint64) string {
+	return filepath.Join(q.cfg.Dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// readCursor returns the persisted read position, or zero values when missing.
+func (q *Queue) readCursor() (seq uint64, offset int64) {
+	content, err := ioutil.ReadFile(filepath.Join(q.cfg.Dir, cursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return 0, 0
+	}
+	seq, err = strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0
+	}
+	offset, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0
+	}
+	return seq, offset
+}
+
+// writeCursor persists the read position, replacing the file atomically.
+func (q *Queue) writeCursor() error {
+	path := filepath.Join(q.cfg.Dir, cursorFile)
+	tmp := path + ".tmp"
+	content := fmt.Sprintf("%d %d\n", q.readSeq, q.readOffset)
+	if err := ioutil.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("cannot write queue cursor: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("cannot write queue cursor: %w", err)
+	}
+	return nil
+}
+
+// readRecord reads the record at the passed offset, returning its total length, payload and write time.
+// Records whose length doesn't fit in the segment size are reported as corrupted before reading them.
+func readRecord(r io.ReaderAt, offset, size int64) (n int64, payload []byte, written time.Time, err error) {
+	header := make([]byte, headerSize)
+	if _, err = r.ReadAt(header, offset); err != nil {
+		return 0, nil, time.Time{}, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	written = time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16])))
+
+	if int64(length) > size-offset-headerSize {
+		return 0, nil, time.Time{}, errCorrupted
+	}
+	payload = make([]byte, length)
+	if _, err = r.ReadAt(payload, offset+headerSize); err != nil {
+		return 0, nil, time.Time{}, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return 0, nil, time.Time{}, errCorrupted
+	}
+	return headerSize + int64(length), payload, written, nil
+}
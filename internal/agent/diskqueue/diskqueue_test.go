@@ -0,0 +1,249 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package diskqueue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_PushPeekAck(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer q.Close()
+
+	_, err = q.Peek()
+	assert.Equal(t, ErrEmpty, err)
+	assert.Equal(t, ErrNotPeeked, q.Ack())
+
+	require.NoError(t, q.Push([]byte("first")))
+	require.NoError(t, q.Push([]byte("second")))
+	assert.Equal(t, 2, q.Len())
+
+	// peeking doesn't remove the record
+	for i := 0; i < 2; i++ {
+		r, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(r))
+	}
+
+	require.NoError(t, q.Ack())
+	r, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(r))
+	require.NoError(t, q.Ack())
+
+	_, err = q.Peek()
+	assert.Equal(t, ErrEmpty, err)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestQueue_Notify(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer q.Close()
+
+	require.NoError(t, q.Push([]byte("record")))
+
+	select {
+	case <-q.Notify():
+	case <-time.After(time.Second):
+		t.Fatal("no push notification")
+	}
+}
+
+func TestQueue_PersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	// GIVEN a queue with records spread over several segments, and some of them acknowledged
+	q, err := Open(Config{Dir: dir, SegmentBytes: 64})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, q.Push([]byte(fmt.Sprintf("record-%d", i))))
+	}
+	for i := 0; i < 4; i++ {
+		_, err = q.Peek()
+		require.NoError(t, err)
+		require.NoError(t, q.Ack())
+	}
+	require.NoError(t, q.Close())
+
+	// WHEN the queue is opened again
+	q, err = Open(Config{Dir: dir, SegmentBytes: 64})
+	require.NoError(t, err)
+	defer q.Close()
+
+	// THEN the pending records are read in order
+	assert.Equal(t, 6, q.Len())
+	for i := 4; i < 10; i++ {
+		r, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("record-%d", i), string(r))
+		require.NoError(t, q.Ack())
+	}
+	_, err = q.Peek()
+	assert.Equal(t, ErrEmpty, err)
+
+	// AND consumed segments are removed
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
+
+func TestQueue_DiscardsPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, q.Push([]byte("complete")))
+	require.NoError(t, q.Close())
+
+	// GIVEN an interrupted write at the end of the segment
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	f, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 42, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// WHEN the queue is opened
+	q, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	defer q.Close()
+
+	// THEN the partial record is discarded and new records can be appended
+	require.NoError(t, q.Push([]byte("next")))
+	assert.Equal(t, 2, q.Len())
+	for _, expected := range []string{"complete", "next"} {
+		r, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(r))
+		require.NoError(t, q.Ack())
+	}
+}
+
+func TestQueue_CorruptedRecordLength(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, q.Push([]byte("first")))
+	require.NoError(t, q.Push([]byte("second")))
+	require.NoError(t, q.Close())
+
+	// GIVEN a torn header announcing a record larger than the segment
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	f, err := os.OpenFile(segments[0], os.O_WRONLY, 0)
+	require.NoError(t, err)
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, 0xFFFFFFFF)
+	_, err = f.WriteAt(length, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// WHEN the queue is opened
+	q, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	defer q.Close()
+
+	// THEN the corrupted records are discarded without reading them and new records can be appended
+	assert.Equal(t, 0, q.Len())
+	require.NoError(t, q.Push([]byte("next")))
+	r, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "next", string(r))
+}
+
+func TestReadRecord_LengthBeyondSegment(t *testing.T) {
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:4], 0xFFFFFFFF)
+
+	_, _, _, err := readRecord(bytes.NewReader(header), 0, int64(len(header)))
+
+	assert.ErrorIs(t, err, errCorrupted)
+}
+
+func TestQueue_MaxBytes(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir(), MaxBytes: 200})
+	require.NoError(t, err)
+	defer q.Close()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, q.Push([]byte(fmt.Sprintf("record-%02d", i))))
+	}
+
+	assert.LessOrEqual(t, q.Size(), int64(200))
+	assert.NotZero(t, q.Dropped())
+	assert.Equal(t, 20, q.Len()+int(q.Dropped()))
+
+	// newest records are kept
+	var last string
+	for {
+		r, err := q.Peek()
+		if err == ErrEmpty {
+			break
+		}
+		require.NoError(t, err)
+		last = string(r)
+		require.NoError(t, q.Ack())
+	}
+	assert.Equal(t, "record-19", last)
+}
+
+func TestQueue_MaxAge(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir(), MaxAge: time.Hour})
+	require.NoError(t, err)
+	defer q.Close()
+
+	now := time.Now()
+	q.now = func() time.Time { return now.Add(-2 * time.Hour) }
+	require.NoError(t, q.Push([]byte("old")))
+	q.now = func() time.Time { return now }
+	require.NoError(t, q.Push([]byte("new")))
+
+	r, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(r))
+	assert.Equal(t, uint64(1), q.Dropped())
+}
+
+func TestQueue_Skip(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	defer q.Close()
+
+	// GIVEN a segment which cannot be read
+	require.NoError(t, q.Push([]byte("first")))
+	require.NoError(t, q.Push([]byte("second")))
+	require.NoError(t, os.Remove(q.segmentPath(q.readSeq)))
+	_, err = q.Peek()
+	require.Error(t, err)
+
+	// WHEN it's skipped
+	require.NoError(t, q.Skip())
+
+	// THEN its records are dropped
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, uint64(2), q.Dropped())
+	_, err = q.Peek()
+	assert.Equal(t, ErrEmpty, err)
+
+	// AND the records pushed afterwards are read
+	require.NoError(t, q.Push([]byte("third")))
+	r, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(r))
+}
@@ -8,6 +8,7 @@ import (
 	goContext "context"
 	"encoding/json"
 	"fmt"
+	"github.com/newrelic/infrastructure-agent/internal/agent/diskqueue"
 	"github.com/newrelic/infrastructure-agent/internal/agent/instrumentation"
 	http2 "github.com/newrelic/infrastructure-agent/pkg/http"
 	"io/ioutil"
@@ -80,7 +81,9 @@ type metricsIngestSender struct {
 	agentIDProvide           id.Provide
 	connectEnabled           bool
 	getBackoffTimer          func(time.Duration) *time.Timer
-	postCount                uint64 // counts post requests for debugging purposes
+	postCount                uint64           // counts post requests for debugging purposes
+	diskQueue                *diskqueue.Queue // Persists the batches before sending them, when enabled
+	diskQueueClosed          bool             // The disk queue is closed on stop, and opened again on start
 }
 
 func newMetricsIngestSender(ctx *context, licenseKey, userAgent string, httpClient backendhttp.Client, connectEnabled bool) *metricsIngestSender {
@@ -108,6 +111,15 @@ func newMetricsIngestSender(ctx *context, licenseKey, userAgent string, httpClie
 		maxMetricsBatchSizeBytes = config.DefaultMaxMetricsBatchSizeBytes
 	}
 
+	var diskQueue *diskqueue.Queue
+	if cfg.PersistentQueueEnabled {
+		var err error
+		if diskQueue, err = openPersistentQueue(cfg); err != nil {
+			ilog.WithError(err).Warn("cannot open persistent queue, events will only be queued in memory")
+			diskQueue = nil
+		}
+	}
+
 	return &metricsIngestSender{
 		eventQueue:               make(chan eventData, eventQueue),
 		batchQueue:               make(chan eventBatch, batchQueue),
@@ -123,6 +135,7 @@ func newMetricsIngestSender(ctx *context, licenseKey, userAgent string, httpClie
 		connectEnabled:           connectEnabled,
 		getBackoffTimer:          time.NewTimer,
 		postCount:                0,
+		diskQueue:                diskQueue,
 	}
 }
 
@@ -135,6 +148,14 @@ func (sender *metricsIngestSender) Start() (err error) {
 	// Set up the stop channel so the routines can wait for it to be closed
 	sender.stopChannel = make(chan bool)
 
+	if sender.diskQueue != nil && sender.diskQueueClosed {
+		if sender.diskQueue, err = openPersistentQueue(sender.Context.Config()); err != nil {
+			ilog.WithError(err).Warn("cannot open persistent queue, events will only be queued in memory")
+			sender.diskQueue, err = nil, nil
+		}
+		sender.diskQueueClosed = false
+	}
+
 	// Wait for accumulateBatches and sendBatches to complete
 	sender.internalRoutineWaits.Add(3)
 
@@ -148,9 +169,29 @@ func (sender *metricsIngestSender) Start() (err error) {
 		sender.accumulateBatches()
 	}()
 
+	if sender.diskQueue == nil {
+		go func() {
+			defer sender.internalRoutineWaits.Done()
+			sender.sendBatches()
+		}()
+		return
+	}
+
+	// batches are persisted first, and submitted from the disk queue
+	sender.internalRoutineWaits.Add(2)
+	go func() {
+		defer sender.internalRoutineWaits.Done()
+		sender.persistBatches()
+	}()
+
+	go func() {
+		defer sender.internalRoutineWaits.Done()
+		sender.replayBatches()
+	}()
+
 	go func() {
 		defer sender.internalRoutineWaits.Done()
-		sender.sendBatches()
+		reportPersistentQueueMetrics(sender.diskQueue, "agent.persistentQueue", sender.stopChannel)
 	}()
 
 	return
@@ -167,6 +208,10 @@ func (sender *metricsIngestSender) Stop() (err error) {
 	sender.internalRoutineWaits.Wait()
 	sender.stopChannel = nil
 
+	if sender.diskQueue != nil {
+		err = sender.closePersistentQueue()
+		sender.diskQueueClosed = true
+	}
 	return
 }
 
@@ -269,6 +314,13 @@ func (sender *metricsIngestSender) accumulateBatches() {
 		case <-sender.stopChannel:
 			// Stop channel has been closed - exit.
 			// There might still be some events in the queue, but they'll still be there in case we start the sender back up.
+			if sender.diskQueue != nil && len(batch) > 0 {
+				// the accumulated events are persisted on stop
+				select {
+				case sender.batchQueue <- batch:
+				default:
+				}
+			}
 			return
 		}
 	}
@@ -339,91 +391,96 @@ func (sender *metricsIngestSender) sendBatches() {
 	retryBO := backoff.NewDefaultBackoff()
 	for {
 		select {
-
 		case batch := <-sender.batchQueue:
-			ctx := goContext.Background()
-			ctx, txn := instrumentation.SelfInstrumentation.StartTransaction(ctx, "sender.sendBatches")
-
-			pclog := ilog.WithField("postCount", sender.postCount)
-			sender.postCount++
-
-			agentKey := ""
-			dataByEntity := make(map[entity.Key]*MetricPost)
-
-			ctx, seg := txn.StartSegment(ctx, "getAgentId")
-			agentID := sender.agentID()
-			seg.End()
-
-			ctx, seg = txn.StartSegment(ctx, "rebuildEvents")
-			// We need to rebuild the array of events as a []json.RawMessage, or else JSON marshalling won't handle them correctly.
-			for _, event := range batch {
-				entityData := dataByEntity[event.entityKey]
-				if entityData == nil {
-					entityData = newMetricPost(event.entityKey, event.entityID, agentID, event.agentKey)
-					dataByEntity[event.entityKey] = entityData
-				}
-				entityData.Events = append(entityData.Events, event.data)
-				if event.agentKey != "" {
-					agentKey = event.agentKey
-				}
-			}
-			seg.End()
-
-			ctx, seg = txn.StartSegment(ctx, "prepareBulkPost")
-			var bulkPost MetricPostBatch
-			for _, entityData := range dataByEntity {
-				metric := instrumentation.NewGauge("agent.postEventsNum", float64(len(entityData.Events)))
-				instrumentation.SelfInstrumentation.RecordMetric(ctx, metric)
-				pclog.WithFieldsF(entityData.getLoggingField).
-					WithFieldsF(entityData.getTimestampLoggingFields).
-					WithField("numEvents", len(entityData.Events)).
-					Debug("Sending events to metrics-ingest.")
-				bulkPost = append(bulkPost, entityData)
-			}
-			pclog.Debug("Preparing metrics post.")
-			seg.End()
+			_ = sender.sendBatch(batch, retryBO)
+		case <-sender.stopChannel:
+			// Stop channel has been closed - exit.
+			// There might still be some batches in the queue, but they'll still be there in case we start the sender back up.
+			return
+		}
+	}
+}
 
-			err := sender.doPost(ctx, bulkPost, agentKey)
+// sendBatch posts a batch to the ingest API, waiting for the requested backoff on failure.
+func (sender *metricsIngestSender) sendBatch(batch eventBatch, retryBO *backoff.Backoff) error {
+	ctx := goContext.Background()
+	ctx, txn := instrumentation.SelfInstrumentation.StartTransaction(ctx, "sender.sendBatches")
 
-			if err == nil {
-				pclog.Debug("Metrics post succeeded.")
-				sender.sendErrorCount = 0
-				retryBO.Reset()
-				txn.End()
-				continue
-			}
+	pclog := ilog.WithField("postCount", sender.postCount)
+	sender.postCount++
 
-			sender.sendErrorCount++
-			pclog.WithError(err).WithField("sendErrorCount", sender.sendErrorCount).Error("metric sender can't process")
+	agentKey := ""
+	dataByEntity := make(map[entity.Key]*MetricPost)
 
-			e, ok := err.(*errRetry)
-			if !ok {
-				txn.NoticeError(err)
-				txn.End()
-				continue
-			}
+	ctx, seg := txn.StartSegment(ctx, "getAgentId")
+	agentID := sender.agentID()
+	seg.End()
 
-			if e.retryPolicy.After > 0 {
-				pclog.WithField("retryAfter", e.retryPolicy.After).Debug("Metric sender retry requested.")
-				retryBO.Reset()
-				sender.backoff(e.retryPolicy.After)
-				txn.NoticeError(e)
-				txn.AddAttribute("retryAfter", e.retryPolicy.After)
-				txn.End()
-				continue
-			}
-			retryBOAfter := retryBO.DurationWithMax(e.retryPolicy.MaxBackOff)
-			pclog.WithField("retryBackoffAfter", retryBOAfter).Debug("Metric sender backoff and retry requested.")
-			sender.backoff(retryBOAfter)
-			txn.AddAttribute("retryBackoffAfter", retryBOAfter)
-			txn.NoticeError(e)
-			txn.End()
-		case <-sender.stopChannel:
-			// Stop channel has been closed - exit.
-			// There might still be some batches in the queue, but they'll still be there in case we start the sender back up.
-			return
+	ctx, seg = txn.StartSegment(ctx, "rebuildEvents")
+	// We need to rebuild the array of events as a []json.RawMessage, or else JSON marshalling won't handle them correctly.
+	for _, event := range batch {
+		entityData := dataByEntity[event.entityKey]
+		if entityData == nil {
+			entityData = newMetricPost(event.entityKey, event.entityID, agentID, event.agentKey)
+			dataByEntity[event.entityKey] = entityData
+		}
+		entityData.Events = append(entityData.Events, event.data)
+		if event.agentKey != "" {
+			agentKey = event.agentKey
 		}
 	}
+	seg.End()
+
+	ctx, seg = txn.StartSegment(ctx, "prepareBulkPost")
+	var bulkPost MetricPostBatch
+	for _, entityData := range dataByEntity {
+		metric := instrumentation.NewGauge("agent.postEventsNum", float64(len(entityData.Events)))
+		instrumentation.SelfInstrumentation.RecordMetric(ctx, metric)
+		pclog.WithFieldsF(entityData.getLoggingField).
+			WithFieldsF(entityData.getTimestampLoggingFields).
+			WithField("numEvents", len(entityData.Events)).
+			Debug("Sending events to metrics-ingest.")
+		bulkPost = append(bulkPost, entityData)
+	}
+	pclog.Debug("Preparing metrics post.")
+	seg.End()
+
+	err := sender.doPost(ctx, bulkPost, agentKey)
+
+	if err == nil {
+		pclog.Debug("Metrics post succeeded.")
+		sender.sendErrorCount = 0
+		retryBO.Reset()
+		txn.End()
+		return nil
+	}
+
+	sender.sendErrorCount++
+	pclog.WithError(err).WithField("sendErrorCount", sender.sendErrorCount).Error("metric sender can't process")
+
+	e, ok := err.(*errRetry)
+	if !ok {
+		txn.NoticeError(err)
+		txn.End()
+		return err
+	}
+
+	if e.retryPolicy.After > 0 {
+		pclog.WithField("retryAfter", e.retryPolicy.After).Debug("Metric sender retry requested.")
+		retryBO.Reset()
+		sender.backoff(e.retryPolicy.After)
+		txn.NoticeError(e)
+		txn.AddAttribute("retryAfter", e.retryPolicy.After)
+		txn.End()
+		return err
+	}
+	retryBOAfter := retryBO.DurationWithMax(e.retryPolicy.MaxBackOff)
+	pclog.WithField("retryBackoffAfter", retryBOAfter).Debug("Metric sender backoff and retry requested.")
+	sender.backoff(retryBOAfter)
+	txn.AddAttribute("retryBackoffAfter", retryBOAfter)
+	txn.NoticeError(e)
+	txn.End()
+	return err
 }
 
 func (s *metricsIngestSender) agentID() entity.ID {
@@ -519,7 +576,7 @@ func (sender *metricsIngestSender) doPost(ctx goContext.Context, post []*MetricP
 	extSeg.End()
 
 	if err != nil {
-		return fmt.Errorf("error sending events: %v", err)
+		return fmt.Errorf("%w: %v", errEventsNotSent, err)
 	}
 
 	// To let the http client reusing the connections, the response body
@@ -0,0 +1,249 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package agent
+
+import (
+	goContext "context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/diskqueue"
+	"github.com/newrelic/infrastructure-agent/internal/agent/instrumentation"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/backoff"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+)
+
+const (
+	persistentQueueDirName   = "queue"
+	dmPersistentQueueDirName = "dm_queue"
+)
+
+// errEventsNotSent is returned when the events post didn't reach the backend.
+var errEventsNotSent = errors.New("error sending events")
+
+// persistedEvent is the on-disk representation of an eventData.
+type persistedEvent struct {
+	EntityKey entity.Key      `json:"entityKey"`
+	EntityID  entity.ID       `json:"entityID,omitempty"`
+	AgentKey  string          `json:"agentKey,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// openPersistentQueue opens the on-disk batches queue placed into the agent data directory.
+func openPersistentQueue(cfg *config.Config) (*diskqueue.Queue, error) {
+	return openDiskQueue(cfg, persistentQueueDirName)
+}
+
+// OpenDimensionalMetricsQueue opens the on-disk dimensional metrics requests queue placed into the agent data
+// directory, reporting its depth until the context is done.
+func OpenDimensionalMetricsQueue(ctx goContext.Context, cfg *config.Config) (*diskqueue.Queue, error) {
+	queue, err := openDiskQueue(cfg, dmPersistentQueueDirName)
+	if err != nil {
+		return nil, err
+	}
+
+	stopChannel := make(chan bool)
+	go func() {
+		<-ctx.Done()
+		close(stopChannel)
+	}()
+	go reportPersistentQueueMetrics(queue, "agent.dmPersistentQueue", stopChannel)
+
+	return queue, nil
+}
+
+func openDiskQueue(cfg *config.Config, dirName string) (*diskqueue.Queue, error) {
+	dataDir := cfg.AppDataDir
+	if dataDir == "" {
+		dataDir = cfg.AgentDir
+	}
+
+	return diskqueue.Open(diskqueue.Config{
+		Dir:      filepath.Join(dataDir, "data", dirName),
+		MaxBytes: cfg.PersistentQueueMaxBytes,
+		MaxAge:   time.Duration(cfg.PersistentQueueMaxAgeSec) * time.Second,
+	})
+}
+
+func marshalBatch(batch eventBatch) ([]byte, error) {
+	events := make([]persistedEvent, 0, len(batch))
+	for _, e := range batch {
+		events = append(events, persistedEvent{
+			EntityKey: e.entityKey,
+			EntityID:  e.entityID,
+			AgentKey:  e.agentKey,
+			Data:      e.data,
+		})
+	}
+	return json.Marshal(events)
+}
+
+func unmarshalBatch(payload []byte) (eventBatch, error) {
+	var events []persistedEvent
+	if err := json.Unmarshal(payload, &events); err != nil {
+		return nil, err
+	}
+	batch := make(eventBatch, 0, len(events))
+	for _, e := range events {
+		batch = append(batch, eventData{
+			entityKey: e.EntityKey,
+			entityID:  e.EntityID,
+			agentKey:  e.AgentKey,
+			data:      e.Data,
+		})
+	}
+	return batch, nil
+}
+
+// persistentQueueReadRetries is the amount of consecutive failed reads before the unreadable segment is skipped.
+const persistentQueueReadRetries = 3
+
+// persistBatches stores the accumulated batches into the disk queue, to be submitted by replayBatches.
+func (sender *metricsIngestSender) persistBatches() {
+	for {
+		select {
+		case batch := <-sender.batchQueue:
+			sender.persistBatch(batch)
+		case <-sender.stopChannel:
+			return
+		}
+	}
+}
+
+func (sender *metricsIngestSender) persistBatch(batch eventBatch) {
+	payload, err := marshalBatch(batch)
+	if err != nil {
+		ilog.WithError(err).Warn("cannot marshal events batch, discarding it")
+		return
+	}
+	if err = sender.diskQueue.Push(payload); err != nil {
+		ilog.WithError(err).Warn("cannot persist events batch, submitting it directly")
+		_ = sender.sendBatch(batch, backoff.NewDefaultBackoff())
+	}
+}
+
+// closePersistentQueue persists the batches still queued in memory and closes the disk queue, so they are
+// submitted once the agent starts again. It must be called once the sender routines are stopped.
+func (sender *metricsIngestSender) closePersistentQueue() error {
+	for {
+		select {
+		case batch := <-sender.batchQueue:
+			payload, err := marshalBatch(batch)
+			if err == nil {
+				err = sender.diskQueue.Push(payload)
+			}
+			if err != nil {
+				ilog.WithError(err).Warn("cannot persist events batch on stop, discarding it")
+			}
+		default:
+			return sender.diskQueue.Close()
+		}
+	}
+}
+
+// replayBatches submits the persisted batches in order, removing them from the disk queue once they are
+// accepted or rejected as invalid. Batches failing because of the backend availability are retried.
+func (sender *metricsIngestSender) replayBatches() {
+	retryBO := backoff.NewDefaultBackoff()
+	readFailures := 0
+	for {
+		payload, err := sender.diskQueue.Peek()
+		if errors.Is(err, diskqueue.ErrEmpty) {
+			select {
+			case <-sender.diskQueue.Notify():
+				continue
+			case <-sender.stopChannel:
+				return
+			}
+		}
+		if err != nil {
+			readFailures++
+			if readFailures < persistentQueueReadRetries {
+				ilog.WithError(err).Warn("cannot read persisted events batch")
+			} else {
+				ilog.WithError(err).Warn("cannot read persisted events batches, discarding them")
+				if err = sender.diskQueue.Skip(); err != nil {
+					ilog.WithError(err).Warn("cannot discard persisted events batches")
+				}
+				readFailures = 0
+			}
+			sender.backoff(retryBO.Duration())
+			if sender.stopped() {
+				return
+			}
+			continue
+		}
+		readFailures = 0
+
+		batch, err := unmarshalBatch(payload)
+		if err != nil {
+			ilog.WithError(err).Warn("cannot unmarshal persisted events batch, discarding it")
+		} else if err = sender.sendBatch(batch, retryBO); err != nil && isRetriableSendError(err) {
+			if _, ok := err.(*errRetry); !ok {
+				// errRetry backoff is already handled while sending
+				sender.backoff(retryBO.Duration())
+			}
+			if sender.stopped() {
+				return
+			}
+			continue
+		}
+
+		if err = sender.diskQueue.Ack(); err != nil {
+			// only persisting the read cursor failed, the batch is not read again
+			ilog.WithError(err).Warn("cannot remove persisted events batch")
+			sender.backoff(retryBO.Duration())
+		}
+		if sender.stopped() {
+			return
+		}
+	}
+}
+
+// isRetriableSendError returns true when the error is caused by the backend availability rather than
+// by the submitted data, so the batch should be submitted again.
+func isRetriableSendError(err error) bool {
+	if errors.Is(err, errEventsNotSent) {
+		return true
+	}
+	e, ok := err.(*errRetry)
+	if !ok {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+func (sender *metricsIngestSender) stopped() bool {
+	select {
+	case <-sender.stopChannel:
+		return true
+	default:
+		return false
+	}
+}
+
+func reportPersistentQueueMetrics(queue *diskqueue.Queue, metricPrefix string, stopChannel chan bool) {
+	sendTimer := time.NewTicker(time.Second * 10)
+	for {
+		select {
+		case <-sendTimer.C:
+			metric := instrumentation.NewGauge(metricPrefix+"Size", float64(queue.Len()))
+			instrumentation.SelfInstrumentation.RecordMetric(goContext.Background(), metric)
+			metric = instrumentation.NewGauge(metricPrefix+"Bytes", float64(queue.Size()))
+			instrumentation.SelfInstrumentation.RecordMetric(goContext.Background(), metric)
+			metric = instrumentation.NewGauge(metricPrefix+"Dropped", float64(queue.Dropped()))
+			instrumentation.SelfInstrumentation.RecordMetric(goContext.Background(), metric)
+		case <-stopChannel:
+			sendTimer.Stop()
+			return
+		}
+	}
+}
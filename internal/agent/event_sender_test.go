@@ -4,6 +4,7 @@ package agent
 
 import (
 	"compress/gzip"
+	goContext "context"
 	"crypto/tls"
 	"encoding/json"
 	"io/ioutil"
@@ -12,7 +13,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -21,6 +25,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
 	infra "github.com/newrelic/infrastructure-agent/test/infra/http"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
@@ -548,3 +553,158 @@ func newTestContext(agentKey string, cfg *config.Config) *context {
 		cfg:      cfg,
 	}
 }
+
+func TestEventSender_PersistentQueueReplaysAfterOutage(t *testing.T) {
+	dataDir := t.TempDir()
+	cfg := &config.Config{
+		AgentDir:                 dataDir,
+		PayloadCompressionLevel:  gzip.NoCompression,
+		PersistentQueueEnabled:   true,
+		PersistentQueueMaxBytes:  1024 * 1024,
+		PersistentQueueMaxAgeSec: 3600,
+	}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil)
+	c.setAgentKey(agentKey)
+
+	// GIVEN the backend is unreachable
+	failedCh := make(chan struct{}, 10)
+	unreachable := func(req *http.Request) (*http.Response, error) {
+		failedCh <- struct{}{}
+		return nil, &net.OpError{Op: "dial", Err: net.UnknownNetworkError("unreachable")}
+	}
+	sender := newMetricsIngestSender(c, "license", "userAgent", unreachable, false)
+	require.NotNil(t, sender.diskQueue)
+	sender.getBackoffTimer = func(time.Duration) *time.Timer { return time.NewTimer(time.Hour) }
+
+	require.NoError(t, sender.Start())
+	require.NoError(t, sender.QueueEvent(ev, ""))
+
+	// WHEN the post fails and the agent stops
+	<-failedCh
+	require.NoError(t, sender.Stop())
+	assert.Equal(t, 1, sender.diskQueue.Len())
+
+	// AND the agent starts again with a reachable backend
+	postedCh := make(chan []MetricPost, 1)
+	reachable := func(req *http.Request) (*http.Response, error) {
+		var posts []MetricPost
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &posts))
+		postedCh <- posts
+		return &http.Response{StatusCode: http.StatusAccepted, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+	sender = newMetricsIngestSender(c, "license", "userAgent", reachable, false)
+	require.NoError(t, sender.Start())
+	defer sender.Stop()
+
+	// THEN the persisted batch is submitted
+	select {
+	case posts := <-postedCh:
+		require.Len(t, posts, 1)
+		require.Len(t, posts[0].Events, 1)
+		assert.Contains(t, string(posts[0].Events[0]), `"value":"5"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("persisted batch was not submitted")
+	}
+}
+
+func TestEventSender_PersistentQueueStoresQueuedBatchesOnStop(t *testing.T) {
+	cfg := &config.Config{
+		AgentDir:               t.TempDir(),
+		PersistentQueueEnabled: true,
+	}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil)
+	c.setAgentKey(agentKey)
+
+	// GIVEN a batch waiting in memory to be persisted
+	sender := newMetricsIngestSender(c, "license", "userAgent", nil, false)
+	require.NotNil(t, sender.diskQueue)
+	sender.batchQueue <- eventBatch{{entityKey: "entity", data: json.RawMessage(`{"value":"5"}`)}}
+
+	// WHEN the persistent queue is closed on stop
+	require.NoError(t, sender.closePersistentQueue())
+
+	// THEN the batch is persisted
+	queue, err := openPersistentQueue(cfg)
+	require.NoError(t, err)
+	defer queue.Close()
+	payload, err := queue.Peek()
+	require.NoError(t, err)
+	batch, err := unmarshalBatch(payload)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, entity.Key("entity"), batch[0].entityKey)
+}
+
+func TestOpenDimensionalMetricsQueue(t *testing.T) {
+	cfg := &config.Config{AgentDir: t.TempDir()}
+	ctx, cancel := goContext.WithCancel(goContext.Background())
+	defer cancel()
+
+	// GIVEN a persisted events batch
+	events, err := openPersistentQueue(cfg)
+	require.NoError(t, err)
+	defer events.Close()
+	require.NoError(t, events.Push([]byte("batch")))
+
+	// WHEN the dimensional metrics queue is opened
+	dm, err := OpenDimensionalMetricsQueue(ctx, cfg)
+	require.NoError(t, err)
+	defer dm.Close()
+
+	// THEN it is stored apart from the events queue
+	assert.Equal(t, 0, dm.Len())
+	assert.DirExists(t, filepath.Join(cfg.AgentDir, "data", dmPersistentQueueDirName))
+}
+
+func TestEventSender_PersistentQueueSkipsUnreadableBatches(t *testing.T) {
+	cfg := &config.Config{
+		AgentDir:                t.TempDir(),
+		PayloadCompressionLevel: gzip.NoCompression,
+		PersistentQueueEnabled:  true,
+	}
+	c := NewContext(cfg, "1.2.3", testhelpers.NullHostnameResolver, host.IDLookup{}, nil)
+	c.setAgentKey(agentKey)
+
+	postedCh := make(chan struct{}, 10)
+	reachable := func(req *http.Request) (*http.Response, error) {
+		postedCh <- struct{}{}
+		return &http.Response{StatusCode: http.StatusAccepted, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+	sender := newMetricsIngestSender(c, "license", "userAgent", reachable, false)
+	require.NotNil(t, sender.diskQueue)
+	backoffs := make(chan time.Duration, 10)
+	sender.getBackoffTimer = func(d time.Duration) *time.Timer {
+		backoffs <- d
+		return time.NewTimer(time.Millisecond)
+	}
+
+	// GIVEN a persisted batch which cannot be read
+	require.NoError(t, sender.diskQueue.Push([]byte("[]")))
+	segments, err := filepath.Glob(filepath.Join(cfg.AgentDir, "data", persistentQueueDirName, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	require.NoError(t, os.Remove(segments[0]))
+
+	// WHEN the sender is started
+	require.NoError(t, sender.Start())
+	defer sender.Stop()
+
+	// THEN it backs off between the failed reads
+	for i := 0; i < persistentQueueReadRetries; i++ {
+		select {
+		case <-backoffs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no backoff after a failed read")
+		}
+	}
+
+	// AND the following batches are submitted once the unreadable ones are skipped
+	require.NoError(t, sender.QueueEvent(ev, ""))
+	select {
+	case <-postedCh:
+	case <-time.After(2 * EVENT_BATCH_TIMER_DURATION * time.Second):
+		t.Fatal("batch was not submitted")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
 	backendhttp "github.com/newrelic/infrastructure-agent/pkg/backend/http"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 )
@@ -23,10 +24,12 @@ const (
 //   - backend endpoints reachability statuses
 //
 // - configuration
+// - integrations health and run history
 // fields will be empty when ReportErrors() report no errors.
 type Report struct {
-	Checks *ChecksReport `json:"checks,omitempty"`
-	Config *ConfigReport `json:"config,omitempty"`
+	Checks       *ChecksReport           `json:"checks,omitempty"`
+	Config       *ConfigReport           `json:"config,omitempty"`
+	Integrations []runner.InstanceStatus `json:"integrations,omitempty"`
 }
 
 type ChecksReport struct {
@@ -45,6 +48,11 @@ type EndpointReport struct {
 	Error     string `json:"error,omitempty"`
 }
 
+// IntegrationsReport health and run history of the running integration instances.
+type IntegrationsReport struct {
+	Integrations []runner.InstanceStatus `json:"integrations"`
+}
+
 // ReportEntity agent entity report.
 type ReportEntity struct {
 	GUID string `json:"guid"`
@@ -59,6 +67,8 @@ type Reporter interface {
 	ReportErrors() (Report, error)
 	// ReportEntity agent entity report.
 	ReportEntity() (ReportEntity, error)
+	// ReportIntegrations integrations health report.
+	ReportIntegrations() (IntegrationsReport, error)
 }
 
 type nrReporter struct {
@@ -69,6 +79,7 @@ type nrReporter struct {
 	userAgent              string
 	idProvide              id.Provide
 	agentEntityKeyProvider func() string
+	integrationsProvider   func() []runner.InstanceStatus
 	timeout                time.Duration
 	transport              http.RoundTripper
 }
@@ -144,9 +155,33 @@ func (r *nrReporter) report(onlyErrors bool) (report Report, err error) {
 
 	}
 
+	for _, is := range r.integrations() {
+		if !onlyErrors || is.Errored() {
+			report.Integrations = append(report.Integrations, is)
+		}
+	}
+
 	return
 }
 
+// ReportIntegrations reports the health and run history of the running integrations.
+func (r *nrReporter) ReportIntegrations() (IntegrationsReport, error) {
+	integrations := r.integrations()
+	if integrations == nil {
+		integrations = []runner.InstanceStatus{}
+	}
+
+	return IntegrationsReport{Integrations: integrations}, nil
+}
+
+func (r *nrReporter) integrations() []runner.InstanceStatus {
+	if r.integrationsProvider == nil {
+		return nil
+	}
+
+	return r.integrationsProvider()
+}
+
 func (r *nrReporter) ReportEntity() (re ReportEntity, err error) {
 	return ReportEntity{
 		GUID: r.idProvide().GUID.String(),
@@ -155,6 +190,7 @@ func (r *nrReporter) ReportEntity() (re ReportEntity, err error) {
 }
 
 // NewReporter creates a new status reporter.
+// integrationsProvider is optional (nil allowed), when missing no integrations are reported.
 func NewReporter(
 	ctx context.Context,
 	l log.Entry,
@@ -163,6 +199,7 @@ func NewReporter(
 	transport http.RoundTripper,
 	agentIDProvide id.Provide,
 	agentEntityKeyProvider func() string,
+	integrationsProvider func() []runner.InstanceStatus,
 	license,
 	userAgent string,
 ) Reporter {
@@ -175,6 +212,7 @@ func NewReporter(
 		userAgent:              userAgent,
 		idProvide:              agentIDProvide,
 		agentEntityKeyProvider: agentEntityKeyProvider,
+		integrationsProvider:   integrationsProvider,
 		timeout:                timeout,
 		transport:              transport,
 	}
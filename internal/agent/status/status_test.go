@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/stretchr/testify/assert"
@@ -78,7 +79,7 @@ func TestNewReporter_Report(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			l := log.WithComponent(tt.name)
-			r := NewReporter(context.Background(), l, tt.endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, "user-agent", "agent-key")
+			r := NewReporter(context.Background(), l, tt.endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, nil, "user-agent", "agent-key")
 
 			got, err := r.Report()
 
@@ -156,7 +157,7 @@ func TestNewReporter_ReportErrors(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			l := log.WithComponent(tt.name)
-			r := NewReporter(context.Background(), l, tt.endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, "user-agent", "agent-key")
+			r := NewReporter(context.Background(), l, tt.endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, nil, "user-agent", "agent-key")
 
 			got, err := r.ReportErrors()
 
@@ -216,7 +217,7 @@ func TestNewReporter_ReportEntity(t *testing.T) {
 			entityKeyProvider := func() string {
 				return tt.entityKey
 			}
-			r := NewReporter(context.Background(), l, []string{}, timeout, transport, idProvide, entityKeyProvider, "user-agent", "agent-key")
+			r := NewReporter(context.Background(), l, []string{}, timeout, transport, idProvide, entityKeyProvider, nil, "user-agent", "agent-key")
 
 			got, err := r.ReportEntity()
 
@@ -230,3 +231,50 @@ func TestNewReporter_ReportEntity(t *testing.T) {
 		})
 	}
 }
+
+func TestNewReporter_ReportIntegrations(t *testing.T) {
+	okCode, errCode := 0, 1
+	integrationsProvider := func() []runner.InstanceStatus {
+		return []runner.InstanceStatus{
+			{Name: "nri-ok", ExitCode: &okCode},
+			{Name: "nri-failing", ExitCode: &errCode},
+		}
+	}
+	emptyIDProvide := func() entity.Identity {
+		return entity.EmptyIdentity
+	}
+	emptyEntityKeyProvider := func() string {
+		return ""
+	}
+	l := log.WithComponent(t.Name())
+	r := NewReporter(context.Background(), l, []string{}, 10*time.Millisecond, &http.Transport{}, emptyIDProvide, emptyEntityKeyProvider, integrationsProvider, "user-agent", "agent-key")
+
+	got, err := r.ReportIntegrations()
+	require.NoError(t, err)
+	assert.Len(t, got.Integrations, 2)
+
+	full, err := r.Report()
+	require.NoError(t, err)
+	assert.Len(t, full.Integrations, 2)
+
+	onlyErrors, err := r.ReportErrors()
+	require.NoError(t, err)
+	require.Len(t, onlyErrors.Integrations, 1)
+	assert.Equal(t, "nri-failing", onlyErrors.Integrations[0].Name)
+}
+
+func TestNewReporter_ReportIntegrations_NoProvider(t *testing.T) {
+	emptyIDProvide := func() entity.Identity {
+		return entity.EmptyIdentity
+	}
+	emptyEntityKeyProvider := func() string {
+		return ""
+	}
+	l := log.WithComponent(t.Name())
+	r := NewReporter(context.Background(), l, []string{}, 10*time.Millisecond, &http.Transport{}, emptyIDProvide, emptyEntityKeyProvider, nil, "user-agent", "agent-key")
+
+	got, err := r.ReportIntegrations()
+	require.NoError(t, err)
+	assert.NotNil(t, got.Integrations)
+	assert.Empty(t, got.Integrations)
+}
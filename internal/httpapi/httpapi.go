@@ -4,11 +4,13 @@ package httpapi
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -19,6 +21,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/agent/status"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/otlp"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/sirupsen/logrus"
 )
@@ -29,10 +32,13 @@ const (
 	statusAPIPath              = "/v1/status"
 	statusOnlyErrorsAPIPath    = "/v1/status/errors"
 	statusEntityAPIPath        = "/v1/status/entity"
+	statusIntegrationsAPIPath  = "/v1/status/integrations"
 	statusAPIPathReady         = "/v1/status/ready"
 	ingestAPIPath              = "/v1/data"
 	ingestAPIPathReady         = "/v1/data/ready"
+	otlpMetricsAPIPath         = "/v1/otlp/metrics"
 	readinessProbeRetryBackoff = 100 * time.Millisecond
+	defaultOTLPMaxPayloadSize  = 10 * 1024 * 1024
 )
 
 const readinessProbeTimeout = time.Second * 5
@@ -58,9 +64,10 @@ type Server struct {
 
 // ComponentConfig stores configuration for a server component.
 type ComponentConfig struct {
-	enabled bool
-	address string
-	tls     tlsConfig
+	enabled            bool
+	address            string
+	tls                tlsConfig
+	otlpMaxPayloadSize int64
 }
 
 // tlsConfig stores tls-related configuration.
@@ -91,6 +98,11 @@ func (sc *ComponentConfig) VerifyTLSClient(caCertPath string) {
 	sc.tls.caPath = caCertPath
 }
 
+// OTLPMaxPayloadSize limits the size of the OTLP request bodies, both as received and once decompressed.
+func (sc *ComponentConfig) OTLPMaxPayloadSize(size int64) {
+	sc.otlpMaxPayloadSize = size
+}
+
 // NewServer creates a new API server.
 // Nice2Have: decouple services into path handlers.
 // Separate HTTP API configs should be deprecated if we want to unify under a single server & port.
@@ -172,6 +184,7 @@ func (s *Server) serveStatus(_ context.Context) error {
 		// read only API
 		router.GET(statusAPIPathReady, s.handleReady)
 		router.GET(statusEntityAPIPath, s.handleEntity)
+		router.GET(statusIntegrationsAPIPath, s.handleIntegrations)
 		router.GET(statusAPIPath, s.handle(false))
 		router.GET(statusOnlyErrorsAPIPath, s.handle(true))
 		// local only API
@@ -190,7 +203,7 @@ func (s *Server) serveStatus(_ context.Context) error {
 	return s.waitUntilReadyOrError(s.Status.address, statusAPIPathReady, s.Status.tls.enabled, s.Status.tls.validateClient, statusServerErr)
 }
 
-// serveIngest creates and starts an HTTP server handling ingestAPIPathReady and ingestAPIPath using Config.Ingest
+// serveIngest creates and starts an HTTP server handling ingestAPIPathReady, ingestAPIPath and otlpMetricsAPIPath using Config.Ingest
 func (s *Server) serveIngest(_ context.Context) error {
 	serverErr := make(chan error, 1)
 
@@ -203,6 +216,7 @@ func (s *Server) serveIngest(_ context.Context) error {
 		router := httprouter.New()
 		router.GET(ingestAPIPathReady, s.handleReady)
 		router.POST(ingestAPIPath, s.handleIngest)
+		router.POST(otlpMetricsAPIPath, s.handleOTLPMetrics)
 
 		server := &http.Server{
 			Handler: router,
@@ -377,6 +391,28 @@ func (s *Server) handleEntity(w http.ResponseWriter, r *http.Request, ps httprou
 	w.WriteHeader(http.StatusOK)
 }
 
+func (s *Server) handleIntegrations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ri, err := s.reporter.ReportIntegrations()
+	if err != nil {
+		s.logger.WithError(err).Error("cannot report integrations Status")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	b, jerr := json.Marshal(ri)
+	if jerr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.WithError(jerr).Warn("couldn't encode integrations report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	_, err = w.Write(b)
+	if err != nil {
+		s.logger.Warn("cannot write integrations response, error: " + err.Error())
+	}
+}
+
 func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	rawBody, err := ioutil.ReadAll(r.Body)
@@ -407,3 +443,80 @@ func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request, ps httprou
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleOTLPMetrics handles OTLP/HTTP metrics export requests, encoded either as protobuf or JSON.
+// Metrics are translated into the v4 protocol and emitted as any other integration payload.
+func (s *Server) handleOTLPMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	mediaType, err := otlp.MediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		s.logger.WithError(err).Warn("cannot handle OTLP payload")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	writeError := func(statusCode int, errMsg string, err error) {
+		s.logger.WithError(err).Warn(errMsg)
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(statusCode)
+		if _, werr := w.Write(otlp.ErrorResponse(mediaType, fmt.Sprintf("%s: %s", errMsg, err.Error()))); werr != nil {
+			s.logger.WithError(werr).Warn("cannot write HTTP response body")
+		}
+	}
+
+	maxSize := s.Ingest.otlpMaxPayloadSize
+	if maxSize <= 0 {
+		maxSize = defaultOTLPMaxPayloadSize
+	}
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxSize)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeError(payloadErrorStatus(err), "cannot decompress OTLP payload", err)
+			return
+		}
+		defer gz.Close()
+		// one byte over the limit tells the decompressed payload is too large
+		body = io.LimitReader(gz, maxSize+1)
+	}
+	rawBody, err := ioutil.ReadAll(body)
+	if err != nil {
+		writeError(payloadErrorStatus(err), "cannot read HTTP payload", err)
+		return
+	}
+	if int64(len(rawBody)) > maxSize {
+		writeError(http.StatusRequestEntityTooLarge, "cannot read HTTP payload", fmt.Errorf("decompressed payload exceeds %d bytes", maxSize))
+		return
+	}
+
+	data, err := otlp.ToV4(mediaType, rawBody)
+	if err != nil {
+		writeError(http.StatusBadRequest, "cannot parse OTLP payload", err)
+		return
+	}
+
+	if len(data.DataSets) > 0 {
+		payload, err := json.Marshal(data)
+		if err == nil {
+			err = s.emitter.Emit(s.definition, nil, nil, payload)
+		}
+		if err != nil {
+			writeError(http.StatusInternalServerError, "cannot emit OTLP payload", err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(otlp.SuccessResponse(mediaType)); err != nil {
+		s.logger.WithError(err).Warn("cannot write HTTP response body")
+	}
+}
+
+// payloadErrorStatus returns the response status code for a request body read error.
+func payloadErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -4,6 +4,7 @@ package httpapi
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
 	"github.com/newrelic/infrastructure-agent/internal/agent/status"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	networkHelpers "github.com/newrelic/infrastructure-agent/pkg/helpers/network"
@@ -63,7 +65,7 @@ func (suite *HTTPAPITestSuite) TestServe_Status() {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	r := status.NewReporter(ctx, logger, endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, "user-agent", "agent-key")
+	r := status.NewReporter(ctx, logger, endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, nil, "user-agent", "agent-key")
 
 	// When agent status API server is ready
 	em := &testemit.RecordEmitter{}
@@ -124,7 +126,7 @@ func (suite *HTTPAPITestSuite) TestServe_OnlyErrors() {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	r := status.NewReporter(ctx, logger, endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, "user-agent", "agent-key")
+	r := status.NewReporter(ctx, logger, endpoints, timeout, transport, emptyIDProvide, emptyEntityKeyProvider, nil, "user-agent", "agent-key")
 
 	// When agent status API server is ready
 	em := &testemit.RecordEmitter{}
@@ -158,6 +160,58 @@ func (suite *HTTPAPITestSuite) TestServe_OnlyErrors() {
 	assert.Equal(suite.T(), serverTimeout.URL, e.URL)
 }
 
+func (suite *HTTPAPITestSuite) TestServe_Integrations() {
+	port, err := networkHelpers.TCPPort()
+	require.NoError(suite.T(), err)
+
+	// Given a status reporter with a failing integration instance
+	exitCode := 3
+	integrationsProvider := func() []runner.InstanceStatus {
+		return []runner.InstanceStatus{{
+			Name:       "nri-mysql",
+			RunnerUID:  "abcdef0123",
+			ExitCode:   &exitCode,
+			LastStderr: []string{"connection refused"},
+		}}
+	}
+	logger := log.WithComponent(suite.T().Name())
+	emptyIDProvide := func() entity.Identity {
+		return entity.EmptyIdentity
+	}
+	emptyEntityKeyProvider := func() string {
+		return ""
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := status.NewReporter(ctx, logger, []string{}, 100*time.Millisecond, &http.Transport{}, emptyIDProvide, emptyEntityKeyProvider, integrationsProvider, "user-agent", "agent-key")
+
+	// When agent status API server is ready
+	s, err := NewServer(r, &testemit.RecordEmitter{})
+	require.NoError(suite.T(), err)
+	s.Status.Enable("localhost", port)
+
+	go s.Serve(ctx)
+
+	s.waitUntilReady()
+
+	// And a request to the integrations status API is sent
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, statusIntegrationsAPIPath))
+	require.NoError(suite.T(), err)
+	defer res.Body.Close()
+
+	// Then response contains the integration run history
+	require.Equal(suite.T(), http.StatusOK, res.StatusCode)
+
+	var gotReport status.IntegrationsReport
+	require.NoError(suite.T(), json.NewDecoder(res.Body).Decode(&gotReport))
+	require.Len(suite.T(), gotReport.Integrations, 1)
+	i := gotReport.Integrations[0]
+	assert.Equal(suite.T(), "nri-mysql", i.Name)
+	require.NotNil(suite.T(), i.ExitCode)
+	assert.Equal(suite.T(), 3, *i.ExitCode)
+	assert.Equal(suite.T(), []string{"connection refused"}, i.LastStderr)
+}
+
 func (suite *HTTPAPITestSuite) TestServe_Entity() {
 	logger := log.WithComponent(suite.T().Name())
 	timeout := 100 * time.Millisecond
@@ -191,7 +245,7 @@ func (suite *HTTPAPITestSuite) TestServe_Entity() {
 			port, err := networkHelpers.TCPPort()
 			require.NoError(t, err)
 
-			r := status.NewReporter(ctx, logger, []string{}, timeout, transport, tt.idProvide, emptyEntityKeyProvider, "user-agent", "agent-key")
+			r := status.NewReporter(ctx, logger, []string{}, timeout, transport, tt.idProvide, emptyEntityKeyProvider, nil, "user-agent", "agent-key")
 			// When agent status API server is ready
 			em := &testemit.RecordEmitter{}
 			s, err := NewServer(r, em)
@@ -261,6 +315,109 @@ func (suite *HTTPAPITestSuite) TestServe_IngestData() {
 }
 
 // nolint:funlen,cyclop
+func (suite *HTTPAPITestSuite) TestServe_IngestOTLPMetrics() {
+	port, err := networkHelpers.TCPPort()
+	require.NoError(suite.T(), err)
+
+	em := &testemit.RecordEmitter{}
+	s, err := NewServer(&noopReporter{}, em)
+	require.NoError(suite.T(), err)
+	s.Ingest.Enable("localhost", port)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.waitUntilReady()
+
+	// Given a gzipped OTLP/JSON payload with a single gauge
+	payload := `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+		{"name": "foo", "gauge": {"dataPoints": [{"asDouble": 1.5}]}}
+	]}]}]}`
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, err = gz.Write([]byte(payload))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), gz.Close())
+
+	// When it's submitted to the OTLP endpoint
+	url := fmt.Sprintf("http://localhost:%d%s", port, otlpMetricsAPIPath)
+	postReq, err := http.NewRequest(http.MethodPost, url, &gzipped)
+	require.NoError(suite.T(), err)
+	postReq.Header.Set("Content-Type", "application/json")
+	postReq.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(postReq)
+	require.NoError(suite.T(), err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(suite.T(), err)
+	_ = resp.Body.Close()
+
+	// Then an empty export response is returned
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), "{}", string(body))
+
+	// And the metric is emitted for the agent entity
+	d, err := em.ReceiveFrom(IntegrationName)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), d.DataSet.PluginDataSet.Entity.Name)
+	assert.Len(suite.T(), d.DataSet.PluginDataSet.Metrics, 1)
+
+	// And unsupported or malformed payloads are rejected
+	resp, err = http.Post(url, "text/plain", bytes.NewReader([]byte(payload)))
+	require.NoError(suite.T(), err)
+	_ = resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp, err = http.Post(url, "application/x-protobuf", bytes.NewReader([]byte{0xff}))
+	require.NoError(suite.T(), err)
+	_ = resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *HTTPAPITestSuite) TestServe_IngestOTLPMetrics_PayloadTooLarge() {
+	port, err := networkHelpers.TCPPort()
+	require.NoError(suite.T(), err)
+
+	em := &testemit.RecordEmitter{}
+	s, err := NewServer(&noopReporter{}, em)
+	require.NoError(suite.T(), err)
+	s.Ingest.Enable("localhost", port)
+	s.Ingest.OTLPMaxPayloadSize(1024)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.waitUntilReady()
+	url := fmt.Sprintf("http://localhost:%d%s", port, otlpMetricsAPIPath)
+
+	// Given a payload which is small once compressed, but exceeds the limit when decompressed
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, err = gz.Write(bytes.Repeat([]byte(" "), 256*1024))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), gz.Close())
+	require.Less(suite.T(), gzipped.Len(), 1024)
+
+	// When it's submitted to the OTLP endpoint
+	postReq, err := http.NewRequest(http.MethodPost, url, &gzipped)
+	require.NoError(suite.T(), err)
+	postReq.Header.Set("Content-Type", "application/json")
+	postReq.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(postReq)
+	require.NoError(suite.T(), err)
+	_ = resp.Body.Close()
+
+	// Then it's rejected
+	assert.Equal(suite.T(), http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// And so are the uncompressed payloads exceeding the limit
+	resp, err = http.Post(url, "application/json", bytes.NewReader(bytes.Repeat([]byte(" "), 2048)))
+	require.NoError(suite.T(), err)
+	_ = resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
 func (suite *HTTPAPITestSuite) TestServe_IngestData_mTLS() {
 	cases := []struct {
 		name           string
@@ -463,3 +620,7 @@ func (r *noopReporter) ReportErrors() (status.Report, error) {
 func (r *noopReporter) ReportEntity() (re status.ReportEntity, err error) {
 	return status.ReportEntity{}, nil
 }
+
+func (r *noopReporter) ReportIntegrations() (status.IntegrationsReport, error) {
+	return status.IntegrationsReport{}, nil
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
@@ -82,16 +85,20 @@ func newDefinitionWithoutLookup(ce config2.ConfigEntry, passthroughEnv []string,
 		Name:           ce.InstanceName,
 		Interval:       interval,
 		LogsQueueSize:  ce.LogsQueueSize,
-		WhenConditions: conditions(ce.When),
 		ConfigTemplate: configTemplate,
 		newTempFile:    newTempFile,
 	}
 
+	var err error
+	d.WhenConditions, err = conditions(ce.When)
+	if err != nil {
+		return Definition{}, fmt.Errorf("invalid 'when' section: %w", err)
+	}
+
 	if ce.InventorySource == "" {
 		// Set to empty as currently Inventory source unknown
 		d.InventorySource = ids.EmptyInventorySource
 	} else {
-		var err error
 		d.InventorySource, err = ids.FromString(ce.InventorySource)
 		if err != nil {
 			return Definition{}, errors.New("Error parsing 'inventory_source' YAML property: " + err.Error())
@@ -250,7 +257,7 @@ func getInterval(duration string) time.Duration {
 }
 
 // get condition functions from the YAML 'when:' section
-func conditions(enabling config2.EnableConditions) []when.Condition {
+func conditions(enabling config2.EnableConditions) ([]when.Condition, error) {
 	var conds []when.Condition
 
 	// We do not consider here FeatureFlag as it is managed at the integrations manager
@@ -261,7 +268,86 @@ func conditions(enabling config2.EnableConditions) []when.Condition {
 	if len(enabling.EnvExists) > 0 {
 		conds = append(conds, when.EnvExists(enabling.EnvExists))
 	}
-	return conds
+
+	if enabling.ProcessRunning != "" {
+		if expr, ok := regexExpression(enabling.ProcessRunning); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'process_running' regular expression: %w", err)
+			}
+			conds = append(conds, when.ProcessMatches(re))
+		} else {
+			conds = append(conds, when.ProcessRunning(enabling.ProcessRunning))
+		}
+	}
+
+	if enabling.PortListening != "" {
+		address := enabling.PortListening
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			// only the port is provided
+			address = net.JoinHostPort("localhost", address)
+		}
+		conds = append(conds, when.PortListening(address))
+	}
+
+	if len(enabling.CommandSucceeds) > 0 {
+		conds = append(conds, when.CommandSucceeds(enabling.CommandSucceeds))
+	}
+
+	if enabling.FileContains != nil {
+		if enabling.FileContains.Path == "" {
+			return nil, errors.New("'file_contains' requires a non-empty 'path' field")
+		}
+		re, err := regexp.Compile(enabling.FileContains.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'file_contains' regular expression: %w", err)
+		}
+		conds = append(conds, when.FileContains(enabling.FileContains.Path, re))
+	}
+
+	if len(enabling.Any) > 0 {
+		var anyConds []when.Condition
+		for _, nested := range enabling.Any {
+			nestedConds, err := conditions(nested)
+			if err != nil {
+				return nil, err
+			}
+			anyConds = append(anyConds, allOf(nestedConds))
+		}
+		conds = append(conds, func() bool {
+			return when.Any(anyConds...)
+		})
+	}
+
+	if enabling.Not != nil {
+		nestedConds, err := conditions(*enabling.Not)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, when.Not(allOf(nestedConds)))
+	}
+
+	return conds, nil
+}
+
+// allOf groups a conditions set into a single condition.
+func allOf(conds []when.Condition) when.Condition {
+	return func() bool {
+		return when.All(conds...)
+	}
+}
+
+// regexExpression returns the expression from a `regex "<expression>"` formatted value.
+func regexExpression(value string) (string, bool) {
+	const prefix = "regex "
+	if !strings.HasPrefix(value, prefix) {
+		return "", false
+	}
+	expr := strings.TrimSpace(strings.TrimPrefix(value, prefix))
+	if len(expr) < 2 || !strings.HasPrefix(expr, `"`) || !strings.HasSuffix(expr, `"`) {
+		return "", false
+	}
+	return expr[1 : len(expr)-1], true
 }
 
 // ErrLookup is a test helper that returns errors.
@@ -6,6 +6,8 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
 	"runtime"
 	"testing"
 
@@ -13,6 +15,7 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/fixtures"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/stretchr/testify/assert"
@@ -248,3 +251,79 @@ func TestDefinition_fromName(t *testing.T) {
 	assert.Equal(t, "/path/to/nri-foo", d.runnable.Command)
 	assert.Equal(t, []string{"arg1", "arg2"}, d.runnable.Args)
 }
+
+func TestWhenConditions(t *testing.T) {
+	// GIVEN a listening port and a file with some content
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	file, err := ioutil.TempFile("", "when-conditions")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("enabled: true\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	testCases := []struct {
+		name     string
+		when     string
+		expected bool
+	}{
+		{"port listening", "port_listening: " + port, true},
+		{"port listening with host", "port_listening: " + listener.Addr().String(), true},
+		{"file contains", "file_contains:\n    path: " + file.Name() + "\n    regex: 'enabled: true'", true},
+		{"file does not contain", "file_contains:\n    path: " + file.Name() + "\n    regex: 'enabled: false'", false},
+		{"not", "not:\n    file_exists: some-unexisting-file", true},
+		{"not all", "not:\n    file_exists: " + file.Name() + "\n    port_listening: " + port, false},
+		{"any", "any:\n    - file_exists: some-unexisting-file\n    - port_listening: " + port, true},
+		{"any none", "any:\n    - file_exists: some-unexisting-file\n    - env_exists:\n        SOME_UNEXISTING_ENV: value", false},
+		{"nested", "any:\n    - not:\n        file_exists: " + file.Name() + "\n    - file_exists: some-unexisting-file", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// WHEN an integration is loaded with the given conditions
+			var config config2.ConfigEntry
+			require.NoError(t, yaml.Unmarshal([]byte("name: foo\nexec: bar\nwhen:\n  "+tc.when), &config))
+
+			i, err := NewDefinition(config, ErrLookup, nil, nil)
+			require.NoError(t, err)
+
+			// THEN the conditions are evaluated as expected
+			assert.Equal(t, tc.expected, when.All(i.WhenConditions...))
+		})
+	}
+}
+
+func TestWhenConditions_Invalid(t *testing.T) {
+	testCases := map[string]string{
+		"process regex":       `process_running: regex "[a-"`,
+		"file contains regex": "file_contains:\n    path: /etc/hosts\n    regex: '[a-'",
+		"file contains path":  "file_contains:\n    regex: 'foo'",
+		"nested any":          "any:\n    - process_running: regex \"[a-\"",
+		"nested not":          "not:\n    process_running: regex \"[a-\"",
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var config config2.ConfigEntry
+			require.NoError(t, yaml.Unmarshal([]byte("name: foo\nexec: bar\nwhen:\n  "+tc), &config))
+
+			_, err := NewDefinition(config, ErrLookup, nil, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRegexExpression(t *testing.T) {
+	expr, ok := regexExpression(`regex "^mysql.*"`)
+	assert.True(t, ok)
+	assert.Equal(t, "^mysql.*", expr)
+
+	_, ok = regexExpression("mysqld")
+	assert.False(t, ok)
+
+	_, ok = regexExpression("regex mysqld")
+	assert.False(t, ok)
+}
@@ -32,12 +32,14 @@ type Group struct {
 	configHandle         configrequest.HandleFn
 	terminateDefinitionQ chan string
 	idLookup             host.IDLookup
+	statuses             *StatusRegistry
 }
 
 type runnerErrorHandler func(ctx context.Context, errs <-chan error)
 
 // NewGroup configures a Group instance that is provided by the passed LoadFn
 // cfgPath is used for caching to be consumed by cmd-channel FF enabler.
+// statuses is optional (nil allowed), when provided runners will report their run status to it.
 func NewGroup(
 	loadFn LoadFn,
 	il integration.InstancesLookup,
@@ -48,6 +50,7 @@ func NewGroup(
 	cfgPath string,
 	terminateDefinitionQ chan string,
 	idLookup host.IDLookup,
+	statuses *StatusRegistry,
 ) (g Group, c FeaturesCache, err error) {
 
 	g, c, err = loadFn(il, passthroughEnv, cfgPath, cmdReqHandle, configHandle, terminateDefinitionQ)
@@ -57,6 +60,7 @@ func NewGroup(
 
 	g.emitter = emitter
 	g.idLookup = idLookup
+	g.statuses = statuses
 
 	return
 }
@@ -65,7 +69,7 @@ func NewGroup(
 // provided context
 func (g *Group) Run(ctx context.Context) (hasStartedAnyOHI bool) {
 	for _, integr := range g.integrations {
-		go NewRunner(integr, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.configHandle, g.terminateDefinitionQ, g.idLookup).Run(ctx, nil, nil)
+		go NewRunner(integr, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.configHandle, g.terminateDefinitionQ, g.idLookup, g.statuses).Run(ctx, nil, nil)
 		hasStartedAnyOHI = true
 	}
 
@@ -80,7 +84,7 @@ func (g *Group) RunOnce(ctx context.Context) {
 		integrationDef.Interval = 0
 		wg.Add(1)
 		go func(definition integration.Definition) {
-			r := NewRunner(definition, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.configHandle, g.terminateDefinitionQ, g.idLookup)
+			r := NewRunner(definition, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.configHandle, g.terminateDefinitionQ, g.idLookup, g.statuses)
 			r.Run(ctx, nil, nil)
 			wg.Done()
 		}(integrationDef)
@@ -64,10 +64,12 @@ type runner struct {
 	cache          cache.Cache
 	terminateQueue chan<- string
 	idLookup       host.IDLookup
+	statuses       *StatusRegistry
+	status         *runStatus
 }
 
 // NewRunner creates an integration runner instance.
-// args: discoverySources, handleErrorsProvide and cmdReqHandle are optional (nils allowed).
+// args: discoverySources, handleErrorsProvide, cmdReqHandle and statuses are optional (nils allowed).
 func NewRunner(
 	intDef integration.Definition,
 	emitter emitter.Emitter,
@@ -77,6 +79,7 @@ func NewRunner(
 	configHandle configrequest.HandleFn,
 	terminateQ chan<- string,
 	idLookup host.IDLookup,
+	statuses *StatusRegistry,
 ) *runner {
 	r := &runner{
 		emitter:        emitter,
@@ -90,7 +93,9 @@ func NewRunner(
 		terminateQueue: terminateQ,
 		cache:          cache.CreateCache(),
 		idLookup:       idLookup,
+		statuses:       statuses,
 	}
+	r.status = newRunStatus(intDef, &r.lastStderr)
 	if handleErrorsProvide != nil {
 		r.handleErrors = handleErrorsProvide()
 	} else {
@@ -103,8 +108,14 @@ func NewRunner(
 func (r *runner) Run(ctx context.Context, pidWCh, exitCodeCh chan<- int) {
 	r.log = illog.WithFields(LogFields(r.definition))
 	defer r.killChildren()
+	r.statuses.register(r.status)
+	defer r.statuses.deregister(r.status)
 	for {
 		waitForNextExecution := time.After(r.definition.Interval)
+		var nextRun time.Time
+		if !r.definition.SingleRun() {
+			nextRun = time.Now().Add(r.definition.Interval)
+		}
 
 		// only cmd-channel run-requests require exit-code, and they only trigger a single instance
 		//var exitCodeCh chan int
@@ -119,6 +130,7 @@ func (r *runner) Run(ctx context.Context, pidWCh, exitCodeCh chan<- int) {
 				Error("can't fetch discovery items")
 		} else {
 			if when.All(r.definition.WhenConditions...) {
+				r.status.started(time.Now(), nextRun)
 				r.execute(ctx, discovery, info, pidWCh, exitCodeCh)
 			} else {
 				r.log.Debug("Integration conditions where not met, skipping execution")
@@ -227,6 +239,9 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, discover
 	defer txn.End()
 	def := r.definition
 
+	// keeps the parent context to tell timeouts apart from cancellations
+	parentCtx := ctx
+
 	// If timeout configuration is set, wraps current context in a heartbeat-enabled timeout context
 	if def.TimeoutEnabled() {
 		var act contexts.Actuator
@@ -250,6 +265,7 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, discover
 	if err != nil {
 		txn.NoticeError(err)
 		r.log.WithError(err).Error("can't start integration")
+		r.status.notStarted(time.Now(), err)
 		return
 	}
 
@@ -271,7 +287,7 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, discover
 
 		go func(txn instrumentation.Transaction) {
 			defer wg.Done()
-			r.handleErrors(ctx, o.Receive.Errors)
+			r.handleErrors(ctx, r.recordErrors(ctx, o.Receive.Errors))
 
 		}(txn)
 	}
@@ -289,9 +305,29 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, discover
 		r.log.Debug("Integration instances finished their execution. Waiting until next interval.")
 	}
 
+	timedOut := def.TimeoutEnabled() && ctx.Err() != nil && parentCtx.Err() == nil
+	r.status.finished(time.Now(), timedOut)
+
 	return
 }
 
+// recordErrors forwards the execution errors to the returned channel, recording them in the
+// runner status. Errors are discarded if the context is cancelled before they are consumed.
+func (r *runner) recordErrors(ctx context.Context, errs <-chan error) <-chan error {
+	fwd := make(chan error)
+	go func() {
+		defer close(fwd)
+		for err := range errs {
+			r.status.instanceError(err)
+			select {
+			case fwd <- err:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return fwd
+}
+
 func (r *runner) handleStderr(stderr <-chan []byte) {
 	for line := range stderr {
 		r.lastStderr.Add(line)
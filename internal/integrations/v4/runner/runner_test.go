@@ -54,7 +54,7 @@ func Test_runner_Run(t *testing.T) {
 	require.NoError(t, err)
 
 	e := &testemit.RecordEmitter{}
-	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, configrequest.NoopHandleFn, nil, host.IDLookup{})
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, configrequest.NoopHandleFn, nil, host.IDLookup{}, nil)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
@@ -88,7 +88,7 @@ func Test_runner_Run_noHandleForCfgProtocol(t *testing.T) {
 	require.NoError(t, err)
 
 	e := &testemit.RecordEmitter{}
-	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, nil, nil, host.IDLookup{})
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, nil, nil, host.IDLookup{}, nil)
 
 	// WHEN the runner executes the binary and handle the payload.
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
@@ -131,7 +131,7 @@ func Test_runner_Run_failToUnMarshallCfgProtocol(t *testing.T) {
 	require.NoError(t, err)
 
 	e := &testemit.RecordEmitter{}
-	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, configrequest.NoopHandleFn, nil, host.IDLookup{})
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, configrequest.NoopHandleFn, nil, host.IDLookup{}, nil)
 
 	// WHEN the runner executes the binary and handle the payload.
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
@@ -170,7 +170,7 @@ func Test_runner_Run_handlesCfgProtocol(t *testing.T) {
 		atomic.AddUint32(&called, 1)
 	}
 	e := &testemit.RecordEmitter{}
-	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, mockHandleFn, nil, host.IDLookup{})
+	r := NewRunner(def, e, nil, nil, cmdrequest.NoopHandleFn, mockHandleFn, nil, host.IDLookup{}, nil)
 
 	// WHEN the runner executes the binary and handle the payload.
 	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
@@ -0,0 +1,208 @@
+// Copyright 2023 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/gobackfill"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+)
+
+// InstanceStatus is a snapshot of the health and run history of an integration instance.
+type InstanceStatus struct {
+	Name         string            `json:"name"`
+	RunnerUID    string            `json:"runner_uid"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Interval     string            `json:"interval,omitempty"`
+	Running      bool              `json:"running"`
+	LastStart    *time.Time        `json:"last_start,omitempty"`
+	LastEnd      *time.Time        `json:"last_end,omitempty"`
+	ExitCode     *int              `json:"exit_code,omitempty"`
+	LastError    string            `json:"last_error,omitempty"`
+	TimedOut     bool              `json:"timed_out"`
+	TimeoutCount int               `json:"timeout_count"`
+	LastStderr   []string          `json:"last_stderr,omitempty"`
+	NextRun      *time.Time        `json:"next_run,omitempty"`
+}
+
+// Errored returns true if the last execution of the instance did not finish successfully.
+func (is *InstanceStatus) Errored() bool {
+	return is.TimedOut || is.LastError != "" || (is.ExitCode != nil && *is.ExitCode != 0)
+}
+
+// StatusRegistry keeps track of the run status of the integration runners, so it can be
+// reported through the status API.
+type StatusRegistry struct {
+	lock     sync.RWMutex
+	statuses map[*runStatus]struct{}
+}
+
+// NewStatusRegistry creates an empty StatusRegistry.
+func NewStatusRegistry() *StatusRegistry {
+	return &StatusRegistry{
+		statuses: make(map[*runStatus]struct{}),
+	}
+}
+
+// List returns a snapshot of the run status of all the registered integration instances, sorted by name.
+func (s *StatusRegistry) List() []InstanceStatus {
+	s.lock.RLock()
+	list := make([]InstanceStatus, 0, len(s.statuses))
+	for rs := range s.statuses {
+		list = append(list, rs.snapshot())
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name == list[j].Name {
+			return list[i].RunnerUID < list[j].RunnerUID
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+func (s *StatusRegistry) register(rs *runStatus) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.statuses[rs] = struct{}{}
+}
+
+func (s *StatusRegistry) deregister(rs *runStatus) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.statuses, rs)
+}
+
+// runStatus holds the run history of a single runner.
+type runStatus struct {
+	lock         sync.Mutex
+	definition   integration.Definition
+	stderr       *stderrQueue
+	running      bool
+	lastStart    time.Time
+	lastEnd      time.Time
+	exitCode     *int
+	cycleCode    int
+	lastError    string
+	cycleError   string
+	timedOut     bool
+	timeoutCount int
+	nextRun      time.Time
+}
+
+func newRunStatus(def integration.Definition, stderr *stderrQueue) *runStatus {
+	return &runStatus{
+		definition: def,
+		stderr:     stderr,
+	}
+}
+
+// started records the beginning of an execution cycle. A zero nextRun means there
+// won't be further executions.
+func (rs *runStatus) started(now, nextRun time.Time) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	rs.running = true
+	rs.lastStart = now
+	rs.nextRun = nextRun
+	rs.cycleCode = 0
+	rs.cycleError = ""
+}
+
+// instanceError records an error received from any of the integration instances of the
+// current cycle. The first non-zero exit code is kept.
+func (rs *runStatus) instanceError(err error) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if rs.cycleCode == 0 {
+			rs.cycleCode = gobackfill.ExitCode(exitErr)
+		}
+		return
+	}
+	rs.cycleError = err.Error()
+}
+
+// finished records the end of an execution cycle. A timed out cycle is recorded as an
+// error, since the exit code of the killed process may not have been received yet.
+func (rs *runStatus) finished(now time.Time, timedOut bool) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	rs.running = false
+	rs.lastEnd = now
+	code := rs.cycleCode
+	rs.exitCode = &code
+	rs.lastError = rs.cycleError
+	rs.timedOut = timedOut
+	if timedOut {
+		rs.timeoutCount++
+		rs.lastError = fmt.Sprintf("timed out after %s", rs.definition.Timeout)
+	}
+}
+
+// notStarted records an execution cycle where the integration could not be started.
+func (rs *runStatus) notStarted(now time.Time, err error) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	rs.running = false
+	rs.lastEnd = now
+	rs.exitCode = nil
+	rs.lastError = err.Error()
+	rs.timedOut = false
+}
+
+func (rs *runStatus) snapshot() InstanceStatus {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	is := InstanceStatus{
+		Name:         rs.definition.Name,
+		RunnerUID:    rs.definition.Hash()[:10],
+		Labels:       rs.definition.Labels,
+		Running:      rs.running,
+		LastError:    rs.lastError,
+		TimedOut:     rs.timedOut,
+		TimeoutCount: rs.timeoutCount,
+		LastStderr:   rs.stderr.Lines(),
+	}
+	if rs.definition.Interval > 0 {
+		is.Interval = rs.definition.Interval.String()
+	}
+	if rs.exitCode != nil {
+		code := *rs.exitCode
+		is.ExitCode = &code
+	}
+	is.LastStart = timeOrNil(rs.lastStart)
+	is.LastEnd = timeOrNil(rs.lastEnd)
+	is.NextRun = timeOrNil(rs.nextRun)
+
+	return is
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/fixtures"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
+	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusRegistry_RecordsRunHistory(t *testing.T) {
+	// GIVEN an integration that exits with error
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "failing",
+		Exec:         testhelp.Command(fixtures.ErrorCmd),
+		Interval:     "1h",
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	statuses := NewStatusRegistry()
+	r := NewRunner(def, &testemit.RecordEmitter{}, nil, nil, cmdrequest.NoopHandleFn, nil, nil, host.IDLookup{}, statuses)
+
+	// WHEN it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx, nil, nil)
+		close(done)
+	}()
+
+	// THEN its run history is reported
+	var st InstanceStatus
+	require.Eventually(t, func() bool {
+		list := statuses.List()
+		if len(list) != 1 || list[0].LastEnd == nil {
+			return false
+		}
+		st = list[0]
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "failing", st.Name)
+	assert.Equal(t, def.Hash()[:10], st.RunnerUID)
+	assert.False(t, st.Running)
+	require.NotNil(t, st.LastStart)
+	require.NotNil(t, st.ExitCode)
+	assert.Equal(t, 3, *st.ExitCode)
+	assert.True(t, st.Errored())
+	assert.False(t, st.TimedOut)
+	assert.Equal(t, 0, st.TimeoutCount)
+	assert.Equal(t, []string{"very bad error"}, st.LastStderr)
+	require.NotNil(t, st.NextRun)
+	assert.True(t, st.NextRun.After(*st.LastStart))
+
+	// AND the instance is no longer reported once the runner stops
+	cancel()
+	<-done
+	assert.Empty(t, statuses.List())
+}
+
+func TestStatusRegistry_CountsTimeouts(t *testing.T) {
+	// GIVEN an integration that runs longer than its timeout
+	to := 100 * time.Millisecond
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "blocked",
+		Exec:         testhelp.Command(fixtures.BlockedCmd),
+		Interval:     "1h",
+		Timeout:      &to,
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	statuses := NewStatusRegistry()
+	r := NewRunner(def, &testemit.RecordEmitter{}, nil, nil, cmdrequest.NoopHandleFn, nil, nil, host.IDLookup{}, statuses)
+
+	// WHEN it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, nil, nil)
+
+	// THEN the timeout is counted and the execution is reported as errored
+	var st InstanceStatus
+	require.Eventually(t, func() bool {
+		list := statuses.List()
+		if len(list) != 1 || list[0].TimeoutCount != 1 {
+			return false
+		}
+		st = list[0]
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.True(t, st.TimedOut)
+	assert.Equal(t, "timed out after 100ms", st.LastError)
+	assert.True(t, st.Errored())
+}
@@ -15,10 +15,13 @@ const (
 )
 
 type stderrQueue struct {
-	mutex    sync.Mutex
-	nextLine int
-	size     int
-	queue    [][]byte
+	mutex sync.Mutex
+	// written counts all the lines ever added, so the ring can be read after being flushed
+	written int
+	// pending counts the lines added since the last flush
+	pending int
+	size    int
+	queue   [][]byte
 }
 
 func newStderrQueue(size int) stderrQueue {
@@ -43,26 +46,24 @@ func (sq *stderrQueue) Add(line []byte) {
 		return
 	}
 
-	sq.queue[sq.nextLine%sq.size] = line
-	sq.nextLine++
+	sq.queue[sq.written%sq.size] = line
+	sq.written++
+	sq.pending++
 }
 
 func (sq *stderrQueue) Flush() string {
 	sq.mutex.Lock()
 	defer sq.mutex.Unlock()
-	if sq.nextLine == 0 || sq.size == 0 {
+	if sq.pending == 0 || sq.size == 0 {
 		return "(no standard error output)"
 	}
-	var start, lines int
+	lines := sq.pending
 	joint := bytes.Buffer{}
-	if sq.nextLine < sq.size {
-		start = 0
-		lines = sq.nextLine
-	} else {
-		joint.WriteString(fmt.Sprintf("(last %d lines out of %d): ", sq.size, sq.nextLine))
-		start = sq.nextLine % sq.size
+	if sq.pending > sq.size {
+		joint.WriteString(fmt.Sprintf("(last %d lines out of %d): ", sq.size, sq.pending))
 		lines = sq.size
 	}
+	start := (sq.written - lines) % sq.size
 	for i := 0; i < lines; i++ {
 		if i > 0 {
 			joint.WriteByte('\n')
@@ -70,6 +71,28 @@ func (sq *stderrQueue) Flush() string {
 		joint.Write(sq.queue[start])
 		start = (start + 1) % sq.size
 	}
-	sq.nextLine = 0
+	sq.pending = 0
 	return joint.String()
 }
+
+// Lines returns the latest stored lines, oldest first. Unlike Flush, it does not
+// reset the queue, so it can be invoked for reporting purposes at any time.
+func (sq *stderrQueue) Lines() []string {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+
+	lines := sq.written
+	if lines > sq.size {
+		lines = sq.size
+	}
+	if lines == 0 {
+		return nil
+	}
+	result := make([]string, 0, lines)
+	start := (sq.written - lines) % sq.size
+	for i := 0; i < lines; i++ {
+		result = append(result, string(sq.queue[start]))
+		start = (start + 1) % sq.size
+	}
+	return result
+}
@@ -62,3 +62,18 @@ func TestQueueSizeConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestQueueLines_NotResetByFlush(t *testing.T) {
+	queue := newStderrQueue(3)
+	assert.Empty(t, queue.Lines())
+
+	for i := 1; i <= 5; i++ {
+		queue.Add([]byte(fmt.Sprintf("log_line:%d", i)))
+	}
+	assert.Equal(t, `(last 3 lines out of 5): log_line:3\nlog_line:4\nlog_line:5`, strings.ReplaceAll(queue.Flush(), "\n", "\\n"))
+	assert.Equal(t, []string{"log_line:3", "log_line:4", "log_line:5"}, queue.Lines())
+
+	queue.Add([]byte("log_line:6"))
+	assert.Equal(t, "log_line:6", queue.Flush())
+	assert.Equal(t, []string{"log_line:4", "log_line:5", "log_line:6"}, queue.Lines())
+}
@@ -2,11 +2,48 @@
 // SPDX-License-Identifier: Apache-2.0
 package when
 
-import "os"
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	portDialTimeout = time.Second
+	commandTimeout  = 10 * time.Second
+)
 
 // Condition is any function that can return true or false
 type Condition func() bool
 
+// processInfo holds the process attributes a condition can match against.
+type processInfo struct {
+	name    string
+	cmdLine string
+}
+
+// listProcesses returns the running processes. Replaceable for testing purposes.
+var listProcesses = func() ([]processInfo, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]processInfo, 0, len(procs))
+	for _, p := range procs {
+		// processes may finish while being listed, so errors are ignored
+		name, _ := p.Name()
+		cmdLine, _ := p.Cmdline()
+		infos = append(infos, processInfo{name: name, cmdLine: cmdLine})
+	}
+	return infos, nil
+}
+
 // FileExists creates a Condition returning true when the passed file path exists
 func FileExists(path string) Condition {
 	return func() bool {
@@ -32,6 +69,84 @@ func EnvExists(envVars map[string]string) Condition {
 	}
 }
 
+// ProcessRunning creates a Condition returning true when there is a running process whose
+// name is equal to the passed one.
+func ProcessRunning(name string) Condition {
+	return func() bool {
+		return anyProcess(func(p processInfo) bool {
+			return p.name == name
+		})
+	}
+}
+
+// ProcessMatches creates a Condition returning true when there is a running process whose
+// name or command line matches the passed regular expression.
+func ProcessMatches(re *regexp.Regexp) Condition {
+	return func() bool {
+		return anyProcess(func(p processInfo) bool {
+			return re.MatchString(p.name) || re.MatchString(p.cmdLine)
+		})
+	}
+}
+
+func anyProcess(match func(processInfo) bool) bool {
+	procs, err := listProcesses()
+	if err != nil {
+		return false
+	}
+	for _, p := range procs {
+		if match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// PortListening creates a Condition returning true when a TCP connection can be established
+// to the passed "host:port" address.
+func PortListening(address string) Condition {
+	return func() bool {
+		conn, err := net.DialTimeout("tcp", address, portDialTimeout)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}
+}
+
+// CommandSucceeds creates a Condition returning true when the passed command, being the first
+// element the command path/name and the next, the command arguments, exits with 0 status.
+func CommandSucceeds(cmd []string) Condition {
+	return func() bool {
+		if len(cmd) == 0 {
+			return false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+		return exec.CommandContext(ctx, cmd[0], cmd[1:]...).Run() == nil
+	}
+}
+
+// FileContains creates a Condition returning true when the content of the passed file
+// matches the regular expression.
+func FileContains(path string, re *regexp.Regexp) Condition {
+	return func() bool {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		return re.Match(content)
+	}
+}
+
+// Not creates a Condition returning the negation of the passed one.
+func Not(cond Condition) Condition {
+	return func() bool {
+		return !cond()
+	}
+}
+
 // All returns true if and only if all the passed conditions are true.
 // If an empty conditions list is passed, it also returns true.
 func All(conditions ...Condition) bool {
@@ -42,3 +157,14 @@ func All(conditions ...Condition) bool {
 	}
 	return true
 }
+
+// Any returns true if at least one of the passed conditions is true.
+// If an empty conditions list is passed, it returns false.
+func Any(conditions ...Condition) bool {
+	for _, cond := range conditions {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
@@ -3,8 +3,13 @@
 package when
 
 import (
+	"errors"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -102,3 +107,90 @@ func TestAll(t *testing.T) {
 		})
 	}
 }
+
+func TestAny(t *testing.T) {
+	trueFunc := func() bool { return true }
+	falseFunc := func() bool { return false }
+
+	assert.False(t, Any())
+	assert.False(t, Any(falseFunc, falseFunc))
+	assert.True(t, Any(falseFunc, trueFunc))
+}
+
+func TestNot(t *testing.T) {
+	assert.False(t, Not(func() bool { return true })())
+	assert.True(t, Not(func() bool { return false })())
+}
+
+func TestProcessRunning(t *testing.T) {
+	defer func(original func() ([]processInfo, error)) { listProcesses = original }(listProcesses)
+	listProcesses = func() ([]processInfo, error) {
+		return []processInfo{
+			{name: "sshd", cmdLine: "/usr/sbin/sshd -D"},
+			{name: "mysqld", cmdLine: "/usr/sbin/mysqld --basedir=/usr"},
+		}, nil
+	}
+
+	assert.True(t, ProcessRunning("mysqld")())
+	assert.False(t, ProcessRunning("mysql")())
+	assert.True(t, ProcessMatches(regexp.MustCompile(`^mysql`))())
+	assert.True(t, ProcessMatches(regexp.MustCompile(`--basedir=/usr$`))())
+	assert.False(t, ProcessMatches(regexp.MustCompile(`postgres`))())
+}
+
+func TestProcessRunning_ListError(t *testing.T) {
+	defer func(original func() ([]processInfo, error)) { listProcesses = original }(listProcesses)
+	listProcesses = func() ([]processInfo, error) {
+		return nil, errors.New("cannot list")
+	}
+
+	assert.False(t, ProcessRunning("mysqld")())
+}
+
+func TestProcessRunning_CurrentProcess(t *testing.T) {
+	// GIVEN the test binary process
+	self, err := os.Executable()
+	require.NoError(t, err)
+
+	// THEN it is matched by its command line
+	assert.True(t, ProcessMatches(regexp.MustCompile(regexp.QuoteMeta(filepath.Base(self))))())
+}
+
+func TestPortListening(t *testing.T) {
+	// GIVEN a listening port
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	// THEN the PortListening condition returns true
+	assert.True(t, PortListening(address)())
+
+	// AND returns false once it is closed
+	require.NoError(t, listener.Close())
+	assert.False(t, PortListening(address)())
+}
+
+func TestCommandSucceeds(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands not available on windows")
+	}
+
+	assert.True(t, CommandSucceeds([]string{"true"})())
+	assert.False(t, CommandSucceeds([]string{"false"})())
+	assert.False(t, CommandSucceeds([]string{"some-unexisting-command"})())
+	assert.False(t, CommandSucceeds(nil)())
+}
+
+func TestFileContains(t *testing.T) {
+	// GIVEN an existing file with some content
+	f, err := ioutil.TempFile("", "conditions")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("bind-address = 127.0.0.1\nport = 3306\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.True(t, FileContains(f.Name(), regexp.MustCompile(`(?m)^port = 3306$`))())
+	assert.False(t, FileContains(f.Name(), regexp.MustCompile(`port = 5432`))())
+	assert.False(t, FileContains("some-unexisting-file", regexp.MustCompile(`.*`))())
+}
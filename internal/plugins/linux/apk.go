@@ -0,0 +1,124 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+const (
+	ApkInstalledDB = "/lib/apk/db/installed"
+)
+
+var apklog = log.WithPlugin("Apk")
+
+type ApkPlugin struct {
+	agent.PluginCommon
+	installedDB string
+	frequency   time.Duration
+}
+
+type ApkItem struct {
+	Name         string `json:"id"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+	Origin       string `json:"origin,omitempty"`
+	License      string `json:"license,omitempty"`
+	BuildTime    string `json:"build_epoch,omitempty"`
+}
+
+func (self ApkItem) SortKey() string {
+	return self.Name
+}
+
+func NewApkPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	cfg := ctx.Config()
+	return &ApkPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		installedDB:  ApkInstalledDB,
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.ApkRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_PACKAGE_MGRS_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+	}
+}
+
+// fetchPackageInfo parses the apk installed database, where each package is a block of "<field>:<value>" lines
+// separated by an empty line.
+func (self *ApkPlugin) fetchPackageInfo() (packages agent.PluginInventoryDataset, err error) {
+	file, err := os.Open(self.installedDB)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var item ApkItem
+	flush := func() {
+		if item.Name != "" {
+			packages = append(packages, item)
+		}
+		item = ApkItem{}
+	}
+	scanner := bufio.NewScanner(file)
+	// file lists can make some lines long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		value := line[2:]
+		switch line[0] {
+		case 'P':
+			item.Name = value
+		case 'V':
+			item.Version = value
+		case 'A':
+			item.Architecture = value
+		case 'o':
+			item.Origin = value
+		case 'L':
+			item.License = value
+		case 't':
+			item.BuildTime = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return packages, scanner.Err()
+}
+
+func (self *ApkPlugin) Run() {
+	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
+		apklog.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(self.frequency)
+	for {
+		data, err := self.fetchPackageInfo()
+		if err != nil {
+			apklog.WithError(err).Error("fetching apk data")
+		} else {
+			self.EmitInventory(data, entity.NewFromNameWithoutID(self.Context.EntityKey()))
+		}
+		<-refreshTimer.C
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testing2 "github.com/newrelic/infrastructure-agent/internal/plugins/testing"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+func TestApkFetchPackageInfo(t *testing.T) {
+	p := NewApkPlugin(*ids.NewPluginID("packages", "apk"), testing2.NewMockAgent()).(*ApkPlugin)
+	p.installedDB = "testdata/packages/apk_installed"
+
+	packages, err := p.fetchPackageInfo()
+
+	require.NoError(t, err)
+	assert.Equal(t, []ApkItem{
+		{Name: "musl", Version: "1.2.3-r0", Architecture: "x86_64", Origin: "musl", License: "MIT", BuildTime: "1649396308"},
+		{Name: "busybox", Version: "1.35.0-r17", Architecture: "x86_64", Origin: "busybox", License: "GPL-2.0-only", BuildTime: "1660070218"},
+	}, []ApkItem{packages[0].(ApkItem), packages[1].(ApkItem)})
+	assert.Len(t, packages, 2)
+}
+
+func TestApkFetchPackageInfo_MissingDB(t *testing.T) {
+	p := NewApkPlugin(*ids.NewPluginID("packages", "apk"), testing2.NewMockAgent()).(*ApkPlugin)
+	p.installedDB = "testdata/packages/non_existing"
+
+	_, err := p.fetchPackageInfo()
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,393 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux || darwin
+// +build linux darwin
+
+package linux
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const (
+	maxCertificateFileSize = 10 * 1024 * 1024
+	pemCertificateType     = "CERTIFICATE"
+	jksMagic               = 0xFEEDFEED
+	jceksMagic             = 0xCECECECE
+	jksPrivateKeyTag       = 1
+	jksTrustedCertTag      = 2
+)
+
+var certlog = log.WithPlugin("Certificates")
+
+// trustStoreBundles are the file names of the CA bundles installed by the distributions, which are skipped,
+// as the links to them, as /etc/ssl/cert.pem.
+var trustStoreBundles = map[string]bool{
+	"ca-bundle.crt":       true,
+	"ca-bundle.trust.crt": true,
+	"ca-certificates.crt": true,
+	"tls-ca-bundle.pem":   true,
+}
+
+// trustStoreBundlePaths are the CA bundles whose file names are too generic to be skipped anywhere else.
+var trustStoreBundlePaths = map[string]bool{
+	"/etc/ssl/cert.pem": true,
+}
+
+// CertificatesPlugin inventories the certificates found in the configured paths, also reporting a
+// CertificateSample for each of them with the days left until it expires.
+type CertificatesPlugin struct {
+	agent.PluginCommon
+	paths     []string
+	frequency time.Duration
+}
+
+// Certificate is the inventory item of a certificate, identified by its file path. The index in the file, or
+// the alias for keystores, is appended to the path for files holding several certificates.
+type Certificate struct {
+	ID           string `json:"id"`
+	Path         string `json:"path"`
+	Alias        string `json:"alias,omitempty"`
+	Subject      string `json:"subject"`
+	Issuer       string `json:"issuer"`
+	SANs         string `json:"sans,omitempty"`
+	SerialNumber string `json:"serial_number"`
+	KeyAlgorithm string `json:"key_algorithm"`
+	KeySize      int    `json:"key_size,omitempty"`
+	NotBefore    string `json:"not_before"`
+	NotAfter     string `json:"not_after"`
+}
+
+func (self Certificate) SortKey() string {
+	return self.ID
+}
+
+// CertificateSample reports the expiry of a certificate.
+type CertificateSample struct {
+	sample.BaseEvent
+
+	Path         string `json:"path"`
+	Alias        string `json:"alias,omitempty"`
+	Subject      string `json:"subject"`
+	Issuer       string `json:"issuer"`
+	SerialNumber string `json:"serialNumber"`
+	// Unix time in seconds
+	NotAfter int64 `json:"notAfter"`
+	// Negative once the certificate has expired
+	DaysUntilExpiry int64 `json:"daysUntilExpiry"`
+}
+
+// certificateEntry is a certificate read from a file, with its alias when read from a keystore.
+type certificateEntry struct {
+	alias string
+	cert  *x509.Certificate
+}
+
+func NewCertificatesPlugin(id ids.PluginID, ctx agent.AgentContext) *CertificatesPlugin {
+	cfg := ctx.Config()
+	return &CertificatesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		paths:        cfg.CertificatesPaths,
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.CertificatesRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_CERTIFICATES_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+	}
+}
+
+// files returns the regular files matching the configured paths, without duplicates.
+func (self *CertificatesPlugin) files() []string {
+	found := map[string]bool{}
+	var files []string
+	for _, pattern := range self.paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			certlog.WithError(err).WithField("pattern", pattern).Warn("Ignoring invalid certificates path.")
+			continue
+		}
+		for _, path := range matches {
+			if found[path] || isTrustStoreBundle(path) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() || info.Size() > maxCertificateFileSize {
+				continue
+			}
+			found[path] = true
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// isTrustStoreBundle returns whether the file is a CA bundle of the distribution, or a link to it.
+func isTrustStoreBundle(path string) bool {
+	if trustStoreBundles[filepath.Base(path)] || trustStoreBundlePaths[filepath.Clean(path)] {
+		return true
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	return err == nil && (trustStoreBundles[filepath.Base(resolved)] || trustStoreBundlePaths[resolved])
+}
+
+// certificates reads the certificates from the configured paths, returning their inventory and samples.
+// Files without certificates, as private keys, are skipped.
+func (self *CertificatesPlugin) certificates(now time.Time) (agent.PluginInventoryDataset, []*CertificateSample) {
+	var dataset agent.PluginInventoryDataset
+	var samples []*CertificateSample
+	for _, path := range self.files() {
+		entries, err := readCertificates(path)
+		if err != nil {
+			certlog.WithError(err).WithField("path", path).Debug("Skipping file.")
+			continue
+		}
+		for i, entry := range entries {
+			id := path
+			if entry.alias != "" {
+				id = path + ":" + entry.alias
+			} else if len(entries) > 1 {
+				id = path + ":" + strconv.Itoa(i)
+			}
+			item := newCertificate(id, path, entry)
+			dataset = append(dataset, item)
+			samples = append(samples, &CertificateSample{
+				Path:            path,
+				Alias:           entry.alias,
+				Subject:         item.Subject,
+				Issuer:          item.Issuer,
+				SerialNumber:    item.SerialNumber,
+				NotAfter:        entry.cert.NotAfter.Unix(),
+				DaysUntilExpiry: int64(math.Floor(entry.cert.NotAfter.Sub(now).Hours() / 24)),
+			})
+		}
+	}
+	return dataset, samples
+}
+
+func newCertificate(id, path string, entry certificateEntry) Certificate {
+	cert := entry.cert
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	item := Certificate{
+		ID:           id,
+		Path:         path,
+		Alias:        entry.alias,
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SANs:         strings.Join(sans, ","),
+		SerialNumber: strings.ToUpper(hex.EncodeToString(cert.SerialNumber.Bytes())),
+		KeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		item.KeySize = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		item.KeySize = key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		item.KeySize = len(key) * 8
+	}
+	return item
+}
+
+// readCertificates reads the certificates of a PEM, DER or Java KeyStore file. The PEM blocks which can't be
+// parsed are skipped.
+func readCertificates(path string) ([]certificateEntry, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) >= 4 {
+		if magic := binary.BigEndian.Uint32(content); magic == jksMagic || magic == jceksMagic {
+			return parseKeyStore(content)
+		}
+	}
+
+	var certs []*x509.Certificate
+	if bytes.Contains(content, []byte("-----BEGIN")) {
+		for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != pemCertificateType {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				certlog.WithError(err).WithField("path", path).Debug("Skipping invalid certificate.")
+				continue
+			}
+			certs = append(certs, cert)
+		}
+	} else if certs, err = x509.ParseCertificates(content); err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+
+	entries := make([]certificateEntry, 0, len(certs))
+	for _, cert := range certs {
+		entries = append(entries, certificateEntry{cert: cert})
+	}
+	return entries, nil
+}
+
+// parseKeyStore reads the certificates of a JKS or JCEKS keystore, which are stored unencrypted, so no password
+// is needed. Only the first certificate in the chain of each private key is returned. Secret key entries of
+// JCEKS keystores can't be skipped, so the entries after them are not read.
+func parseKeyStore(content []byte) ([]certificateEntry, error) {
+	r := keyStoreReader{buf: bytes.NewReader(content)}
+	r.uint32() // magic
+	version := r.uint32()
+	count := r.uint32()
+
+	var entries []certificateEntry
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		tag := r.uint32()
+		alias := r.utf()
+		r.uint64() // creation date
+		switch tag {
+		case jksPrivateKeyTag:
+			r.bytes(int(r.uint32())) // encrypted private key
+			chain := r.uint32()
+			for j := uint32(0); j < chain && r.err == nil; j++ {
+				cert := r.certificate(version)
+				if j == 0 && cert != nil {
+					entries = append(entries, certificateEntry{alias: alias, cert: cert})
+				}
+			}
+		case jksTrustedCertTag:
+			if cert := r.certificate(version); cert != nil {
+				entries = append(entries, certificateEntry{alias: alias, cert: cert})
+			}
+		default:
+			if len(entries) > 0 {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("unsupported keystore entry type %d", tag)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid keystore: %w", r.err)
+	}
+	return entries, nil
+}
+
+// keyStoreReader reads the big endian fields of a keystore, keeping the first error.
+type keyStoreReader struct {
+	buf *bytes.Reader
+	err error
+}
+
+func (r *keyStoreReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > r.buf.Len() {
+		r.err = errors.New("unexpected end of keystore")
+		return nil
+	}
+	b := make([]byte, n)
+	_, r.err = io.ReadFull(r.buf, b)
+	return b
+}
+
+func (r *keyStoreReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *keyStoreReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *keyStoreReader) utf() string {
+	if b := r.bytes(2); b != nil {
+		return string(r.bytes(int(binary.BigEndian.Uint16(b))))
+	}
+	return ""
+}
+
+// certificate reads a certificate, prefixed by its type since version 2 keystores. Certificates which can't be
+// parsed, or not X.509, are skipped.
+func (r *keyStoreReader) certificate(version uint32) *x509.Certificate {
+	certType := "X.509"
+	if version == 2 {
+		certType = r.utf()
+	}
+	der := r.bytes(int(r.uint32()))
+	if r.err != nil || certType != "X.509" {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		certlog.WithError(err).Debug("Skipping keystore certificate.")
+		return nil
+	}
+	return cert
+}
+
+func (self *CertificatesPlugin) Run() {
+	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
+		certlog.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(1)
+	for {
+		select {
+		case <-refreshTimer.C:
+			{
+				refreshTimer.Stop()
+				refreshTimer = time.NewTicker(self.frequency)
+				now := time.Now()
+				dataset, samples := self.certificates(now)
+				self.EmitInventory(dataset, entity.NewFromNameWithoutID(self.Context.EntityKey()))
+				for _, s := range samples {
+					s.Type("CertificateSample")
+					s.Timestamp(now.Unix())
+					self.Context.SendEvent(s, entity.EmptyKey)
+				}
+			}
+		}
+	}
+}
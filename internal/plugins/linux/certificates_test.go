@@ -0,0 +1,186 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var certificatesNow = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestCertificate(t *testing.T, cn string, serial int64, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn, "www." + cn},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return der
+}
+
+// newTestKeyStore builds a version 2 JKS keystore with a private key entry and a trusted certificate entry.
+func newTestKeyStore(keyCert, trustedCert []byte) []byte {
+	var b bytes.Buffer
+	write := func(v interface{}) { _ = binary.Write(&b, binary.BigEndian, v) }
+	utf := func(s string) {
+		write(uint16(len(s)))
+		b.WriteString(s)
+	}
+	write(uint32(jksMagic))
+	write(uint32(2))
+	write(uint32(2))
+
+	write(uint32(jksPrivateKeyTag))
+	utf("server")
+	write(uint64(0))
+	write(uint32(3))
+	b.Write([]byte{1, 2, 3})
+	write(uint32(1))
+	utf("X.509")
+	write(uint32(len(keyCert)))
+	b.Write(keyCert)
+
+	write(uint32(jksTrustedCertTag))
+	utf("rootca")
+	write(uint64(0))
+	utf("X.509")
+	write(uint32(len(trustedCert)))
+	b.Write(trustedCert)
+	return b.Bytes()
+}
+
+func TestCertificatesPlugin_Certificates(t *testing.T) {
+	// GIVEN PEM, DER and JKS certificate files, a private key and a trust store bundle
+	dir := t.TempDir()
+	expiring := newTestCertificate(t, "example.com", 0x1f2e, certificatesNow.Add(36*time.Hour))
+	expired := newTestCertificate(t, "expired.com", 2, certificatesNow.Add(-12*time.Hour))
+	var chain bytes.Buffer
+	require.NoError(t, pem.Encode(&chain, &pem.Block{Type: "CERTIFICATE", Bytes: expiring}))
+	require.NoError(t, pem.Encode(&chain, &pem.Block{Type: "CERTIFICATE", Bytes: expired}))
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{1, 2, 3}})
+	files := map[string][]byte{
+		"chain.pem":           chain.Bytes(),
+		"server.crt":          expiring,
+		"server.key":          key,
+		"keystore.jks":        newTestKeyStore(expiring, expired),
+		"ca-certificates.crt": chain.Bytes(),
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), content, 0600))
+	}
+	p := &CertificatesPlugin{
+		paths: []string{filepath.Join(dir, "*.pem"), filepath.Join(dir, "*"), "[invalid"},
+	}
+
+	// WHEN the certificates are read
+	dataset, samples := p.certificates(certificatesNow)
+
+	// THEN every certificate is reported once, with the file index or keystore alias in its id
+	require.Len(t, dataset, 5)
+	require.Len(t, samples, 5)
+	var ids []string
+	for _, item := range dataset {
+		ids = append(ids, item.SortKey())
+	}
+	assert.Equal(t, []string{
+		filepath.Join(dir, "chain.pem") + ":0",
+		filepath.Join(dir, "chain.pem") + ":1",
+		filepath.Join(dir, "keystore.jks") + ":server",
+		filepath.Join(dir, "keystore.jks") + ":rootca",
+		filepath.Join(dir, "server.crt"),
+	}, ids)
+
+	assert.Equal(t, Certificate{
+		ID:           filepath.Join(dir, "server.crt"),
+		Path:         filepath.Join(dir, "server.crt"),
+		Subject:      "CN=example.com",
+		Issuer:       "CN=example.com",
+		SANs:         "example.com,www.example.com,10.0.0.1",
+		SerialNumber: "1F2E",
+		KeyAlgorithm: "ECDSA",
+		KeySize:      256,
+		NotBefore:    "2021-06-03T00:00:00Z",
+		NotAfter:     "2022-06-03T00:00:00Z",
+	}, dataset[4])
+
+	assert.Equal(t, "server", samples[2].Alias)
+	assert.Equal(t, int64(1), samples[2].DaysUntilExpiry)
+	assert.Equal(t, certificatesNow.Add(36*time.Hour).Unix(), samples[2].NotAfter)
+	assert.Equal(t, "CN=expired.com", samples[3].Subject)
+	assert.Equal(t, int64(-1), samples[3].DaysUntilExpiry)
+}
+
+func TestReadCertificates_Errors(t *testing.T) {
+	dir := t.TempDir()
+	truncated := newTestKeyStore([]byte{1}, []byte{2})[:40]
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "truncated.jks"), truncated, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "empty.pem"), []byte("-----BEGIN\n"), 0600))
+
+	_, err := readCertificates(filepath.Join(dir, "truncated.jks"))
+	assert.Error(t, err)
+	_, err = readCertificates(filepath.Join(dir, "empty.pem"))
+	assert.Error(t, err)
+	_, err = readCertificates(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestReadCertificates_SkipsInvalidBlocks(t *testing.T) {
+	// GIVEN a PEM file with an invalid certificate between valid ones
+	var content bytes.Buffer
+	require.NoError(t, pem.Encode(&content, &pem.Block{Type: "CERTIFICATE", Bytes: newTestCertificate(t, "a.com", 1, certificatesNow)}))
+	require.NoError(t, pem.Encode(&content, &pem.Block{Type: "CERTIFICATE", Bytes: []byte{1, 2, 3}}))
+	require.NoError(t, pem.Encode(&content, &pem.Block{Type: "CERTIFICATE", Bytes: newTestCertificate(t, "b.com", 2, certificatesNow)}))
+	path := filepath.Join(t.TempDir(), "chain.pem")
+	require.NoError(t, ioutil.WriteFile(path, content.Bytes(), 0600))
+
+	// WHEN it's read
+	entries, err := readCertificates(path)
+
+	// THEN only the invalid certificate is skipped
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "a.com", entries[0].cert.Subject.CommonName)
+	assert.Equal(t, "b.com", entries[1].cert.Subject.CommonName)
+}
+
+func TestIsTrustStoreBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca-certificates.crt")
+	require.NoError(t, ioutil.WriteFile(bundle, []byte("bundle"), 0600))
+	link := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.Symlink(bundle, link))
+	other := filepath.Join(dir, "server.pem")
+	require.NoError(t, ioutil.WriteFile(other, []byte("cert"), 0600))
+
+	assert.True(t, isTrustStoreBundle(bundle))
+	assert.True(t, isTrustStoreBundle(link))
+	assert.True(t, isTrustStoreBundle("/etc/ssl/cert.pem"))
+	assert.False(t, isTrustStoreBundle(other))
+}
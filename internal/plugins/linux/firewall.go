@@ -0,0 +1,296 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+const (
+	firewallBackendNftables = "nftables"
+	firewallBackendIptables = "iptables"
+
+	// length of the rule hash in the rule ids, in hex characters
+	firewallRuleHashLen = 12
+)
+
+var fwlog = log.WithPlugin("Firewall")
+
+type FirewallPlugin struct {
+	agent.PluginCommon
+	frequency  time.Duration
+	runCommand func(command string, stdin string, arguments ...string) (string, error)
+}
+
+// FirewallChain is a chain of the ruleset, identified as "<family>/<table>/<chain>". Type, hook and priority are
+// only reported for nftables base chains. The amount of rules isn't reported, as the rules are items of their own.
+type FirewallChain struct {
+	ID       string `json:"id"`
+	Backend  string `json:"backend"`
+	Family   string `json:"family"`
+	Table    string `json:"table"`
+	Chain    string `json:"chain"`
+	Type     string `json:"type,omitempty"`
+	Hook     string `json:"hook,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	Policy   string `json:"policy,omitempty"`
+}
+
+func (self FirewallChain) SortKey() string {
+	return self.ID
+}
+
+// FirewallRule is a rule of a chain, identified by the chain id and a hash of the rule. Identical rules of a
+// chain are suffixed by their occurrence, as "-2". The position of the rule isn't reported, so inserting or
+// removing a rule is reported as a single change instead of changing all the following ones. The rule is
+// reported as listed by the backend, without counters.
+type FirewallRule struct {
+	ID     string `json:"id"`
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Chain  string `json:"chain"`
+	Rule   string `json:"rule"`
+}
+
+func (self FirewallRule) SortKey() string {
+	return self.ID
+}
+
+func NewFirewallPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	cfg := ctx.Config()
+	return &FirewallPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.FirewallRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_FIREWALL_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+		runCommand: helpers.RunCommand,
+	}
+}
+
+// rulesetBuilder accumulates the chains and their rules in the order they are listed.
+type rulesetBuilder struct {
+	backend string
+	chains  []*FirewallChain
+	rules   []FirewallRule
+	// occurrences of every rule id, to tell identical rules of a chain apart
+	ruleIDs map[string]int
+}
+
+func (b *rulesetBuilder) chain(family, table, chain string) *FirewallChain {
+	id := fmt.Sprintf("%s/%s/%s", family, table, chain)
+	for _, c := range b.chains {
+		if c.ID == id {
+			return c
+		}
+	}
+	c := &FirewallChain{ID: id, Backend: b.backend, Family: family, Table: table, Chain: chain}
+	b.chains = append(b.chains, c)
+	return c
+}
+
+func (b *rulesetBuilder) addRule(c *FirewallChain, rule string) {
+	hash := sha256.Sum256([]byte(rule))
+	id := c.ID + "/" + hex.EncodeToString(hash[:])[:firewallRuleHashLen]
+	if b.ruleIDs == nil {
+		b.ruleIDs = make(map[string]int)
+	}
+	b.ruleIDs[id]++
+	if n := b.ruleIDs[id]; n > 1 {
+		id = fmt.Sprintf("%s-%d", id, n)
+	}
+	b.rules = append(b.rules, FirewallRule{
+		ID:     id,
+		Family: c.Family,
+		Table:  c.Table,
+		Chain:  c.Chain,
+		Rule:   rule,
+	})
+}
+
+func (b *rulesetBuilder) dataset() (dataset agent.PluginInventoryDataset) {
+	for _, c := range b.chains {
+		dataset = append(dataset, *c)
+	}
+	for _, r := range b.rules {
+		dataset = append(dataset, r)
+	}
+	return dataset
+}
+
+// braceDepth returns the change of nesting level of a line, ignoring the braces in quoted strings, as comments.
+func braceDepth(line string) (depth int) {
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '{' && !quoted:
+			depth++
+		case r == '}' && !quoted:
+			depth--
+		}
+	}
+	return depth
+}
+
+// parseNftRuleset parses the output of "nft --stateless list ruleset". Only tables and their chains are
+// reported, while sets, maps and other objects are skipped, as the rules referencing them already are.
+func parseNftRuleset(output string) (agent.PluginInventoryDataset, error) {
+	b := rulesetBuilder{backend: firewallBackendNftables}
+	var family, table string
+	var chain *FirewallChain
+	// nesting level of the current line, and of the table object being skipped, if any
+	depth, skipping := 0, 0
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		delta := braceDepth(line)
+		fields := strings.Fields(line)
+
+		switch {
+		case line == "":
+		case skipping > 0:
+			if depth+delta < skipping {
+				skipping = 0
+			}
+		case depth == 0 && len(fields) == 4 && fields[0] == "table" && fields[3] == "{":
+			family, table = fields[1], fields[2]
+		case depth == 0 && len(fields) == 3 && fields[0] == "table" && fields[2] == "{":
+			// the ip family is omitted by older versions
+			family, table = "ip", fields[1]
+		case depth == 1 && len(fields) == 3 && fields[0] == "chain" && fields[2] == "{":
+			chain = b.chain(family, table, fields[1])
+		case depth == 1 && delta > 0:
+			// other table objects, as sets or maps
+			skipping = depth + 1
+		case depth == 2 && chain != nil && line == "}":
+			chain = nil
+		case depth == 2 && chain != nil && fields[0] == "type" && strings.Contains(line, " hook "):
+			parseNftBaseChain(chain, line)
+		case depth == 2 && chain != nil:
+			b.addRule(chain, line)
+		case depth == 1 && line == "}":
+			family, table = "", ""
+		}
+		depth += delta
+		if depth < 0 {
+			return nil, fmt.Errorf("unbalanced braces in nftables ruleset")
+		}
+	}
+	return b.dataset(), scanner.Err()
+}
+
+// parseNftBaseChain parses the base chain declaration, as
+// "type filter hook input priority filter; policy drop;".
+func parseNftBaseChain(chain *FirewallChain, line string) {
+	for _, statement := range strings.Split(line, ";") {
+		fields := strings.Fields(statement)
+		for i := 0; i+1 < len(fields); i += 2 {
+			switch fields[i] {
+			case "type":
+				chain.Type = fields[i+1]
+			case "hook":
+				chain.Hook = fields[i+1]
+			case "priority":
+				chain.Priority = strings.Join(fields[i+1:], " ")
+				i = len(fields)
+			case "policy":
+				chain.Policy = fields[i+1]
+			}
+		}
+	}
+}
+
+// parseIptablesSave parses the output of iptables-save or ip6tables-save for the given family, as:
+//
+//	*filter
+//	:INPUT DROP [120:7200]
+//	-A INPUT -i lo -j ACCEPT
+//	COMMIT
+func parseIptablesSave(b *rulesetBuilder, family, output string) {
+	var table string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+		case strings.HasPrefix(line, ":"):
+			// policy of the chain followed by its counters, "-" for user defined chains
+			fields := strings.Fields(line[1:])
+			chain := b.chain(family, table, fields[0])
+			if len(fields) > 1 && fields[1] != "-" {
+				chain.Policy = strings.ToLower(fields[1])
+			}
+		case strings.HasPrefix(line, "-A "):
+			fields := strings.SplitN(line[3:], " ", 2)
+			rule := ""
+			if len(fields) == 2 {
+				rule = fields[1]
+			}
+			b.addRule(b.chain(family, table, fields[0]), rule)
+		}
+	}
+}
+
+// getDataset returns the nftables ruleset, or the iptables one when nft is not available or the nftables
+// ruleset is empty, as with the legacy iptables.
+func (self *FirewallPlugin) getDataset() (agent.PluginInventoryDataset, error) {
+	output, err := self.runCommand("nft", "", "--stateless", "list", "ruleset")
+	if err == nil && strings.TrimSpace(output) != "" {
+		return parseNftRuleset(output)
+	}
+	if err != nil {
+		fwlog.WithError(err).Debug("Cannot list nftables ruleset, falling back to iptables.")
+	}
+
+	b := rulesetBuilder{backend: firewallBackendIptables}
+	output, err = self.runCommand("iptables-save", "")
+	if err != nil {
+		return nil, err
+	}
+	parseIptablesSave(&b, "ip", output)
+	if output, err = self.runCommand("ip6tables-save", ""); err != nil {
+		fwlog.WithError(err).Debug("Cannot list ip6tables ruleset.")
+	} else {
+		parseIptablesSave(&b, "ip6", output)
+	}
+	return b.dataset(), nil
+}
+
+func (self *FirewallPlugin) Run() {
+	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
+		fwlog.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(self.frequency)
+	for {
+		dataset, err := self.getDataset()
+		if err != nil {
+			fwlog.WithError(err).Error("fetching firewall ruleset")
+		} else {
+			self.EmitInventory(dataset, entity.NewFromNameWithoutID(self.Context.EntityKey()))
+		}
+		<-refreshTimer.C
+	}
+}
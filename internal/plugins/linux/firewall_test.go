@@ -0,0 +1,184 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	testing2 "github.com/newrelic/infrastructure-agent/internal/plugins/testing"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+// recordedCommands returns a command runner replying with the recorded outputs, and failing for the other commands.
+func recordedCommands(t *testing.T, outputs map[string]string) func(string, string, ...string) (string, error) {
+	return func(command string, _ string, _ ...string) (string, error) {
+		file, ok := outputs[command]
+		if !ok {
+			return "", errors.New(command + ": command not found")
+		}
+		content, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		return string(content), nil
+	}
+}
+
+func splitFirewallDataset(dataset agent.PluginInventoryDataset) (chains []FirewallChain, rules []FirewallRule) {
+	for _, item := range dataset {
+		switch i := item.(type) {
+		case FirewallChain:
+			chains = append(chains, i)
+		case FirewallRule:
+			rules = append(rules, i)
+		}
+	}
+	return chains, rules
+}
+
+func TestFirewallNftables(t *testing.T) {
+	// GIVEN a host with an nftables ruleset
+	p := NewFirewallPlugin(*ids.NewPluginID("config", "firewall"), testing2.NewMockAgent()).(*FirewallPlugin)
+	p.runCommand = recordedCommands(t, map[string]string{
+		"nft":           "testdata/firewall/nft_ruleset.txt",
+		"iptables-save": "testdata/firewall/iptables_save.txt",
+	})
+
+	// WHEN the ruleset is fetched
+	dataset, err := p.getDataset()
+	require.NoError(t, err)
+
+	// THEN the chains of the tables are reported, skipping the sets
+	chains, rules := splitFirewallDataset(dataset)
+	assert.Equal(t, []FirewallChain{
+		{ID: "inet/filter/input", Backend: "nftables", Family: "inet", Table: "filter", Chain: "input", Type: "filter", Hook: "input", Priority: "filter", Policy: "drop"},
+		{ID: "inet/filter/forward", Backend: "nftables", Family: "inet", Table: "filter", Chain: "forward", Type: "filter", Hook: "forward", Priority: "filter", Policy: "drop"},
+		{ID: "inet/filter/output", Backend: "nftables", Family: "inet", Table: "filter", Chain: "output", Type: "filter", Hook: "output", Priority: "filter", Policy: "accept"},
+		{ID: "ip/nat/POSTROUTING", Backend: "nftables", Family: "ip", Table: "nat", Chain: "POSTROUTING", Type: "nat", Hook: "postrouting", Priority: "srcnat", Policy: "accept"},
+		{ID: "ip/nat/DOCKER", Backend: "nftables", Family: "ip", Table: "nat", Chain: "DOCKER"},
+	}, chains)
+
+	// AND their rules, including the ones with braces
+	assert.Equal(t, []FirewallRule{
+		{ID: "inet/filter/input/d715fd9ae664", Family: "inet", Table: "filter", Chain: "input", Rule: "ct state established,related accept"},
+		{ID: "inet/filter/input/2c560835504c", Family: "inet", Table: "filter", Chain: "input", Rule: `iif "lo" accept`},
+		{ID: "inet/filter/input/b0cc776b0c68", Family: "inet", Table: "filter", Chain: "input", Rule: "ip saddr @blocklist drop"},
+		{ID: "inet/filter/input/e05b2cf41d2a", Family: "inet", Table: "filter", Chain: "input", Rule: `tcp dport { 22, 443 } accept comment "ssh and https {public}"`},
+		{ID: "inet/filter/input/31e1ec47c048", Family: "inet", Table: "filter", Chain: "input", Rule: "counter drop"},
+		{ID: "ip/nat/POSTROUTING/bea7e5133ed6", Family: "ip", Table: "nat", Chain: "POSTROUTING", Rule: `oifname "eth0" masquerade`},
+		{ID: "ip/nat/DOCKER/b5eeca00d60e", Family: "ip", Table: "nat", Chain: "DOCKER", Rule: `iifname "docker0" return`},
+	}, rules)
+}
+
+func TestFirewallIptables(t *testing.T) {
+	// GIVEN a host without nft
+	p := NewFirewallPlugin(*ids.NewPluginID("config", "firewall"), testing2.NewMockAgent()).(*FirewallPlugin)
+	p.runCommand = recordedCommands(t, map[string]string{
+		"iptables-save":  "testdata/firewall/iptables_save.txt",
+		"ip6tables-save": "testdata/firewall/ip6tables_save.txt",
+	})
+
+	// WHEN the ruleset is fetched
+	dataset, err := p.getDataset()
+	require.NoError(t, err)
+
+	// THEN the iptables and ip6tables chains are reported, without counters
+	chains, rules := splitFirewallDataset(dataset)
+	assert.Equal(t, []FirewallChain{
+		{ID: "ip/nat/PREROUTING", Backend: "iptables", Family: "ip", Table: "nat", Chain: "PREROUTING", Policy: "accept"},
+		{ID: "ip/nat/INPUT", Backend: "iptables", Family: "ip", Table: "nat", Chain: "INPUT", Policy: "accept"},
+		{ID: "ip/nat/OUTPUT", Backend: "iptables", Family: "ip", Table: "nat", Chain: "OUTPUT", Policy: "accept"},
+		{ID: "ip/nat/POSTROUTING", Backend: "iptables", Family: "ip", Table: "nat", Chain: "POSTROUTING", Policy: "accept"},
+		{ID: "ip/nat/DOCKER", Backend: "iptables", Family: "ip", Table: "nat", Chain: "DOCKER"},
+		{ID: "ip/filter/INPUT", Backend: "iptables", Family: "ip", Table: "filter", Chain: "INPUT", Policy: "drop"},
+		{ID: "ip/filter/FORWARD", Backend: "iptables", Family: "ip", Table: "filter", Chain: "FORWARD", Policy: "drop"},
+		{ID: "ip/filter/OUTPUT", Backend: "iptables", Family: "ip", Table: "filter", Chain: "OUTPUT", Policy: "accept"},
+		{ID: "ip6/filter/INPUT", Backend: "iptables", Family: "ip6", Table: "filter", Chain: "INPUT", Policy: "drop"},
+		{ID: "ip6/filter/FORWARD", Backend: "iptables", Family: "ip6", Table: "filter", Chain: "FORWARD", Policy: "drop"},
+		{ID: "ip6/filter/OUTPUT", Backend: "iptables", Family: "ip6", Table: "filter", Chain: "OUTPUT", Policy: "accept"},
+	}, chains)
+
+	// AND the rules of each chain
+	require.Len(t, rules, 8)
+	assert.Equal(t, FirewallRule{ID: "ip/nat/POSTROUTING/4b582e6eab63", Family: "ip", Table: "nat", Chain: "POSTROUTING", Rule: "-s 172.17.0.0/16 ! -o docker0 -j MASQUERADE"}, rules[1])
+	assert.Contains(t, rules[5].Rule, `--comment "ssh access"`)
+	assert.Equal(t, FirewallRule{ID: "ip6/filter/INPUT/af2f91a62693", Family: "ip6", Table: "filter", Chain: "INPUT", Rule: "-p ipv6-icmp -j ACCEPT"}, rules[7])
+}
+
+func TestFirewallNoBackend(t *testing.T) {
+	// GIVEN a host without nft nor iptables
+	p := NewFirewallPlugin(*ids.NewPluginID("config", "firewall"), testing2.NewMockAgent()).(*FirewallPlugin)
+	p.runCommand = recordedCommands(t, map[string]string{})
+
+	// WHEN the ruleset is fetched
+	_, err := p.getDataset()
+
+	// THEN it fails
+	assert.Error(t, err)
+}
+
+func TestParseNftRuleset_RuleIDs(t *testing.T) {
+	// GIVEN a chain with identical rules
+	ruleset := `table inet filter {
+	chain input {
+		tcp dport 22 accept
+		counter drop
+		counter drop
+	}
+}`
+
+	// WHEN it is parsed
+	dataset, err := parseNftRuleset(ruleset)
+	require.NoError(t, err)
+	_, rules := splitFirewallDataset(dataset)
+
+	// THEN the identical rules are told apart by their occurrence
+	require.Len(t, rules, 3)
+	assert.Equal(t, rules[1].ID+"-2", rules[2].ID)
+}
+
+func TestParseNftRuleset_InsertedRuleIsSingleChange(t *testing.T) {
+	// GIVEN a parsed ruleset
+	ruleset := `table inet filter {
+	chain input {
+		tcp dport 22 accept
+		counter drop
+		counter drop
+	}
+}`
+	before, err := parseNftRuleset(ruleset)
+	require.NoError(t, err)
+
+	// WHEN a rule is inserted before the existing ones
+	after, err := parseNftRuleset(strings.Replace(ruleset, "tcp dport 22", "tcp dport 443 accept\n\t\ttcp dport 22", 1))
+	require.NoError(t, err)
+
+	// THEN only the inserted rule is a changed inventory item
+	items := map[string]agent.Sortable{}
+	for _, item := range before {
+		items[item.SortKey()] = item
+	}
+	var changed []agent.Sortable
+	for _, item := range after {
+		if previous, ok := items[item.SortKey()]; !ok || previous != item {
+			changed = append(changed, item)
+		}
+	}
+	require.Len(t, changed, 1)
+	assert.Equal(t, "tcp dport 443 accept", changed[0].(FirewallRule).Rule)
+	assert.Len(t, after, len(before)+1)
+}
+
+func TestParseNftRuleset_Unbalanced(t *testing.T) {
+	_, err := parseNftRuleset("table inet filter {\n}\n}\n")
+
+	assert.Error(t, err)
+}
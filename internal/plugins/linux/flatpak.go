@@ -0,0 +1,143 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+const (
+	// FlatpakSystemDir is the system wide flatpak installation, relative to the host /var
+	FlatpakSystemDir = "/lib/flatpak"
+)
+
+var flatpaklog = log.WithPlugin("Flatpak")
+
+type FlatpakPlugin struct {
+	agent.PluginCommon
+	installDir string
+	frequency  time.Duration
+}
+
+// FlatpakItem is an installed application or runtime, identified by its ref as "<name>/<arch>/<branch>", as
+// several architectures or branches of the same one can be installed.
+type FlatpakItem struct {
+	Ref          string `json:"id"`
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	Version      string `json:"version,omitempty"`
+	Architecture string `json:"architecture"`
+	Branch       string `json:"branch"`
+	Commit       string `json:"commit"`
+}
+
+func (self FlatpakItem) SortKey() string {
+	return self.Ref
+}
+
+// appStreamComponent is the subset of the AppStream metadata of an application or runtime.
+type appStreamComponent struct {
+	Releases []struct {
+		Version string `xml:"version,attr"`
+	} `xml:"releases>release"`
+}
+
+func NewFlatpakPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	cfg := ctx.Config()
+	return &FlatpakPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		installDir:   helpers.HostVar(FlatpakSystemDir),
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.FlatpakRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_PACKAGE_MGRS_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+	}
+}
+
+// fetchPackageInfo walks the installation deployments, laid out as "<kind>/<name>/<arch>/<branch>/active", where
+// active links to the deployed commit.
+func (self *FlatpakPlugin) fetchPackageInfo() (packages agent.PluginInventoryDataset, err error) {
+	if _, err = os.Stat(self.installDir); err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{"app", "runtime"} {
+		deployments, err := filepath.Glob(filepath.Join(self.installDir, kind, "*", "*", "*", "active"))
+		if err != nil {
+			return nil, err
+		}
+		for _, active := range deployments {
+			commit, err := os.Readlink(active)
+			if err != nil {
+				flatpaklog.WithError(err).WithField("path", active).Debug("Skipping deployment.")
+				continue
+			}
+			branchDir := filepath.Dir(active)
+			archDir := filepath.Dir(branchDir)
+			name := filepath.Base(filepath.Dir(archDir))
+			item := FlatpakItem{
+				Name:         name,
+				Kind:         kind,
+				Architecture: filepath.Base(archDir),
+				Branch:       filepath.Base(branchDir),
+				Commit:       filepath.Base(commit),
+				Version:      appStreamVersion(active, name),
+			}
+			item.Ref = name + "/" + item.Architecture + "/" + item.Branch
+			packages = append(packages, item)
+		}
+	}
+	return packages, nil
+}
+
+// appStreamVersion returns the latest release version in the AppStream metadata of the deployment, if any.
+func appStreamVersion(deployment, name string) string {
+	for _, path := range []string{
+		filepath.Join(deployment, "files", "share", "metainfo", name+".metainfo.xml"),
+		filepath.Join(deployment, "files", "share", "appdata", name+".appdata.xml"),
+	} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var component appStreamComponent
+		if err := xml.Unmarshal(content, &component); err != nil || len(component.Releases) == 0 {
+			continue
+		}
+		return component.Releases[0].Version
+	}
+	return ""
+}
+
+func (self *FlatpakPlugin) Run() {
+	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
+		flatpaklog.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(self.frequency)
+	for {
+		data, err := self.fetchPackageInfo()
+		if err != nil {
+			flatpaklog.WithError(err).Error("fetching flatpak data")
+		} else {
+			self.EmitInventory(data, entity.NewFromNameWithoutID(self.Context.EntityKey()))
+		}
+		<-refreshTimer.C
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testing2 "github.com/newrelic/infrastructure-agent/internal/plugins/testing"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+func TestFlatpakFetchPackageInfo(t *testing.T) {
+	p := NewFlatpakPlugin(*ids.NewPluginID("packages", "flatpak"), testing2.NewMockAgent()).(*FlatpakPlugin)
+	p.installDir = "testdata/packages/flatpak"
+
+	packages, err := p.fetchPackageInfo()
+
+	require.NoError(t, err)
+	require.Len(t, packages, 2)
+	sort.Sort(packages)
+	assert.Equal(t, FlatpakItem{
+		Ref:          "org.freedesktop.Platform/x86_64/22.08",
+		Name:         "org.freedesktop.Platform",
+		Kind:         "runtime",
+		Architecture: "x86_64",
+		Branch:       "22.08",
+		Commit:       "91bd5e3c7f",
+	}, packages[0])
+	assert.Equal(t, FlatpakItem{
+		Ref:          "org.gnome.Calculator/x86_64/stable",
+		Name:         "org.gnome.Calculator",
+		Kind:         "app",
+		Version:      "43.0.1",
+		Architecture: "x86_64",
+		Branch:       "stable",
+		Commit:       "4a0c2b1d9e",
+	}, packages[1])
+}
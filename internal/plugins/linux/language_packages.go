@@ -0,0 +1,227 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+// pipSitePackagesPatterns are the system wide Python packages directories.
+var pipSitePackagesPatterns = []string{
+	"/usr/lib/python3*/site-packages",
+	"/usr/lib64/python3*/site-packages",
+	"/usr/lib/python3/dist-packages",
+	"/usr/local/lib/python3*/site-packages",
+	"/usr/local/lib/python3*/dist-packages",
+}
+
+// npmGlobalModulesPatterns are the directories of the global npm packages.
+var npmGlobalModulesPatterns = []string{
+	"/usr/lib/node_modules",
+	"/usr/local/lib/node_modules",
+}
+
+// LanguagePackagesPlugin reports the packages installed by a language package manager in its system wide
+// directories.
+type LanguagePackagesPlugin struct {
+	agent.PluginCommon
+	logger    log.Entry
+	patterns  []string
+	list      func(dir string) []LanguagePackageItem
+	frequency time.Duration
+}
+
+// LanguagePackageItem is an installed package, identified by its directory and name, as the same package can be
+// installed in several directories, as in the ones of each Python version.
+type LanguagePackageItem struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture,omitempty"`
+	Location     string `json:"location"`
+}
+
+func (self LanguagePackageItem) SortKey() string {
+	return self.ID
+}
+
+func newLanguagePackageItem(dir, name, version, arch string) LanguagePackageItem {
+	return LanguagePackageItem{
+		ID:           dir + ":" + name,
+		Name:         name,
+		Version:      version,
+		Architecture: arch,
+		Location:     dir,
+	}
+}
+
+func newLanguagePackagesPlugin(id ids.PluginID, ctx agent.AgentContext, patterns []string, list func(string) []LanguagePackageItem) *LanguagePackagesPlugin {
+	cfg := ctx.Config()
+	return &LanguagePackagesPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		logger:       log.WithPlugin(id.String()),
+		patterns:     patterns,
+		list:         list,
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.LanguagePackagesRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_PACKAGE_MGRS_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+	}
+}
+
+// NewPipPlugin reports the Python packages installed in the system site-packages directories.
+func NewPipPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	return newLanguagePackagesPlugin(id, ctx, pipSitePackagesPatterns, listPipPackages)
+}
+
+// NewNpmPlugin reports the global npm packages.
+func NewNpmPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	return newLanguagePackagesPlugin(id, ctx, npmGlobalModulesPatterns, listNpmPackages)
+}
+
+func (self *LanguagePackagesPlugin) fetchPackageInfo() (packages agent.PluginInventoryDataset) {
+	for _, pattern := range self.patterns {
+		dirs, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, dir := range dirs {
+			for _, item := range self.list(dir) {
+				packages = append(packages, item)
+			}
+		}
+	}
+	return packages
+}
+
+// listPipPackages reads the metadata of the wheel (.dist-info) and egg (.egg-info) installed packages.
+func listPipPackages(dir string) (packages []LanguagePackageItem) {
+	distInfos, _ := filepath.Glob(filepath.Join(dir, "*.dist-info"))
+	for _, distInfo := range distInfos {
+		meta, err := readPackageMetadata(filepath.Join(distInfo, "METADATA"))
+		if err != nil || meta["Name"] == "" {
+			continue
+		}
+		packages = append(packages, newLanguagePackageItem(dir, meta["Name"], meta["Version"], wheelPlatform(distInfo)))
+	}
+
+	eggInfos, _ := filepath.Glob(filepath.Join(dir, "*.egg-info"))
+	for _, eggInfo := range eggInfos {
+		// egg-info can be either a directory or the PKG-INFO file itself
+		path := eggInfo
+		if info, err := os.Stat(eggInfo); err == nil && info.IsDir() {
+			path = filepath.Join(eggInfo, "PKG-INFO")
+		}
+		meta, err := readPackageMetadata(path)
+		if err != nil || meta["Name"] == "" {
+			continue
+		}
+		packages = append(packages, newLanguagePackageItem(dir, meta["Name"], meta["Version"], ""))
+	}
+	return packages
+}
+
+// readPackageMetadata reads the header fields of a Python package metadata file, which precede its description.
+func readPackageMetadata(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	meta := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			if _, found := meta[key]; !found {
+				meta[key] = value
+			}
+		}
+	}
+	return meta, scanner.Err()
+}
+
+// wheelPlatform returns the platforms of the wheel tags, as "any" for pure Python packages or
+// "manylinux_2_17_x86_64".
+func wheelPlatform(distInfo string) string {
+	file, err := os.Open(filepath.Join(distInfo, "WHEEL"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	found := map[string]bool{}
+	var platforms []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		tag := strings.TrimPrefix(scanner.Text(), "Tag: ")
+		if tag == scanner.Text() {
+			continue
+		}
+		// tags are formatted as <python>-<abi>-<platform>
+		parts := strings.SplitN(tag, "-", 3)
+		if len(parts) == 3 && !found[parts[2]] {
+			found[parts[2]] = true
+			platforms = append(platforms, parts[2])
+		}
+	}
+	sort.Strings(platforms)
+	return strings.Join(platforms, ",")
+}
+
+// listNpmPackages reads the package.json of the packages, including the scoped ones.
+func listNpmPackages(dir string) (packages []LanguagePackageItem) {
+	manifests, _ := filepath.Glob(filepath.Join(dir, "*", "package.json"))
+	scoped, _ := filepath.Glob(filepath.Join(dir, "@*", "*", "package.json"))
+	for _, manifest := range append(manifests, scoped...) {
+		content, err := ioutil.ReadFile(manifest)
+		if err != nil {
+			continue
+		}
+		var pkg struct {
+			Name    string   `json:"name"`
+			Version string   `json:"version"`
+			CPU     []string `json:"cpu"`
+		}
+		if err := json.Unmarshal(content, &pkg); err != nil || pkg.Name == "" {
+			continue
+		}
+		packages = append(packages, newLanguagePackageItem(dir, pkg.Name, pkg.Version, strings.Join(pkg.CPU, ",")))
+	}
+	return packages
+}
+
+func (self *LanguagePackagesPlugin) Run() {
+	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
+		self.logger.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(self.frequency)
+	for {
+		self.EmitInventory(self.fetchPackageInfo(), entity.NewFromNameWithoutID(self.Context.EntityKey()))
+		<-refreshTimer.C
+	}
+}
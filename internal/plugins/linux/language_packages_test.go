@@ -0,0 +1,47 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testing2 "github.com/newrelic/infrastructure-agent/internal/plugins/testing"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+func TestPipFetchPackageInfo(t *testing.T) {
+	p := NewPipPlugin(*ids.NewPluginID("packages", "pip"), testing2.NewMockAgent()).(*LanguagePackagesPlugin)
+	p.patterns = []string{"testdata/packages/python3*/site-packages", "["}
+
+	packages := p.fetchPackageInfo()
+
+	require.Len(t, packages, 4)
+	sort.Sort(packages)
+	dir := "testdata/packages/python3.10/site-packages"
+	assert.Equal(t, newLanguagePackageItem(dir, "numpy", "1.23.0", "manylinux2014_x86_64,manylinux_2_17_x86_64"), packages[0])
+	assert.Equal(t, newLanguagePackageItem(dir, "requests", "2.28.1", "any"), packages[1])
+	// egg-info file and directory
+	assert.Equal(t, newLanguagePackageItem(dir, "setuptools", "59.6.0", ""), packages[2])
+	assert.Equal(t, newLanguagePackageItem(dir, "six", "1.16.0", ""), packages[3])
+	assert.Equal(t, dir+":six", packages[3].SortKey())
+}
+
+func TestNpmFetchPackageInfo(t *testing.T) {
+	p := NewNpmPlugin(*ids.NewPluginID("packages", "npm"), testing2.NewMockAgent()).(*LanguagePackagesPlugin)
+	p.patterns = []string{"testdata/packages/node_modules"}
+
+	packages := p.fetchPackageInfo()
+
+	require.Len(t, packages, 2)
+	sort.Sort(packages)
+	dir := "testdata/packages/node_modules"
+	assert.Equal(t, newLanguagePackageItem(dir, "@angular/cli", "14.2.6", "x64,arm64"), packages[0])
+	assert.Equal(t, newLanguagePackageItem(dir, "npm", "8.19.2", ""), packages[1])
+}
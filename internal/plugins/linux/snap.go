@@ -0,0 +1,158 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+const (
+	// SnapdStateFile is the state of snapd, relative to the host /var
+	SnapdStateFile = "/lib/snapd/state.json"
+)
+
+// snapMountDirs are the directories where the snaps are mounted, depending on the distro.
+var snapMountDirs = []string{"/snap", "/var/lib/snapd/snap"}
+
+var snaplog = log.WithPlugin("Snap")
+
+type SnapPlugin struct {
+	agent.PluginCommon
+	stateFile string
+	mountDirs []string
+	frequency time.Duration
+}
+
+type SnapItem struct {
+	Name         string `json:"id"`
+	Version      string `json:"version,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Revision     string `json:"revision"`
+	Channel      string `json:"channel,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Active       bool   `json:"active"`
+}
+
+func (self SnapItem) SortKey() string {
+	return self.Name
+}
+
+// snapdState is the subset of the snapd state holding the installed snaps.
+type snapdState struct {
+	Data struct {
+		Snaps map[string]struct {
+			Type            string `json:"type"`
+			Active          bool   `json:"active"`
+			Current         string `json:"current"`
+			Channel         string `json:"channel"`
+			TrackingChannel string `json:"tracking-channel"`
+		} `json:"snaps"`
+	} `json:"data"`
+}
+
+// snapMeta is the subset of the snap.yaml metadata of a snap.
+type snapMeta struct {
+	Version       string   `yaml:"version"`
+	Architectures []string `yaml:"architectures"`
+}
+
+func NewSnapPlugin(id ids.PluginID, ctx agent.AgentContext) agent.Plugin {
+	cfg := ctx.Config()
+	return &SnapPlugin{
+		PluginCommon: agent.PluginCommon{ID: id, Context: ctx},
+		stateFile:    helpers.HostVar(SnapdStateFile),
+		mountDirs:    snapMountDirs,
+		frequency: config.ValidateConfigFrequencySetting(
+			cfg.SnapRefreshSec,
+			config.FREQ_MINIMUM_INVENTORY_SAMPLE_RATE,
+			config.FREQ_PLUGIN_PACKAGE_MGRS_UPDATES,
+			cfg.DisableAllPlugins,
+		) * time.Second,
+	}
+}
+
+// fetchPackageInfo reads the installed snaps from the snapd state, and their version and architectures from the
+// metadata of the current revision, when mounted.
+func (self *SnapPlugin) fetchPackageInfo() (packages agent.PluginInventoryDataset, err error) {
+	content, err := ioutil.ReadFile(self.stateFile)
+	if err != nil {
+		return nil, err
+	}
+	var state snapdState
+	if err = json.Unmarshal(content, &state); err != nil {
+		return nil, err
+	}
+
+	for name, snap := range state.Data.Snaps {
+		item := SnapItem{
+			Name:     name,
+			Revision: snap.Current,
+			Channel:  snap.TrackingChannel,
+			Type:     snap.Type,
+			Active:   snap.Active,
+		}
+		if item.Channel == "" {
+			item.Channel = snap.Channel
+		}
+		if meta, err := self.readSnapMeta(name, snap.Current); err != nil {
+			snaplog.WithError(err).WithField("snap", name).Debug("Cannot read snap metadata.")
+		} else {
+			item.Version = meta.Version
+			item.Architecture = strings.Join(meta.Architectures, ",")
+			// snaps not declaring their architectures are built for all of them
+			if item.Architecture == "" {
+				item.Architecture = "all"
+			}
+		}
+		packages = append(packages, item)
+	}
+	return packages, nil
+}
+
+func (self *SnapPlugin) readSnapMeta(name, revision string) (meta snapMeta, err error) {
+	var content []byte
+	for _, dir := range self.mountDirs {
+		if content, err = ioutil.ReadFile(filepath.Join(dir, name, revision, "meta", "snap.yaml")); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	err = yaml.Unmarshal(content, &meta)
+	return
+}
+
+func (self *SnapPlugin) Run() {
+	if self.frequency <= config.FREQ_DISABLE_SAMPLING {
+		snaplog.Debug("Disabled.")
+		return
+	}
+
+	refreshTimer := time.NewTicker(self.frequency)
+	for {
+		data, err := self.fetchPackageInfo()
+		if err != nil {
+			snaplog.WithError(err).Error("fetching snap data")
+		} else {
+			self.EmitInventory(data, entity.NewFromNameWithoutID(self.Context.EntityKey()))
+		}
+		<-refreshTimer.C
+	}
+}
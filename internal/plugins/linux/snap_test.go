@@ -0,0 +1,35 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package linux
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testing2 "github.com/newrelic/infrastructure-agent/internal/plugins/testing"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+func TestSnapFetchPackageInfo(t *testing.T) {
+	p := NewSnapPlugin(*ids.NewPluginID("packages", "snap"), testing2.NewMockAgent()).(*SnapPlugin)
+	p.stateFile = "testdata/packages/snapd/state.json"
+	p.mountDirs = []string{"testdata/packages/non_existing", "testdata/packages/snap"}
+
+	packages, err := p.fetchPackageInfo()
+
+	require.NoError(t, err)
+	sort.Sort(packages)
+	assert.Equal(t, []interface{}{
+		SnapItem{Name: "core20", Version: "20220527", Architecture: "amd64", Revision: "1518", Channel: "latest/stable", Type: "base", Active: true},
+		// not mounted, as when disabled
+		SnapItem{Name: "hello", Revision: "38", Channel: "stable", Type: "app"},
+		SnapItem{Name: "lxd", Version: "4.0.9-8e2046b", Architecture: "all", Revision: "23155", Channel: "4.0/stable/ubuntu-20.04", Type: "app", Active: true},
+	}, []interface{}{packages[0], packages[1], packages[2]})
+	assert.Len(t, packages, 3)
+}
@@ -6,14 +6,19 @@
 package linux
 
 import (
-	"bufio"
-	"github.com/newrelic/infrastructure-agent/pkg/entity"
-	"os/exec"
-	"regexp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo"
@@ -25,19 +30,55 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 )
 
+const (
+	serviceSuffix          = ".service"
+	unitStateFailed        = "failed"
+	loadStateNotFound      = "not-found"
+	systemdDbusTimeout     = 30 * time.Second
+	systemBusAddressFormat = "unix:path=%s"
+	systemBusDefaultPath   = "/run/dbus/system_bus_socket"
+	dbusSystemBusAddrEnv   = "DBUS_SYSTEM_BUS_ADDRESS"
+	// unit state changes are buffered between the refreshes, changes are only dropped when the buffer is full
+	subStateUpdatesBuffer = 256
+)
+
 var sdlog = log.WithPlugin("Systemd")
-var reService = regexp.MustCompile(`(\S+)\.service.*loaded.*?(active|inactive).*?(running|failed|dead)(.*)`)
 var systemdPluginId = ids.PluginID{"services", "systemd"}
 
+// systemdConn is the subset of the systemd D-Bus API used by the plugin.
+type systemdConn interface {
+	ListUnitsContext(ctx context.Context) ([]dbus.UnitStatus, error)
+	ListUnitFilesContext(ctx context.Context) ([]dbus.UnitFile, error)
+	GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]interface{}, error)
+	GetUnitTypePropertiesContext(ctx context.Context, unit string, unitType string) (map[string]interface{}, error)
+	Subscribe() error
+	SetSubStateSubscriber(updateCh chan<- *dbus.SubStateUpdate, errCh chan<- error)
+	Close()
+}
+
 type SystemdPlugin struct {
 	agent.PluginCommon
-	runningServices map[string]SystemdService
-	frequency       time.Duration
+	services   map[string]SystemdService
+	connect    func() (systemdConn, error)
+	conn       systemdConn
+	updates    chan *dbus.SubStateUpdate
+	updateErrs chan error
+	frequency  time.Duration
 }
 
+// SystemdService is the inventory item of a systemd service unit, identified by the unit name without the
+// ".service" suffix.
 type SystemdService struct {
-	Name string `json:"id"`
-	Pid  string `json:"pid"`
+	Name          string  `json:"id"`
+	Pid           string  `json:"pid,omitempty"`
+	ActiveState   string  `json:"active_state"`
+	SubState      string  `json:"sub_state"`
+	UnitFileState string  `json:"unit_file_state,omitempty"`
+	Restarts      *uint32 `json:"restarts,omitempty"`
+	FragmentPath  string  `json:"fragment_path,omitempty"`
+	// service result and exit status, only reported in the failure events
+	result     string
+	exitStatus int32
 }
 
 func (self SystemdService) SortKey() string {
@@ -47,7 +88,7 @@ func (self SystemdService) SortKey() string {
 func (self SystemdPlugin) getSystemdDataset() agent.PluginInventoryDataset {
 	var dataset agent.PluginInventoryDataset
 
-	for _, v := range self.runningServices {
+	for _, v := range self.services {
 		dataset = append(dataset, v)
 	}
 
@@ -57,7 +98,7 @@ func (self SystemdPlugin) getSystemdDataset() agent.PluginInventoryDataset {
 func (self SystemdPlugin) getSystemdPidMap() map[int]string {
 	result := make(map[int]string)
 
-	for _, v := range self.runningServices {
+	for _, v := range self.services {
 		pid, err := strconv.Atoi(v.Pid)
 		if err == nil {
 			result[pid] = v.Name
@@ -67,83 +108,239 @@ func (self SystemdPlugin) getSystemdPidMap() map[int]string {
 	return result
 }
 
-func parseSystemctlOutput(line string) (name string, loaded string, status string) {
-	matches := reService.FindStringSubmatch(line)
-	if matches == nil {
-		return "", "", ""
+// getSystemdServices returns the loaded service units, with their state and service properties, and the service
+// unit files which are not loaded, as the disabled and inactive ones.
+func (self *SystemdPlugin) getSystemdServices(conn systemdConn) (map[string]SystemdService, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), systemdDbusTimeout)
+	defer cancel()
+
+	units, err := conn.ListUnitsContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list systemd units: %w", err)
 	}
-	if len(matches) == 5 {
-		name = matches[1]
-		loaded = matches[2]
-		status = matches[3]
-		return name, loaded, status
-	} else {
-		sdlog.WithField("line", line).Warn("Saw unexpected line from systemctl")
-		return "", "", ""
+
+	services := make(map[string]SystemdService)
+	for _, unit := range units {
+		if !strings.HasSuffix(unit.Name, serviceSuffix) || unit.LoadState == loadStateNotFound {
+			continue
+		}
+		service := SystemdService{
+			Name:        strings.TrimSuffix(unit.Name, serviceSuffix),
+			ActiveState: unit.ActiveState,
+			SubState:    unit.SubState,
+		}
+		if props, err := conn.GetUnitPropertiesContext(ctx, unit.Name); err != nil {
+			sdlog.WithError(err).WithField("unit", unit.Name).Debug("Cannot get unit properties.")
+		} else {
+			service.UnitFileState, _ = props["UnitFileState"].(string)
+			service.FragmentPath, _ = props["FragmentPath"].(string)
+		}
+		getServiceProperties(ctx, conn, &service)
+		services[service.Name] = service
 	}
-}
 
-func getPidFromName(line string) string {
-	var pid string
-	if pid_slice := strings.Split(line, "="); len(pid_slice) == 2 {
-		pid = pid_slice[1]
-		if pid == "0" {
-			pid = "unknown"
+	files, err := conn.ListUnitFilesContext(ctx)
+	if err != nil {
+		sdlog.WithError(err).Debug("Cannot list systemd unit files.")
+		return services, nil
+	}
+	for _, file := range files {
+		unitName := filepath.Base(file.Path)
+		// template units can't be started themselves, only their instances
+		if !strings.HasSuffix(unitName, serviceSuffix) || strings.HasSuffix(unitName, "@"+serviceSuffix) {
+			continue
+		}
+		name := strings.TrimSuffix(unitName, serviceSuffix)
+		if _, loaded := services[name]; loaded {
+			continue
 		}
-		if pid == "" {
-			pid = "unknown"
+		services[name] = SystemdService{
+			Name:          name,
+			ActiveState:   "inactive",
+			SubState:      "dead",
+			UnitFileState: file.Type,
+			FragmentPath:  file.Path,
 		}
-	} else {
-		pid = "unknown"
 	}
-	return pid
+
+	return services, nil
 }
 
-func (self *SystemdPlugin) getSystemdServiceStatus() {
-	output, err := helpers.RunCommand("/bin/systemctl", "", "--plain", "-l", "--no-pager", "--no-legend", "--all", "--type=service", "list-units")
+// getServiceProperties fills the properties of the service type of the unit.
+func getServiceProperties(ctx context.Context, conn systemdConn, service *SystemdService) {
+	unitName := service.Name + serviceSuffix
+	props, err := conn.GetUnitTypePropertiesContext(ctx, unitName, "Service")
 	if err != nil {
-		sdlog.WithError(err).Error("unable to get systemd service status")
+		sdlog.WithError(err).WithField("unit", unitName).Debug("Cannot get service properties.")
+		return
+	}
+	if pid, ok := props["MainPID"].(uint32); ok && pid > 0 {
+		service.Pid = strconv.FormatUint(uint64(pid), 10)
 	}
+	// NRestarts is only provided since systemd 235
+	if restarts, ok := props["NRestarts"].(uint32); ok {
+		service.Restarts = &restarts
+	}
+	service.result, _ = props["Result"].(string)
+	service.exitStatus, _ = props["ExecMainStatus"].(int32)
+}
 
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		// first up parse out the service name and status
-		name, loaded, status := parseSystemctlOutput(scanner.Text())
-		if name == "" {
-			continue
+// failedSince returns whether the service failed since its last known state: it's failed and it wasn't, including
+// the services not known yet, or it was restarted automatically, as systemd does after a failure with the
+// on-failure restart policies.
+func failedSince(last SystemdService, known bool, service SystemdService) bool {
+	if service.ActiveState == unitStateFailed && (!known || last.ActiveState != unitStateFailed) {
+		return true
+	}
+	return known && autoRestarted(last, service)
+}
+
+func autoRestarted(last SystemdService, service SystemdService) bool {
+	return last.Restarts != nil && service.Restarts != nil && *service.Restarts > *last.Restarts
+}
+
+// failedServices returns the services which failed since the last refresh.
+func (self *SystemdPlugin) failedServices(services map[string]SystemdService) (failed []SystemdService) {
+	for name, service := range services {
+		last, known := self.services[name]
+		if failedSince(last, known, service) {
+			failed = append(failed, service)
 		}
-		if loaded == "inactive" {
-			delete(self.runningServices, name)
-			continue
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Name < failed[j].Name })
+	return failed
+}
+
+func (self *SystemdPlugin) emitFailureEvent(last SystemdService, service SystemdService) {
+	event := map[string]interface{}{
+		"eventType":  "InfrastructureEvent",
+		"category":   "systemd",
+		"summary":    "Systemd unit failed",
+		"unitName":   service.Name + serviceSuffix,
+		"subState":   service.SubState,
+		"result":     service.result,
+		"exitStatus": service.exitStatus,
+	}
+	if service.Restarts != nil {
+		event["restarts"] = *service.Restarts
+	}
+	if autoRestarted(last, service) {
+		event["autoRestarted"] = true
+	}
+	self.EmitEvent(event, entity.EmptyKey)
+}
+
+// refresh updates the services, emitting a failure event for every service which failed since the last refresh.
+// No events are emitted on the first refresh. The connection is established again when lost.
+func (self *SystemdPlugin) refresh() error {
+	if self.conn == nil {
+		if err := self.connectAndSubscribe(); err != nil {
+			return fmt.Errorf("cannot connect to systemd: %w", err)
 		}
-		if loaded == "active" {
-			switch status {
-			case "running":
-				output, err := helpers.RunCommand("/bin/systemctl", "", "--property=MainPID", "show", name)
-				if err != nil {
-					sdlog.WithError(err).Error("systemctl unable to get pid")
-				}
-				pid := getPidFromName(output)
-				self.runningServices[name] = SystemdService{name, pid}
-			case "dead":
-				delete(self.runningServices, name)
-			case "exited":
-				continue
-			}
+	}
+	services, err := self.getSystemdServices(self.conn)
+	if err != nil {
+		self.conn.Close()
+		self.conn = nil
+		return err
+	}
+	if self.services != nil {
+		for _, service := range self.failedServices(services) {
+			self.emitFailureEvent(self.services[service.Name], service)
 		}
 	}
+	self.services = services
+	return nil
+}
+
+// connectAndSubscribe connects to systemd and subscribes to the unit state changes, so failures are reported as
+// they happen. Failures are still reported on refresh when the subscription is not available.
+func (self *SystemdPlugin) connectAndSubscribe() error {
+	conn, err := self.connect()
+	if err != nil {
+		return err
+	}
+	if err = conn.Subscribe(); err != nil {
+		sdlog.WithError(err).Debug("Cannot subscribe to systemd unit changes.")
+	} else {
+		conn.SetSubStateSubscriber(self.updates, self.updateErrs)
+	}
+	self.conn = conn
+	return nil
+}
+
+// handleSubStateUpdate emits the failure event of a service as soon as systemd reports its state change, so
+// services failing and being restarted between refreshes are reported.
+func (self *SystemdPlugin) handleSubStateUpdate(update *dbus.SubStateUpdate) {
+	if self.services == nil || self.conn == nil || !strings.HasSuffix(update.UnitName, serviceSuffix) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), systemdDbusTimeout)
+	defer cancel()
+
+	props, err := self.conn.GetUnitPropertiesContext(ctx, update.UnitName)
+	if err != nil {
+		sdlog.WithError(err).WithField("unit", update.UnitName).Debug("Cannot get unit properties.")
+		return
+	}
+	service := SystemdService{Name: strings.TrimSuffix(update.UnitName, serviceSuffix), SubState: update.SubState}
+	service.ActiveState, _ = props["ActiveState"].(string)
+	service.UnitFileState, _ = props["UnitFileState"].(string)
+	service.FragmentPath, _ = props["FragmentPath"].(string)
+	getServiceProperties(ctx, self.conn, &service)
+
+	last, known := self.services[service.Name]
+	if failedSince(last, known, service) {
+		self.emitFailureEvent(last, service)
+	}
+	self.services[service.Name] = service
+}
+
+// systemBusAddress returns the address of the host system bus, unless overridden through the environment.
+func systemBusAddress() string {
+	if address, found := os.LookupEnv(dbusSystemBusAddrEnv); found {
+		return address
+	}
+	return fmt.Sprintf(systemBusAddressFormat, helpers.HostVar(systemBusDefaultPath))
+}
+
+// dialSystemBus connects to the system bus, authenticating as go-systemd does.
+func dialSystemBus(address string) (*godbus.Conn, error) {
+	conn, err := godbus.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.Auth([]godbus.Auth{godbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
 
-func systemdPresent() bool {
-	_, err := exec.LookPath("systemctl")
-	return err == nil
+// connectSystemd connects to systemd through the host system bus, or directly as root when the bus is not
+// available.
+func connectSystemd() (systemdConn, error) {
+	address := systemBusAddress()
+	conn, err := dbus.NewConnection(func() (*godbus.Conn, error) {
+		return dialSystemBus(address)
+	})
+	if err != nil && os.Geteuid() == 0 {
+		return dbus.NewSystemdConnectionContext(context.Background())
+	}
+	return conn, err
 }
 
 func NewSystemdPlugin(ctx agent.AgentContext) agent.Plugin {
 	cfg := ctx.Config()
 	return &SystemdPlugin{
-		PluginCommon:    agent.PluginCommon{ID: systemdPluginId, Context: ctx},
-		runningServices: make(map[string]SystemdService),
+		PluginCommon: agent.PluginCommon{ID: systemdPluginId, Context: ctx},
+		connect:      connectSystemd,
+		updates:      make(chan *dbus.SubStateUpdate, subStateUpdatesBuffer),
+		updateErrs:   make(chan error, 1),
 		frequency: config.ValidateConfigFrequencySetting(
 			cfg.SystemdIntervalSec,
 			config.FREQ_MINIMUM_FAST_INVENTORY_SAMPLE_RATE,
@@ -159,21 +356,35 @@ func (self *SystemdPlugin) Run() {
 		return
 	}
 
-	if systemdPresent() {
-		refreshTimer := time.NewTicker(1)
-		for {
-			select {
-			case <-refreshTimer.C:
-				{
-					refreshTimer.Stop()
-					refreshTimer = time.NewTicker(self.frequency)
-					self.getSystemdServiceStatus()
-					self.EmitInventory(self.getSystemdDataset(), entity.NewFromNameWithoutID(self.Context.EntityKey()))
-					self.Context.CacheServicePids(sysinfo.PROCESS_NAME_SOURCE_SYSTEMD, self.getSystemdPidMap())
+	if err := self.connectAndSubscribe(); err != nil {
+		sdlog.WithError(err).Debug("Cannot connect to systemd through D-Bus.")
+		self.Unregister()
+		return
+	}
+	defer func() {
+		if self.conn != nil {
+			self.conn.Close()
+		}
+	}()
+
+	refreshTimer := time.NewTicker(1)
+	for {
+		select {
+		case <-refreshTimer.C:
+			{
+				refreshTimer.Stop()
+				refreshTimer = time.NewTicker(self.frequency)
+				if err := self.refresh(); err != nil {
+					sdlog.WithError(err).Error("unable to get systemd service status")
+					continue
 				}
+				self.EmitInventory(self.getSystemdDataset(), entity.NewFromNameWithoutID(self.Context.EntityKey()))
+				self.Context.CacheServicePids(sysinfo.PROCESS_NAME_SOURCE_SYSTEMD, self.getSystemdPidMap())
 			}
+		case update := <-self.updates:
+			self.handleSubStateUpdate(update)
+		case err := <-self.updateErrs:
+			sdlog.WithError(err).Debug("Systemd unit changes subscription error.")
 		}
-	} else {
-		self.Unregister()
 	}
 }
@@ -6,50 +6,259 @@
 package linux
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 
-	. "gopkg.in/check.v1"
+	"testing"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
 )
 
-type SystemdSuite struct{}
-
-var _ = Suite(&SystemdSuite{})
-
-func (self *SystemdSuite) TestSystemctlParse(c *C) {
-	data := `
-auditd.service                                                                            loaded active   running Security Auditing Service
-avahi-daemon.service                                                                      loaded active   running Avahi mDNS/DNS-SD Stack
-brandbot.service                                                                          loaded inactive dead    Flexible Branding Service
-cpupower.service                                                                          loaded inactive dead    Configure CPU power related settings
-display-manager.service                                                                   not-found inactive dead    display-manager.service
-exim.service                                                                              not-found inactive dead    exim.service
-kdump.service                                                                             loaded failed   failed  Crash recovery kernel arming
-crond.service                                                                             loaded failed   failed  Command Scheduler
-kmod-static-nodes.service                                                                 loaded active   exited  Create list of required static device nodes for the current kernel
-lvm2-monitor.service                                                                      loaded active   exited  Monitoring of LVM2 mirrors, snapshots etc. using dmeventd or progress polling
-`
-
-	scan := bufio.NewScanner(bytes.NewBuffer([]byte(data)))
-	var findings []string
-	for scan.Scan() {
-		name, loaded, status := parseSystemctlOutput(scan.Text())
-		if name == "" {
-			c.Assert(status, Equals, "")
-			c.Assert(loaded, Equals, "")
-		} else if loaded == "active" && status == "running" {
-			findings = append(findings, name)
-		}
+// fakeSystemdConn serves the units and their properties as a systemd D-Bus connection would.
+type fakeSystemdConn struct {
+	units        []dbus.UnitStatus
+	files        []dbus.UnitFile
+	unitProps    map[string]map[string]interface{}
+	serviceProps map[string]map[string]interface{}
+	subscribed   bool
+	closed       bool
+}
+
+func (f *fakeSystemdConn) ListUnitsContext(_ context.Context) ([]dbus.UnitStatus, error) {
+	if f.units == nil {
+		return nil, errors.New("bus unavailable")
+	}
+	return f.units, nil
+}
+
+func (f *fakeSystemdConn) ListUnitFilesContext(_ context.Context) ([]dbus.UnitFile, error) {
+	return f.files, nil
+}
+
+func (f *fakeSystemdConn) GetUnitPropertiesContext(_ context.Context, unit string) (map[string]interface{}, error) {
+	return f.unitProps[unit], nil
+}
+
+func (f *fakeSystemdConn) GetUnitTypePropertiesContext(_ context.Context, unit string, _ string) (map[string]interface{}, error) {
+	props, ok := f.serviceProps[unit]
+	if !ok {
+		return nil, errors.New("no such unit")
+	}
+	return props, nil
+}
+
+func (f *fakeSystemdConn) Subscribe() error {
+	f.subscribed = true
+	return nil
+}
+
+func (f *fakeSystemdConn) SetSubStateSubscriber(_ chan<- *dbus.SubStateUpdate, _ chan<- error) {}
+
+func (f *fakeSystemdConn) Close() {
+	f.closed = true
+}
+
+func newFakeSystemdConn() *fakeSystemdConn {
+	return &fakeSystemdConn{
+		units: []dbus.UnitStatus{
+			{Name: "sshd.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			{Name: "kdump.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+			{Name: "lvm2-monitor.service", LoadState: "loaded", ActiveState: "active", SubState: "exited"},
+			{Name: "exim.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+			{Name: "-.mount", LoadState: "loaded", ActiveState: "active", SubState: "mounted"},
+		},
+		files: []dbus.UnitFile{
+			{Path: "/usr/lib/systemd/system/sshd.service", Type: "enabled"},
+			{Path: "/usr/lib/systemd/system/cups.service", Type: "disabled"},
+			{Path: "/etc/systemd/system/telnet.service", Type: "masked"},
+			{Path: "/usr/lib/systemd/system/getty@.service", Type: "enabled"},
+			{Path: "/usr/lib/systemd/system/tmp.mount", Type: "static"},
+		},
+		unitProps: map[string]map[string]interface{}{
+			"sshd.service":  {"UnitFileState": "enabled", "FragmentPath": "/usr/lib/systemd/system/sshd.service"},
+			"kdump.service": {"UnitFileState": "enabled", "FragmentPath": "/usr/lib/systemd/system/kdump.service"},
+		},
+		serviceProps: map[string]map[string]interface{}{
+			"sshd.service":         {"MainPID": uint32(8575), "NRestarts": uint32(2), "Result": "success", "ExecMainStatus": int32(0)},
+			"kdump.service":        {"MainPID": uint32(0), "NRestarts": uint32(0), "Result": "exit-code", "ExecMainStatus": int32(1)},
+			"lvm2-monitor.service": {"MainPID": uint32(0)},
+		},
 	}
-	c.Assert(len(findings), Equals, 2)
-	c.Assert(findings[0], Equals, "auditd")
-	c.Assert(findings[1], Equals, "avahi-daemon")
 }
 
-func (self *SystemdSuite) TestGetPidFromName(c *C) {
-	c.Assert(getPidFromName("MainPID=8575"), Equals, "8575")
-	c.Assert(getPidFromName("MainPID=0"), Equals, "unknown")
-	c.Assert(getPidFromName("MainPID="), Equals, "unknown")
-	c.Assert(getPidFromName(" "), Equals, "unknown")
-	c.Assert(getPidFromName(""), Equals, "unknown")
+func newTestSystemdPlugin(ctx agent.AgentContext, conn systemdConn) *SystemdPlugin {
+	return &SystemdPlugin{
+		PluginCommon: agent.PluginCommon{ID: systemdPluginId, Context: ctx},
+		conn:         conn,
+	}
+}
+
+// eventsRecorder returns an agent context recording the events sent.
+func eventsRecorder(events *[]map[string]interface{}) *mocks.AgentContext {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{})
+	ctx.On("SendEvent", mock.Anything, entity.EmptyKey).Run(func(args mock.Arguments) {
+		var event map[string]interface{}
+		data, _ := json.Marshal(args.Get(0).(sample.Event))
+		_ = json.Unmarshal(data, &event)
+		*events = append(*events, event)
+	})
+	return ctx
+}
+
+func TestGetSystemdServices(t *testing.T) {
+	p := newTestSystemdPlugin(nil, nil)
+
+	services, err := p.getSystemdServices(newFakeSystemdConn())
+
+	require.NoError(t, err)
+	require.Len(t, services, 5)
+	restarts := uint32(2)
+	assert.Equal(t, SystemdService{
+		Name:          "sshd",
+		Pid:           "8575",
+		ActiveState:   "active",
+		SubState:      "running",
+		UnitFileState: "enabled",
+		Restarts:      &restarts,
+		FragmentPath:  "/usr/lib/systemd/system/sshd.service",
+		result:        "success",
+	}, services["sshd"])
+	assert.Equal(t, "failed", services["kdump"].ActiveState)
+	assert.Equal(t, "", services["kdump"].Pid)
+	// exited units are inventoried, without restarts when not provided by systemd
+	assert.Equal(t, "exited", services["lvm2-monitor"].SubState)
+	assert.Nil(t, services["lvm2-monitor"].Restarts)
+	// unit files which are not loaded are inventoried as inactive
+	assert.Equal(t, "disabled", services["cups"].UnitFileState)
+	assert.Equal(t, "inactive", services["cups"].ActiveState)
+	assert.Equal(t, "masked", services["telnet"].UnitFileState)
+	assert.Equal(t, "/etc/systemd/system/telnet.service", services["telnet"].FragmentPath)
+
+	assert.Empty(t, p.getSystemdPidMap())
+	p.services = services
+	assert.Equal(t, map[int]string{8575: "sshd"}, p.getSystemdPidMap())
+}
+
+func TestGetSystemdServices_BusError(t *testing.T) {
+	p := newTestSystemdPlugin(nil, nil)
+
+	_, err := p.getSystemdServices(&fakeSystemdConn{})
+
+	assert.Error(t, err)
+}
+
+func TestSystemdRefresh_FailureEvents(t *testing.T) {
+	// GIVEN a running service
+	conn := newFakeSystemdConn()
+	var events []map[string]interface{}
+	p := newTestSystemdPlugin(eventsRecorder(&events), conn)
+
+	// WHEN it fails between refreshes
+	require.NoError(t, p.refresh())
+	conn.units[0].ActiveState = "failed"
+	conn.units[0].SubState = "failed"
+	conn.serviceProps["sshd.service"]["Result"] = "signal"
+	require.NoError(t, p.refresh())
+	// and stays failed
+	require.NoError(t, p.refresh())
+
+	// THEN a single event is emitted for it, and none for the service failed before the first refresh
+	require.Len(t, events, 1)
+	assert.Equal(t, "InfrastructureEvent", events[0]["eventType"])
+	assert.Equal(t, "systemd", events[0]["category"])
+	assert.Equal(t, "sshd.service", events[0]["unitName"])
+	assert.Equal(t, "signal", events[0]["result"])
+	assert.Equal(t, float64(2), events[0]["restarts"])
+	assert.NotContains(t, events[0], "autoRestarted")
+}
+
+func TestSystemdRefresh_NewFailedService(t *testing.T) {
+	// GIVEN a refreshed plugin
+	conn := newFakeSystemdConn()
+	var events []map[string]interface{}
+	p := newTestSystemdPlugin(eventsRecorder(&events), conn)
+	require.NoError(t, p.refresh())
+
+	// WHEN a service not seen before is loaded already failed
+	conn.units = append(conn.units, dbus.UnitStatus{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"})
+	conn.serviceProps["backup.service"] = map[string]interface{}{"Result": "exit-code", "ExecMainStatus": int32(2)}
+	require.NoError(t, p.refresh())
+
+	// THEN its failure is reported
+	require.Len(t, events, 1)
+	assert.Equal(t, "backup.service", events[0]["unitName"])
+	assert.Equal(t, float64(2), events[0]["exitStatus"])
+}
+
+func TestSystemdRefresh_AutoRestartedService(t *testing.T) {
+	// GIVEN a refreshed plugin
+	conn := newFakeSystemdConn()
+	var events []map[string]interface{}
+	p := newTestSystemdPlugin(eventsRecorder(&events), conn)
+	require.NoError(t, p.refresh())
+
+	// WHEN a service fails and is restarted by systemd between refreshes
+	conn.serviceProps["sshd.service"]["NRestarts"] = uint32(3)
+	require.NoError(t, p.refresh())
+
+	// THEN its failure is reported
+	require.Len(t, events, 1)
+	assert.Equal(t, "sshd.service", events[0]["unitName"])
+	assert.Equal(t, float64(3), events[0]["restarts"])
+	assert.Equal(t, true, events[0]["autoRestarted"])
+}
+
+func TestSystemdSubStateUpdate(t *testing.T) {
+	// GIVEN a refreshed plugin
+	conn := newFakeSystemdConn()
+	var events []map[string]interface{}
+	p := newTestSystemdPlugin(eventsRecorder(&events), conn)
+	require.NoError(t, p.refresh())
+
+	// WHEN systemd signals a service failure
+	conn.unitProps["sshd.service"]["ActiveState"] = "failed"
+	conn.serviceProps["sshd.service"]["Result"] = "core-dump"
+	p.handleSubStateUpdate(&dbus.SubStateUpdate{UnitName: "sshd.service", SubState: "failed"})
+	// and the failure is still listed by the next refresh
+	conn.units[0].ActiveState = "failed"
+	conn.units[0].SubState = "failed"
+	require.NoError(t, p.refresh())
+
+	// THEN the failure is reported once, as signaled
+	require.Len(t, events, 1)
+	assert.Equal(t, "sshd.service", events[0]["unitName"])
+	assert.Equal(t, "core-dump", events[0]["result"])
+	// AND changes of other units are ignored
+	p.handleSubStateUpdate(&dbus.SubStateUpdate{UnitName: "-.mount", SubState: "failed"})
+	assert.Len(t, events, 1)
+}
+
+func TestSystemdRefresh_Reconnect(t *testing.T) {
+	// GIVEN a connection to systemd which is lost
+	lost := &fakeSystemdConn{}
+	conn := newFakeSystemdConn()
+	p := newTestSystemdPlugin(nil, lost)
+	p.connect = func() (systemdConn, error) { return conn, nil }
+
+	// WHEN refreshing
+	err := p.refresh()
+
+	// THEN the refresh fails and the connection is closed
+	require.Error(t, err)
+	assert.True(t, lost.closed)
+	// AND the next refresh connects again and subscribes to the unit changes
+	require.NoError(t, p.refresh())
+	assert.True(t, conn.subscribed)
+	assert.Len(t, p.services, 5)
 }
@@ -5,38 +5,93 @@ package socketapi
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net"
-	"strings"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 )
 
-const IntegrationName = "socket-api"
+const (
+	IntegrationName = "socket-api"
+
+	// DefaultMaxConnections is the default limit of concurrent client connections.
+	DefaultMaxConnections = 100
+	// DefaultMaxPayloadSize is the default limit, in bytes, of a single line payload.
+	DefaultMaxPayloadSize = 10 * 1024 * 1024
+
+	initialBufferSize = 64 * 1024
+
+	// socketFileMode restricts the Unix domain socket to the agent user and group.
+	socketFileMode os.FileMode = 0660
+
+	// delays between failed accepts, as net/http.Server does for temporary errors
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = 1 * time.Second
+
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+var (
+	errTooManyConnections = errors.New("too many connections")
+	errPayloadTooLarge    = errors.New("payload too large")
+)
+
+// Config socket API server configuration.
+type Config struct {
+	// Port is the TCP port to listen to. Ignored when SocketPath is set.
+	Port int
+	// SocketPath is the Unix domain socket path to listen to, instead of a TCP port.
+	SocketPath string
+	// MaxConnections limits the amount of concurrent client connections. Zero means DefaultMaxConnections.
+	MaxConnections int
+	// MaxPayloadSize limits the size in bytes of a single line payload. Zero means DefaultMaxPayloadSize.
+	MaxPayloadSize int
+}
+
+// Response is written back to the client as a JSON line for every received payload.
+type Response struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
 
 // Server runtime for socket API server.
+// Clients submit newline delimited integration payloads, each of them being acknowledged
+// by a Response line once the payload has been parsed and queued, or rejected.
 type Server struct {
-	port    int
+	cfg     Config
 	logger  log.Entry
 	emitter emitter.Emitter
 	readyCh chan struct{}
+	slots   chan struct{}
 }
 
 // NewServer creates a new socket API server.
-func NewServer(emitter emitter.Emitter, port int) *Server {
-	logger := log.WithComponent("Server")
+func NewServer(emitter emitter.Emitter, cfg Config) *Server {
+	if cfg.MaxConnections <= 0 {
+		cfg.MaxConnections = DefaultMaxConnections
+	}
+	if cfg.MaxPayloadSize <= 0 {
+		cfg.MaxPayloadSize = DefaultMaxPayloadSize
+	}
+
 	return &Server{
-		port:    port,
-		logger:  logger,
+		cfg:     cfg,
+		logger:  log.WithComponent("SocketAPI"),
 		emitter: emitter,
 		readyCh: make(chan struct{}),
+		slots:   make(chan struct{}, cfg.MaxConnections),
 	}
 }
 
-// Serve serves socket API requests.
+// Serve serves socket API requests until the context is cancelled.
 func (s *Server) Serve(ctx context.Context) {
 	def, err := integration.NewAPIDefinition(IntegrationName)
 	if err != nil {
@@ -44,56 +99,168 @@ func (s *Server) Serve(ctx context.Context) {
 		return
 	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	network, address := s.address()
+	llog := s.logger.WithField("network", network).WithField("address", address)
+
+	if network == "unix" {
+		removeStaleSocket(llog, address)
+	}
+
+	listener, err := net.Listen(network, address)
 	if err != nil {
-		s.logger.WithField("port", s.port).WithError(err).Error("trying to listen")
+		llog.WithError(err).Error("trying to listen")
 		return
 	}
-	defer listener.Close()
-
-	for {
-		select {
-		case <-ctx.Done():
+	if network == "unix" {
+		if err = os.Chmod(address, socketFileMode); err != nil {
+			llog.WithError(err).Error("cannot set socket file permissions")
+			_ = listener.Close()
 			return
-		default:
+		}
+	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		if err := listener.Close(); err != nil {
+			llog.WithError(err).Debug("cannot close listener")
 		}
+	}()
+
+	close(s.readyCh)
+	llog.Debug("Socket API listening.")
 
-		// this is a PoC! just handling 1 connection fir this purpose is enough
-		close(s.readyCh)
+	var connsWg sync.WaitGroup
+	defer connsWg.Wait()
+
+	var acceptDelay time.Duration
+	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			s.logger.WithField("port", s.port).WithError(err).Error("cannot accept connection")
-		}
-		defer func() {
-			if err = conn.Close(); err != nil {
-				s.logger.WithError(err).Error("cannot close connection")
+			if ctx.Err() != nil {
+				return
 			}
-		}()
-
-		r := bufio.NewReader(conn)
-		for {
-			line, err := r.ReadString('\n')
-			if err == io.EOF {
-				break
+			if acceptDelay == 0 {
+				acceptDelay = minAcceptDelay
+			} else if acceptDelay *= 2; acceptDelay > maxAcceptDelay {
+				acceptDelay = maxAcceptDelay
 			}
-
-			if err != nil {
-				s.logger.WithError(err).Warn("cannot read connection")
-				break
+			llog.WithError(err).WithField("retry_in", acceptDelay).Error("cannot accept connection")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(acceptDelay):
 			}
+			continue
+		}
+		acceptDelay = 0
 
-			line = strings.TrimSuffix(line, "\n")
-
-			err = s.emitter.Emit(def, nil, nil, []byte(line))
-			if err != nil {
-				s.logger.WithError(err).Error("cannot emit payload")
-			}
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			llog.WithField("max_connections", s.cfg.MaxConnections).Warn("rejecting connection, limit reached")
+			s.reject(conn, errTooManyConnections)
+			continue
 		}
+
+		connsWg.Add(1)
+		go func() {
+			defer connsWg.Done()
+			defer func() { <-s.slots }()
+			s.handleConnection(ctx, def, conn)
+		}()
 	}
 }
 
 // WaitUntilReady blocks the call until server is ready to accept connections.
 func (s *Server) WaitUntilReady() {
-	_, _ = <-s.readyCh
+	<-s.readyCh
+}
+
+func (s *Server) address() (network, address string) {
+	if s.cfg.SocketPath != "" {
+		return "unix", s.cfg.SocketPath
+	}
+	return "tcp", fmt.Sprintf(":%d", s.cfg.Port)
+}
+
+// removeStaleSocket removes the socket file left by a previous unclean shutdown. Other files are kept, so
+// listening fails instead of removing a file that doesn't belong to the agent.
+func removeStaleSocket(llog log.Entry, path string) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		llog.WithError(err).Warn("cannot check existing socket file")
+		return
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		llog.WithField("mode", info.Mode().String()).Warn("existing file is not a socket, not removing it")
+		return
+	}
+	if err = os.Remove(path); err != nil {
+		llog.WithError(err).Warn("cannot remove existing socket file")
+	}
+}
+
+// handleConnection reads line payloads from a single client connection, acknowledging each of them.
+func (s *Server) handleConnection(ctx context.Context, def integration.Definition, conn net.Conn) {
+	clog := s.logger.WithField("remote_address", conn.RemoteAddr().String())
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// unblocks the reader on shutdown
+		<-connCtx.Done()
+		if err := conn.Close(); err != nil {
+			clog.WithError(err).Debug("cannot close connection")
+		}
+	}()
+
+	// the max token size is the larger of the initial buffer capacity and the max size
+	bufSize := initialBufferSize
+	if bufSize > s.cfg.MaxPayloadSize {
+		bufSize = s.cfg.MaxPayloadSize
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, bufSize), s.cfg.MaxPayloadSize)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		resp := Response{Status: statusOK}
+		if err := s.emitter.Emit(def, nil, nil, line); err != nil {
+			clog.WithError(err).Warn("cannot emit payload")
+			resp = Response{Status: statusError, Error: err.Error()}
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			clog.WithError(err).Debug("cannot write response")
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil && connCtx.Err() == nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = errPayloadTooLarge
+			_ = encoder.Encode(Response{Status: statusError, Error: err.Error()})
+		}
+		clog.WithError(err).Warn("cannot read connection")
+	}
+}
+
+// reject notifies the client about the error and closes the connection.
+func (s *Server) reject(conn net.Conn, reason error) {
+	if err := json.NewEncoder(conn).Encode(Response{Status: statusError, Error: reason.Error()}); err != nil {
+		s.logger.WithError(err).Debug("cannot write response")
+	}
+	if err := conn.Close(); err != nil {
+		s.logger.WithError(err).Debug("cannot close connection")
+	}
 }
@@ -3,10 +3,16 @@
 package socketapi
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,25 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestPayloadFwServer_Serve(t *testing.T) {
-	t.Skipf("because time race, as WaitUntilReady is not right")
-
-	port, err := network_helpers.TCPPort()
-	require.NoError(t, err)
-
-	e := &testemit.RecordEmitter{}
-	pf := NewServer(e, port)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go pf.Serve(ctx)
-
-	payloadWritten := make(chan struct{})
-	go func() {
-		pf.WaitUntilReady()
-		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
-		require.NoError(t, err)
-		_, err = conn.Write([]byte(strings.Replace(`{
+var payload = strings.Replace(`{
   "protocol_version": "4",
   "integration": {
     "name": "com.newrelic.foo",
@@ -51,7 +39,25 @@ func TestPayloadFwServer_Serve(t *testing.T) {
       }
     }
   ]
-}`, "\n", "", -1) + "\n"))
+}`, "\n", "", -1) + "\n"
+
+func TestPayloadFwServer_Serve(t *testing.T) {
+	port, err := network_helpers.TCPPort()
+	require.NoError(t, err)
+
+	e := &testemit.RecordEmitter{}
+	pf := NewServer(e, Config{Port: port})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go pf.Serve(ctx)
+
+	payloadWritten := make(chan struct{})
+	go func() {
+		pf.WaitUntilReady()
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		require.NoError(t, err)
+		_, err = conn.Write([]byte(payload))
 		assert.NoError(t, err)
 		close(payloadWritten)
 	}()
@@ -68,6 +74,219 @@ func TestPayloadFwServer_Serve(t *testing.T) {
 	assert.NotEmpty(t, d)
 }
 
+func TestServe_AcknowledgesEachPayload(t *testing.T) {
+	port, err := network_helpers.TCPPort()
+	require.NoError(t, err)
+
+	e := &testemit.RecordEmitter{}
+	s := NewServer(e, Config{Port: port})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.WaitUntilReady()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// valid payload is acknowledged
+	_, err = conn.Write([]byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, Response{Status: statusOK}, readResponse(t, r))
+
+	// invalid payload is rejected, but connection is kept open
+	_, err = conn.Write([]byte("{not json\n"))
+	require.NoError(t, err)
+	resp := readResponse(t, r)
+	assert.Equal(t, statusError, resp.Status)
+	assert.NotEmpty(t, resp.Error)
+
+	_, err = conn.Write([]byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, Response{Status: statusOK}, readResponse(t, r))
+}
+
+func TestServe_ConcurrentClients(t *testing.T) {
+	port, err := network_helpers.TCPPort()
+	require.NoError(t, err)
+
+	e := &testemit.RecordEmitter{}
+	s := NewServer(e, Config{Port: port})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.WaitUntilReady()
+
+	const clients = 10
+	const payloadsPerClient = 5
+
+	// all the clients keep their connection open at the same time
+	conns := make([]net.Conn, clients)
+	for i := range conns {
+		conns[i], err = net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		require.NoError(t, err)
+		defer conns[i].Close()
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(clients)
+	for _, conn := range conns {
+		go func(conn net.Conn) {
+			defer wg.Done()
+			r := bufio.NewReader(conn)
+			for i := 0; i < payloadsPerClient; i++ {
+				_, err := conn.Write([]byte(payload))
+				assert.NoError(t, err)
+				assert.Equal(t, Response{Status: statusOK}, readResponse(t, r))
+			}
+		}(conn)
+	}
+
+	received := 0
+	for received < clients*payloadsPerClient {
+		_, err := e.ReceiveFrom(IntegrationName)
+		require.NoError(t, err)
+		received++
+	}
+	wg.Wait()
+}
+
+func TestServe_MaxConnections(t *testing.T) {
+	port, err := network_helpers.TCPPort()
+	require.NoError(t, err)
+
+	s := NewServer(&testemit.RecordEmitter{}, Config{Port: port, MaxConnections: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.WaitUntilReady()
+
+	// GIVEN a connected client using the only available connection
+	first, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = first.Write([]byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, Response{Status: statusOK}, readResponse(t, bufio.NewReader(first)))
+
+	// WHEN another client connects
+	second, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	require.NoError(t, err)
+	defer second.Close()
+
+	// THEN it is rejected
+	assert.Equal(t, Response{Status: statusError, Error: errTooManyConnections.Error()}, readResponse(t, bufio.NewReader(second)))
+}
+
+func TestServe_PayloadTooLarge(t *testing.T) {
+	port, err := network_helpers.TCPPort()
+	require.NoError(t, err)
+
+	s := NewServer(&testemit.RecordEmitter{}, Config{Port: port, MaxPayloadSize: 16})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.WaitUntilReady()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, Response{Status: statusError, Error: errPayloadTooLarge.Error()}, readResponse(t, bufio.NewReader(conn)))
+}
+
+func TestServe_UnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets not tested on windows")
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "socket-api.sock")
+
+	e := &testemit.RecordEmitter{}
+	s := NewServer(e, Config{SocketPath: socketPath})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Serve(ctx)
+	s.WaitUntilReady()
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, Response{Status: statusOK}, readResponse(t, bufio.NewReader(conn)))
+
+	d, err := e.ReceiveFrom(IntegrationName)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, d)
+}
+
+func TestServe_UnixSocket_ReplacesStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets not tested on windows")
+	}
+
+	// GIVEN a socket file left by a previous run
+	socketPath := filepath.Join(t.TempDir(), "socket-api.sock")
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	// WHEN the server starts
+	s := NewServer(&testemit.RecordEmitter{}, Config{SocketPath: socketPath})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx)
+	s.WaitUntilReady()
+
+	// THEN it listens on a new socket, only accessible to the agent user and group
+	info, err := os.Lstat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSocket|socketFileMode, info.Mode()&(os.ModeSocket|os.ModePerm))
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestServe_UnixSocket_KeepsOtherFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets not tested on windows")
+	}
+
+	// GIVEN a regular file at the socket path
+	socketPath := filepath.Join(t.TempDir(), "socket-api.sock")
+	require.NoError(t, os.WriteFile(socketPath, []byte("data"), 0644))
+
+	// WHEN the server is started
+	s := NewServer(&testemit.RecordEmitter{}, Config{SocketPath: socketPath})
+	s.Serve(context.Background())
+
+	// THEN it doesn't listen, and the file is kept
+	content, err := os.ReadFile(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) (resp Response) {
+	t.Helper()
+
+	line, err := r.ReadBytes('\n')
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(line, &resp))
+
+	return
+}
+
 var il = integration.InstancesLookup{
 	Legacy: func(_ integration.DefinitionCommandConfig) (integration.Definition, error) {
 		return integration.Definition{Name: "bar"}, nil
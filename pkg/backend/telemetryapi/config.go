@@ -10,6 +10,8 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/diskqueue"
 )
 
 const (
@@ -70,6 +72,9 @@ type Config struct {
 	// MaxEntitiesPerBatch limits the total of metrics to queue
 	// If zero, DefaultMaxEntitiesPerBatch is used (1000 entities).
 	MaxEntitiesPerBatch int
+	// PersistentQueue stores the harvested requests before submitting them, so they are kept
+	// during backend outages and restarts. If nil, requests are only retried in memory.
+	PersistentQueue *diskqueue.Queue
 }
 
 // ConfigAPIKey sets the Config's APIKey which is required and refers to your
@@ -112,6 +117,14 @@ func ConfigMaxEntitiesPerBatch(n int) func(*Config) {
 	}
 }
 
+// ConfigPersistentQueue sets the Config's PersistentQueue field which stores the harvested
+// requests on disk, replaying them in order until they are accepted.
+func ConfigPersistentQueue(queue *diskqueue.Queue) func(*Config) {
+	return func(cfg *Config) {
+		cfg.PersistentQueue = queue
+	}
+}
+
 // ConfigHarvestPeriod sets the Config's HarvestPeriod field which controls the
 // rate data is reported to New Relic.  If it is set to zero then the Harvester
 // will never report data unless HarvestNow is called.
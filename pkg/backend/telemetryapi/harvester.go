@@ -118,6 +118,10 @@ func (h *Harvester) Start() {
 		go harvestRoutine(h)
 	}
 
+	if h.config.PersistentQueue != nil {
+		go h.replayRequests()
+	}
+
 	for i := 1; i <= h.config.MaxConns; i++ {
 		go func(workerNo int) {
 			wlog := logger.WithField("WorkerNo.", workerNo)
@@ -462,6 +466,16 @@ func (h *Harvester) HarvestNow(ct context.Context) {
 	reqs = append(reqs, h.swapOutBatchMetrics(ctx)...)
 
 	for _, req := range reqs {
+		if h.config.PersistentQueue != nil {
+			err := h.persistRequest(req)
+			if err == nil {
+				continue
+			}
+			h.config.logError(map[string]interface{}{
+				"err":     err.Error(),
+				"message": "cannot persist request, submitting it directly",
+			})
+		}
 		h.requestsQueue <- req
 		if err := ctx.Err(); err != nil {
 			// NOTE: It is possible that the context was
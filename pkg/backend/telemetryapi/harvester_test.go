@@ -18,6 +18,11 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/diskqueue"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/telemetryapi/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // compactJSONString removes the whitespace from a JSON string.  This function
@@ -779,3 +784,97 @@ func BenchmarkRetryBody1(b *testing.B) { benchmarkRetryBodyN(b, 1) }
 func BenchmarkRetryBody2(b *testing.B) { benchmarkRetryBodyN(b, 2) }
 func BenchmarkRetryBody4(b *testing.B) { benchmarkRetryBodyN(b, 4) }
 func BenchmarkRetryBody8(b *testing.B) { benchmarkRetryBodyN(b, 8) }
+
+func TestHarvester_PersistentQueue(t *testing.T) {
+	queue, err := diskqueue.Open(diskqueue.Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer queue.Close()
+
+	// GIVEN a backend that is not available on the first attempt
+	var attempts int32
+	posted := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h, err := NewHarvester(func(cfg *Config) {
+		cfg.HarvestPeriod = 0
+		cfg.APIKey = "APIKey"
+		cfg.Context = ctx
+		cfg.PersistentQueue = queue
+		cfg.Client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return emptyResponse(503), nil
+			}
+			assert.Equal(t, "APIKey", req.Header.Get("Api-Key"))
+			assert.Equal(t, "entity-1", req.Header.Get(entityIDsHeader))
+			body, err := internal.Uncompress(readAll(t, req))
+			require.NoError(t, err)
+			posted <- string(body)
+			return emptyResponse(202), nil
+		})
+	})
+	require.NoError(t, err)
+
+	// WHEN metrics are harvested
+	require.NoError(t, h.RecordInfraMetrics(Attributes{nrEntityID: "entity-1"}, []Metric{Gauge{Name: "metric", Timestamp: time.Now()}}))
+	h.HarvestNow(context.Background())
+
+	// THEN the request is replayed from the persistent queue until it is accepted
+	select {
+	case body := <-posted:
+		assert.Contains(t, body, `"name":"metric"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not submitted")
+	}
+	assert.Eventually(t, func() bool { return queue.Len() == 0 }, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+}
+
+func TestHarvester_PersistentQueue_ReplaysOnStart(t *testing.T) {
+	dir := t.TempDir()
+
+	// GIVEN a request persisted by a previous run
+	queue, err := diskqueue.Open(diskqueue.Config{Dir: dir})
+	require.NoError(t, err)
+	compressed, err := internal.Compress([]byte(`[{"metrics":[]}]`))
+	require.NoError(t, err)
+	payload, err := json.Marshal(persistedRequest{URL: "https://metric-api.newrelic.com/metric/v1", Body: compressed.Bytes()})
+	require.NoError(t, err)
+	require.NoError(t, queue.Push(payload))
+	require.NoError(t, queue.Close())
+
+	queue, err = diskqueue.Open(diskqueue.Config{Dir: dir})
+	require.NoError(t, err)
+	defer queue.Close()
+
+	// WHEN the harvester starts
+	posted := make(chan *http.Request, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = NewHarvester(func(cfg *Config) {
+		cfg.HarvestPeriod = 0
+		cfg.APIKey = "APIKey"
+		cfg.Context = ctx
+		cfg.PersistentQueue = queue
+		cfg.Client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			posted <- req
+			return emptyResponse(202), nil
+		})
+	})
+	require.NoError(t, err)
+
+	// THEN the persisted request is submitted
+	select {
+	case req := <-posted:
+		assert.Equal(t, "https://metric-api.newrelic.com/metric/v1", req.URL.String())
+		assert.Equal(t, "APIKey", req.Header.Get("Api-Key"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("persisted request was not submitted")
+	}
+	assert.Eventually(t, func() bool { return queue.Len() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func readAll(t *testing.T, req *http.Request) []byte {
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	return body
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetryapi
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/diskqueue"
+)
+
+const (
+	// persistentQueueReadRetries is the amount of consecutive failed reads before the unreadable segment is skipped.
+	persistentQueueReadRetries = 3
+	persistentQueueReadBackoff = 5 * time.Second
+)
+
+// persistedRequest is the on-disk representation of a request. The API key is not persisted, it's
+// added again when the request is replayed.
+type persistedRequest struct {
+	URL       string `json:"url"`
+	EntityIDs string `json:"entityIDs,omitempty"`
+	Body      []byte `json:"body"` // gzip compressed
+}
+
+// persistRequest stores the request into the persistent queue, to be submitted by replayRequests.
+func (h *Harvester) persistRequest(req request) error {
+	payload, err := json.Marshal(persistedRequest{
+		URL:       req.Request.URL.String(),
+		EntityIDs: req.Request.Header.Get(entityIDsHeader),
+		Body:      req.compressedBody,
+	})
+	if err != nil {
+		return err
+	}
+	return h.config.PersistentQueue.Push(payload)
+}
+
+// replayRequests submits the persisted requests in order, removing them from the persistent queue once
+// they are accepted or rejected as invalid. Failing requests are retried until the harvester is cancelled.
+func (h *Harvester) replayRequests() {
+	queue := h.config.PersistentQueue
+	ctx := h.config.Context
+	readFailures := 0
+	for {
+		payload, err := queue.Peek()
+		if errors.Is(err, diskqueue.ErrEmpty) {
+			select {
+			case <-queue.Notify():
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			readFailures++
+			if readFailures < persistentQueueReadRetries {
+				logger.WithError(err).Warn("cannot read persisted metrics request")
+			} else {
+				logger.WithError(err).Warn("cannot read persisted metrics requests, discarding them")
+				if err = queue.Skip(); err != nil {
+					logger.WithError(err).Warn("cannot discard persisted metrics requests")
+				}
+				readFailures = 0
+			}
+			tmr := time.NewTimer(persistentQueueReadBackoff)
+			select {
+			case <-tmr.C:
+				continue
+			case <-ctx.Done():
+				tmr.Stop()
+				return
+			}
+		}
+		readFailures = 0
+
+		var pr persistedRequest
+		if err = json.Unmarshal(payload, &pr); err != nil {
+			logger.WithError(err).Warn("cannot unmarshal persisted metrics request, discarding it")
+		} else if req, err := createCompressedRequest(ctx, nil, pr.Body, h.config.APIKey, pr.URL, h.config.userAgent()); err != nil {
+			logger.WithError(err).Warn("cannot create persisted metrics request, discarding it")
+		} else {
+			if pr.EntityIDs != "" {
+				req.Request.Header.Add(entityIDsHeader, pr.EntityIDs)
+			}
+			// retries until the request is accepted, rejected or the harvester is cancelled
+			harvestRequest(req, &h.config)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		if err = queue.Ack(); err != nil {
+			// only persisting the read cursor failed, the request is not read again
+			logger.WithError(err).Warn("cannot remove persisted metrics request")
+		}
+	}
+}
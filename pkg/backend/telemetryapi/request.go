@@ -15,6 +15,7 @@ import (
 
 const (
 	maxCompressedSizeBytes = 1 << 20
+	entityIDsHeader        = "X-NRI-Entity-Ids"
 )
 
 // request contains an http.Request and the UncompressedBody which is provided
@@ -100,7 +101,7 @@ func buildRequests(ctx context.Context, metricsBatch []metricBatch, apiKey strin
 
 	jsonPayload := string(buf.Bytes())
 	logger.WithTraceField("json", jsonPayload).Debug("Request created")
-	req.Request.Header.Add("X-NRI-Entity-Ids", entityIds)
+	req.Request.Header.Add(entityIDsHeader, entityIds)
 	return []request{req}, err
 }
 
@@ -117,9 +118,12 @@ func createRequest(ctx context.Context, rawJSON json.RawMessage, apiKey string,
 	if nil != err {
 		return req, fmt.Errorf("error compressing data: %v", err)
 	}
-	compressedLen := compressed.Len()
+	return createCompressedRequest(ctx, rawJSON, compressed.Bytes(), apiKey, url, userAgent)
+}
 
-	reqHTTP, err := http.NewRequest("POST", url, compressed)
+// createCompressedRequest creates a request for an already compressed body. The uncompressed body is only used for logging.
+func createCompressedRequest(ctx context.Context, rawJSON json.RawMessage, compressed []byte, apiKey string, url string, userAgent string) (req request, err error) {
+	reqHTTP, err := http.NewRequest("POST", url, bytes.NewReader(compressed))
 	if nil != err {
 		return req, fmt.Errorf("error creating request: %v", err)
 	}
@@ -130,8 +134,8 @@ func createRequest(ctx context.Context, rawJSON json.RawMessage, apiKey string,
 	req = request{
 		Request:              reqHTTP.WithContext(ctx),
 		UncompressedBody:     rawJSON,
-		compressedBody:       compressed.Bytes(),
-		compressedBodyLength: compressedLen,
+		compressedBody:       compressed,
+		compressedBodyLength: len(compressed),
 	}
 	return req, err
 }
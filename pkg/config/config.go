@@ -73,6 +73,13 @@ const (
 	LogLevelTrace string = "trace"
 )
 
+const (
+	// NtpModePool queries the offset from the configured NTP pool.
+	NtpModePool = "pool"
+	// NtpModeLocal reads the synchronization status of the host time daemon.
+	NtpModeLocal = "local"
+)
+
 type CustomAttributeMap map[string]interface{}
 
 var clog = log.WithComponent("Configuration")
@@ -145,6 +152,19 @@ type Config struct {
 	// Public: No
 	CommandChannelIntervalSec int `yaml:"command_channel_interval_sec" envconfig:"command_channel_interval_sec" public:"false"`
 
+	// DiagnosticsDir is the directory where the diagnostics bundles requested through the command channel
+	// `collect_diagnostics` command are stored. Only the most recent bundles are kept.
+	// Default: Empty
+	// Public: Yes
+	DiagnosticsDir string `yaml:"diagnostics_dir" envconfig:"diagnostics_dir"`
+
+	// DiagnosticsEndpoint is the URL where the diagnostics bundles requested through the command channel
+	// `collect_diagnostics` command are submitted through an HTTP POST. Requests are rejected when neither
+	// this or diagnostics_dir are set.
+	// Default: Empty
+	// Public: Yes
+	DiagnosticsEndpoint string `yaml:"diagnostics_endpoint" envconfig:"diagnostics_endpoint"`
+
 	// IgnoreSystemProxy makes `HTTPS_PROXY` and `HTTP_PROXY` environment variables to be ignored, in case the Agent
 	// requires to not using an existing system proxy, and connect directly to the New Relic metrics collector.
 	// Default: False
@@ -442,6 +462,19 @@ type Config struct {
 	// Public: Yes
 	FileDevicesIgnored []string `yaml:"file_devices_ignored" envconfig:"file_devices_ignored"`
 
+	// StorageIncludeMountPatterns List of mount point glob patterns to be reported by the StorageSamples on Linux
+	// regardless of their filesystem type, as tmpfs or overlay mounts, i.e. "/dev/shm" or
+	// "/var/lib/docker/overlay2/*/merged".
+	// Default: Empty
+	// Public: Yes
+	StorageIncludeMountPatterns []string `yaml:"storage_include_mount_patterns" envconfig:"storage_include_mount_patterns"`
+
+	// StorageExcludeMountPatterns List of mount point glob patterns not to be reported by the StorageSamples on
+	// Linux, taking precedence over the supported filesystems and the include patterns, i.e. "/mnt/backup*".
+	// Default: Empty
+	// Public: Yes
+	StorageExcludeMountPatterns []string `yaml:"storage_exclude_mount_patterns" envconfig:"storage_exclude_mount_patterns"`
+
 	// NetworkInterfaceFilters You can use the network interface filters configuration to hide unused or uninteresting
 	// network interfaces from the Infrastructure agent. This helps reduce resource usage, work, and noise in your data.
 	// Default: Empty
@@ -489,6 +522,37 @@ type Config struct {
 	// Public: Yes
 	DpkgRefreshSec int64 `yaml:"dpkg_interval_sec" envconfig:"dpkg_interval_sec"`
 
+	// ApkRefreshSec Sampling period / interval in seconds for Apk plugin. Set as value -1 for disabling it.
+	// 30 is the minimum value. Only activated in root or privileged modes and on Alpine.
+	// Default: 30
+	// Public: Yes
+	ApkRefreshSec int64 `yaml:"apk_interval_sec" envconfig:"apk_interval_sec" os:"linux"`
+
+	// SnapRefreshSec Sampling period / interval in seconds for Snap plugin. Set as value -1 for disabling it.
+	// 30 is the minimum value. Only activated in root or privileged modes and when snapd is installed.
+	// Default: 30
+	// Public: Yes
+	SnapRefreshSec int64 `yaml:"snap_interval_sec" envconfig:"snap_interval_sec" os:"linux"`
+
+	// FlatpakRefreshSec Sampling period / interval in seconds for Flatpak plugin, which reports the system wide
+	// installed applications and runtimes. Set as value -1 for disabling it. 30 is the minimum value. Only
+	// activated in root or privileged modes and when flatpak is installed.
+	// Default: 30
+	// Public: Yes
+	FlatpakRefreshSec int64 `yaml:"flatpak_interval_sec" envconfig:"flatpak_interval_sec" os:"linux"`
+
+	// EnableLanguagePackagesPlugin enables the plugins reporting the Python packages installed in the system
+	// site-packages directories and the global npm packages.
+	// Default: False
+	// Public: Yes
+	EnableLanguagePackagesPlugin bool `yaml:"enable_language_packages_plugin" envconfig:"enable_language_packages_plugin" os:"linux"`
+
+	// LanguagePackagesRefreshSec Sampling period / interval in seconds for the pip and npm plugins. Set as value
+	// -1 for disabling them. 30 is the minimum value.
+	// Default: 30
+	// Public: Yes
+	LanguagePackagesRefreshSec int64 `yaml:"language_packages_interval_sec" envconfig:"language_packages_interval_sec" os:"linux"`
+
 	// DaemontoolsRefreshSec Sampling period / interval in seconds for Daemontools plugin. Set as value -1 for
 	// disabling it. 10 is the minimum value
 	// Default: 15
@@ -578,6 +642,31 @@ type Config struct {
 	// Public: Yes
 	SshdConfigRefreshSec int64 `yaml:"sshd_config_refresh_sec" envconfig:"sshd_config_refresh_sec"`
 
+	// EnableFirewallPlugin enables the Firewall plugin, which reports the nftables ruleset, or the iptables one
+	// when nftables is not available. This plugin can be activated only in root mode.
+	// Default: False
+	// Public: Yes
+	EnableFirewallPlugin bool `yaml:"enable_firewall_plugin" envconfig:"enable_firewall_plugin" os:"linux"`
+
+	// FirewallRefreshSec Sampling period / interval in seconds for Firewall plugin, when enabled. Set as value -1
+	// for disabling it. 30 is the minimum value.
+	// Default: 60
+	// Public: Yes
+	FirewallRefreshSec int64 `yaml:"firewall_refresh_sec" envconfig:"firewall_refresh_sec" os:"linux"`
+
+	// CertificatesRefreshSec Sampling period / interval in seconds for Certificates plugin. Set as value -1
+	// for disabling it. 30 is the minimum value.
+	// Default: 3600
+	// Public: Yes
+	CertificatesRefreshSec int64 `yaml:"certificates_refresh_sec" envconfig:"certificates_refresh_sec" os:"linux"`
+
+	// CertificatesPaths List of glob patterns of the PEM, DER and Java KeyStore certificate files to be
+	// inventoried by the Certificates plugin. Trust store bundles shouldn't be included, as every CA certificate
+	// in them would be reported.
+	// Default: /etc/ssl/*.pem, /etc/ssl/*.crt, /etc/pki/tls/certs/*.pem, /etc/pki/tls/certs/*.crt
+	// Public: Yes
+	CertificatesPaths []string `yaml:"certificates_paths" envconfig:"certificates_paths" os:"linux"`
+
 	// WindowsServicesRefreshSec Sampling period / interval in seconds for WindowsServices plugin. Set as value -1
 	// for disabling it. 10 is the minimum value.
 	// Default: 30
@@ -668,6 +757,25 @@ type Config struct {
 	// Public: No
 	BatchQueueDepth int `yaml:"batch_queue_depth" envconfig:"batch_queue_depth" public:"false"` // See event_sender.go
 
+	// PersistentQueueEnabled stores the metric event batches and the dimensional metrics requests into on-disk
+	// queues under the agent data directory before submitting them, so they are kept during backend outages and
+	// agent restarts, and replayed in order once the backend is reachable.
+	// Default: False
+	// Public: Yes
+	PersistentQueueEnabled bool `yaml:"persistent_queue_enabled" envconfig:"persistent_queue_enabled"`
+
+	// PersistentQueueMaxBytes limits the on-disk size of each persistent queue. The oldest batches are dropped
+	// when exceeded.
+	// Default: 104857600
+	// Public: Yes
+	PersistentQueueMaxBytes int64 `yaml:"persistent_queue_max_bytes" envconfig:"persistent_queue_max_bytes"`
+
+	// PersistentQueueMaxAgeSec drops persisted batches older than this amount of seconds instead of submitting them.
+	// Zero value disables the age limit.
+	// Default: 86400
+	// Public: Yes
+	PersistentQueueMaxAgeSec int `yaml:"persistent_queue_max_age_sec" envconfig:"persistent_queue_max_age_sec"`
+
 	// InventoryQueueLen sets the inventory processing queue size. Zero value makes inventory processing synchronous (blocking call).
 	// Default: 0
 	// Public: Yes
@@ -825,6 +933,7 @@ type Config struct {
 
 	// HTTPServerEnabled By setting true this configuration parameter (used by statsD integration v1) the agent will
 	//	// open HTTP port (by default, 8001) to receive integration payloads via HTTP.
+	// OpenTelemetry metrics are also accepted as OTLP/HTTP protobuf or JSON requests on /v1/otlp/metrics.
 	// Default: False
 	// Public: Yes
 	HTTPServerEnabled bool `yaml:"http_server_enabled" envconfig:"http_server_enabled"`
@@ -840,6 +949,12 @@ type Config struct {
 	// Public: Yes
 	HTTPServerPort int `yaml:"http_server_port" envconfig:"http_server_port"`
 
+	// HTTPServerOTLPMaxPayloadSize Maximum size in bytes of an OTLP request body received by the http server, both
+	// as received and once decompressed. Larger requests are rejected with a 413 response.
+	// Default: 10485760
+	// Public: Yes
+	HTTPServerOTLPMaxPayloadSize int64 `yaml:"http_server_otlp_max_payload_size" envconfig:"http_server_otlp_max_payload_size"`
+
 	// HTTPServerCert Path to a PEM-encoded certificate to listen for integration payloads over HTTPs.
 	HTTPServerCert string `yaml:"http_server_cert" envconfig:"http_server_cert"`
 
@@ -860,6 +975,23 @@ type Config struct {
 	// Public: Yes
 	TCPServerPort int `yaml:"tcp_server_port" envconfig:"tcp_server_port"`
 
+	// TCPServerSocketPath Set a Unix domain socket path for the tcp server to listen to, instead of the
+	// tcp_server_port.
+	// Default: empty
+	// Public: Yes
+	TCPServerSocketPath string `yaml:"tcp_server_socket_path" envconfig:"tcp_server_socket_path"`
+
+	// TCPServerMaxConnections Maximum number of concurrent client connections accepted by the tcp server.
+	// Further connections are rejected with an error response.
+	// Default: 100
+	// Public: Yes
+	TCPServerMaxConnections int `yaml:"tcp_server_max_connections" envconfig:"tcp_server_max_connections"`
+
+	// TCPServerMaxPayloadSize Maximum size in bytes of a single line payload received by the tcp server.
+	// Default: 10485760
+	// Public: Yes
+	TCPServerMaxPayloadSize int `yaml:"tcp_server_max_payload_size" envconfig:"tcp_server_max_payload_size"`
+
 	// StatusServerEnabled will listen into TCP port (status_server_port) to serve status requests.
 	// Default: False
 	// Public: Yes
@@ -992,6 +1124,18 @@ type Config struct {
 	// Public: No
 	FilesConfigOn bool `yaml:"files_config_enabled" envconfig:"files_config_enabled" public:"false"`
 
+	// FilesConfigDiffMaxSize Maximum size in bytes of the unified diff reported in the events of the configuration
+	// file monitoring when a file changes. Longer diffs are truncated. Set as value 0 for not reporting diffs.
+	// Default: 4000
+	// Public: No
+	FilesConfigDiffMaxSize int `yaml:"files_config_diff_max_size" envconfig:"files_config_diff_max_size" public:"false"`
+
+	// FilesConfigRedactPatterns List of regular expressions of the content to be replaced by "[REDACTED]" in the
+	// diffs reported by the configuration file monitoring, as passwords or tokens.
+	// Default: Values following password, secret, token or api_key keys, and crypt(3) password hashes
+	// Public: No
+	FilesConfigRedactPatterns []string `yaml:"files_config_redact_patterns" envconfig:"files_config_redact_patterns" public:"false"`
+
 	// DebugLogSec Value in seconds. It defines the frequency we report the memory stats
 	// Default: 600
 	// Public: No
@@ -1098,16 +1242,51 @@ type Config struct {
 	// Public: Yes
 	EnableProcessMetrics *bool `yaml:"enable_process_metrics" envconfig:"enable_process_metrics"`
 
+	// EnableProcessNetworkMetrics adds the listeningPorts, tcpConnectionCount and establishedConnectionCount
+	// attributes to the ProcessSample on Linux. It requires the agent to run in root or privileged mode.
+	// Default: False
+	// Public: Yes
+	EnableProcessNetworkMetrics bool `yaml:"enable_process_network_metrics" envconfig:"enable_process_network_metrics"`
+
+	// ProcessNetworkMetricsMaxFds bounds the cost of the process network metrics: it's the maximum number of file
+	// descriptors inspected in each process sampling cycle. Processes sampled once it's reached are reported without
+	// the network attributes.
+	// Default: 100000
+	// Public: Yes
+	ProcessNetworkMetricsMaxFds int `yaml:"process_network_metrics_max_fds" envconfig:"process_network_metrics_max_fds"`
+
+	// ProcessLifecycleEvents reports on Linux an InfrastructureEvent, with the "process" category, when a process
+	// starts or exits between two process sampling cycles, or is killed by the kernel OOM killer.
+	// Key-value can be any of the following:
+	// "enabled: boolean" flag to enable/disable the ProcessStartEvent and ProcessExitEvent (Default: false)
+	// "match: map" expressions selecting the processes to report, with the include_matching_metrics format,
+	//    i.e. process.name: [regex "^java"]. All the processes are reported when empty (Default: {})
+	// "oom_kill: boolean" flag to report an OomKillEvent for the processes killed by the OOM killer, as read from
+	//    /dev/kmsg. It requires the agent to run in root or privileged mode (Default: false)
+	// Default: none
+	// Public: Yes
+	ProcessLifecycleEvents ProcessLifecycleConfig `yaml:"process_lifecycle_events" envconfig:"process_lifecycle_events"`
+
 	// IncludeMetricsMatchers Configuration of the metrics matchers that determine which metric data should the agent
 	// send to the New Relic backend.
 	// If no configuration is defined, the previous behaviour is maintained, i.e., every metric data captured is sent.
 	// If a configuration is defined, then only metric data matching the configuration is sent.
 	// Note that ALL DATA NOT MATCHED WILL BE DROPPED.
-	// Also note that at present it ONLY APPLIES to metric data related to processes. All other metric data is still being sent as usual.
+	// Keys are "<sample>.<attribute>", where sample is one of process, storage, network or system, and the attribute
+	// is any sample attribute (i.e. process.cpuPercent or storage.mountPoint). Values are literals, regular expressions
+	// (regex "^java") or numeric comparisons (> 5). Rules only apply to the samples they refer to, so metric data
+	// without rules is still being sent as usual.
 	// Default: none
 	// Public: Yes
 	IncludeMetricsMatchers IncludeMetricsMap `yaml:"include_matching_metrics" envconfig:"include_matching_metrics"`
 
+	// ExcludeMetricsMatchers Configuration of the metrics matchers that determine which metric data should NOT be
+	// sent to the New Relic backend. It uses the same format than include_matching_metrics, and it's applied after it,
+	// so metric data matching any of these rules is always dropped.
+	// Default: none
+	// Public: Yes
+	ExcludeMetricsMatchers IncludeMetricsMap `yaml:"exclude_matching_metrics" envconfig:"exclude_matching_metrics"`
+
 	// AgentMetricsEndpoint Set the endpoint (host:port) for the HTTP server the agent will use to server OpenMetrics
 	// if empty the server will be not spawned
 	// Default: empty
@@ -1134,13 +1313,61 @@ type Config struct {
 	// Separate keys and values with colons :, as in KEY: VALUE, and separate each key-value pair with a line break.
 	// Key-value can be any of the following:
 	// "enabled: boolean" flag to enable/disable the ntp values (Default: false)
+	// "mode: string" "pool" to query the offset from the ntp servers, or "local" to report on Linux the kernel
+	//    clock synchronization status, without outbound requests (Default: pool)
 	// "pool: []string" list of ntp servers (Default: [])
 	// "interval: int" interval in minutes to check ntp servers  (Default: 15)
 	// "timeout: int" ntp request timeout value in seconds (Default: 10)
+	// "chrony_address: string" in local mode, chronyd command address to also report the offset and stratum,
+	//    as "127.0.0.1:323" or the "/var/run/chrony/chronyd.sock" unix socket (Default: "")
 	// Default: none
 	// Public: Yes
 	NtpMetrics NtpConfig `yaml:"ntp_metrics" envconfig:"ntp_metrics"`
 
+	// NetworkConnectionMetrics toggles each group of metrics of the NetworkConnectionSample, reported on Linux at
+	// the metrics_network_sample_rate. The sample is not reported when all of them are disabled.
+	// Key-value can be any of the following:
+	// "tcp_states: boolean" TCP connection counts per state, UDP sockets and socket queue sizes (Default: false)
+	// "retransmits: boolean" TCP retransmitted segments per second (Default: false)
+	// "listen_drops: boolean" TCP listen queue overflows and SYN drops per second (Default: false)
+	// "conntrack: boolean" netfilter connection tracking table usage (Default: false)
+	// Default: none
+	// Public: Yes
+	NetworkConnectionMetrics NetworkConnectionConfig `yaml:"network_connection_metrics" envconfig:"network_connection_metrics"`
+
+	// CgroupMetrics configures the CgroupSample, reported on Linux for the cgroup v2 groups under /sys/fs/cgroup,
+	// so systemd services and podman or containerd containers get a resource usage view. It is disabled by default.
+	// Cgroup paths are relative to the cgroup root, as "system.slice/sshd.service". Patterns are globs, where "*"
+	// matches within a path element and "**" across them, or regular expressions as in `regex "^system.slice/"`.
+	// Key-value can be any of the following:
+	// "enabled: boolean" flag to enable/disable the cgroup samples (Default: false)
+	// "sample_rate: int" sampling interval in seconds (Default: 15)
+	// "include: []string" patterns of the cgroups to report (Default: ["system.slice/*.service", "**/*.scope"])
+	// "exclude: []string" patterns of the cgroups not to report, taking precedence over include (Default: [])
+	// Default: none
+	// Public: Yes
+	CgroupMetrics CgroupConfig `yaml:"cgroup_metrics" envconfig:"cgroup_metrics"`
+
+	// SensorMetrics configures the SensorSample, reported on Linux for each hardware sensor exposed under
+	// /sys/class/hwmon and /sys/class/thermal: temperatures, fan speeds and voltages with their thresholds.
+	// It is disabled by default.
+	// Key-value can be any of the following:
+	// "enabled: boolean" flag to enable/disable the sensor samples (Default: false)
+	// "sample_rate: int" sampling interval in seconds (Default: 30)
+	// Default: none
+	// Public: Yes
+	SensorMetrics SensorConfig `yaml:"sensor_metrics" envconfig:"sensor_metrics"`
+
+	// CPUTopologyMetrics configures the CpuCoreSample, reported for each logical CPU with the breakdown of its
+	// usage, and the NumaNodeSample, reported on Linux for each NUMA node under /sys/devices/system/node with its
+	// memory usage. They are disabled by default.
+	// Key-value can be any of the following:
+	// "enabled: boolean" flag to enable/disable the CPU core and NUMA node samples (Default: false)
+	// "sample_rate: int" sampling interval in seconds (Default: 15)
+	// Default: none
+	// Public: Yes
+	CPUTopologyMetrics CPUTopologyConfig `yaml:"cpu_topology_metrics" envconfig:"cpu_topology_metrics"`
+
 	// AgentTempDir is the directory where the agent stores temporary files (i.e. fb config, discovery...)
 	// It will be DELETED on every agent restart only if it matches default value
 	//
@@ -1256,18 +1483,102 @@ func (lc *LogConfig) VerboseEnabled() int {
 
 // NtpConfig map all ntp configuration options.
 type NtpConfig struct {
-	Pool     []string `yaml:"pool" envconfig:"pool"`
-	Enabled  bool     `yaml:"enabled" envconfig:"enabled"`
-	Interval uint     `yaml:"interval" envconfig:"interval"`
-	Timeout  uint     `yaml:"timeout" envconfig:"timeout"`
+	Pool          []string `yaml:"pool" envconfig:"pool"`
+	Enabled       bool     `yaml:"enabled" envconfig:"enabled"`
+	Mode          string   `yaml:"mode" envconfig:"mode"`
+	Interval      uint     `yaml:"interval" envconfig:"interval"`
+	Timeout       uint     `yaml:"timeout" envconfig:"timeout"`
+	ChronyAddress string   `yaml:"chrony_address" envconfig:"chrony_address"`
 }
 
 func NewNtpConfig() NtpConfig {
 	return NtpConfig{
-		Pool:     defaultNtpPool,
-		Enabled:  defaultNtpEnabled,
-		Interval: defaultNtpInterval,
-		Timeout:  defaultNtpTimeout,
+		Pool:          defaultNtpPool,
+		Enabled:       defaultNtpEnabled,
+		Mode:          defaultNtpMode,
+		Interval:      defaultNtpInterval,
+		Timeout:       defaultNtpTimeout,
+		ChronyAddress: defaultNtpChronyAddress,
+	}
+}
+
+// NetworkConnectionConfig toggles the NetworkConnectionSample metric groups.
+type NetworkConnectionConfig struct {
+	TCPStates   bool `yaml:"tcp_states" envconfig:"tcp_states"`
+	Retransmits bool `yaml:"retransmits" envconfig:"retransmits"`
+	ListenDrops bool `yaml:"listen_drops" envconfig:"listen_drops"`
+	Conntrack   bool `yaml:"conntrack" envconfig:"conntrack"`
+}
+
+// Enabled returns true if any of the metric groups is enabled.
+func (c NetworkConnectionConfig) Enabled() bool {
+	return c.TCPStates || c.Retransmits || c.ListenDrops || c.Conntrack
+}
+
+func NewNetworkConnectionConfig() NetworkConnectionConfig {
+	return NetworkConnectionConfig{
+		TCPStates:   defaultNetworkConnectionTCPStates,
+		Retransmits: defaultNetworkConnectionRetransmits,
+		ListenDrops: defaultNetworkConnectionListenDrops,
+		Conntrack:   defaultNetworkConnectionConntrack,
+	}
+}
+
+// CgroupConfig map all the cgroup sampler configuration options.
+type CgroupConfig struct {
+	Enabled    bool     `yaml:"enabled" envconfig:"enabled"`
+	SampleRate int      `yaml:"sample_rate" envconfig:"sample_rate"`
+	Include    []string `yaml:"include" envconfig:"include"`
+	Exclude    []string `yaml:"exclude" envconfig:"exclude"`
+}
+
+func NewCgroupConfig() CgroupConfig {
+	return CgroupConfig{
+		Enabled:    defaultCgroupEnabled,
+		SampleRate: defaultCgroupSampleRate,
+		Include:    defaultCgroupInclude,
+		Exclude:    defaultCgroupExclude,
+	}
+}
+
+// SensorConfig map all the hardware sensors sampler configuration options.
+type SensorConfig struct {
+	Enabled    bool `yaml:"enabled" envconfig:"enabled"`
+	SampleRate int  `yaml:"sample_rate" envconfig:"sample_rate"`
+}
+
+func NewSensorConfig() SensorConfig {
+	return SensorConfig{
+		Enabled:    defaultSensorEnabled,
+		SampleRate: defaultSensorSampleRate,
+	}
+}
+
+// CPUTopologyConfig map all the CPU core and NUMA node sampler configuration options.
+type CPUTopologyConfig struct {
+	Enabled    bool `yaml:"enabled" envconfig:"enabled"`
+	SampleRate int  `yaml:"sample_rate" envconfig:"sample_rate"`
+}
+
+func NewCPUTopologyConfig() CPUTopologyConfig {
+	return CPUTopologyConfig{
+		Enabled:    defaultCPUTopologyEnabled,
+		SampleRate: defaultCPUTopologySampleRate,
+	}
+}
+
+// ProcessLifecycleConfig map all the process lifecycle events configuration options.
+type ProcessLifecycleConfig struct {
+	Enabled bool              `yaml:"enabled" envconfig:"enabled"`
+	Match   IncludeMetricsMap `yaml:"match" envconfig:"match"`
+	OomKill bool              `yaml:"oom_kill" envconfig:"oom_kill"`
+}
+
+func NewProcessLifecycleConfig() ProcessLifecycleConfig {
+	return ProcessLifecycleConfig{
+		Enabled: defaultProcessLifecycleEnabled,
+		Match:   defaultProcessLifecycleMatch,
+		OomKill: defaultProcessLifecycleOomKill,
 	}
 }
 
@@ -1650,7 +1961,10 @@ func NewConfig() *Config {
 		LoggingRetryLimit:             defaultLoggingRetryLimit,
 		HTTPServerHost:                defaultHTTPServerHost,
 		HTTPServerPort:                defaultHTTPServerPort,
+		HTTPServerOTLPMaxPayloadSize:  defaultHTTPServerOTLPMaxPayloadSize,
 		TCPServerPort:                 defaultTCPServerPort,
+		TCPServerMaxConnections:       defaultTCPServerMaxConnections,
+		TCPServerMaxPayloadSize:       defaultTCPServerMaxPayloadSize,
 		StatusServerPort:              defaultStatusServerPort,
 		DockerApiVersion:              DefaultDockerApiVersion,
 		FingerprintUpdateFreqSec:      defaultFingerprintUpdateFreqSec,
@@ -1681,9 +1995,12 @@ func NewConfig() *Config {
 		CompactEnabled:              defaultCompactEnabled,
 		StripCommandLine:            DefaultStripCommandLine,
 		NetworkInterfaceFilters:     defaultNetworkInterfaceFilters,
+		CertificatesPaths:           defaultCertificatesPaths,
 		SelinuxEnableSemodule:       defaultSelinuxEnableSemodule,
 		OfflineTimeToReset:          DefaultOfflineTimeToReset,
 		FilesConfigOn:               defaultFilesConfigOn,
+		FilesConfigDiffMaxSize:      defaultFilesConfigDiffMaxSize,
+		FilesConfigRedactPatterns:   defaultFilesConfigRedactPatterns,
 		PayloadCompressionLevel:     defaultPayloadCompressionLevel,
 		EnableWinUpdatePlugin:       defaultWinUpdatePlugin,
 		LogToStdout:                 defaultLogToStdout,
@@ -1695,8 +2012,17 @@ func NewConfig() *Config {
 		SmartVerboseModeEntryLimit:  DefaultSmartVerboseModeEntryLimit,
 		DefaultIntegrationsTempDir:  defaultIntegrationsTempDir,
 		IncludeMetricsMatchers:      defaultMetricsMatcherConfig,
+		ExcludeMetricsMatchers:      defaultExcludeMetricsMatcher,
 		InventoryQueueLen:           DefaultInventoryQueue,
+		PersistentQueueMaxBytes:     defaultPersistentQueueMaxBytes,
+		PersistentQueueMaxAgeSec:    defaultPersistentQueueMaxAgeSec,
 		NtpMetrics:                  NewNtpConfig(),
+		NetworkConnectionMetrics:    NewNetworkConnectionConfig(),
+		CgroupMetrics:               NewCgroupConfig(),
+		SensorMetrics:               NewSensorConfig(),
+		CPUTopologyMetrics:          NewCPUTopologyConfig(),
+		ProcessLifecycleEvents:      NewProcessLifecycleConfig(),
+		ProcessNetworkMetricsMaxFds: defaultProcessNetworkMetricsMaxFds,
 		AgentTempDir:                defaultAgentTempDir,
 	}
 }
@@ -36,6 +36,12 @@ func init() {
 		"prefix":  {"dummy", "lo", "vmnet", "sit", "tun", "tap", "veth"},
 		"index-1": {"tun", "tap"},
 	}
+	defaultCertificatesPaths = []string{
+		filepath.Join("/etc", "ssl", "*.pem"),
+		filepath.Join("/etc", "ssl", "*.crt"),
+		filepath.Join("/etc", "pki", "tls", "certs", "*.pem"),
+		filepath.Join("/etc", "pki", "tls", "certs", "*.crt"),
+	}
 
 	defaultLoggingBinDir = "/opt/td-agent-bit/bin"
 	defaultLoggingHomeDir = "logging"
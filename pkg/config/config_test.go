@@ -313,6 +313,8 @@ agent_dir: /my/overriden/agent/dir
 	c.Assert(cfg.CompactEnabled, Equals, defaultCompactEnabled)
 	c.Assert(cfg.CompactThreshold, Equals, uint64(defaultCompactThreshold))
 	c.Assert(cfg.FilesConfigOn, Equals, defaultFilesConfigOn)
+	c.Assert(cfg.FilesConfigDiffMaxSize, Equals, defaultFilesConfigDiffMaxSize)
+	c.Assert(cfg.FilesConfigRedactPatterns, DeepEquals, defaultFilesConfigRedactPatterns)
 	c.Assert(cfg.SupervisorRpcSocket, Equals, defaultSupervisorRpcSock)
 	c.Assert(cfg.DebugLogSec, Equals, defaultDebugLogSec)
 	c.Assert(cfg.StripCommandLine, Equals, DefaultStripCommandLine)
@@ -639,6 +641,25 @@ func Test_ParseIncludeMatchingRule_EnvVar(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(cfg.IncludeMetricsMatchers, expected))
 }
 
+func Test_ParseExcludeMatchingRule_EnvVar(t *testing.T) {
+	os.Setenv("NRIA_EXCLUDE_MATCHING_METRICS", "storage.mountPoint:\n - regex \"^/snap\" \nprocess.cpuPercent:\n - \"< 1\"\n")
+	defer os.Unsetenv("NRIA_EXCLUDE_MATCHING_METRICS")
+
+	configStr := "license_key: abc123"
+	f, err := ioutil.TempFile("", "yaml_config_test")
+	assert.NoError(t, err)
+	f.WriteString(configStr)
+	f.Close()
+
+	cfg, err := LoadConfig(f.Name())
+	assert.NoError(t, err)
+	expected := IncludeMetricsMap{
+		"storage.mountPoint": []string{"regex \"^/snap\""},
+		"process.cpuPercent": []string{"< 1"},
+	}
+	assert.Equal(t, expected, cfg.ExcludeMetricsMatchers)
+}
+
 func TestLoadYamlConfig_withDatabindJSONVariables(t *testing.T) {
 	yamlData := []byte(`
 variables:
@@ -814,6 +835,7 @@ func TestLoadNtpConfig(t *testing.T) {
 			expected: NtpConfig{
 				Enabled:  defaultNtpEnabled,
 				Pool:     defaultNtpPool,
+				Mode:     defaultNtpMode,
 				Timeout:  defaultNtpTimeout,
 				Interval: defaultNtpInterval,
 			},
@@ -827,6 +849,7 @@ ntp_metrics:
 			expected: NtpConfig{
 				Enabled:  false,
 				Pool:     defaultNtpPool,
+				Mode:     defaultNtpMode,
 				Timeout:  defaultNtpTimeout,
 				Interval: defaultNtpInterval,
 			},
@@ -845,6 +868,7 @@ ntp_metrics:
 			expected: NtpConfig{
 				Enabled:  defaultNtpEnabled,
 				Pool:     []string{"one.server.com", "two.server.com", "three.server.com"},
+				Mode:     defaultNtpMode,
 				Timeout:  300,
 				Interval: 10,
 			},
@@ -864,10 +888,28 @@ ntp_metrics:
 			expected: NtpConfig{
 				Enabled:  true,
 				Pool:     []string{"one.server.com", "two.server.com", "three.server.com"},
+				Mode:     defaultNtpMode,
 				Timeout:  300,
 				Interval: 10,
 			},
 		},
+		{
+			name: "Local mode with chrony",
+			yamlCfg: `
+ntp_metrics:
+  enabled: true
+  mode: local
+  chrony_address: 127.0.0.1:323
+`,
+			expected: NtpConfig{
+				Enabled:       true,
+				Pool:          defaultNtpPool,
+				Mode:          NtpModeLocal,
+				Timeout:       defaultNtpTimeout,
+				Interval:      defaultNtpInterval,
+				ChronyAddress: "127.0.0.1:323",
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
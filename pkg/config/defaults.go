@@ -67,7 +67,10 @@ var (
 	defaultMaxProcs                      = 1
 	defaultHTTPServerHost                = "localhost"
 	defaultHTTPServerPort                = 8001
+	defaultHTTPServerOTLPMaxPayloadSize  = int64(10 * 1024 * 1024)
 	defaultTCPServerPort                 = 8002
+	defaultTCPServerMaxConnections       = 100
+	defaultTCPServerMaxPayloadSize       = 10 * 1024 * 1024
 	defaultStatusServerPort              = 8003
 	defaultIpData                        = true
 	defaultTruncTextValues               = true
@@ -103,11 +106,37 @@ var (
 	defaultProxyConfigPlugin             = true
 	defaultWinRemovableDrives            = true
 	defaultMetricsMatcherConfig          = IncludeMetricsMap{}
+	defaultExcludeMetricsMatcher         = IncludeMetricsMap{}
+	defaultPersistentQueueMaxBytes       = int64(100 * 1024 * 1024)
+	defaultPersistentQueueMaxAgeSec      = 86400 // 1 day
 	defaultRegisterMaxRetryBoSecs        = 60
 	defaultNtpPool                       = []string{} // i.e: []string{"time.cloudflare.com"}
 	defaultNtpEnabled                    = false
 	defaultNtpInterval                   = uint(15)   // minutes
 	defaultNtpTimeout                    = uint(5000) // millisecods
+	defaultNtpMode                       = NtpModePool
+	defaultNtpChronyAddress              = ""
+	defaultNetworkConnectionTCPStates    = false
+	defaultNetworkConnectionRetransmits  = false
+	defaultNetworkConnectionListenDrops  = false
+	defaultNetworkConnectionConntrack    = false
+	defaultProcessNetworkMetricsMaxFds   = 100000
+	defaultCgroupEnabled                 = false
+	defaultCgroupSampleRate              = 15 // seconds
+	defaultCgroupInclude                 = []string{"system.slice/*.service", "**/*.scope"}
+	defaultCgroupExclude                 = []string{}
+	defaultSensorEnabled                 = false
+	defaultSensorSampleRate              = 30 // seconds
+	defaultCPUTopologyEnabled            = false
+	defaultCPUTopologySampleRate         = 15 // seconds
+	defaultProcessLifecycleEnabled       = false
+	defaultProcessLifecycleMatch         = IncludeMetricsMap{}
+	defaultProcessLifecycleOomKill       = false
+	defaultFilesConfigDiffMaxSize        = 4000 // bytes, below the event attributes length limit
+	defaultFilesConfigRedactPatterns     = []string{
+		`(?i)(password|passwd|secret|token|api[_-]?key)\s*[=:]\s*\S+`,
+		`\$[0-9a-z]+\$[^:\s]+`, // crypt(3) password hashes, as in /etc/shadow
+	}
 )
 
 // Default internal values
@@ -117,6 +146,7 @@ var (
 	defaultConfigFiles             []string
 	defaultLogFile                 string
 	defaultNetworkInterfaceFilters map[string][]string
+	defaultCertificatesPaths       []string
 	defaultPassthroughEnvironment  []string
 	defaultPluginConfigFiles       []string
 	defaultPluginInstanceDir       string
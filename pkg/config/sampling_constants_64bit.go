@@ -41,6 +41,9 @@ const (
 	FREQ_PLUGIN_NETWORK_INTERFACE_UPDATES = 60 // seconds
 	FREQ_PLUGIN_CLOUD_SECURITY_UPDATES    = 60 // seconds
 
+	FREQ_PLUGIN_CERTIFICATES_UPDATES = 3600 // seconds, certificates expiry is reported in days
+	FREQ_PLUGIN_FIREWALL_UPDATES     = 60   // seconds
+
 	// WINDOWS PLUGINS
 	FREQ_PLUGIN_WINDOWS_SERVICES = 30 // seconds, 0 == off, 30 == minimum otherwise: inventory: running services
 	FREQ_PLUGIN_WINDOWS_UPDATES  = 60 // seconds
@@ -0,0 +1,223 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package validate checks the agent, integrations and logging configuration files without running them,
+// using the same loaders the agent does.
+package validate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/config/envvar"
+	v4config "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/fs"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
+)
+
+// yaml.v2 parsing errors are prefixed by the failing line, e.g.: "yaml: line 3: mapping values are not allowed"
+var yamlLineRegex = regexp.MustCompile(`line (\d+):`)
+
+// Issue is a configuration problem found in a file. Line is zero when it applies to the whole file.
+type Issue struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Err)
+	}
+	return fmt.Sprintf("%s: %s", i.File, i.Err)
+}
+
+func newIssue(file string, line int, err error) Issue {
+	if line == 0 {
+		if m := yamlLineRegex.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+	}
+	return Issue{File: file, Line: line, Err: err}
+}
+
+// Agent loads the agent configuration file, returning the loaded config or the issue preventing it.
+func Agent(configFile string) (*config.Config, []Issue) {
+	if configFile != "" {
+		if _, err := os.Stat(configFile); err != nil {
+			return nil, []Issue{newIssue(configFile, 0, err)}
+		}
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return nil, []Issue{newIssue(configFile, 0, err)}
+	}
+	return cfg, nil
+}
+
+// Integrations validates the v4 integrations config files within the passed directories, or the
+// passed files, loading their definitions as the agent does through the instances lookup. Legacy v3
+// config files are ignored.
+func Integrations(paths []string, il integration.InstancesLookup, passthroughEnv []string) (issues []Issue) {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				issues = append(issues, newIssue(path, 0, err))
+			}
+			continue
+		}
+		if !info.IsDir() {
+			issues = append(issues, integrationsFile(path, il, passthroughEnv)...)
+			continue
+		}
+		yamls, err := files.AllYAMLs(path)
+		if err != nil {
+			issues = append(issues, newIssue(path, 0, err))
+			continue
+		}
+		for _, f := range yamls {
+			issues = append(issues, integrationsFile(filepath.Join(path, f.Name()), il, passthroughEnv)...)
+		}
+	}
+	return issues
+}
+
+func integrationsFile(file string, il integration.InstancesLookup, passthroughEnv []string) (issues []Issue) {
+	cfg, err := v4config.NewPathLoader().LoadFile(file)
+	if err == v4config.LegacyYAML {
+		return nil
+	}
+	if err != nil {
+		return []Issue{newIssue(file, 0, err)}
+	}
+
+	if _, err = cfg.Databind.DataSources(); err != nil {
+		issues = append(issues, newIssue(file, 0, err))
+	}
+
+	lines := entryLines(file, "integrations")
+	for i, entry := range cfg.Integrations {
+		line := lineAt(lines, i)
+		template, err := integration.LoadConfigTemplate(entry.TemplatePath, entry.Config)
+		if err == nil {
+			_, err = integration.NewDefinition(entry, il, passthroughEnv, template)
+		}
+		if err != nil {
+			if entry.InstanceName != "" {
+				err = fmt.Errorf("integration %q: %w", entry.InstanceName, err)
+			}
+			issues = append(issues, newIssue(file, line, err))
+		}
+	}
+	return issues
+}
+
+// Logs validates the logging config files from the log forwarder config directory, returning the
+// FluentBit configuration the agent would generate from them.
+func Logs(logFwd config.LogForward) (fbConfig string, issues []Issue) {
+	if logFwd.ConfigsDir == "" {
+		return "", nil
+	}
+	paths, err := fs.OSFilesInFolderFn(logFwd.ConfigsDir)
+	if err != nil {
+		if err == fs.ErrFilesNotFound || err == fs.ErrFolderNotFound {
+			return "", nil
+		}
+		return "", []Issue{newIssue(logFwd.ConfigsDir, 0, err)}
+	}
+
+	var cfgs logs.LogsCfg
+	for _, file := range paths {
+		if ext := filepath.Ext(file); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		fileCfgs, fileIssues := logsFile(file, logFwd.HomeDir)
+		cfgs = append(cfgs, fileCfgs...)
+		issues = append(issues, fileIssues...)
+	}
+	if len(cfgs) == 0 {
+		return "", issues
+	}
+
+	hostname, _ := os.Hostname()
+	fb, err := logs.NewFBConf(cfgs, &logFwd, "", hostname)
+	if err != nil {
+		return "", append(issues, newIssue(logFwd.ConfigsDir, 0, err))
+	}
+	fbConfig, _, err = fb.Format()
+	if err != nil {
+		return "", append(issues, newIssue(logFwd.ConfigsDir, 0, fmt.Errorf("cannot render fluent-bit config: %w", err)))
+	}
+	return fbConfig, issues
+}
+
+func logsFile(file, logsHomeDir string) (valid logs.LogsCfg, issues []Issue) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, []Issue{newIssue(file, 0, err)}
+	}
+
+	var y logs.YAML
+	if err = yaml.Unmarshal(content, &y); err != nil {
+		return nil, []Issue{newIssue(file, 0, err)}
+	}
+
+	lines := entryLines(file, "logs")
+	for i, l := range y.Logs {
+		if err = l.Validate(logsHomeDir); err != nil {
+			issues = append(issues, newIssue(file, lineAt(lines, i), err))
+			continue
+		}
+		valid = append(valid, l)
+	}
+	return valid, issues
+}
+
+// entryLines returns the line number of every entry of the top-level sequence under the passed key.
+func entryLines(file, key string) []int {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	if expanded, err := envvar.ExpandInContent(content); err == nil {
+		content = expanded
+	}
+
+	var doc yamlv3.Node
+	if err = yamlv3.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != key || root.Content[i+1].Kind != yamlv3.SequenceNode {
+			continue
+		}
+		var lines []int
+		for _, entry := range root.Content[i+1].Content {
+			lines = append(lines, entry.Line)
+		}
+		return lines
+	}
+	return nil
+}
+
+func lineAt(lines []int, i int) int {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return 0
+}
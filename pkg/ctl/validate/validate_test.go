@@ -0,0 +1,146 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package validate
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+// installed looks up the executables of the passed integration names.
+func installed(names ...string) integration.InstancesLookup {
+	return integration.InstancesLookup{
+		Legacy: integration.ErrLookup.Legacy,
+		ByName: func(name string) (string, error) {
+			for _, n := range names {
+				if n == name {
+					return "/var/db/newrelic-infra/newrelic-integrations/bin/" + name, nil
+				}
+			}
+			return "", errors.New("executable not found: " + name)
+		},
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestIntegrations(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "valid.yml", `
+integrations:
+  - name: nri-foo
+    exec: /bin/foo
+`)
+	invalid := writeFile(t, dir, "invalid.yml", `
+integrations:
+  - name: nri-foo
+    exec: /bin/foo
+  - exec: /bin/bar
+  - name: nri-baz
+    exec: /bin/baz
+    cli_args: [-v]
+  - name: nri-installed
+  - name: nri-missing
+  - name: nri-qux
+    exec: /bin/qux
+    inventory_source: qux
+`)
+	malformed := writeFile(t, dir, "malformed.yaml", `
+integrations:
+  - name: nri-foo
+   exec: /bin/foo
+`)
+	writeFile(t, dir, "legacy.yml", `
+integration_name: com.newrelic.foo
+instances:
+  - name: foo
+    command: all
+`)
+	writeFile(t, dir, "readme.txt", "not a config file")
+
+	issues := Integrations([]string{dir}, installed("nri-installed"), nil)
+
+	require.Len(t, issues, 5)
+	assert.Equal(t, invalid, issues[0].File)
+	assert.Equal(t, 5, issues[0].Line)
+	assert.Contains(t, issues[0].Err.Error(), "'name'")
+	assert.Equal(t, invalid, issues[1].File)
+	assert.Equal(t, 6, issues[1].Line)
+	assert.Contains(t, issues[1].Err.Error(), "'exec' or 'cli_args'")
+	assert.Equal(t, 10, issues[2].Line)
+	assert.Contains(t, issues[2].Err.Error(), `integration "nri-missing"`)
+	assert.Contains(t, issues[2].Err.Error(), "executable not found")
+	assert.Equal(t, 11, issues[3].Line)
+	assert.Contains(t, issues[3].Err.Error(), "inventory_source")
+	assert.Equal(t, malformed, issues[4].File)
+	assert.Equal(t, 3, issues[4].Line)
+}
+
+func TestIntegrations_MissingPath(t *testing.T) {
+	assert.Empty(t, Integrations([]string{filepath.Join(t.TempDir(), "missing")}, installed(), nil))
+}
+
+func TestLogs(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "logs.yml", `
+logs:
+  - name: syslog
+    file: /var/log/syslog
+  - name: missing-input
+  - name: tcp
+    tcp:
+      uri: not-an-uri
+`)
+
+	fbConfig, issues := Logs(config.LogForward{ConfigsDir: dir, HomeDir: dir})
+
+	require.Len(t, issues, 2)
+	assert.Equal(t, Issue{File: file, Line: 5, Err: issues[0].Err}, issues[0])
+	assert.Equal(t, file, issues[1].File)
+	assert.Equal(t, 6, issues[1].Line)
+
+	// valid entries are still rendered
+	assert.Contains(t, fbConfig, "Path /var/log/syslog")
+	assert.Contains(t, fbConfig, "[OUTPUT]")
+}
+
+func TestLogs_Malformed(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "logs.yml", "logs:\n  - name: foo\n   file: /foo\n")
+
+	fbConfig, issues := Logs(config.LogForward{ConfigsDir: dir})
+
+	assert.Empty(t, fbConfig)
+	require.Len(t, issues, 1)
+	assert.Equal(t, file, issues[0].File)
+	assert.Equal(t, 2, issues[0].Line)
+}
+
+func TestAgent_MissingFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "newrelic-infra.yml")
+
+	cfg, issues := Agent(file)
+
+	assert.Nil(t, cfg)
+	require.Len(t, issues, 1)
+	assert.Equal(t, file, issues[0].File)
+}
+
+func TestIssue_String(t *testing.T) {
+	assert.Equal(t, "foo.yml:3: bar", newIssue("foo.yml", 3, errors.New("bar")).String())
+	assert.Equal(t, "foo.yml:7: yaml: line 7: bar", newIssue("foo.yml", 0, errors.New("yaml: line 7: bar")).String())
+	assert.Equal(t, "foo.yml: bar", newIssue("foo.yml", 0, errors.New("bar")).String())
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	tcpListenState = "0A"
+	socketPrefix   = "socket:["
+)
+
+// procListeners returns the listening TCP sockets of each process, by matching the socket inodes
+// from <root>/net/tcp and <root>/net/tcp6 with the process file descriptors.
+func procListeners(root string) (map[int32][]listener, error) {
+	sockets := map[string]listener{}
+	for _, table := range []string{"tcp", "tcp6"} {
+		if err := readListeningSockets(filepath.Join(root, "net", table), sockets); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	result := map[int32][]listener{}
+	if len(sockets) == 0 {
+		return result, nil
+	}
+
+	procDirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range procDirs {
+		pid, err := strconv.ParseInt(dir.Name(), 10, 32)
+		if err != nil {
+			continue // not a process directory
+		}
+		// processes from other users can't be inspected without privileges
+		fds, err := ioutil.ReadDir(filepath.Join(root, dir.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(root, dir.Name(), "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, socketPrefix) {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, socketPrefix), "]")
+			if l, ok := sockets[inode]; ok {
+				result[int32(pid)] = append(result[int32(pid)], l)
+			}
+		}
+	}
+	return result, nil
+}
+
+// readListeningSockets adds the listening sockets from a /proc/net/tcp formatted file, indexed by inode.
+func readListeningSockets(path string, sockets map[string]listener) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListenState {
+			continue
+		}
+		l, err := parseAddress(fields[1])
+		if err != nil {
+			return fmt.Errorf("invalid address in %s: %s", path, err)
+		}
+		sockets[fields[9]] = l
+	}
+	return scanner.Err()
+}
+
+// parseAddress parses an hex encoded "address:port" entry. The address is stored as
+// a sequence of 32-bit words in host (little endian) byte order.
+func parseAddress(hexAddr string) (listener, error) {
+	parts := strings.Split(hexAddr, ":")
+	if len(parts) != 2 {
+		return listener{}, fmt.Errorf("unexpected format %q", hexAddr)
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return listener{}, err
+	}
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return listener{}, err
+	}
+	if len(raw) != net.IPv4len && len(raw) != net.IPv6len {
+		return listener{}, fmt.Errorf("unexpected address length %q", hexAddr)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return listener{ip: ip.String(), port: int(port)}, nil
+}
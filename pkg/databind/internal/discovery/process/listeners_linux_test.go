@@ -0,0 +1,82 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	tcpTable = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1538 00000000:0000 0A 00000000:00000000 00:00000000 00000000   111        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:9C40 0100007F:1538 01 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 100 0 0 10 0
+`
+	tcp6Table = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1538 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000   111        0 1004 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1005 1 0000000000000000 100 0 0 10 0
+`
+)
+
+func TestProcListeners(t *testing.T) {
+	// GIVEN a proc filesystem with tcp tables
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "net", "tcp"), []byte(tcpTable), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "net", "tcp6"), []byte(tcp6Table), 0644))
+
+	// AND processes owning the sockets
+	fds := map[string]map[string]string{
+		"10": {"3": "socket:[1001]", "4": "socket:[1004]", "5": "/var/log/postgres.log"},
+		"20": {"6": "socket:[1002]", "7": "socket:[1003]"},
+		"30": {"3": "socket:[1005]"},
+		"40": {"0": "/dev/null"},
+	}
+	for pid, links := range fds {
+		dir := filepath.Join(root, pid, "fd")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for fd, target := range links {
+			require.NoError(t, os.Symlink(target, filepath.Join(dir, fd)))
+		}
+	}
+
+	// WHEN the listeners are read
+	listeners, err := procListeners(root)
+	require.NoError(t, err)
+
+	// THEN only listening sockets are returned for each process
+	assert.ElementsMatch(t, []listener{{ip: "0.0.0.0", port: 5432}, {ip: "::", port: 5432}}, listeners[10])
+	assert.Equal(t, []listener{{ip: "127.0.0.1", port: 80}}, listeners[20])
+	assert.Equal(t, []listener{{ip: "::1", port: 22}}, listeners[30])
+	assert.NotContains(t, listeners, int32(40))
+}
+
+func TestProcListeners_NoTables(t *testing.T) {
+	listeners, err := procListeners(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+}
+
+func TestListenersByPID_HostProc(t *testing.T) {
+	// GIVEN a host proc filesystem mounted out of /proc
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "net", "tcp"), []byte(tcpTable), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "10", "fd"), 0755))
+	require.NoError(t, os.Symlink("socket:[1001]", filepath.Join(root, "10", "fd", "3")))
+	t.Setenv("HOST_PROC", root)
+
+	// WHEN the listeners are read
+	listeners, err := listenersByPID()
+	require.NoError(t, err)
+
+	// THEN they are read from the host proc filesystem
+	assert.Equal(t, map[int32][]listener{10: {{ip: "0.0.0.0", port: 5432}}}, listeners)
+}
@@ -0,0 +1,12 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+// +build !linux
+
+package process
+
+// procListeners listening sockets are only read from the Linux proc filesystem.
+func procListeners(_ string) (map[int32][]listener, error) {
+	return map[int32][]listener{}, nil
+}
@@ -0,0 +1,10 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+var dlog = log.WithComponent("ProcessDiscovery")
@@ -0,0 +1,145 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// processInfo holds the process attributes exposed as discovery variables.
+type processInfo struct {
+	pid     int32
+	name    string
+	cmdline string
+	user    string
+	cwd     string
+}
+
+// listener is a listening TCP socket.
+type listener struct {
+	ip   string
+	port int
+}
+
+// listProcesses returns the running processes. Replaceable for testing purposes.
+var listProcesses = func() ([]processInfo, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]processInfo, 0, len(procs))
+	for _, p := range procs {
+		// processes may finish while being listed, or belong to other users, so errors are ignored
+		name, _ := p.Name()
+		cmdline, _ := p.Cmdline()
+		user, _ := p.Username()
+		cwd, _ := p.Cwd()
+		infos = append(infos, processInfo{pid: p.Pid, name: name, cmdline: cmdline, user: user, cwd: cwd})
+	}
+	return infos, nil
+}
+
+// listenersByPID returns the listening TCP sockets of each process, reading the same proc filesystem as
+// the processes list. Replaceable for testing purposes.
+var listenersByPID = func() (map[int32][]listener, error) {
+	return procListeners(helpers.HostProc())
+}
+
+// Discoverer returns a local processes discoverer from the provided configuration.
+// The fetching process will return an array of map values for each discovered process, with the
+// keys discovery.pid, discovery.name, discovery.cmdline, discovery.user, discovery.cwd and the
+// listening sockets as discovery.ip, discovery.port, discovery.ip.N and discovery.ports.N
+func Discoverer(d discovery.Process) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	matcher, err := discovery.NewMatcher(d.Match)
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]discovery.Discovery, error) {
+		return fetch(&matcher)
+	}, nil
+}
+
+func fetch(matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners, err := listenersByPID()
+	if err != nil {
+		// processes can still be discovered by their attributes
+		dlog.WithError(err).Debug("cannot read listening sockets")
+	}
+
+	return getDiscoveries(procs, listeners, matcher), nil
+}
+
+// getDiscoveries will filter the process list to only the ones that match the config and extract
+// discovery variables from those.
+func getDiscoveries(procs []processInfo, listeners map[int32][]listener, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	var matches []discovery.Discovery
+
+	for _, proc := range procs {
+		labels := map[string]string{
+			data.PID:     strconv.Itoa(int(proc.pid)),
+			data.Name:    proc.name,
+			data.Cmdline: proc.cmdline,
+			data.User:    proc.user,
+			data.Cwd:     proc.cwd,
+		}
+		addPorts(listeners[proc.pid], labels)
+
+		// only processes matching all the criteria will be added
+		if matcher.All(labels) {
+			matches = append(matches, discovery.Discovery{
+				Variables: discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+			})
+		}
+	}
+	return matches
+}
+
+func addPorts(listeners []listener, labels map[string]string) {
+	// sort ports from lower to higher so we are always consistent with the returned ports
+	sort.Slice(listeners, func(i, j int) bool {
+		if listeners[i].port != listeners[j].port {
+			return listeners[i].port < listeners[j].port
+		}
+		return listeners[i].ip < listeners[j].ip
+	})
+
+	for index, l := range listeners {
+		indexStr := "." + strconv.Itoa(index)
+		ip := reachableIP(l.ip)
+		port := strconv.Itoa(l.port)
+
+		if index == 0 {
+			labels[data.IP] = ip
+			labels[data.Port] = port
+		}
+		labels[data.IP+indexStr] = ip
+		labels[data.Ports+indexStr] = port
+	}
+}
+
+// reachableIP returns the loopback address for sockets listening on all the interfaces,
+// so the discovered address can be used to connect to the process.
+func reachableIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || !parsed.IsUnspecified() {
+		return ip
+	}
+	if parsed.To4() != nil {
+		return "127.0.0.1"
+	}
+	return "::1"
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var givenProcesses = []processInfo{
+	{pid: 10, name: "postgres", cmdline: "/usr/lib/postgresql/14/bin/postgres -D /var/lib/postgresql/14/main", user: "postgres", cwd: "/var/lib/postgresql/14/main"},
+	{pid: 11, name: "postgres", cmdline: "postgres: checkpointer", user: "postgres", cwd: "/var/lib/postgresql/14/main"},
+	{pid: 20, name: "nginx", cmdline: "nginx: master process /usr/sbin/nginx", user: "root", cwd: "/"},
+}
+
+var givenListeners = map[int32][]listener{
+	10: {{ip: "::", port: 5432}, {ip: "0.0.0.0", port: 5432}},
+	20: {{ip: "0.0.0.0", port: 443}, {ip: "10.0.0.1", port: 80}},
+}
+
+func TestGetDiscoveries(t *testing.T) {
+	matcher, err := discovery.NewMatcher(map[string]string{"cmdline": "/postgres -D/"})
+	require.NoError(t, err)
+
+	discoveries := getDiscoveries(givenProcesses, givenListeners, &matcher)
+
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, data.Map{
+		"discovery.pid":     "10",
+		"discovery.name":    "postgres",
+		"discovery.cmdline": "/usr/lib/postgresql/14/bin/postgres -D /var/lib/postgresql/14/main",
+		"discovery.user":    "postgres",
+		"discovery.cwd":     "/var/lib/postgresql/14/main",
+		"discovery.ip":      "127.0.0.1",
+		"discovery.port":    "5432",
+		"discovery.ip.0":    "127.0.0.1",
+		"discovery.ports.0": "5432",
+		"discovery.ip.1":    "::1",
+		"discovery.ports.1": "5432",
+	}, discoveries[0].Variables)
+}
+
+func TestGetDiscoveries_SortsPorts(t *testing.T) {
+	matcher, err := discovery.NewMatcher(map[string]string{"name": "nginx"})
+	require.NoError(t, err)
+
+	discoveries := getDiscoveries(givenProcesses, givenListeners, &matcher)
+
+	require.Len(t, discoveries, 1)
+	vars := discoveries[0].Variables
+	assert.Equal(t, "10.0.0.1", vars["discovery.ip"])
+	assert.Equal(t, "80", vars["discovery.port"])
+	assert.Equal(t, "127.0.0.1", vars["discovery.ip.1"])
+	assert.Equal(t, "443", vars["discovery.ports.1"])
+}
+
+func TestGetDiscoveries_MatchByPort(t *testing.T) {
+	matcher, err := discovery.NewMatcher(map[string]string{"ports.1": "443"})
+	require.NoError(t, err)
+
+	discoveries := getDiscoveries(givenProcesses, givenListeners, &matcher)
+
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "20", discoveries[0].Variables["discovery.pid"])
+}
+
+func TestGetDiscoveries_NotListening(t *testing.T) {
+	matcher, err := discovery.NewMatcher(map[string]string{"name": "postgres", "user": "/^post/"})
+	require.NoError(t, err)
+
+	discoveries := getDiscoveries(givenProcesses, givenListeners, &matcher)
+
+	require.Len(t, discoveries, 2)
+	assert.Equal(t, "11", discoveries[1].Variables["discovery.pid"])
+	assert.NotContains(t, discoveries[1].Variables, "discovery.port")
+}
+
+func TestDiscoverer(t *testing.T) {
+	defer func(lp func() ([]processInfo, error), lb func() (map[int32][]listener, error)) {
+		listProcesses, listenersByPID = lp, lb
+	}(listProcesses, listenersByPID)
+	listProcesses = func() ([]processInfo, error) { return givenProcesses, nil }
+	listenersByPID = func() (map[int32][]listener, error) { return givenListeners, nil }
+
+	fetch, err := Discoverer(discovery.Process{Match: map[string]string{"name": "/^(nginx|postgres)$/"}})
+	require.NoError(t, err)
+
+	discoveries, err := fetch()
+	require.NoError(t, err)
+	assert.Len(t, discoveries, 3)
+}
+
+func TestDiscoverer_InvalidMatcher(t *testing.T) {
+	_, err := Discoverer(discovery.Process{Match: map[string]string{"name": "/[/"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// FileSecret defines a secret stored into a local file, e.g. Kubernetes or Docker mounted secrets.
+type FileSecret struct {
+	Path string `yaml:"path"`
+	Type string `yaml:"type,omitempty"` // can be 'json', 'yaml', 'equal' and 'plain' (default)
+}
+
+type fileGatherer struct {
+	cfg *FileSecret
+}
+
+// FileGatherer instantiates a File variable gatherer from the given configuration. The fetching process
+// will return either a map containing access paths to the stored JSON, YAML or ShortHand, or a string if
+// the stored secret is just a string.
+// E.g. if the stored secret is `{"db":{"user":"admin","password":"pass"}}`, the returned Map
+// contents will be:
+// "db.user"     -> "admin"
+// "db.password" -> "pass"
+func FileGatherer(file *FileSecret) func() (interface{}, error) {
+	g := fileGatherer{cfg: file}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *fileGatherer) get() (interface{}, error) {
+	dt, err := ioutil.ReadFile(g.cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret file '%s': %s", g.cfg.Path, err)
+	}
+	return decodeSecret(dt, g.cfg.Type)
+}
+
+// Validate checks if the FileSecret configuration is correct
+func (f *FileSecret) Validate() error {
+	if f.Path == "" {
+		return errors.New("file secrets must have a path in order to be set")
+	}
+	return validateType(f.Type)
+}
+
+// decodeSecret decodes a secret payload according to its type. Plain text secrets are
+// trimmed of the trailing end of line, usually appended by editors and secret tooling.
+func decodeSecret(payload []byte, dataType string) (interface{}, error) {
+	if dataType == "" || dataType == typePlain {
+		return strings.TrimRight(string(payload), "\r\n"), nil
+	}
+	return handleDataType(payload, dataType)
+}
+
+func validateType(dataType string) error {
+	switch dataType {
+	case "", typePlain, typeJson, typeYaml, typeEqual:
+		return nil
+	}
+	return errors.New("type can be only " + typePlain + ", " + typeJson + ", " + typeYaml + " or " + typeEqual)
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileGatherer(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		dataType string
+		expected interface{}
+	}{
+		{"plain trims end of line", "my-password\n", "", "my-password"},
+		{"plain explicit", "my-password\r\n", typePlain, "my-password"},
+		{"json", `{"db":{"user":"admin","password":"pass"}}`, typeJson,
+			data.InterfaceMap{"db": map[string]interface{}{"user": "admin", "password": "pass"}}},
+		{"yaml", "db:\n  user: admin\n  password: pass\n", typeYaml,
+			data.InterfaceMap{"db": map[string]interface{}{"user": "admin", "password": "pass"}}},
+		{"equal", "user=admin,password=pass", typeEqual,
+			data.InterfaceMap{"user": "admin", "password": "pass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secret")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.content), 0600))
+
+			f := &FileSecret{Path: path, Type: tt.dataType}
+			require.NoError(t, f.Validate())
+
+			result, err := FileGatherer(f)()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestFileGatherer_FlattenedYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("db:\n  hosts:\n    - a\n    - b\n  port: 5432\n"), 0600))
+
+	result, err := FileGatherer(&FileSecret{Path: path, Type: typeYaml})()
+	require.NoError(t, err)
+
+	values := data.Map{}
+	data.AddValues(values, "creds", result)
+	assert.Equal(t, data.Map{"creds.db.hosts[0]": "a", "creds.db.hosts[1]": "b", "creds.db.port": "5432"}, values)
+}
+
+func TestFileGatherer_Errors(t *testing.T) {
+	_, err := FileGatherer(&FileSecret{Path: filepath.Join(t.TempDir(), "missing")})()
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{not json"), 0600))
+	_, err = FileGatherer(&FileSecret{Path: path, Type: typeJson})()
+	assert.Error(t, err)
+}
+
+func TestFile_Validate(t *testing.T) {
+	assert.Error(t, (&FileSecret{}).Validate())
+	assert.Error(t, (&FileSecret{Path: "/secret", Type: "xml"}).Validate())
+	assert.NoError(t, (&FileSecret{Path: "/secret", Type: typeYaml}).Validate())
+}
+
+func TestSystemdCredentialGatherer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db"), []byte(`{"password":"pass"}`), 0600))
+	t.Setenv(credentialsDirEnv, dir)
+
+	result, err := SystemdCredentialGatherer(&SystemdCredential{Name: "db", Type: typeJson})()
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"password": "pass"}, result)
+
+	_, err = SystemdCredentialGatherer(&SystemdCredential{Name: "missing"})()
+	assert.Error(t, err)
+}
+
+func TestSystemdCredentialGatherer_NoCredentialsDirectory(t *testing.T) {
+	t.Setenv(credentialsDirEnv, "")
+
+	_, err := SystemdCredentialGatherer(&SystemdCredential{Name: "db"})()
+	assert.Error(t, err)
+}
+
+func TestSystemdCredential_Validate(t *testing.T) {
+	assert.Error(t, (&SystemdCredential{}).Validate())
+	assert.Error(t, (&SystemdCredential{Name: "../etc/shadow"}).Validate())
+	assert.NoError(t, (&SystemdCredential{Name: "db"}).Validate())
+}
@@ -17,12 +17,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/ghodss/yaml"
 )
 
 const (
 	typeJson  = "json"  // the output will be decoded as JSON
 	typeEqual = "equal" // the output will be decoded as key1=value1,key2=value2
 	typePlain = "plain" // the output will be decoded just as plain text
+	typeYaml  = "yaml"  // the output will be decoded as YAML
 )
 
 // KMS defines the AWS-KMS data source
@@ -149,7 +151,7 @@ func (g *kmsGatherer) retrieve(encoded []byte) (interface{}, error) {
 	return handleDataType(res.Plaintext, g.cfg.Type)
 }
 
-// this function converts from the stored payload to a map (dataType json, equal)
+// this function converts from the stored payload to a map (dataType json, yaml, equal)
 // or a string (dataType plain)
 func handleDataType(kmsPayload []byte, dataType string) (interface{}, error) {
 	switch dataType {
@@ -160,6 +162,14 @@ func handleDataType(kmsPayload []byte, dataType string) (interface{}, error) {
 			return nil, fmt.Errorf("error hanking KMS data: %s", err.Error())
 		}
 		return jsonResult, nil
+	case typeYaml:
+		var yamlResult data.InterfaceMap
+		// converted through JSON, so nested maps are decoded as map[string]interface{}
+		err := yaml.Unmarshal(kmsPayload, &yamlResult)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding YAML data: %s", err.Error())
+		}
+		return yamlResult, nil
 	case typeEqual:
 		result := data.InterfaceMap{}
 		commaSplit := bytes.Split(kmsPayload, []byte{','})
@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+const (
+	defaultSopsCLI = "sops"
+	defaultAgeCLI  = "age"
+)
+
+// Make mocking simpler
+var decryptExecCommand = exec.Command
+
+// Sops defines a secret stored into a sops encrypted file, decrypted through the sops CLI.
+// Key material (age, PGP, cloud KMS) is resolved by sops itself, e.g. SOPS_AGE_KEY_FILE.
+type Sops struct {
+	CLI  string `yaml:"cli,omitempty"` // defaults to 'sops' from the PATH
+	File string `yaml:"file"`
+	Type string `yaml:"type,omitempty"` // can be 'json', 'yaml', 'equal' and 'plain' (default)
+}
+
+// Age defines a secret stored into an age encrypted file, decrypted through the age CLI.
+type Age struct {
+	CLI      string `yaml:"cli,omitempty"` // defaults to 'age' from the PATH
+	File     string `yaml:"file"`
+	Identity string `yaml:"identity"`       // identity (private key) file
+	Type     string `yaml:"type,omitempty"` // can be 'json', 'yaml', 'equal' and 'plain' (default)
+}
+
+// SopsGatherer instantiates a sops variable gatherer from the given configuration. The decrypted
+// file is decoded as the File gatherer does.
+func SopsGatherer(sops *Sops) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		cli := sops.CLI
+		if cli == "" {
+			cli = defaultSopsCLI
+		}
+		dt, err := decrypt("sops", cli, "--decrypt", sops.File)
+		if err != nil {
+			return "", err
+		}
+		return decodeSecret(dt, sops.Type)
+	}
+}
+
+// AgeGatherer instantiates an age variable gatherer from the given configuration. The decrypted
+// file is decoded as the File gatherer does.
+func AgeGatherer(age *Age) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		cli := age.CLI
+		if cli == "" {
+			cli = defaultAgeCLI
+		}
+		dt, err := decrypt("age", cli, "--decrypt", "--identity", age.Identity, age.File)
+		if err != nil {
+			return "", err
+		}
+		return decodeSecret(dt, age.Type)
+	}
+}
+
+func decrypt(provider, cli string, args ...string) ([]byte, error) {
+	cmd := decryptExecCommand(cli, args...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("unable to decrypt %s secret. err: %s err msg: %s", provider, err, stderr.String())
+	}
+	return out.Bytes(), nil
+}
+
+// Validate checks if the Sops configuration is correct
+func (s *Sops) Validate() error {
+	if s.File == "" {
+		return errors.New("sops secrets must have a file in order to be set")
+	}
+	return validateType(s.Type)
+}
+
+// Validate checks if the Age configuration is correct
+func (a *Age) Validate() error {
+	if a.File == "" || a.Identity == "" {
+		return errors.New("age secrets must have a file and identity in order to be set")
+	}
+	return validateType(a.Type)
+}
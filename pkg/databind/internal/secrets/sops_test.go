@@ -0,0 +1,55 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSopsGatherer(t *testing.T) {
+	var calledWith []string
+	decryptExecCommand = func(command string, args ...string) *exec.Cmd {
+		calledWith = append([]string{command}, args...)
+		return fakeExecCommand(command, args...)
+	}
+	defer func() { decryptExecCommand = exec.Command }()
+
+	result, err := SopsGatherer(&Sops{File: "/etc/secrets.enc"})()
+	require.NoError(t, err)
+	// test helper process may append test output, just test for a prefix match
+	assert.True(t, strings.HasPrefix(result.(string), "password"))
+	assert.Equal(t, []string{"sops", "--decrypt", "/etc/secrets.enc"}, calledWith)
+}
+
+func TestAgeGatherer(t *testing.T) {
+	var calledWith []string
+	decryptExecCommand = func(command string, args ...string) *exec.Cmd {
+		calledWith = append([]string{command}, args...)
+		return fakeExecCommand(command, args...)
+	}
+	defer func() { decryptExecCommand = exec.Command }()
+
+	result, err := AgeGatherer(&Age{CLI: "/usr/local/bin/age", File: "/etc/secret.age", Identity: "/etc/key.txt"})()
+	require.NoError(t, err)
+	// test helper process may append test output, just test for a prefix match
+	assert.True(t, strings.HasPrefix(result.(string), "password"))
+	assert.Equal(t, []string{"/usr/local/bin/age", "--decrypt", "--identity", "/etc/key.txt", "/etc/secret.age"}, calledWith)
+}
+
+func TestDecrypt_Error(t *testing.T) {
+	_, err := SopsGatherer(&Sops{CLI: "/non/existing/sops", File: "/etc/secrets.enc"})()
+	assert.Error(t, err)
+}
+
+func TestSopsAge_Validate(t *testing.T) {
+	assert.Error(t, (&Sops{}).Validate())
+	assert.NoError(t, (&Sops{File: "/etc/secrets.enc", Type: typeYaml}).Validate())
+	assert.Error(t, (&Age{File: "/etc/secret.age"}).Validate())
+	assert.NoError(t, (&Age{File: "/etc/secret.age", Identity: "/etc/key.txt"}).Validate())
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentialsDirEnv is set by systemd to the directory holding the unit credentials.
+const credentialsDirEnv = "CREDENTIALS_DIRECTORY"
+
+// SystemdCredential defines a secret passed by systemd to the agent service through
+// LoadCredential=, LoadCredentialEncrypted= or SetCredential= unit directives.
+type SystemdCredential struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type,omitempty"` // can be 'json', 'yaml', 'equal' and 'plain' (default)
+}
+
+type systemdCredentialGatherer struct {
+	cfg *SystemdCredential
+}
+
+// SystemdCredentialGatherer instantiates a systemd credential variable gatherer from the given
+// configuration. The credential is read from the $CREDENTIALS_DIRECTORY/<name> file and decoded
+// as the File gatherer does.
+func SystemdCredentialGatherer(cred *SystemdCredential) func() (interface{}, error) {
+	g := systemdCredentialGatherer{cfg: cred}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *systemdCredentialGatherer) get() (interface{}, error) {
+	dir, ok := os.LookupEnv(credentialsDirEnv)
+	if !ok || dir == "" {
+		return nil, fmt.Errorf("unable to read systemd credential '%s': %s environment variable is not set", g.cfg.Name, credentialsDirEnv)
+	}
+	dt, err := ioutil.ReadFile(filepath.Join(dir, g.cfg.Name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read systemd credential '%s': %s", g.cfg.Name, err)
+	}
+	return decodeSecret(dt, g.cfg.Type)
+}
+
+// Validate checks if the SystemdCredential configuration is correct
+func (s *SystemdCredential) Validate() error {
+	if s.Name == "" {
+		return errors.New("systemd-credential secrets must have a name in order to be set")
+	}
+	if strings.ContainsAny(s.Name, `/\`) {
+		return errors.New("systemd-credential name can't contain path separators")
+	}
+	return validateType(s.Type)
+}
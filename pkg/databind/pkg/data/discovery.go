@@ -28,6 +28,10 @@ const (
 	Label                      = "label"
 	Command                    = "command"
 	DockerContainerName        = "dockerContainerName"
+	PID                        = "pid"
+	Cmdline                    = "cmdline"
+	User                       = "user"
+	Cwd                        = "cwd"
 	EntityRewriteActionReplace = "replace"
 )
 
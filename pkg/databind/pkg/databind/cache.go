@@ -54,6 +54,7 @@ const (
 	typeDocker  DiscovererType = "docker"
 	typeFargate DiscovererType = "fargate"
 	typeCmd     DiscovererType = "command"
+	typeProcess DiscovererType = "process"
 )
 
 // DiscovererInfo keeps util info about the discoverer.
@@ -14,6 +14,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/docker"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/fargate"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/process"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/secrets"
 )
 
@@ -33,6 +34,7 @@ type YAMLConfig struct {
 		Docker  *discovery.Container `yaml:"docker,omitempty"`
 		Fargate *discovery.Container `yaml:"fargate,omitempty"`
 		Command *discovery.Command   `yaml:"command,omitempty"`
+		Process *discovery.Process   `yaml:"process,omitempty"`
 	} `yaml:"discovery"`
 }
 
@@ -40,17 +42,22 @@ func (y *YAMLConfig) Enabled() bool {
 	return len(y.Variables) > 0 ||
 		y.Discovery.Docker != nil ||
 		y.Discovery.Fargate != nil ||
-		y.Discovery.Command != nil
+		y.Discovery.Command != nil ||
+		y.Discovery.Process != nil
 }
 
 type varEntry struct {
-	TTL         string               `yaml:"ttl,omitempty" json:"ttl,omitempty"`
-	Test        *Test                `yaml:"test,omitempty" json:"test,omitempty"`
-	KMS         *secrets.KMS         `yaml:"aws-kms,omitempty" json:"aws-kms,omitempty"`
-	Vault       *secrets.Vault       `yaml:"vault,omitempty" json:"vault,omitempty"`
-	CyberArkCLI *secrets.CyberArkCLI `yaml:"cyberark-cli,omitempty" json:"cyberark-cli,omitempty"`
-	CyberArkAPI *secrets.CyberArkAPI `yaml:"cyberark-api,omitempty" json:"cyberark-api,omitempty"`
-	Obfuscated  *secrets.Obfuscated  `yaml:"obfuscated,omitempty" json:"obfuscated,omitempty"`
+	TTL         string                     `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	Test        *Test                      `yaml:"test,omitempty" json:"test,omitempty"`
+	KMS         *secrets.KMS               `yaml:"aws-kms,omitempty" json:"aws-kms,omitempty"`
+	Vault       *secrets.Vault             `yaml:"vault,omitempty" json:"vault,omitempty"`
+	CyberArkCLI *secrets.CyberArkCLI       `yaml:"cyberark-cli,omitempty" json:"cyberark-cli,omitempty"`
+	CyberArkAPI *secrets.CyberArkAPI       `yaml:"cyberark-api,omitempty" json:"cyberark-api,omitempty"`
+	Obfuscated  *secrets.Obfuscated        `yaml:"obfuscated,omitempty" json:"obfuscated,omitempty"`
+	File        *secrets.FileSecret        `yaml:"file,omitempty" json:"file,omitempty"`
+	SystemdCred *secrets.SystemdCredential `yaml:"systemd-credential,omitempty" json:"systemd-credential,omitempty"`
+	Sops        *secrets.Sops              `yaml:"sops,omitempty" json:"sops,omitempty"`
+	Age         *secrets.Age               `yaml:"age,omitempty" json:"age,omitempty"`
 }
 
 // Test for testing purposes until providers get decoupled.
@@ -156,6 +163,13 @@ func (dc *YAMLConfig) selectDiscoverer(ttl time.Duration) (*discoverer, error) {
 			fetch: fetch,
 		}, err
 
+	} else if dc.Discovery.Process != nil {
+		fetch, err := process.Discoverer(*dc.Discovery.Process)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
 	}
 	return nil, nil
 }
@@ -178,6 +192,11 @@ func (y *YAMLConfig) addDiscoveryInfo() DiscovererInfo {
 			Name:     fmt.Sprintf("%v", y.Discovery.Command.Exec),
 			Matchers: y.Discovery.Command.Matcher,
 		}
+	} else if y.Discovery.Process != nil {
+		res = DiscovererInfo{
+			Type:     typeProcess,
+			Matchers: y.Discovery.Process.Match,
+		}
 	}
 	return res
 }
@@ -204,6 +223,13 @@ func (y *YAMLConfig) validate() error {
 		}
 	}
 
+	if y.Discovery.Process != nil {
+		sections++
+		if err := y.Discovery.Process.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if sections > 1 {
 		return errors.New("only one discovery source allowed")
 	}
@@ -259,8 +285,32 @@ func (v *varEntry) validate() error {
 			return err
 		}
 	}
+	if v.File != nil {
+		sections++
+		if err := v.File.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.SystemdCred != nil {
+		sections++
+		if err := v.SystemdCred.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.Sops != nil {
+		sections++
+		if err := v.Sops.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.Age != nil {
+		sections++
+		if err := v.Age.Validate(); err != nil {
+			return err
+		}
+	}
 	if sections == 0 {
-		return errors.New("you should specify one source to gather the variable: aws-kms or vault or cyberark-cli")
+		return errors.New("you should specify one source to gather the variable: aws-kms, vault, cyberark-cli, cyberark-api, obfuscated, file, systemd-credential, sops or age")
 	}
 	if sections > 1 {
 		return errors.New("you can't specify more than one source into a single variable. Use another variable")
@@ -297,6 +347,26 @@ func (v *varEntry) selectGatherer(ttl time.Duration) *gatherer {
 			cache: cachedEntry{ttl: ttl},
 			fetch: secrets.ObfuscateGatherer(v.Obfuscated),
 		}
+	} else if v.File != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.FileGatherer(v.File),
+		}
+	} else if v.SystemdCred != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.SystemdCredentialGatherer(v.SystemdCred),
+		}
+	} else if v.Sops != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.SopsGatherer(v.Sops),
+		}
+	} else if v.Age != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.AgeGatherer(v.Age),
+		}
 	} else if v.Test != nil {
 		return &gatherer{
 			cache: cachedEntry{ttl: ttl},
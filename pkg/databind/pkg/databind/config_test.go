@@ -4,9 +4,12 @@
 package databind
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidYAMLs(t *testing.T) {
@@ -41,6 +44,29 @@ variables:
     cyberark-api:
       http:
         url: https://10.1.0.5/AIMWebService/api/Accounts?AppID=NewRelic&Query=Safe=ALL-NERE-WIN-A-NEWRELIC-UP;Object=ALL-localhost-testuser
+`}, {"simple file variable", `
+variables:
+  myData:
+    file:
+      path: /run/secrets/db
+      type: yaml
+`}, {"simple systemd-credential variable", `
+variables:
+  myData:
+    systemd-credential:
+      name: db-password
+`}, {"simple sops variable", `
+variables:
+  myData:
+    sops:
+      file: /etc/newrelic-infra/secrets.enc.json
+      type: json
+`}, {"simple age variable", `
+variables:
+  myData:
+    age:
+      file: /etc/newrelic-infra/secret.age
+      identity: /etc/newrelic-infra/key.txt
 `}}
 	for _, input := range inputs {
 		t.Run(input.description, func(t *testing.T) {
@@ -97,7 +123,23 @@ variables:
     cyberark-api:
       http:
         url: 
-      `}}
+      `}, {"file variable without path", `
+variables:
+  myData:
+    file:
+      type: json
+`}, {"file variable with unknown type", `
+variables:
+  myData:
+    file:
+      path: /run/secrets/db
+      type: xml
+`}, {"age variable without identity", `
+variables:
+  myData:
+    age:
+      file: /etc/newrelic-infra/secret.age
+`}}
 	for _, input := range inputs {
 		t.Run(input.description, func(t *testing.T) {
 			_, err := LoadYAML([]byte(input.yaml))
@@ -105,3 +147,64 @@ variables:
 		})
 	}
 }
+
+func TestFileVariable(t *testing.T) {
+	// GIVEN a secret file with YAML contents
+	secretPath := filepath.Join(t.TempDir(), "db")
+	require.NoError(t, ioutil.WriteFile(secretPath, []byte("user: admin\npassword: pass\n"), 0600))
+
+	// AND a file variable pointing to it
+	sources, err := LoadYAML([]byte(`
+variables:
+  creds:
+    file:
+      path: ` + secretPath + `
+      type: yaml
+`))
+	require.NoError(t, err)
+
+	// WHEN the variables are fetched and replaced
+	b := New()
+	vals, err := b.Fetch(sources)
+	require.NoError(t, err)
+	matches, err := b.Replace(&vals, "${creds.user}:${creds.password}")
+	require.NoError(t, err)
+
+	// THEN the YAML keys are accessible by path
+	require.Len(t, matches, 1)
+	assert.Equal(t, "admin:pass", matches[0].Variables)
+}
+
+func TestProcessDiscovery(t *testing.T) {
+	sources, err := LoadYAML([]byte(`
+discovery:
+  process:
+    match:
+      name: /^postgres$/
+`))
+	require.NoError(t, err)
+
+	assert.NotNil(t, sources.discoverer)
+	assert.Equal(t, DiscovererInfo{Type: typeProcess, Matchers: map[string]string{"name": "/^postgres$/"}}, sources.Info)
+}
+
+func TestProcessDiscovery_Invalid(t *testing.T) {
+	_, err := LoadYAML([]byte(`
+discovery:
+  process:
+    match:
+`))
+	assert.Error(t, err)
+
+	_, err = LoadYAML([]byte(`
+discovery:
+  process:
+    match:
+      name: postgres
+  command:
+    exec: /bin/discover
+    match:
+      name: postgres
+`))
+	assert.Error(t, err)
+}
@@ -32,6 +32,8 @@ func GetLinuxDistro() int {
 				return LINUX_COREOS
 			case identity == "sles":
 				return LINUX_SUSE
+			case identity == "alpine":
+				return LINUX_ALPINE
 			}
 		}
 		// Look alikes
@@ -49,6 +49,15 @@ ANSI_COLOR="1;32"
 HOME_URL="https://coreos.com/"
 BUG_REPORT_URL="https://github.com/coreos/bugs/issues"`,
 	)
+
+	ALPINE = []byte(`
+NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.16.2
+PRETTY_NAME="Alpine Linux v3.16"
+HOME_URL="https://alpinelinux.org/"
+BUG_REPORT_URL="https://gitlab.alpinelinux.org/alpine/aports/-/issues"`,
+	)
 )
 
 func (s *DetectionSuite) TestGetLinuxDistroCoreOS(c *C) {
@@ -67,6 +76,22 @@ func (s *DetectionSuite) TestGetLinuxDistroCoreOS(c *C) {
 	c.Assert(val, Equals, LINUX_COREOS)
 }
 
+func (s *DetectionSuite) TestGetLinuxDistroAlpine(c *C) {
+	tmpEtc, err := ioutil.TempDir("", "/testing")
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer os.RemoveAll(tmpEtc)
+
+	tmpEtc2 := filepath.Join(tmpEtc, "os-release")
+	if err := ioutil.WriteFile(tmpEtc2, ALPINE, 0666); err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("HOST_ETC", tmpEtc)
+	val := GetLinuxDistro()
+	c.Assert(val, Equals, LINUX_ALPINE)
+}
+
 func (s *DetectionSuite) TestGetLinuxDistro(c *C) {
 	tmpEtc, err := ioutil.TempDir("", "/testing")
 	if err != nil {
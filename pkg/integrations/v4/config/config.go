@@ -47,6 +47,28 @@ type EnableConditions struct {
 	// EnvExists conditions the execution of the OHI only if the given
 	// environment variables exists and match the value.
 	EnvExists map[string]string `yaml:"env_exists"`
+	// ProcessRunning conditions the execution of the OHI only if a process with the given name is running.
+	// The `regex "<expression>"` format matches either the process name or command line against a regular expression.
+	ProcessRunning string `yaml:"process_running"`
+	// PortListening conditions the execution of the OHI only if the given local TCP port (or "host:port"
+	// address) accepts connections.
+	PortListening string `yaml:"port_listening"`
+	// CommandSucceeds conditions the execution of the OHI only if the given command exits with 0 status.
+	CommandSucceeds ShlexOpt `yaml:"command_succeeds"`
+	// FileContains conditions the execution of the OHI only if the given file content matches a regular expression.
+	FileContains *FileContainsCondition `yaml:"file_contains"`
+	// Any conditions the execution of the OHI only if any of the given conditions sets is true.
+	// Feature is ignored in nested conditions.
+	Any []EnableConditions `yaml:"any"`
+	// Not conditions the execution of the OHI only if the given conditions set is false.
+	// Feature is ignored in nested conditions.
+	Not *EnableConditions `yaml:"not"`
+}
+
+// FileContainsCondition matches the content of a file against a regular expression.
+type FileContainsCondition struct {
+	Path  string `yaml:"path"`
+	Regex string `yaml:"regex"`
 }
 
 // ShlexOpt is a wrapper around []string so we can use go-shlex for shell tokenizing
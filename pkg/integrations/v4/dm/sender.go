@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/agent/diskqueue"
 	"github.com/newrelic/infrastructure-agent/internal/agent/id"
 
 	telemetry "github.com/newrelic/infrastructure-agent/pkg/backend/telemetryapi"
@@ -29,6 +30,8 @@ type MetricsSenderConfig struct {
 	SubmissionPeriod    time.Duration
 	MaxEntitiesPerReq   int
 	MaxEntitiesPerBatch int
+	// PersistentQueue stores the requests before submitting them, when enabled.
+	PersistentQueue *diskqueue.Queue
 }
 
 func NewConfig(url string, fedramp bool, licenseKey string, submissionPeriod time.Duration, maxEntitiesPerReq int, maxEntitiesPerBatch int) MetricsSenderConfig {
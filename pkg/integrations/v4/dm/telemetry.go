@@ -57,6 +57,7 @@ func newTelemetryHarverster(conf MetricsSenderConfig, transport http.RoundTrippe
 		telemetry.ConfigHarvestPeriod(conf.SubmissionPeriod),
 		telemetry.ConfigMaxEntitiesPerRequest(conf.MaxEntitiesPerReq),
 		telemetry.ConfigMaxEntitiesPerBatch(conf.MaxEntitiesPerBatch),
+		telemetry.ConfigPersistentQueue(conf.PersistentQueue),
 	)
 }
 
@@ -136,6 +136,15 @@ func (l *LogCfg) IsValid() bool {
 	return l.Name != "" && (l.File != "" || l.Systemd != "" || l.Syslog != nil || l.Tcp != nil || l.Fluentbit != nil || l.Winlog != nil || l.Winevtlog != nil)
 }
 
+// Validate returns the reason preventing the config from being translated into FluentBit configuration, if any.
+func (l *LogCfg) Validate(logsHomeDir string) error {
+	if !l.IsValid() {
+		return fmt.Errorf("log entry requires a 'name' and one of 'file', 'systemd', 'syslog', 'tcp', 'fluentbit', 'winlog' or 'winevtlog'")
+	}
+	_, _, _, err := parseConfigBlock(*l, logsHomeDir)
+	return err
+}
+
 // FBCfg FluentBit automatically generated configuration.
 type FBCfg struct {
 	Inputs      []FBCfgInput
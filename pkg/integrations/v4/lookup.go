@@ -0,0 +1,46 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	"path/filepath"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/v3legacy"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const executablesSubFolder = "bin"
+
+// DefinitionFolders returns the folders where the agent looks for the v3 legacy definitions and the
+// integrations executables.
+func DefinitionFolders(cfg *config.Config) []string {
+	return helpers.RemoveEmptyAndDuplicateEntries([]string{
+		cfg.CustomPluginInstallationDir,
+		filepath.Join(cfg.AgentDir, "custom-integrations"),
+		filepath.Join(cfg.AgentDir, config.DefaultIntegrationsDir),
+		filepath.Join(cfg.AgentDir, "bundled-plugins"),
+		filepath.Join(cfg.AgentDir, "plugins"),
+	})
+}
+
+// NewInstancesLookup creates an instance lookup that:
+// - looks in the v3 legacy definitions repository for defined commands
+// - looks in the definition folders (and bin/ subfolders) for executable names
+func NewInstancesLookup(cfg ManagerConfig) integration.InstancesLookup {
+	var execFolders []string
+	for _, df := range cfg.DefinitionFolders {
+		execFolders = append(execFolders, df)
+		execFolders = append(execFolders, filepath.Join(df, executablesSubFolder))
+	}
+	legacyDefinedCommands := v3legacy.NewDefinitionsRepo(v3legacy.LegacyConfig{
+		DefinitionFolders: cfg.DefinitionFolders,
+		Verbose:           cfg.Verbose,
+	})
+	return integration.InstancesLookup{
+		Legacy: legacyDefinedCommands.NewDefinitionCommand,
+		ByName: files.Executables{Folders: execFolders}.Path,
+	}
+}
@@ -101,6 +101,7 @@ type Manager struct {
 	handleConfig             configrequest.HandleFn
 	tracker                  *track.Tracker
 	idLookup                 host.IDLookup
+	statuses                 *runner.StatusRegistry
 }
 
 // groupContext pairs a runner.Group with its cancellation context
@@ -208,6 +209,7 @@ func NewManager(
 		handleConfig:             configrequest.NewHandleFn(configEntryQ, terminateDefinitionQ, il, illog),
 		tracker:                  tracker,
 		idLookup:                 idLookup,
+		statuses:                 runner.NewStatusRegistry(),
 	}
 
 	// Loads all the configuration files from the provided ConfigPaths.
@@ -276,6 +278,11 @@ func (mgr *Manager) RunOnce(ctx context.Context) {
 	wg.Wait()
 }
 
+// IntegrationsStatus returns the health and run history of the running integration instances.
+func (mgr *Manager) IntegrationsStatus() []runner.InstanceStatus {
+	return mgr.statuses.List()
+}
+
 // EnableOHIFromFF enables an integration coming from CC request.
 func (mgr *Manager) EnableOHIFromFF(ctx context.Context, featureFlag string) error {
 	cfgPath, err := mgr.cfgPathForFF(featureFlag)
@@ -323,7 +330,7 @@ func (mgr *Manager) loadEnabledRunnerGroups(cfgs map[string]v4Config.YAML) {
 func (mgr *Manager) loadRunnerGroup(path string, cfg v4Config.YAML, cmdFF *runner.CmdFF) (*groupContext, error) {
 	f := runner.NewFeatures(mgr.managerConfig.AgentFeatures, cmdFF)
 	loader := runner.NewLoadFn(cfg, f)
-	gr, fc, err := runner.NewGroup(loader, mgr.lookup, mgr.managerConfig.PassthroughEnvironment, mgr.emitter, mgr.handleCmdReq, mgr.handleConfig, path, mgr.terminateDefinitionQueue, mgr.idLookup)
+	gr, fc, err := runner.NewGroup(loader, mgr.lookup, mgr.managerConfig.PassthroughEnvironment, mgr.emitter, mgr.handleCmdReq, mgr.handleConfig, path, mgr.terminateDefinitionQueue, mgr.idLookup, mgr.statuses)
 	if err != nil {
 		return nil, err
 	}
@@ -340,7 +347,7 @@ func (mgr *Manager) handleRequestsQueue(ctx context.Context) {
 			return
 
 		case def := <-mgr.definitionQueue:
-			r := runner.NewRunner(def, mgr.emitter, nil, nil, mgr.handleCmdReq, nil, mgr.terminateDefinitionQueue, mgr.idLookup)
+			r := runner.NewRunner(def, mgr.emitter, nil, nil, mgr.handleCmdReq, nil, mgr.terminateDefinitionQueue, mgr.idLookup, mgr.statuses)
 			if def.CmdChanReq != nil {
 				// tracking so cmd requests can be stopped by hash
 				runCtx, pidWCh := mgr.tracker.Track(ctx, def.CmdChanReq.CmdChannelCmdHash, &def)
@@ -355,7 +362,7 @@ func (mgr *Manager) handleRequestsQueue(ctx context.Context) {
 			}
 		case entry := <-mgr.configEntryQueue:
 			ds, _ := entry.Databind.DataSources()
-			r := runner.NewRunner(entry.Definition, mgr.emitter, ds, nil, nil, nil, mgr.terminateDefinitionQueue, mgr.idLookup)
+			r := runner.NewRunner(entry.Definition, mgr.emitter, ds, nil, nil, nil, mgr.terminateDefinitionQueue, mgr.idLookup, mgr.statuses)
 			runCtx, pidWCh := mgr.tracker.Track(ctx, entry.Definition.Hash(), &entry.Definition)
 			go r.Run(runCtx, pidWCh, nil)
 
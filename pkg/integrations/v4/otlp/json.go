@@ -0,0 +1,117 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type jsonObject = map[string]interface{}
+
+// unmarshalJSON decodes an OTLP/JSON ExportMetricsServiceRequest into the generated types with protojson, so
+// both encodings share the data model. The generated types predate the scope metrics and the histogram min and
+// max, so the request is adapted before: scope metrics are renamed to the instrumentation library metrics they
+// replaced, and the histogram min and max are set into the unknown fields, as they are decoded from protobuf.
+// Unknown fields are ignored, as required by the OTLP/JSON specification.
+func unmarshalJSON(b []byte, req *collectormetrics.ExportMetricsServiceRequest) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// keeps the numbers as they are sent, so protojson validates them
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	var histogramsUnknown [][]byte
+	for _, rm := range jsonObjects(jsonField(doc, "resourceMetrics")) {
+		renameJSONField(rm, "scopeMetrics", "instrumentationLibraryMetrics")
+		for _, ilm := range jsonObjects(rm["instrumentationLibraryMetrics"]) {
+			renameJSONField(ilm, "scope", "instrumentationLibrary")
+			for _, m := range jsonObjects(ilm["metrics"]) {
+				for _, p := range jsonObjects(jsonField(m["histogram"], "dataPoints")) {
+					histogramsUnknown = append(histogramsUnknown, takeHistogramMinMax(p))
+				}
+			}
+		}
+	}
+
+	adapted, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(adapted, req); err != nil {
+		return err
+	}
+
+	// data points are decoded in the same order they were walked
+	i := 0
+	for _, rm := range req.GetResourceMetrics() {
+		for _, ilm := range rm.GetInstrumentationLibraryMetrics() {
+			for _, m := range ilm.GetMetrics() {
+				for _, p := range m.GetHistogram().GetDataPoints() {
+					if i < len(histogramsUnknown) && len(histogramsUnknown[i]) > 0 {
+						p.ProtoReflect().SetUnknown(histogramsUnknown[i])
+					}
+					i++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// takeHistogramMinMax removes the min and max from the histogram data point, returning them encoded as
+// the unknown fields of the generated data point.
+func takeHistogramMinMax(p jsonObject) (unknown []byte) {
+	fields := []struct {
+		name   string
+		number protowire.Number
+	}{
+		{"min", histogramMinField},
+		{"max", histogramMaxField},
+	}
+	for _, f := range fields {
+		if n, ok := p[f.name].(json.Number); ok {
+			if v, err := n.Float64(); err == nil {
+				unknown = appendUnknownDouble(unknown, f.number, v)
+			}
+		}
+		delete(p, f.name)
+	}
+	return unknown
+}
+
+// renameJSONField renames the field unless the object already has a field with the new name.
+func renameJSONField(o jsonObject, from, to string) {
+	v, ok := o[from]
+	if !ok {
+		return
+	}
+	if _, ok = o[to]; !ok {
+		o[to] = v
+	}
+	delete(o, from)
+}
+
+func jsonField(v interface{}, name string) interface{} {
+	o, _ := v.(jsonObject)
+	return o[name]
+}
+
+// jsonObjects returns the objects of a JSON array, left for protojson to reject when it's not an objects array.
+func jsonObjects(v interface{}) []jsonObject {
+	values, _ := v.([]interface{})
+	objects := make([]jsonObject, 0, len(values))
+	for _, value := range values {
+		if o, ok := value.(jsonObject); ok {
+			objects = append(objects, o)
+		}
+	}
+	return objects
+}
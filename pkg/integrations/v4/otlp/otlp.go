@@ -0,0 +1,300 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package otlp translates OpenTelemetry OTLP metrics export requests into v4 integration protocol
+// payloads, so they can be handled by the dimensional metrics emitter.
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+const (
+	// IntegrationName is the integration name reported for OTLP metrics.
+	IntegrationName = "com.newrelic.otlp"
+
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+
+	// Attributes added to every metric.
+	AttrScopeName    = "otel.scope.name"
+	AttrScopeVersion = "otel.scope.version"
+	AttrUnit         = "unit"
+
+	nanosPerMilli = 1000000
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// MediaType returns the supported OTLP encoding for a Content-Type header value.
+func MediaType(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+	if mediaType != ContentTypeProtobuf && mediaType != ContentTypeJSON {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedContentType, mediaType)
+	}
+	return mediaType, nil
+}
+
+// ToV4 decodes an OTLP ExportMetricsServiceRequest, encoded as protobuf or JSON depending on the
+// media type, into a v4 payload with a dataset per resource. Both encodings are decoded into the
+// generated OTLP types. Datasets have no entity, so they are decorated with the host entity.
+// Gauges and non-monotonic sums are mapped to gauges, delta sums to counts and cumulative monotonic
+// sums to cumulative counts. Cumulative histograms are mapped to prometheus histograms and delta
+// histograms to summaries, or to ".count" and ".sum" counts when they don't provide min and max.
+// Data points with unsupported types or without temporality are ignored.
+func ToV4(mediaType string, body []byte) (protocol.DataV4, error) {
+	var req collectormetrics.ExportMetricsServiceRequest
+	var err error
+	switch mediaType {
+	case ContentTypeProtobuf:
+		err = proto.Unmarshal(body, &req)
+	case ContentTypeJSON:
+		err = unmarshalJSON(body, &req)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedContentType, mediaType)
+	}
+	if err != nil {
+		return protocol.DataV4{}, fmt.Errorf("cannot decode OTLP metrics: %w", err)
+	}
+
+	data := protocol.DataV4{
+		PluginProtocolVersion: protocol.PluginProtocolVersion{RawProtocolVersion: "4"},
+		Integration:           protocol.IntegrationMetadata{Name: IntegrationName},
+	}
+	for _, rm := range req.GetResourceMetrics() {
+		ds := protocol.Dataset{
+			Common: protocol.Common{Attributes: attributes(rm.GetResource().GetAttributes())},
+		}
+		// scope metrics are decoded as the instrumentation library metrics they replaced
+		for _, ilm := range rm.GetInstrumentationLibraryMetrics() {
+			for _, m := range ilm.GetMetrics() {
+				ds.Metrics = append(ds.Metrics, convertMetric(ilm.GetInstrumentationLibrary(), m)...)
+			}
+		}
+		if len(ds.Metrics) > 0 {
+			data.DataSets = append(data.DataSets, ds)
+		}
+	}
+	return data, nil
+}
+
+func convertMetric(s *commonpb.InstrumentationLibrary, m *metricspb.Metric) (metrics []protocol.Metric) {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, p := range data.Gauge.GetDataPoints() {
+			if mt, ok := numberMetric(s, m, p, protocol.MetricTypeGauge); ok {
+				metrics = append(metrics, mt)
+			}
+		}
+	case *metricspb.Metric_Sum:
+		var metricType protocol.MetricType
+		switch {
+		case !data.Sum.GetIsMonotonic():
+			metricType = protocol.MetricTypeGauge
+		case data.Sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+			metricType = protocol.MetricTypeCount
+		case data.Sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+			metricType = protocol.MetricTypeCumulativeCount
+		default:
+			return nil
+		}
+		for _, p := range data.Sum.GetDataPoints() {
+			if mt, ok := numberMetric(s, m, p, metricType); ok {
+				metrics = append(metrics, mt)
+			}
+		}
+	case *metricspb.Metric_Histogram:
+		for _, p := range data.Histogram.GetDataPoints() {
+			switch data.Histogram.GetAggregationTemporality() {
+			case metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+				metrics = append(metrics, cumulativeHistogram(s, m, p))
+			case metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+				metrics = append(metrics, deltaHistogram(s, m, p)...)
+			}
+		}
+	}
+	return metrics
+}
+
+func numberMetric(s *commonpb.InstrumentationLibrary, m *metricspb.Metric, p *metricspb.NumberDataPoint, metricType protocol.MetricType) (protocol.Metric, bool) {
+	var value interface{}
+	switch v := p.GetValue().(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		value = v.AsDouble
+	case *metricspb.NumberDataPoint_AsInt:
+		value = v.AsInt
+	default:
+		return protocol.Metric{}, false
+	}
+
+	mt := newMetric(m.GetName(), metricType, s, m, p.GetAttributes(), p.GetTimeUnixNano())
+	if metricType.HasInterval() {
+		mt.Interval = interval(p.GetStartTimeUnixNano(), p.GetTimeUnixNano())
+	}
+	mt.Value = rawJSON(value)
+	return mt, true
+}
+
+// cumulativeHistogram converts the data point into a prometheus histogram. The sum presence cannot be told
+// apart in the generated types, so it's always reported.
+func cumulativeHistogram(s *commonpb.InstrumentationLibrary, m *metricspb.Metric, p *metricspb.HistogramDataPoint) protocol.Metric {
+	// OTLP bucket counts are per bucket, while prometheus ones are cumulative. The last OTLP bucket
+	// is the +Inf one, which matches the sample count.
+	type bucket struct {
+		CumulativeCount float64 `json:"cumulative_count"`
+		UpperBound      float64 `json:"upper_bound"`
+	}
+	var cumulative uint64
+	bucketCounts := p.GetBucketCounts()
+	buckets := make([]bucket, 0, len(p.GetExplicitBounds()))
+	for i, bound := range p.GetExplicitBounds() {
+		if i < len(bucketCounts) {
+			cumulative += bucketCounts[i]
+		}
+		buckets = append(buckets, bucket{CumulativeCount: float64(cumulative), UpperBound: bound})
+	}
+
+	// same fields as protocol.PrometheusHistogramValue, which buckets can't be set from this package
+	value := map[string]interface{}{
+		"sample_count": p.GetCount(),
+		"sample_sum":   p.GetSum(),
+		"buckets":      buckets,
+	}
+
+	mt := newMetric(m.GetName(), protocol.MetricTypePrometheusHistogram, s, m, p.GetAttributes(), p.GetTimeUnixNano())
+	mt.Value = rawJSON(value)
+	return mt
+}
+
+func deltaHistogram(s *commonpb.InstrumentationLibrary, m *metricspb.Metric, p *metricspb.HistogramDataPoint) []protocol.Metric {
+	itv := interval(p.GetStartTimeUnixNano(), p.GetTimeUnixNano())
+
+	min, max := unknownDouble(p, histogramMinField), unknownDouble(p, histogramMaxField)
+	if min != nil && max != nil {
+		mt := newMetric(m.GetName(), protocol.MetricTypeSummary, s, m, p.GetAttributes(), p.GetTimeUnixNano())
+		mt.Interval = itv
+		mt.Value = rawJSON(protocol.SummaryValue{
+			Count: float64(p.GetCount()),
+			Min:   *min,
+			Max:   *max,
+			Sum:   p.GetSum(),
+		})
+		return []protocol.Metric{mt}
+	}
+
+	count := newMetric(m.GetName()+".count", protocol.MetricTypeCount, s, m, p.GetAttributes(), p.GetTimeUnixNano())
+	count.Interval = itv
+	count.Value = rawJSON(p.GetCount())
+	sumMetric := newMetric(m.GetName()+".sum", protocol.MetricTypeCount, s, m, p.GetAttributes(), p.GetTimeUnixNano())
+	sumMetric.Interval = itv
+	sumMetric.Value = rawJSON(p.GetSum())
+	return []protocol.Metric{count, sumMetric}
+}
+
+func newMetric(name string, metricType protocol.MetricType, s *commonpb.InstrumentationLibrary, m *metricspb.Metric, attrs []*commonpb.KeyValue, timeUnixNano uint64) protocol.Metric {
+	mt := protocol.Metric{
+		Name:       name,
+		Type:       metricType,
+		Attributes: attributes(attrs),
+	}
+	if s.GetName() != "" {
+		mt.Attributes[AttrScopeName] = s.GetName()
+	}
+	if s.GetVersion() != "" {
+		mt.Attributes[AttrScopeVersion] = s.GetVersion()
+	}
+	if m.GetUnit() != "" {
+		mt.Attributes[AttrUnit] = m.GetUnit()
+	}
+	if timeUnixNano > 0 {
+		ts := int64(timeUnixNano / nanosPerMilli)
+		mt.Timestamp = &ts
+	}
+	return mt
+}
+
+func attributes(kvs []*commonpb.KeyValue) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		if v := attributeValue(kv.GetValue()); v != nil {
+			attrs[kv.GetKey()] = v
+		}
+	}
+	return attrs
+}
+
+// attributeValue returns the value as a type supported by the metric attributes. Arrays and key-value
+// lists are encoded as JSON.
+func attributeValue(v *commonpb.AnyValue) interface{} {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return value.DoubleValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(value.ArrayValue.GetValues()))
+		for _, av := range value.ArrayValue.GetValues() {
+			values = append(values, attributeValue(av))
+		}
+		return string(rawJSON(values))
+	case *commonpb.AnyValue_KvlistValue:
+		return string(rawJSON(attributes(value.KvlistValue.GetValues())))
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(value.BytesValue)
+	}
+	return nil
+}
+
+func interval(startUnixNano, timeUnixNano uint64) *int64 {
+	if startUnixNano == 0 || timeUnixNano <= startUnixNano {
+		return nil
+	}
+	itv := int64((timeUnixNano - startUnixNano) / nanosPerMilli)
+	return &itv
+}
+
+func rawJSON(v interface{}) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// SuccessResponse returns an empty ExportMetricsServiceResponse encoded as the media type.
+func SuccessResponse(mediaType string) []byte {
+	if mediaType == ContentTypeJSON {
+		return []byte("{}")
+	}
+	return []byte{}
+}
+
+// ErrorResponse returns a google.rpc.Status with the passed message encoded as the media type.
+func ErrorResponse(mediaType string, message string) []byte {
+	if mediaType == ContentTypeJSON {
+		return rawJSON(struct {
+			Message string `json:"message"`
+		}{message})
+	}
+	b := protowire.AppendTag(nil, 2, protowire.BytesType)
+	return protowire.AppendString(b, message)
+}
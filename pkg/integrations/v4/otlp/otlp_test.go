@@ -0,0 +1,299 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+const (
+	startNano = uint64(1600000000000000000)
+	timeNano  = uint64(1600000010000000000)
+)
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// exportRequest returns an export request built with the generated OTLP types, as the SDK exporters do.
+func exportRequest(metrics ...*metricspb.Metric) *collectormetrics.ExportMetricsServiceRequest {
+	return &collectormetrics.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttr("service.name", "checkout")}},
+			InstrumentationLibraryMetrics: []*metricspb.InstrumentationLibraryMetrics{{
+				InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: "my.meter", Version: "1.0"},
+				Metrics:                metrics,
+			}},
+		}},
+	}
+}
+
+func protoRequest(t *testing.T, metrics ...*metricspb.Metric) []byte {
+	t.Helper()
+	b, err := proto.Marshal(exportRequest(metrics...))
+	require.NoError(t, err)
+	return b
+}
+
+func metricsByName(t *testing.T, data protocol.DataV4) map[string]protocol.Metric {
+	t.Helper()
+	require.Len(t, data.DataSets, 1)
+	metrics := map[string]protocol.Metric{}
+	for _, m := range data.DataSets[0].Metrics {
+		metrics[m.Name] = m
+	}
+	return metrics
+}
+
+func TestToV4_Protobuf(t *testing.T) {
+	// GIVEN an OTLP protobuf request with a gauge, a delta sum, a cumulative sum and a cumulative histogram
+	gaugeMetric := &metricspb.Metric{
+		Name: "memory.used",
+		Unit: "By",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+			TimeUnixNano: timeNano,
+			Value:        &metricspb.NumberDataPoint_AsInt{AsInt: 1024},
+			Attributes:   []*commonpb.KeyValue{stringAttr("state", "used")},
+		}}}},
+	}
+	deltaSum := &metricspb.Metric{
+		Name: "requests",
+		Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints: []*metricspb.NumberDataPoint{{
+				StartTimeUnixNano: startNano,
+				TimeUnixNano:      timeNano,
+				Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: 5},
+			}},
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			IsMonotonic:            true,
+		}},
+	}
+	cumulativeSum := &metricspb.Metric{
+		Name: "requests.total",
+		Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints: []*metricspb.NumberDataPoint{{
+				StartTimeUnixNano: startNano,
+				TimeUnixNano:      timeNano,
+				Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: 50},
+			}},
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}},
+	}
+	hist := &metricspb.Metric{
+		Name: "latency",
+		Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints: []*metricspb.HistogramDataPoint{{
+				TimeUnixNano:   timeNano,
+				Count:          6,
+				Sum:            12.5,
+				BucketCounts:   []uint64{1, 2, 3},
+				ExplicitBounds: []float64{1, 5},
+			}},
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}},
+	}
+
+	// WHEN translated into the v4 protocol
+	data, err := ToV4(ContentTypeProtobuf, protoRequest(t, gaugeMetric, deltaSum, cumulativeSum, hist))
+	require.NoError(t, err)
+
+	// THEN a dataset with the resource attributes and no entity is returned
+	assert.Equal(t, IntegrationName, data.Integration.Name)
+	metrics := metricsByName(t, data)
+	assert.Equal(t, map[string]interface{}{"service.name": "checkout"}, data.DataSets[0].Common.Attributes)
+	assert.True(t, data.DataSets[0].Entity.IsAgent())
+	require.Len(t, metrics, 4)
+
+	gaugeMt := metrics["memory.used"]
+	assert.Equal(t, protocol.MetricTypeGauge, gaugeMt.Type)
+	assert.JSONEq(t, "1024", string(gaugeMt.Value))
+	assert.Equal(t, int64(1600000010000), *gaugeMt.Timestamp)
+	assert.Equal(t, map[string]interface{}{
+		"state":          "used",
+		AttrScopeName:    "my.meter",
+		AttrScopeVersion: "1.0",
+		AttrUnit:         "By",
+	}, gaugeMt.Attributes)
+
+	assert.Equal(t, protocol.MetricTypeCount, metrics["requests"].Type)
+	assert.Equal(t, int64(10000), *metrics["requests"].Interval)
+	assert.JSONEq(t, "5", string(metrics["requests"].Value))
+
+	assert.Equal(t, protocol.MetricTypeCumulativeCount, metrics["requests.total"].Type)
+	assert.Nil(t, metrics["requests.total"].Interval)
+
+	assert.Equal(t, protocol.MetricTypePrometheusHistogram, metrics["latency"].Type)
+	assert.JSONEq(t, `{
+		"sample_count": 6,
+		"sample_sum": 12.5,
+		"buckets": [{"cumulative_count": 1, "upper_bound": 1}, {"cumulative_count": 3, "upper_bound": 5}]
+	}`, string(metrics["latency"].Value))
+
+	// AND the histogram value can be decoded by the protocol type
+	var histValue protocol.PrometheusHistogramValue
+	require.NoError(t, json.Unmarshal(metrics["latency"].Value, &histValue))
+	assert.Equal(t, uint64(6), *histValue.SampleCount)
+}
+
+func TestToV4_ProtobufHistogramMinMax(t *testing.T) {
+	// GIVEN a delta histogram data point with the min and max fields of the current OTLP schema
+	point := &metricspb.HistogramDataPoint{StartTimeUnixNano: startNano, TimeUnixNano: timeNano, Count: 4, Sum: 10}
+	var minMax []byte
+	minMax = protowire.AppendTag(minMax, histogramMinField, protowire.Fixed64Type)
+	minMax = protowire.AppendFixed64(minMax, math.Float64bits(1))
+	minMax = protowire.AppendTag(minMax, histogramMaxField, protowire.Fixed64Type)
+	minMax = protowire.AppendFixed64(minMax, math.Float64bits(4))
+	point.ProtoReflect().SetUnknown(minMax)
+	hist := &metricspb.Metric{
+		Name: "duration",
+		Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints:             []*metricspb.HistogramDataPoint{point},
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+		}},
+	}
+
+	// WHEN translated into the v4 protocol
+	data, err := ToV4(ContentTypeProtobuf, protoRequest(t, hist))
+	require.NoError(t, err)
+
+	// THEN they are reported in the summary
+	metrics := metricsByName(t, data)
+	assert.Equal(t, protocol.MetricTypeSummary, metrics["duration"].Type)
+	assert.JSONEq(t, `{"count": 4, "sum": 10, "min": 1, "max": 4}`, string(metrics["duration"].Value))
+}
+
+func TestToV4_JSON(t *testing.T) {
+	// GIVEN an OTLP JSON request with a non-monotonic sum and delta histograms
+	body := `{
+	  "resourceMetrics": [{
+	    "resource": {"attributes": [{"key": "host.name", "value": {"stringValue": "foo"}}]},
+	    "scopeMetrics": [{
+	      "scope": {"name": "my.meter"},
+	      "metrics": [
+	        {
+	          "name": "queue.size",
+	          "sum": {
+	            "aggregationTemporality": "AGGREGATION_TEMPORALITY_CUMULATIVE",
+	            "isMonotonic": false,
+	            "dataPoints": [{"timeUnixNano": "1600000010000000000", "asInt": "7",
+	              "attributes": [{"key": "partition", "value": {"intValue": "3"}}]}]
+	          }
+	        },
+	        {
+	          "name": "duration",
+	          "histogram": {
+	            "aggregationTemporality": 1,
+	            "dataPoints": [{"startTimeUnixNano": "1600000000000000000", "timeUnixNano": "1600000010000000000",
+	              "count": "4", "sum": 10, "min": 1, "max": 4}]
+	          }
+	        },
+	        {
+	          "name": "size",
+	          "histogram": {
+	            "aggregationTemporality": 1,
+	            "dataPoints": [{"timeUnixNano": 1600000010000000000, "count": 2, "sum": 3}]
+	          }
+	        },
+	        {
+	          "name": "unspecified",
+	          "sum": {"isMonotonic": true, "dataPoints": [{"asDouble": 1}]}
+	        }
+	      ]
+	    }]
+	  }]
+	}`
+
+	// WHEN translated into the v4 protocol
+	data, err := ToV4(ContentTypeJSON, []byte(body))
+	require.NoError(t, err)
+
+	// THEN metrics are mapped into gauges, summaries and counts
+	metrics := metricsByName(t, data)
+	require.Len(t, metrics, 4)
+
+	assert.Equal(t, protocol.MetricTypeGauge, metrics["queue.size"].Type)
+	assert.JSONEq(t, "7", string(metrics["queue.size"].Value))
+	assert.Equal(t, int64(3), metrics["queue.size"].Attributes["partition"])
+
+	assert.Equal(t, protocol.MetricTypeSummary, metrics["duration"].Type)
+	assert.Equal(t, int64(10000), *metrics["duration"].Interval)
+	assert.JSONEq(t, `{"count": 4, "sum": 10, "min": 1, "max": 4}`, string(metrics["duration"].Value))
+
+	assert.Equal(t, protocol.MetricTypeCount, metrics["size.count"].Type)
+	assert.JSONEq(t, "2", string(metrics["size.count"].Value))
+	assert.Equal(t, protocol.MetricTypeCount, metrics["size.sum"].Type)
+	assert.JSONEq(t, "3", string(metrics["size.sum"].Value))
+}
+
+func TestToV4_JSONMatchesProtobuf(t *testing.T) {
+	// GIVEN a request with 64 bits integers and bytes, which OTLP/JSON encodes as strings
+	gaugeMetric := &metricspb.Metric{
+		Name: "bytes.sent",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+			TimeUnixNano: timeNano,
+			Value:        &metricspb.NumberDataPoint_AsInt{AsInt: math.MaxInt64},
+			Attributes: []*commonpb.KeyValue{
+				{Key: "id", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: []byte{0xca, 0xfe}}}},
+				{Key: "port", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: -1}}},
+			},
+		}}}},
+	}
+	req := exportRequest(gaugeMetric)
+	jsonBody, err := protojson.Marshal(req)
+	require.NoError(t, err)
+	protoBody, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	// WHEN translated from both encodings
+	fromJSON, err := ToV4(ContentTypeJSON, jsonBody)
+	require.NoError(t, err)
+	fromProto, err := ToV4(ContentTypeProtobuf, protoBody)
+	require.NoError(t, err)
+
+	// THEN the same payload is returned
+	assert.Equal(t, fromProto, fromJSON)
+	metrics := metricsByName(t, fromJSON)
+	assert.JSONEq(t, "9223372036854775807", string(metrics["bytes.sent"].Value))
+	assert.Equal(t, "yv4=", metrics["bytes.sent"].Attributes["id"])
+	assert.Equal(t, int64(-1), metrics["bytes.sent"].Attributes["port"])
+}
+
+func TestToV4_Errors(t *testing.T) {
+	_, err := ToV4(ContentTypeProtobuf, []byte{0xff})
+	assert.Error(t, err)
+
+	_, err = ToV4(ContentTypeJSON, []byte(`{"resourceMetrics": {}}`))
+	assert.Error(t, err)
+
+	_, err = ToV4("text/plain", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedContentType)
+}
+
+func TestMediaType(t *testing.T) {
+	mediaType, err := MediaType("application/json; charset=utf-8")
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeJSON, mediaType)
+
+	mediaType, err = MediaType("application/x-protobuf")
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeProtobuf, mediaType)
+
+	_, err = MediaType("text/plain")
+	assert.ErrorIs(t, err, ErrUnsupportedContentType)
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Histogram data point min and max were added to the OTLP schema after the version of the generated types, so
+// they are read from the unknown fields.
+const (
+	histogramMinField protowire.Number = 11
+	histogramMaxField protowire.Number = 12
+)
+
+// unknownDouble returns the value of a double field unknown to the generated message, if present.
+func unknownDouble(m proto.Message, number protowire.Number) (value *float64) {
+	b := m.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return value
+		}
+		b = b[n:]
+		if num == number && typ == protowire.Fixed64Type {
+			v, _ := protowire.ConsumeFixed64(b)
+			d := math.Float64frombits(v)
+			value = &d
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return value
+		}
+		b = b[n:]
+	}
+	return value
+}
+
+// appendUnknownDouble appends a double field unknown to the generated messages to their unknown fields.
+func appendUnknownDouble(b []byte, number protowire.Number, value float64) []byte {
+	b = protowire.AppendTag(b, number, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(value))
+}
@@ -21,6 +21,8 @@ const (
 	MetricTypeGauge   MetricType = "gauge"
 	MetricTypeRate    MetricType = "rate"
 
+	MetricTypeCumulativeCount MetricType = "cumulative-count"
+
 	MetricTypePrometheusSummary   MetricType = "prometheus-summary"
 	MetricTypePrometheusHistogram MetricType = "prometheus-histogram"
 )
@@ -0,0 +1,184 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var cglog = log.WithComponent("CgroupSampler")
+
+var ErrCgroupV2NotFound = fmt.Errorf("cgroup v2 unified hierarchy not found")
+
+// Sample reports the resource usage of a cgroup v2 group. Metrics of controllers not enabled for the group
+// are omitted, as well as the rates on the first sample of the group.
+type Sample struct {
+	sample.BaseEvent
+
+	// Path of the cgroup relative to the cgroup root, i.e. "system.slice/sshd.service"
+	CgroupPath string `json:"cgroupPath"`
+	// Last element of the cgroup path
+	CgroupName string `json:"cgroupName"`
+	// Container ID, when the cgroup name contains one, as in "libpod-<id>.scope"
+	ContainerID string `json:"containerId,omitempty"`
+
+	// Percentage of a single CPU used by the cgroup
+	CPUPercent       *float64 `json:"cpuPercent,omitempty"`
+	CPUUserPercent   *float64 `json:"cpuUserPercent,omitempty"`
+	CPUSystemPercent *float64 `json:"cpuSystemPercent,omitempty"`
+	// CPU bandwidth limit, in number of CPUs
+	CPULimitCores *float64 `json:"cpuLimitCores,omitempty"`
+	// Percentage of the enforcement periods where the cgroup was throttled
+	CPUThrottledPeriodsPercent *float64 `json:"cpuThrottledPeriodsPercent,omitempty"`
+	// Milliseconds the cgroup was throttled per second
+	CPUThrottledMsPerSec *float64 `json:"cpuThrottledMsPerSecond,omitempty"`
+
+	MemoryCurrentBytes *uint64  `json:"memoryCurrentBytes,omitempty"`
+	MemoryMaxBytes     *uint64  `json:"memoryMaxBytes,omitempty"`
+	MemoryUsedPercent  *float64 `json:"memoryUsedPercent,omitempty"`
+	// Total number of memory.events since the cgroup creation
+	MemoryHighEvents    *uint64 `json:"memoryHighEvents,omitempty"`
+	MemoryMaxEvents     *uint64 `json:"memoryMaxEvents,omitempty"`
+	MemoryOomEvents     *uint64 `json:"memoryOomEvents,omitempty"`
+	MemoryOomKillEvents *uint64 `json:"memoryOomKillEvents,omitempty"`
+
+	// I/O of all the devices used by the cgroup
+	IOReadBytesPerSec  *float64 `json:"ioReadBytesPerSecond,omitempty"`
+	IOWriteBytesPerSec *float64 `json:"ioWriteBytesPerSecond,omitempty"`
+	IOReadsPerSec      *float64 `json:"ioReadsPerSecond,omitempty"`
+	IOWritesPerSec     *float64 `json:"ioWritesPerSecond,omitempty"`
+
+	PidsCurrent *uint64 `json:"pidsCurrent,omitempty"`
+	PidsMax     *uint64 `json:"pidsMax,omitempty"`
+}
+
+// Sampler reports a CgroupSample for each cgroup matching the configured include patterns and none of the
+// exclude ones.
+type Sampler struct {
+	sampleRate time.Duration
+	enabled    bool
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	lastStats  map[string]stats
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	cfg := config.NewCgroupConfig()
+	if context != nil {
+		cfg = context.Config().CgroupMetrics
+	}
+	sampleRateSec := cfg.SampleRate
+	if sampleRateSec < config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && sampleRateSec > config.FREQ_DISABLE_SAMPLING {
+		sampleRateSec = config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
+	}
+
+	return &Sampler{
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		enabled:    cfg.Enabled,
+		include:    compilePatterns(cfg.Include),
+		exclude:    compilePatterns(cfg.Exclude),
+		lastStats:  map[string]stats{},
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "CgroupSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING || !s.enabled
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in cgroup.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+	samples, err := s.populate()
+	if err != nil {
+		if errors.Is(err, ErrCgroupV2NotFound) {
+			cglog.WithError(err).Debug("Unable to retrieve cgroup stats.")
+		} else {
+			cglog.WithError(err).Warn("Unable to retrieve cgroup stats.")
+		}
+		return nil, nil
+	}
+	for _, cs := range samples {
+		cs.Type("CgroupSample")
+		eventBatch = append(eventBatch, cs)
+	}
+	return eventBatch, nil
+}
+
+// matches returns whether the cgroup path is included and not excluded.
+func (s *Sampler) matches(path string) bool {
+	return matchesAny(s.include, path) && !matchesAny(s.exclude, path)
+}
+
+func matchesAny(patterns []*regexp.Regexp, path string) bool {
+	for _, p := range patterns {
+		if p.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePatterns compiles the cgroup path patterns, which follow the metrics matchers format for regular
+// expressions (regex "<expression>") and are globs otherwise. Invalid patterns are ignored.
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		expr := globToRegexp(pattern)
+		if strings.HasPrefix(pattern, "regex") {
+			expr = strings.Trim(strings.TrimSpace(strings.TrimPrefix(pattern, "regex")), `"`)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			cglog.WithError(err).WithField("pattern", pattern).Warn("Ignoring invalid cgroup path pattern.")
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// globToRegexp translates a glob where "**" matches any sequence of characters, "*" any sequence within a path
+// element and "?" a single character within a path element.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
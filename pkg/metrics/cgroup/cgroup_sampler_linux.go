@@ -0,0 +1,273 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package cgroup
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// unlimited is the value of the cgroup v2 limit files when no limit is set
+const unlimited = "max"
+
+var containerIDRegex = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// stats holds the cgroup counters needed to calculate rates between samples.
+type stats struct {
+	time         time.Time
+	cpu          map[string]uint64
+	cpuAvailable bool
+	ioReadBytes  uint64
+	ioWriteBytes uint64
+	ioReads      uint64
+	ioWrites     uint64
+	ioAvailable  bool
+}
+
+// populate walks the cgroup v2 hierarchy under the host /sys/fs/cgroup, sampling the matching cgroups.
+func (s *Sampler) populate() ([]*Sample, error) {
+	root := helpers.HostSys("fs", "cgroup")
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCgroupV2NotFound, err)
+	}
+
+	samples := []*Sample{}
+	current := map[string]stats{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		// cgroups may be removed while walking the hierarchy
+		if err != nil || !d.IsDir() || path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || !s.matches(rel) {
+			return nil
+		}
+		sample, st := readCgroup(path, rel, s.lastStats[rel])
+		samples = append(samples, sample)
+		current[rel] = st
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// removed cgroups are forgotten
+	s.lastStats = current
+	return samples, nil
+}
+
+// readCgroup samples the interface files of a cgroup, calculating the rates against the last stats.
+func readCgroup(dir, rel string, last stats) (*Sample, stats) {
+	s := &Sample{
+		CgroupPath:  rel,
+		CgroupName:  filepath.Base(rel),
+		ContainerID: containerIDRegex.FindString(filepath.Base(rel)),
+	}
+	st := stats{time: time.Now()}
+	elapsedSeconds := st.time.Sub(last.time).Seconds()
+
+	if cpu, err := readFlatKeyed(filepath.Join(dir, "cpu.stat")); err == nil {
+		st.cpu = cpu
+		st.cpuAvailable = true
+		if last.cpuAvailable {
+			s.sampleCPU(cpu, last.cpu, elapsedSeconds)
+		}
+	}
+	if quota, period, err := readCPUMax(filepath.Join(dir, "cpu.max")); err == nil && quota != unlimited {
+		q, errQ := strconv.ParseFloat(quota, 64)
+		p, errP := strconv.ParseFloat(period, 64)
+		if errQ == nil && errP == nil && p > 0 {
+			limit := q / p
+			s.CPULimitCores = &limit
+		}
+	}
+
+	s.sampleMemory(dir)
+
+	if read, write, reads, writes, err := readIOStat(filepath.Join(dir, "io.stat")); err == nil {
+		st.ioReadBytes, st.ioWriteBytes, st.ioReads, st.ioWrites = read, write, reads, writes
+		st.ioAvailable = true
+		if last.ioAvailable {
+			s.IOReadBytesPerSec = rate(read, last.ioReadBytes, elapsedSeconds)
+			s.IOWriteBytesPerSec = rate(write, last.ioWriteBytes, elapsedSeconds)
+			s.IOReadsPerSec = rate(reads, last.ioReads, elapsedSeconds)
+			s.IOWritesPerSec = rate(writes, last.ioWrites, elapsedSeconds)
+		}
+	}
+
+	if pids, _, err := readUintOrMax(filepath.Join(dir, "pids.current")); err == nil {
+		s.PidsCurrent = &pids
+	}
+	if max, isMax, err := readUintOrMax(filepath.Join(dir, "pids.max")); err == nil && !isMax {
+		s.PidsMax = &max
+	}
+
+	return s, st
+}
+
+// sampleCPU reports the cpu.stat usage and throttling, in microseconds:
+//
+//	usage_usec 1234
+//	user_usec 1000
+//	system_usec 234
+//	nr_periods 10
+//	nr_throttled 2
+//	throttled_usec 5000
+func (s *Sample) sampleCPU(cpu, last map[string]uint64, elapsedSeconds float64) {
+	usecPerSecToPercent := func(key string) *float64 {
+		current, ok := cpu[key]
+		if !ok {
+			return nil
+		}
+		percent := acquire.CalculateSafeDelta(current, last[key], elapsedSeconds) / 1e4
+		return &percent
+	}
+	s.CPUPercent = usecPerSecToPercent("usage_usec")
+	s.CPUUserPercent = usecPerSecToPercent("user_usec")
+	s.CPUSystemPercent = usecPerSecToPercent("system_usec")
+
+	// throttling stats are only reported when the cpu controller is enabled
+	periods, ok := cpu["nr_periods"]
+	if !ok {
+		return
+	}
+	throttledPercent := float64(0)
+	if periods > last["nr_periods"] && cpu["nr_throttled"] >= last["nr_throttled"] {
+		throttledPercent = float64(cpu["nr_throttled"]-last["nr_throttled"]) / float64(periods-last["nr_periods"]) * 100
+	}
+	s.CPUThrottledPeriodsPercent = &throttledPercent
+	throttledMs := acquire.CalculateSafeDelta(cpu["throttled_usec"], last["throttled_usec"], elapsedSeconds) / 1e3
+	s.CPUThrottledMsPerSec = &throttledMs
+}
+
+// sampleMemory reports the memory usage, limit and events. The files are missing when the memory controller is
+// not enabled for the cgroup.
+func (s *Sample) sampleMemory(dir string) {
+	current, _, err := readUintOrMax(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return
+	}
+	s.MemoryCurrentBytes = &current
+
+	if max, isMax, err := readUintOrMax(filepath.Join(dir, "memory.max")); err == nil && !isMax {
+		s.MemoryMaxBytes = &max
+		if max > 0 {
+			usedPercent := float64(current) / float64(max) * 100
+			s.MemoryUsedPercent = &usedPercent
+		}
+	}
+
+	events, err := readFlatKeyed(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return
+	}
+	value := func(key string) *uint64 {
+		v, ok := events[key]
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	s.MemoryHighEvents = value("high")
+	s.MemoryMaxEvents = value("max")
+	s.MemoryOomEvents = value("oom")
+	s.MemoryOomKillEvents = value("oom_kill")
+}
+
+func rate(current, last uint64, elapsedSeconds float64) *float64 {
+	r := acquire.CalculateSafeDelta(current, last, elapsedSeconds)
+	return &r
+}
+
+// readFlatKeyed parses the "<key> <value>" lines of the cgroup flat keyed files, as cpu.stat or memory.events.
+func readFlatKeyed(path string) (map[string]uint64, error) {
+	lines, err := acquire.ReadLines(path)
+	// EOF is returned after reading the whole file
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	values := map[string]uint64{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, nil
+}
+
+// readIOStat sums the bytes and operations of all the devices of an io.stat file:
+//
+//	8:0 rbytes=90430464 wbytes=299008000 rios=8950 wios=21940 dbytes=0 dios=0
+func readIOStat(path string) (readBytes, writeBytes, reads, writes uint64, err error) {
+	lines, err := acquire.ReadLines(path)
+	if err != nil && err != io.EOF {
+		return 0, 0, 0, 0, err
+	}
+	for _, line := range lines {
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			v, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				readBytes += v
+			case "wbytes":
+				writeBytes += v
+			case "rios":
+				reads += v
+			case "wios":
+				writes += v
+			}
+		}
+	}
+	return readBytes, writeBytes, reads, writes, nil
+}
+
+// readCPUMax returns the quota and period of a cpu.max file, where the quota is "max" when unlimited.
+func readCPUMax(path string) (quota, period string, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("unexpected cpu.max format: %q", content)
+	}
+	return fields[0], fields[1], nil
+}
+
+// readUintOrMax reads a single value file, returning whether it holds the "max" unlimited value.
+func readUintOrMax(path string) (value uint64, isMax bool, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	str := strings.TrimSpace(string(content))
+	if str == unlimited {
+		return 0, true, nil
+	}
+	value, err = strconv.ParseUint(str, 10, 64)
+	return value, false, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+const containerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func writeCgroup(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+}
+
+func TestSampler_Sample(t *testing.T) {
+	// GIVEN a cgroup v2 hierarchy with a limited container scope and an unlimited service
+	sysDir := t.TempDir()
+	root := filepath.Join(sysDir, "fs", "cgroup")
+	writeCgroup(t, root, map[string]string{"cgroup.controllers": "cpu io memory pids\n"})
+	scope := filepath.Join(root, "machine.slice", "libpod-"+containerID+".scope")
+	writeCgroup(t, scope, map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 800000\nsystem_usec 200000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 50000\n",
+		"cpu.max":        "50000 100000\n",
+		"memory.current": "104857600\n",
+		"memory.max":     "209715200\n",
+		"memory.events":  "low 0\nhigh 0\nmax 12\noom 2\noom_kill 1\n",
+		"io.stat":        "8:0 rbytes=1000 wbytes=2000 rios=10 wios=20 dbytes=0 dios=0\n8:16 rbytes=500 wbytes=0 rios=5 wios=0 dbytes=0 dios=0\n",
+		"pids.current":   "7\n",
+		"pids.max":       "100\n",
+	})
+	service := filepath.Join(root, "system.slice", "sshd.service")
+	writeCgroup(t, service, map[string]string{
+		"cpu.stat":       "usage_usec 5000\nuser_usec 4000\nsystem_usec 1000\n",
+		"cpu.max":        "max 100000\n",
+		"memory.current": "4096\n",
+		"memory.max":     "max\n",
+		"pids.current":   "1\n",
+		"pids.max":       "max\n",
+	})
+	writeCgroup(t, filepath.Join(root, "system.slice", "cron.service"), map[string]string{"cpu.stat": "usage_usec 1\n"})
+	t.Setenv("HOST_SYS", sysDir)
+
+	s := newTestSampler(config.CgroupConfig{
+		Enabled:    true,
+		SampleRate: 15,
+		Include:    []string{"system.slice/*.service", "**/*.scope"},
+		Exclude:    []string{"system.slice/cron.service"},
+	})
+
+	// WHEN sampled for the first time
+	results, err := s.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	samples := map[string]*Sample{}
+	for _, r := range results {
+		samples[r.(*Sample).CgroupPath] = r.(*Sample)
+	}
+
+	// THEN the matching cgroups are reported, without rates
+	c := samples[filepath.Join("machine.slice", "libpod-"+containerID+".scope")]
+	require.NotNil(t, c)
+	assert.Equal(t, "CgroupSample", c.EventType)
+	assert.Equal(t, containerID, c.ContainerID)
+	assert.Nil(t, c.CPUPercent)
+	assert.Nil(t, c.IOReadBytesPerSec)
+	assert.Equal(t, 0.5, *c.CPULimitCores)
+	assert.Equal(t, uint64(104857600), *c.MemoryCurrentBytes)
+	assert.Equal(t, uint64(209715200), *c.MemoryMaxBytes)
+	assert.Equal(t, 50.0, *c.MemoryUsedPercent)
+	assert.Equal(t, uint64(12), *c.MemoryMaxEvents)
+	assert.Equal(t, uint64(2), *c.MemoryOomEvents)
+	assert.Equal(t, uint64(1), *c.MemoryOomKillEvents)
+	assert.Equal(t, uint64(7), *c.PidsCurrent)
+	assert.Equal(t, uint64(100), *c.PidsMax)
+
+	// AND unlimited values are omitted
+	svc := samples[filepath.Join("system.slice", "sshd.service")]
+	require.NotNil(t, svc)
+	assert.Equal(t, "sshd.service", svc.CgroupName)
+	assert.Empty(t, svc.ContainerID)
+	assert.Nil(t, svc.CPULimitCores)
+	assert.Equal(t, uint64(4096), *svc.MemoryCurrentBytes)
+	assert.Nil(t, svc.MemoryMaxBytes)
+	assert.Nil(t, svc.MemoryOomKillEvents)
+	assert.Nil(t, svc.PidsMax)
+
+	// WHEN counters increase
+	writeCgroup(t, scope, map[string]string{
+		"cpu.stat": "usage_usec 6000000\nuser_usec 4800000\nsystem_usec 1200000\nnr_periods 200\nnr_throttled 60\nthrottled_usec 1050000\n",
+		"io.stat":  "8:0 rbytes=11000 wbytes=2000 rios=20 wios=20 dbytes=0 dios=0\n8:16 rbytes=10500 wbytes=0 rios=15 wios=0 dbytes=0 dios=0\n",
+	})
+	last := s.lastStats[c.CgroupPath]
+	last.time = last.time.Add(-10 * time.Second)
+	s.lastStats[c.CgroupPath] = last
+	results, err = s.Sample()
+	require.NoError(t, err)
+	for _, r := range results {
+		samples[r.(*Sample).CgroupPath] = r.(*Sample)
+	}
+
+	// THEN CPU, throttling and I/O rates are reported
+	c = samples[c.CgroupPath]
+	assert.InDelta(t, 50, *c.CPUPercent, 0.5)
+	assert.InDelta(t, 40, *c.CPUUserPercent, 0.5)
+	assert.InDelta(t, 10, *c.CPUSystemPercent, 0.5)
+	assert.Equal(t, 50.0, *c.CPUThrottledPeriodsPercent)
+	assert.InDelta(t, 100, *c.CPUThrottledMsPerSec, 1)
+	assert.InDelta(t, 2000, *c.IOReadBytesPerSec, 20)
+	assert.InDelta(t, 0, *c.IOWriteBytesPerSec, 0.1)
+	assert.InDelta(t, 2, *c.IOReadsPerSec, 0.1)
+
+	// AND throttling is omitted when the cpu controller is not enabled
+	assert.Nil(t, samples[svc.CgroupPath].CPUThrottledPeriodsPercent)
+	assert.NotNil(t, samples[svc.CgroupPath].CPUPercent)
+}
+
+func TestSampler_SampleCgroupV1(t *testing.T) {
+	// GIVEN a cgroup v1 hierarchy
+	sysDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(sysDir, "fs", "cgroup", "memory"), 0o755))
+	t.Setenv("HOST_SYS", sysDir)
+
+	// WHEN sampled
+	results, err := newTestSampler(config.CgroupConfig{Enabled: true, SampleRate: 15, Include: []string{"**"}}).Sample()
+
+	// THEN no samples are reported
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
@@ -0,0 +1,13 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build !linux
+// +build !linux
+
+package cgroup
+
+// stats is not used on platforms without cgroups.
+type stats struct{}
+
+func (s *Sampler) populate() ([]*Sample, error) {
+	return nil, ErrCgroupV2NotFound
+}
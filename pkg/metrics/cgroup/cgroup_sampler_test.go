@@ -0,0 +1,57 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package cgroup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+func newTestSampler(cfg config.CgroupConfig) *Sampler {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{CgroupMetrics: cfg})
+	return NewSampler(ctx)
+}
+
+func TestSampler_Disabled(t *testing.T) {
+	assert.True(t, newTestSampler(config.CgroupConfig{SampleRate: 15}).Disabled())
+	assert.True(t, newTestSampler(config.CgroupConfig{Enabled: true, SampleRate: -1}).Disabled())
+	assert.False(t, newTestSampler(config.CgroupConfig{Enabled: true, SampleRate: 15}).Disabled())
+	assert.Equal(t, config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS*time.Second, newTestSampler(config.CgroupConfig{SampleRate: 1}).Interval())
+}
+
+func TestSampler_Matches(t *testing.T) {
+	s := newTestSampler(config.CgroupConfig{
+		Include: []string{"system.slice/*.service", "**/*.scope", `regex "^kubepods"`},
+		Exclude: []string{"system.slice/systemd-*", "**/session-?.scope", "regex \"[\""},
+	})
+
+	tests := map[string]bool{
+		"system.slice/sshd.service":                   true,
+		"system.slice/sshd.service/child":             false,
+		"system.slice/systemd-journald.service":       false,
+		"machine.slice/libpod-abc.scope":              true,
+		"user.slice/user-1000.slice/session-3.scope":  false,
+		"user.slice/user-1000.slice/session-33.scope": true,
+		"kubepods.slice/kubepods-burstable.slice":     true,
+		"init.scope":   false,
+		"system.slice": false,
+		"system.slice/sshd.service.d/override.service": false,
+	}
+	for path, expected := range tests {
+		assert.Equal(t, expected, s.matches(path), path)
+	}
+}
+
+func TestSampler_DefaultPatterns(t *testing.T) {
+	s := NewSampler(nil)
+
+	assert.True(t, s.matches("system.slice/docker.service"))
+	assert.True(t, s.matches("system.slice/docker-0123.scope"))
+	assert.False(t, s.matches("user.slice"))
+}
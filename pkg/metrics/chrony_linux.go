@@ -0,0 +1,164 @@
+// Copyright 2021 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// chronyd command and monitoring protocol, as defined in the chrony candm.h header.
+const (
+	chronyProtocolVersion    = 6
+	chronyPktTypeRequest     = 1
+	chronyPktTypeReply       = 2
+	chronyReqTracking        = 33
+	chronyRpyTracking        = 5
+	chronyStatusSuccess      = 0
+	chronyLeapUnsynchronised = 3
+
+	chronyRequestHeaderLen = 20
+	chronyReplyHeaderLen   = 28
+	// tracking reply data length: ref_id(4) ip_addr(20) stratum(2) leap_status(2) ref_time(12) and 9 floats(36).
+	// Requests are padded to the reply length to prevent traffic amplification.
+	chronyTrackingLen      = 76
+	chronyTrackingReplyLen = chronyReplyHeaderLen + chronyTrackingLen
+
+	chronyTimeoutDefault = time.Second
+)
+
+var ErrChronyReply = errors.New("invalid chronyd reply")
+
+// chronyTracking holds the values of the chronyd tracking report.
+type chronyTracking struct {
+	stratum    uint16
+	leapStatus uint16
+	// current offset of the system clock, which chronyd is slewing to correct
+	currentCorrection time.Duration
+}
+
+func (t *chronyTracking) synced() bool {
+	return t.leapStatus != chronyLeapUnsynchronised
+}
+
+// chronyClient queries chronyd through its command address, which can be an UDP "host:port" address or the path
+// of the chronyd unix socket.
+type chronyClient struct {
+	address string
+	timeout time.Duration
+}
+
+func newChronyClient(address string) *chronyClient {
+	return &chronyClient{address: address, timeout: chronyTimeoutDefault}
+}
+
+func (c *chronyClient) tracking() (*chronyTracking, error) {
+	conn, closeConn, err := c.dial()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to chronyd: %w", err)
+	}
+	defer closeConn()
+
+	if err = conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	sequence := rand.Uint32()
+	if _, err = conn.Write(chronyTrackingRequest(sequence)); err != nil {
+		return nil, fmt.Errorf("cannot send chronyd request: %w", err)
+	}
+	reply := make([]byte, 1024)
+	n, err := conn.Read(reply)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read chronyd reply: %w", err)
+	}
+	return parseChronyTracking(reply[:n], sequence)
+}
+
+// dial connects to the chronyd address. Replies through the unix socket are sent to the client socket, which is
+// bound next to the chronyd one, as chronyc does.
+func (c *chronyClient) dial() (net.Conn, func(), error) {
+	if !strings.HasPrefix(c.address, "/") {
+		conn, err := net.DialTimeout("udp", c.address, c.timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, func() { conn.Close() }, nil
+	}
+
+	local := filepath.Join(filepath.Dir(c.address), fmt.Sprintf("nria.%d.sock", os.Getpid()))
+	_ = os.Remove(local)
+	conn, err := net.DialUnix("unixgram",
+		&net.UnixAddr{Name: local, Net: "unixgram"},
+		&net.UnixAddr{Name: c.address, Net: "unixgram"})
+	if err != nil {
+		return nil, nil, err
+	}
+	// chronyd drops its privileges, so it must be allowed to write to the client socket
+	if err = os.Chmod(local, 0o666); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, func() { conn.Close(); _ = os.Remove(local) }, nil
+}
+
+func chronyTrackingRequest(sequence uint32) []byte {
+	req := make([]byte, chronyTrackingReplyLen)
+	req[0] = chronyProtocolVersion
+	req[1] = chronyPktTypeRequest
+	binary.BigEndian.PutUint16(req[4:], chronyReqTracking)
+	binary.BigEndian.PutUint32(req[8:], sequence)
+	return req
+}
+
+// parseChronyTracking decodes a tracking reply, laid out as:
+//
+//	header: version(1) pkt_type(1) res(2) command(2) reply(2) status(2) pad(6) sequence(4) pad(8)
+//	data: ref_id(4) ip_addr(20) stratum(2) leap_status(2) ref_time(12) current_correction(4) ...
+func parseChronyTracking(reply []byte, sequence uint32) (*chronyTracking, error) {
+	if len(reply) < chronyTrackingReplyLen {
+		return nil, fmt.Errorf("%w: %d bytes", ErrChronyReply, len(reply))
+	}
+	if reply[0] != chronyProtocolVersion || reply[1] != chronyPktTypeReply {
+		return nil, fmt.Errorf("%w: version %d, packet type %d", ErrChronyReply, reply[0], reply[1])
+	}
+	if seq := binary.BigEndian.Uint32(reply[16:]); seq != sequence {
+		return nil, fmt.Errorf("%w: unexpected sequence %d", ErrChronyReply, seq)
+	}
+	if status := binary.BigEndian.Uint16(reply[8:]); status != chronyStatusSuccess {
+		return nil, fmt.Errorf("%w: status %d", ErrChronyReply, status)
+	}
+	if rpy := binary.BigEndian.Uint16(reply[6:]); rpy != chronyRpyTracking {
+		return nil, fmt.Errorf("%w: unexpected reply %d", ErrChronyReply, rpy)
+	}
+
+	data := reply[chronyReplyHeaderLen:]
+	correction := chronyFloat(binary.BigEndian.Uint32(data[40:]))
+	return &chronyTracking{
+		stratum:           binary.BigEndian.Uint16(data[24:]),
+		leapStatus:        binary.BigEndian.Uint16(data[26:]),
+		currentCorrection: time.Duration(correction * float64(time.Second)),
+	}, nil
+}
+
+// chronyFloat decodes the chronyd float format: a 7 bits signed exponent followed by a 25 bits signed
+// coefficient.
+func chronyFloat(x uint32) float64 {
+	exp := int32(x >> 25)
+	if exp >= 1<<6 {
+		exp -= 1 << 7
+	}
+	coef := int32(x & (1<<25 - 1))
+	if coef >= 1<<24 {
+		coef -= 1 << 25
+	}
+	return float64(coef) * math.Pow(2, float64(exp-25))
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeChronyFloat is the inverse of chronyFloat, as chronyd UTI_FloatHostToNetwork.
+func encodeChronyFloat(x float64) uint32 {
+	if x == 0 {
+		return 0
+	}
+	exp := int32(math.Floor(math.Log2(math.Abs(x)))) + 1
+	coef := int32(math.Round(x * math.Pow(2, float64(25-exp))))
+	for coef >= 1<<24 || coef < -(1<<24) {
+		coef >>= 1
+		exp++
+	}
+	return uint32(exp&0x7f)<<25 | uint32(coef)&(1<<25-1)
+}
+
+// recordedTrackingReply reads the chronyd tracking reply fixture, written as commented hex bytes.
+func recordedTrackingReply(t *testing.T) []byte {
+	content, err := ioutil.ReadFile("fixtures/chrony/tracking_reply.hex")
+	require.NoError(t, err)
+	var reply []byte
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		b, err := hex.DecodeString(strings.ReplaceAll(line, " ", ""))
+		require.NoError(t, err)
+		reply = append(reply, b...)
+	}
+	return reply
+}
+
+// trackingReply returns the recorded tracking reply with the given values.
+func trackingReply(t *testing.T, sequence uint32, stratum, leap uint16, correction float64) []byte {
+	reply := recordedTrackingReply(t)
+	binary.BigEndian.PutUint32(reply[16:], sequence)
+	data := reply[chronyReplyHeaderLen:]
+	binary.BigEndian.PutUint16(data[24:], stratum)
+	binary.BigEndian.PutUint16(data[26:], leap)
+	binary.BigEndian.PutUint32(data[40:], encodeChronyFloat(correction))
+	return reply
+}
+
+// serveTracking replies to a single tracking request with the given values.
+func serveTracking(t *testing.T, conn net.PacketConn, stratum, leap uint16, correction float64) {
+	go func() {
+		req := make([]byte, 1024)
+		n, addr, err := conn.ReadFrom(req)
+		if err != nil {
+			return
+		}
+		assert.Equal(t, chronyTrackingReplyLen, n)
+		assert.Equal(t, uint16(chronyReqTracking), binary.BigEndian.Uint16(req[4:]))
+		_, err = conn.WriteTo(trackingReply(t, binary.BigEndian.Uint32(req[8:]), stratum, leap, correction), addr)
+		assert.NoError(t, err)
+	}()
+}
+
+func TestChronyFloat(t *testing.T) {
+	for _, value := range []float64{0, 1, -1, 0.000123, -0.0421, 1234.5678} {
+		assert.InDelta(t, value, chronyFloat(encodeChronyFloat(value)), math.Abs(value)*1e-6, "value %v", value)
+	}
+}
+
+func TestParseChronyTracking_Recorded(t *testing.T) {
+	// GIVEN the reply of chronyd to a tracking request
+	reply := recordedTrackingReply(t)
+	require.Len(t, reply, chronyTrackingReplyLen)
+
+	// WHEN parsed
+	tracking, err := parseChronyTracking(reply, 0x5ac3e1f0)
+
+	// THEN the tracking values are decoded
+	require.NoError(t, err)
+	assert.Equal(t, uint16(4), tracking.stratum)
+	assert.True(t, tracking.synced())
+	assert.InDelta(t, -1.858, float64(tracking.currentCorrection)/float64(time.Microsecond), 1e-3)
+}
+
+func TestParseChronyTracking(t *testing.T) {
+	// GIVEN a tracking reply
+	reply := trackingReply(t, 42, 3, 0, -0.0015)
+
+	// WHEN parsed
+	tracking, err := parseChronyTracking(reply, 42)
+
+	// THEN the tracking values are decoded
+	require.NoError(t, err)
+	assert.Equal(t, uint16(3), tracking.stratum)
+	assert.True(t, tracking.synced())
+	assert.InDelta(t, -1.5, float64(tracking.currentCorrection)/float64(time.Millisecond), 1e-6)
+}
+
+func TestParseChronyTracking_Invalid(t *testing.T) {
+	unsuccessful := trackingReply(t, 42, 3, 0, 0)
+	binary.BigEndian.PutUint16(unsuccessful[8:], 2)
+
+	testCases := map[string][]byte{
+		"truncated":         trackingReply(t, 42, 3, 0, 0)[:chronyReplyHeaderLen],
+		"unexpected seq":    trackingReply(t, 41, 3, 0, 0),
+		"unsuccessful":      unsuccessful,
+		"not a reply":       chronyTrackingRequest(42),
+		"unexpected length": {},
+	}
+	for name, reply := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseChronyTracking(reply, 42)
+			assert.ErrorIs(t, err, ErrChronyReply)
+		})
+	}
+}
+
+func TestChronyClient_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	serveTracking(t, conn, 2, chronyLeapUnsynchronised, 0.25)
+
+	tracking, err := newChronyClient(conn.LocalAddr().String()).tracking()
+
+	require.NoError(t, err)
+	assert.Equal(t, uint16(2), tracking.stratum)
+	assert.False(t, tracking.synced())
+	assert.Equal(t, 250*time.Millisecond, tracking.currentCorrection)
+}
+
+func TestChronyClient_UnixSocket(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "chronyd.sock")
+	conn, err := net.ListenPacket("unixgram", address)
+	require.NoError(t, err)
+	defer conn.Close()
+	serveTracking(t, conn, 4, 0, 0)
+
+	tracking, err := newChronyClient(address).tracking()
+
+	require.NoError(t, err)
+	assert.Equal(t, uint16(4), tracking.stratum)
+	assert.True(t, tracking.synced())
+	// the client socket is removed
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(address), "nria.*.sock"))
+	assert.Empty(t, matches)
+}
+
+func TestChronyClient_Timeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := newChronyClient(conn.LocalAddr().String())
+	client.timeout = 50 * time.Millisecond
+	_, err = client.tracking()
+
+	assert.Error(t, err)
+}
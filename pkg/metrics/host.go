@@ -13,6 +13,11 @@ import (
 type HostSample struct {
 	Uptime    uint64   `json:"uptime"`
 	NtpOffset *float64 `json:"ntpOffset,omitempty"`
+	NtpSynced *bool    `json:"ntpSynced,omitempty"`
+	// Kernel estimated and maximum clock errors
+	ClockEstimatedErrorMs *float64 `json:"clockEstimatedErrorMs,omitempty"`
+	ClockMaxErrorMs       *float64 `json:"clockMaxErrorMs,omitempty"`
+	NtpStratum            *uint16  `json:"ntpStratum,omitempty"`
 }
 
 type HostMonitor struct {
@@ -23,6 +28,21 @@ type NtpMonitor interface {
 	Offset() (time.Duration, error)
 }
 
+// TimeSyncMonitor is a NtpMonitor which also reports the clock synchronization status.
+type TimeSyncMonitor interface {
+	NtpMonitor
+	SyncStatus() (*TimeSyncStatus, error)
+}
+
+// TimeSyncStatus holds the clock synchronization values known by the monitor, the unknown ones are nil.
+type TimeSyncStatus struct {
+	Synced         *bool
+	EstimatedError *time.Duration
+	MaxError       *time.Duration
+	Stratum        *uint16
+	Offset         *time.Duration
+}
+
 func NewHostMonitor(ntpMonitor NtpMonitor) *HostMonitor {
 	return &HostMonitor{ntpMonitor: ntpMonitor}
 }
@@ -35,7 +55,14 @@ func (m *HostMonitor) Sample() (*HostSample, error) {
 	}
 	hostSample.Uptime = uptime
 
-	if m.ntpMonitor != nil {
+	if syncMonitor, ok := m.ntpMonitor.(TimeSyncMonitor); ok {
+		status, err := syncMonitor.SyncStatus()
+		if err != nil {
+			syslog.WithError(err).Error("cannot get time sync status")
+		} else {
+			hostSample.setSyncStatus(status)
+		}
+	} else if m.ntpMonitor != nil {
 		ntpOffset, err := m.ntpMonitor.Offset()
 		if err != nil {
 			syslog.WithError(err).Error("cannot get ntp offset")
@@ -47,3 +74,21 @@ func (m *HostMonitor) Sample() (*HostSample, error) {
 
 	return hostSample, nil
 }
+
+func (s *HostSample) setSyncStatus(status *TimeSyncStatus) {
+	toMs := func(d *time.Duration) *float64 {
+		if d == nil {
+			return nil
+		}
+		ms := float64(*d) / float64(time.Millisecond)
+		return &ms
+	}
+	if status.Offset != nil {
+		seconds := status.Offset.Seconds()
+		s.NtpOffset = &seconds
+	}
+	s.NtpSynced = status.Synced
+	s.ClockEstimatedErrorMs = toMs(status.EstimatedError)
+	s.ClockMaxErrorMs = toMs(status.MaxError)
+	s.NtpStratum = status.Stratum
+}
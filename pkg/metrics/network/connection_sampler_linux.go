@@ -0,0 +1,315 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// TCP states, as reported in hexadecimal by /proc/net/tcp
+const (
+	tcpEstablished = 0x01
+	tcpSynSent     = 0x02
+	tcpSynRecv     = 0x03
+	tcpFinWait1    = 0x04
+	tcpFinWait2    = 0x05
+	tcpTimeWait    = 0x06
+	tcpClose       = 0x07
+	tcpCloseWait   = 0x08
+	tcpLastAck     = 0x09
+	tcpListen      = 0x0A
+	tcpClosing     = 0x0B
+	tcpStatesCount = 0x0C
+)
+
+// /proc/net/snmp and /proc/net/netstat counters, keyed as "<protocol>.<counter>"
+const (
+	counterRetransSegs     = "Tcp.RetransSegs"
+	counterListenOverflows = "TcpExt.ListenOverflows"
+	counterListenDrops     = "TcpExt.ListenDrops"
+	counterReqQFullDrop    = "TcpExt.TCPReqQFullDrop"
+)
+
+// NetworkConnectionSample summarizes the host TCP and UDP sockets, and the kernel network counters.
+// Metric groups that are disabled or can't be read are omitted.
+type NetworkConnectionSample struct {
+	sample.BaseEvent
+
+	TCPEstablished *uint64 `json:"tcpConnectionsEstablished,omitempty"`
+	TCPSynSent     *uint64 `json:"tcpConnectionsSynSent,omitempty"`
+	TCPSynRecv     *uint64 `json:"tcpConnectionsSynRecv,omitempty"`
+	TCPFinWait1    *uint64 `json:"tcpConnectionsFinWait1,omitempty"`
+	TCPFinWait2    *uint64 `json:"tcpConnectionsFinWait2,omitempty"`
+	TCPTimeWait    *uint64 `json:"tcpConnectionsTimeWait,omitempty"`
+	TCPClose       *uint64 `json:"tcpConnectionsClose,omitempty"`
+	TCPCloseWait   *uint64 `json:"tcpConnectionsCloseWait,omitempty"`
+	TCPLastAck     *uint64 `json:"tcpConnectionsLastAck,omitempty"`
+	TCPListen      *uint64 `json:"tcpConnectionsListen,omitempty"`
+	TCPClosing     *uint64 `json:"tcpConnectionsClosing,omitempty"`
+	// Bytes waiting in the receive and send queues of the non-listening TCP sockets
+	TCPReceiveQueueBytes *uint64 `json:"tcpReceiveQueueBytes,omitempty"`
+	TCPSendQueueBytes    *uint64 `json:"tcpSendQueueBytes,omitempty"`
+	// Connections waiting to be accepted by the listening TCP sockets
+	TCPListenBacklog *uint64 `json:"tcpListenBacklog,omitempty"`
+	UDPSockets       *uint64 `json:"udpSockets,omitempty"`
+
+	TCPRetransmitsPerSec *float64 `json:"tcpRetransmitsPerSecond,omitempty"`
+
+	TCPListenOverflowsPerSec *float64 `json:"tcpListenOverflowsPerSecond,omitempty"`
+	TCPListenDropsPerSec     *float64 `json:"tcpListenDropsPerSecond,omitempty"`
+	TCPSynDropsPerSec        *float64 `json:"tcpSynDropsPerSecond,omitempty"`
+
+	ConntrackEntries     *uint64  `json:"conntrackEntries,omitempty"`
+	ConntrackMax         *uint64  `json:"conntrackMax,omitempty"`
+	ConntrackUsedPercent *float64 `json:"conntrackUsedPercent,omitempty"`
+}
+
+// ConnectionSampler reports the NetworkConnectionSample at the network sample rate.
+type ConnectionSampler struct {
+	cfg            config.NetworkConnectionConfig
+	sampleInterval time.Duration
+	lastRun        time.Time
+	lastCounters   map[string]uint64
+}
+
+func NewConnectionSampler(context agent.AgentContext) *ConnectionSampler {
+	samplerIntervalSec := config.FREQ_INTERVAL_FLOOR_NETWORK_METRICS
+	var cfg config.NetworkConnectionConfig
+	if context != nil {
+		samplerIntervalSec = context.Config().MetricsNetworkSampleRate
+		cfg = context.Config().NetworkConnectionMetrics
+	}
+
+	return &ConnectionSampler{
+		cfg:            cfg,
+		sampleInterval: time.Second * time.Duration(samplerIntervalSec),
+	}
+}
+
+func (cs *ConnectionSampler) Name() string { return "NetworkConnectionSampler" }
+
+func (cs *ConnectionSampler) Interval() time.Duration {
+	return cs.sampleInterval
+}
+
+func (cs *ConnectionSampler) Disabled() bool {
+	return cs.Interval() <= config.FREQ_DISABLE_SAMPLING || !cs.cfg.Enabled()
+}
+
+func (cs *ConnectionSampler) OnStartup() {}
+
+func (cs *ConnectionSampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in NetworkConnectionSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	s := &NetworkConnectionSample{}
+	s.Type("NetworkConnectionSample")
+
+	if cs.cfg.TCPStates {
+		if err := s.sampleSockets(); err != nil {
+			nslog.WithError(err).Debug("Cannot sample network sockets.")
+		}
+	}
+
+	if cs.cfg.Retransmits || cs.cfg.ListenDrops {
+		cs.sampleCounters(s)
+	}
+
+	if cs.cfg.Conntrack {
+		s.sampleConntrack()
+	}
+
+	helpers.LogStructureDetails(nslog, s, "NetworkConnectionSample", "final", nil)
+
+	return sample.EventBatch{s}, nil
+}
+
+func (s *NetworkConnectionSample) sampleSockets() error {
+	var states [tcpStatesCount]uint64
+	var recvQueue, sendQueue, listenBacklog uint64
+	for _, file := range []string{"tcp", "tcp6"} {
+		err := readSockets(helpers.HostProc("net", file), func(state, txQueue, rxQueue uint64) {
+			if state < tcpStatesCount {
+				states[state]++
+			}
+			if state == tcpListen {
+				listenBacklog += rxQueue
+			} else {
+				recvQueue += rxQueue
+				sendQueue += txQueue
+			}
+		})
+		// tcp6 is missing when IPv6 is disabled
+		if err != nil && !(file == "tcp6" && os.IsNotExist(err)) {
+			return err
+		}
+	}
+
+	var udpSockets uint64
+	for _, file := range []string{"udp", "udp6"} {
+		err := readSockets(helpers.HostProc("net", file), func(_, _, _ uint64) {
+			udpSockets++
+		})
+		if err != nil && !(file == "udp6" && os.IsNotExist(err)) {
+			return err
+		}
+	}
+
+	s.TCPEstablished = &states[tcpEstablished]
+	s.TCPSynSent = &states[tcpSynSent]
+	s.TCPSynRecv = &states[tcpSynRecv]
+	s.TCPFinWait1 = &states[tcpFinWait1]
+	s.TCPFinWait2 = &states[tcpFinWait2]
+	s.TCPTimeWait = &states[tcpTimeWait]
+	s.TCPClose = &states[tcpClose]
+	s.TCPCloseWait = &states[tcpCloseWait]
+	s.TCPLastAck = &states[tcpLastAck]
+	s.TCPListen = &states[tcpListen]
+	s.TCPClosing = &states[tcpClosing]
+	s.TCPReceiveQueueBytes = &recvQueue
+	s.TCPSendQueueBytes = &sendQueue
+	s.TCPListenBacklog = &listenBacklog
+	s.UDPSockets = &udpSockets
+	return nil
+}
+
+// readSockets calls fn with the state and queue sizes of every socket in a /proc/net/{tcp,udp}[6] file:
+//
+//	sl  local_address rem_address   st tx_queue:rx_queue ...
+//	 0: 0100007F:0277 00000000:0000 0A 00000000:00000000 ...
+func readSockets(path string, fn func(state, txQueue, rxQueue uint64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			continue
+		}
+		queues := strings.SplitN(fields[4], ":", 2)
+		if len(queues) != 2 {
+			continue
+		}
+		txQueue, _ := strconv.ParseUint(queues[0], 16, 64)
+		rxQueue, _ := strconv.ParseUint(queues[1], 16, 64)
+		fn(state, txQueue, rxQueue)
+	}
+	return scanner.Err()
+}
+
+// sampleCounters reports the per second rates of the kernel counters. Rates are omitted on the first sample.
+func (cs *ConnectionSampler) sampleCounters(s *NetworkConnectionSample) {
+	counters := map[string]uint64{}
+	for _, file := range []string{"snmp", "netstat"} {
+		if err := readCounters(helpers.HostProc("net", file), counters); err != nil {
+			nslog.WithError(err).WithField("file", file).Debug("Cannot read network counters.")
+		}
+	}
+
+	now := time.Now()
+	if cs.lastCounters != nil {
+		elapsedSeconds := now.Sub(cs.lastRun).Seconds()
+		rate := func(counter string) *float64 {
+			current, ok := counters[counter]
+			if !ok {
+				return nil
+			}
+			last, ok := cs.lastCounters[counter]
+			if !ok {
+				return nil
+			}
+			r := acquire.CalculateSafeDelta(current, last, elapsedSeconds)
+			return &r
+		}
+
+		if cs.cfg.Retransmits {
+			s.TCPRetransmitsPerSec = rate(counterRetransSegs)
+		}
+		if cs.cfg.ListenDrops {
+			s.TCPListenOverflowsPerSec = rate(counterListenOverflows)
+			s.TCPListenDropsPerSec = rate(counterListenDrops)
+			s.TCPSynDropsPerSec = rate(counterReqQFullDrop)
+		}
+	}
+	cs.lastRun = now
+	cs.lastCounters = counters
+}
+
+// readCounters parses the /proc/net/{snmp,netstat} files, where each protocol is reported as a header
+// line with the counter names followed by a line with their values:
+//
+//	Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens ...
+//	Tcp: 1 200 120000 -1 3416 ...
+func readCounters(path string, counters map[string]uint64) error {
+	lines, err := acquire.ReadLines(path)
+	// EOF is returned after reading the whole file
+	if err != nil && err != io.EOF {
+		return err
+	}
+	for i := 0; i+1 < len(lines); i += 2 {
+		names := strings.Fields(lines[i])
+		values := strings.Fields(lines[i+1])
+		if len(names) == 0 || len(names) != len(values) || names[0] != values[0] {
+			return fmt.Errorf("unexpected format at line %d", i+1)
+		}
+		protocol := strings.TrimSuffix(names[0], ":")
+		for j := 1; j < len(names); j++ {
+			// negative values, as MaxConn, are not counters
+			if v, err := strconv.ParseUint(values[j], 10, 64); err == nil {
+				counters[protocol+"."+names[j]] = v
+			}
+		}
+	}
+	return nil
+}
+
+// sampleConntrack reports the netfilter connection tracking table usage, when the module is loaded.
+func (s *NetworkConnectionSample) sampleConntrack() {
+	entries, err := readUint(helpers.HostProc("sys", "net", "netfilter", "nf_conntrack_count"))
+	if err != nil {
+		return
+	}
+	s.ConntrackEntries = &entries
+
+	max, err := readUint(helpers.HostProc("sys", "net", "netfilter", "nf_conntrack_max"))
+	if err != nil || max == 0 {
+		return
+	}
+	s.ConntrackMax = &max
+	usedPercent := float64(entries) / float64(max) * 100
+	s.ConntrackUsedPercent = &usedPercent
+}
+
+func readUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+func newTestConnectionSampler(cfg config.NetworkConnectionConfig) *ConnectionSampler {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{
+		MetricsNetworkSampleRate: 10,
+		NetworkConnectionMetrics: cfg,
+	})
+	return NewConnectionSampler(ctx)
+}
+
+func TestConnectionSampler_Disabled(t *testing.T) {
+	assert.True(t, newTestConnectionSampler(config.NetworkConnectionConfig{}).Disabled())
+	assert.False(t, newTestConnectionSampler(config.NetworkConnectionConfig{Conntrack: true}).Disabled())
+	assert.Equal(t, 10*time.Second, newTestConnectionSampler(config.NetworkConnectionConfig{}).Interval())
+}
+
+func TestConnectionSampler_Sample(t *testing.T) {
+	// GIVEN proc fixture files
+	t.Setenv("HOST_PROC", filepath.Join("fixtures", "proc"))
+	cs := newTestConnectionSampler(config.NetworkConnectionConfig{TCPStates: true, Conntrack: true})
+
+	// WHEN sampling socket states and conntrack usage
+	results, err := cs.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	s := results[0].(*NetworkConnectionSample)
+
+	// THEN TCP sockets are counted per state for IPv4 and IPv6
+	assert.Equal(t, uint64(3), *s.TCPListen)
+	assert.Equal(t, uint64(2), *s.TCPEstablished)
+	assert.Equal(t, uint64(1), *s.TCPTimeWait)
+	assert.Equal(t, uint64(1), *s.TCPCloseWait)
+	assert.Equal(t, uint64(0), *s.TCPSynRecv)
+	assert.Equal(t, uint64(3), *s.TCPListenBacklog)
+	assert.Equal(t, uint64(0x10), *s.TCPReceiveQueueBytes)
+	assert.Equal(t, uint64(0x24), *s.TCPSendQueueBytes)
+	// AND the missing udp6 file is ignored
+	assert.Equal(t, uint64(2), *s.UDPSockets)
+
+	// AND the conntrack table usage is reported
+	assert.Equal(t, uint64(3000), *s.ConntrackEntries)
+	assert.Equal(t, uint64(12000), *s.ConntrackMax)
+	assert.Equal(t, 25.0, *s.ConntrackUsedPercent)
+
+	// AND disabled groups are omitted
+	assert.Nil(t, s.TCPRetransmitsPerSec)
+}
+
+func TestConnectionSampler_SampleCounterRates(t *testing.T) {
+	procDir := t.TempDir()
+	netDir := filepath.Join(procDir, "net")
+	require.NoError(t, os.MkdirAll(netDir, 0o755))
+	for _, file := range []string{"snmp", "netstat"} {
+		content, err := ioutil.ReadFile(filepath.Join("fixtures", "proc", "net", file))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(netDir, file), content, 0o644))
+	}
+	t.Setenv("HOST_PROC", procDir)
+	cs := newTestConnectionSampler(config.NetworkConnectionConfig{Retransmits: true, ListenDrops: true})
+
+	// GIVEN a first sample, which has no rates
+	results, err := cs.Sample()
+	require.NoError(t, err)
+	assert.Nil(t, results[0].(*NetworkConnectionSample).TCPRetransmitsPerSec)
+
+	// WHEN counters increase
+	netstat := "TcpExt: ListenOverflows ListenDrops TCPReqQFullDrop\nTcpExt: 70 90 5\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netDir, "netstat"), []byte(netstat), 0o644))
+	snmp, err := ioutil.ReadFile(filepath.Join(netDir, "snmp"))
+	require.NoError(t, err)
+	snmp = []byte(strings.Replace(string(snmp), "150000 1000 0 155", "150000 1100 0 155", 1))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netDir, "snmp"), snmp, 0o644))
+	cs.lastRun = cs.lastRun.Add(-10 * time.Second)
+
+	results, err = cs.Sample()
+	require.NoError(t, err)
+	s := results[0].(*NetworkConnectionSample)
+
+	// THEN per second rates are reported
+	assert.InDelta(t, 10, *s.TCPRetransmitsPerSec, 0.1)
+	assert.InDelta(t, 2, *s.TCPListenOverflowsPerSec, 0.1)
+	assert.InDelta(t, 3, *s.TCPListenDropsPerSec, 0.1)
+	assert.Equal(t, 0.0, *s.TCPSynDropsPerSec)
+	assert.Nil(t, s.TCPEstablished)
+}
+
+func TestReadCounters_InvalidFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snmp")
+	require.NoError(t, ioutil.WriteFile(path, []byte("Tcp: RtoAlgorithm RtoMin\nTcp: 1\n"), 0o644))
+
+	assert.Error(t, readCounters(path, map[string]uint64{}))
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+// PressureSample reports the Linux Pressure Stall Information (PSI): the share of time, averaged over
+// the last 10, 60 and 300 seconds, in which some (or all) non-idle tasks were stalled on a resource, and
+// the total stall time in microseconds since the previous sample.
+// Metrics are omitted when the kernel doesn't support PSI.
+type PressureSample struct {
+	CPUSomeAvg10      *float64 `json:"cpuSomePressureAvg10,omitempty"`
+	CPUSomeAvg60      *float64 `json:"cpuSomePressureAvg60,omitempty"`
+	CPUSomeAvg300     *float64 `json:"cpuSomePressureAvg300,omitempty"`
+	CPUSomeTotalDelta *uint64  `json:"cpuSomePressureTotalDelta,omitempty"`
+
+	MemorySomeAvg10      *float64 `json:"memorySomePressureAvg10,omitempty"`
+	MemorySomeAvg60      *float64 `json:"memorySomePressureAvg60,omitempty"`
+	MemorySomeAvg300     *float64 `json:"memorySomePressureAvg300,omitempty"`
+	MemorySomeTotalDelta *uint64  `json:"memorySomePressureTotalDelta,omitempty"`
+	MemoryFullAvg10      *float64 `json:"memoryFullPressureAvg10,omitempty"`
+	MemoryFullAvg60      *float64 `json:"memoryFullPressureAvg60,omitempty"`
+	MemoryFullAvg300     *float64 `json:"memoryFullPressureAvg300,omitempty"`
+	MemoryFullTotalDelta *uint64  `json:"memoryFullPressureTotalDelta,omitempty"`
+
+	IOSomeAvg10      *float64 `json:"ioSomePressureAvg10,omitempty"`
+	IOSomeAvg60      *float64 `json:"ioSomePressureAvg60,omitempty"`
+	IOSomeAvg300     *float64 `json:"ioSomePressureAvg300,omitempty"`
+	IOSomeTotalDelta *uint64  `json:"ioSomePressureTotalDelta,omitempty"`
+	IOFullAvg10      *float64 `json:"ioFullPressureAvg10,omitempty"`
+	IOFullAvg60      *float64 `json:"ioFullPressureAvg60,omitempty"`
+	IOFullAvg300     *float64 `json:"ioFullPressureAvg300,omitempty"`
+	IOFullTotalDelta *uint64  `json:"ioFullPressureTotalDelta,omitempty"`
+}
+
+type PressureMonitor struct {
+	// last total stall time for each resource line, e.g. "io.full"
+	lastTotals map[string]uint64
+}
+
+func NewPressureMonitor() *PressureMonitor {
+	return &PressureMonitor{lastTotals: map[string]uint64{}}
+}
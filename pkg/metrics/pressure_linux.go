@@ -0,0 +1,106 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// pressureLine holds the values of a /proc/pressure line, e.g.:
+// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+type pressureLine struct {
+	avg10, avg60, avg300 float64
+	total                uint64
+}
+
+// Sample reads the PSI files from HOST_PROC/pressure. It returns a nil sample when they are not
+// present, as happens in kernels older than 4.20 or booted without PSI support.
+func (m *PressureMonitor) Sample() (*PressureSample, error) {
+	cpu, err := readPressure("cpu")
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	memory, err := readPressure("memory")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ioPressure, err := readPressure("io")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	s := &PressureSample{}
+	s.CPUSomeAvg10, s.CPUSomeAvg60, s.CPUSomeAvg300, s.CPUSomeTotalDelta = m.fields("cpu.some", cpu["some"])
+	s.MemorySomeAvg10, s.MemorySomeAvg60, s.MemorySomeAvg300, s.MemorySomeTotalDelta = m.fields("memory.some", memory["some"])
+	s.MemoryFullAvg10, s.MemoryFullAvg60, s.MemoryFullAvg300, s.MemoryFullTotalDelta = m.fields("memory.full", memory["full"])
+	s.IOSomeAvg10, s.IOSomeAvg60, s.IOSomeAvg300, s.IOSomeTotalDelta = m.fields("io.some", ioPressure["some"])
+	s.IOFullAvg10, s.IOFullAvg60, s.IOFullAvg300, s.IOFullTotalDelta = m.fields("io.full", ioPressure["full"])
+	return s, nil
+}
+
+// fields returns the sample values of a pressure line. The total delta is nil on the first sample
+// and when the counter goes backwards.
+func (m *PressureMonitor) fields(key string, l *pressureLine) (avg10, avg60, avg300 *float64, totalDelta *uint64) {
+	if l == nil {
+		return nil, nil, nil, nil
+	}
+	last, ok := m.lastTotals[key]
+	m.lastTotals[key] = l.total
+	if ok && l.total >= last {
+		delta := l.total - last
+		totalDelta = &delta
+	}
+	return &l.avg10, &l.avg60, &l.avg300, totalDelta
+}
+
+func readPressure(resource string) (map[string]*pressureLine, error) {
+	lines, err := acquire.ReadLines(helpers.HostProc("pressure", resource))
+	// EOF is returned after reading the whole file
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return parsePressure(lines)
+}
+
+func parsePressure(lines []string) (map[string]*pressureLine, error) {
+	parsed := map[string]*pressureLine{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		l := &pressureLine{}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid pressure field %q", field)
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				l.avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				l.avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				l.avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				l.total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid pressure field %q: %w", field, err)
+			}
+		}
+		parsed[fields[0]] = l
+	}
+	return parsed, nil
+}
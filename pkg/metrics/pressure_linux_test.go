@@ -0,0 +1,84 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPressureMonitor_Sample(t *testing.T) {
+	// GIVEN PSI fixture files under the overridden host proc
+	t.Setenv("HOST_PROC", "fixtures")
+	m := NewPressureMonitor()
+
+	// WHEN sampling them
+	sample, err := m.Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	// THEN the averages are reported
+	assert.Equal(t, 1.5, *sample.CPUSomeAvg10)
+	assert.Equal(t, 0.8, *sample.CPUSomeAvg60)
+	assert.Equal(t, 0.25, *sample.CPUSomeAvg300)
+	assert.Equal(t, 0.3, *sample.MemorySomeAvg10)
+	assert.Equal(t, 0.01, *sample.MemoryFullAvg300)
+	assert.Equal(t, 4.2, *sample.IOSomeAvg10)
+	assert.Equal(t, 1.95, *sample.IOFullAvg60)
+
+	// AND no total deltas are reported on the first sample
+	assert.Nil(t, sample.CPUSomeTotalDelta)
+	assert.Nil(t, sample.IOFullTotalDelta)
+}
+
+func TestPressureMonitor_SampleTotalDelta(t *testing.T) {
+	procDir := t.TempDir()
+	pressureDir := filepath.Join(procDir, "pressure")
+	require.NoError(t, os.MkdirAll(pressureDir, 0o755))
+	writePressure := func(cpuTotal, ioTotal string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pressureDir, "cpu"),
+			[]byte("some avg10=0.00 avg60=0.00 avg300=0.00 total="+cpuTotal+"\n"), 0o644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pressureDir, "io"),
+			[]byte("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total="+ioTotal+"\n"), 0o644))
+	}
+	t.Setenv("HOST_PROC", procDir)
+	m := NewPressureMonitor()
+
+	// GIVEN a first sample
+	writePressure("1000", "500")
+	_, err := m.Sample()
+	require.NoError(t, err)
+
+	// WHEN the stall totals increase
+	writePressure("4000", "700")
+	sample, err := m.Sample()
+	require.NoError(t, err)
+
+	// THEN the total deltas are reported
+	assert.Equal(t, uint64(3000), *sample.CPUSomeTotalDelta)
+	assert.Equal(t, uint64(200), *sample.IOFullTotalDelta)
+	// AND the missing memory file is ignored
+	assert.Nil(t, sample.MemorySomeAvg10)
+}
+
+func TestPressureMonitor_SampleUnsupported(t *testing.T) {
+	t.Setenv("HOST_PROC", t.TempDir())
+
+	sample, err := NewPressureMonitor().Sample()
+
+	assert.NoError(t, err)
+	assert.Nil(t, sample)
+}
+
+func TestParsePressure_Invalid(t *testing.T) {
+	_, err := parsePressure([]string{"some avg10=foo avg60=0.00 avg300=0.00 total=0"})
+	assert.Error(t, err)
+
+	_, err = parsePressure([]string{"some avg10"})
+	assert.Error(t, err)
+}
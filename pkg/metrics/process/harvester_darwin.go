@@ -51,6 +51,9 @@ type darwinHarvester struct {
 
 var _ Harvester = (*darwinHarvester)(nil) // static interface assertion
 
+// StartCycle does nothing, as the darwin harvester doesn't hold any state per sampling cycle
+func (*darwinHarvester) StartCycle() {}
+
 // Pids returns a slice of process IDs that are running now
 func (*darwinHarvester) Pids() ([]int32, error) {
 	return process.Pids()
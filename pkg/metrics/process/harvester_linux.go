@@ -25,12 +25,19 @@ func newHarvester(ctx agent.AgentContext, cache *cache) *linuxHarvester {
 	disableZeroRSSFilter := cfg != nil && cfg.DisableZeroRSSFilter
 	stripCommandLine := (cfg != nil && cfg.StripCommandLine) || (cfg == nil && config.DefaultStripCommandLine)
 
+	// reading the file descriptors of other users' processes requires privileges
+	var network *processNetwork
+	if cfg != nil && cfg.EnableProcessNetworkMetrics && privileged {
+		network = newProcessNetwork(cfg.ProcessNetworkMetricsMaxFds)
+	}
+
 	return &linuxHarvester{
 		privileged:           privileged,
 		disableZeroRSSFilter: disableZeroRSSFilter,
 		stripCommandLine:     stripCommandLine,
 		serviceForPid:        ctx.GetServiceForPid,
 		cache:                cache,
+		network:              network,
 	}
 }
 
@@ -41,12 +48,21 @@ type linuxHarvester struct {
 	stripCommandLine     bool
 	cache                *cache
 	serviceForPid        func(int) (string, bool)
+	// network is nil when the process network metrics are disabled
+	network *processNetwork
 }
 
 var _ Harvester = (*linuxHarvester)(nil) // static interface assertion
 
+// StartCycle refreshes the sockets used by the process network metrics.
+func (ps *linuxHarvester) StartCycle() {
+	if ps.network != nil {
+		ps.network.reset()
+	}
+}
+
 // Pids returns a slice of process IDs that are running now
-func (*linuxHarvester) Pids() ([]int32, error) {
+func (ps *linuxHarvester) Pids() ([]int32, error) {
 	return process.Pids()
 }
 
@@ -92,6 +108,10 @@ func (ps *linuxHarvester) Do(pid int32, elapsedSeconds float64) (*types.ProcessS
 		return nil, errors.Wrap(err, "can't fetch deltas")
 	}
 
+	if ps.network != nil {
+		ps.network.populate(sample)
+	}
+
 	// This must happen every time, even if we already had a cached sample for the process, because
 	// the available process name metadata may have changed underneath us (if we pick up a new
 	// service/PID association, etc)
@@ -24,6 +24,8 @@ var errProcessWithoutRSS = fmt.Errorf("process with zero rss")
 // Harvester manages sampling for individual processes. It is used by the Process Sampler to get information about the
 // existing processes.
 type Harvester interface {
+	// StartCycle prepares the harvester for a new sampling cycle, before harvesting its processes
+	StartCycle()
 	// Pids return the IDs of all the processes that are currently running
 	Pids() ([]int32, error)
 	// Do performs the actual harvesting operation, returning a process sample containing all the metrics data
@@ -14,6 +14,8 @@ type HarvesterMock struct {
 	mock.Mock
 }
 
+func (h *HarvesterMock) StartCycle() {}
+
 func (h *HarvesterMock) Pids() ([]int32, error) {
 	args := h.Called()
 
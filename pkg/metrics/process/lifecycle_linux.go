@@ -0,0 +1,139 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"sort"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/sampler"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const (
+	infrastructureEventType = "InfrastructureEvent"
+	processEventCategory    = "process"
+)
+
+// ProcessEventAttributes identify the process of a lifecycle event.
+type ProcessEventAttributes struct {
+	ProcessID          int32  `json:"processId"`
+	ParentProcessID    int32  `json:"parentProcessId,omitempty"`
+	ProcessDisplayName string `json:"processDisplayName"`
+	CommandName        string `json:"commandName"`
+	CmdLine            string `json:"commandLine,omitempty"`
+	User               string `json:"userName,omitempty"`
+	ContainerID        string `json:"containerId,omitempty"`
+}
+
+// ProcessStartEvent is reported when a process is first seen by the process sampler.
+type ProcessStartEvent struct {
+	sample.BaseEvent
+	Category string `json:"category"`
+	Summary  string `json:"summary"`
+	ProcessEventAttributes
+}
+
+// ProcessExitEvent is reported when a process is no longer seen by the process sampler.
+type ProcessExitEvent struct {
+	sample.BaseEvent
+	Category string `json:"category"`
+	Summary  string `json:"summary"`
+	ProcessEventAttributes
+}
+
+func newProcessStartEvent(s *types.ProcessSample) *ProcessStartEvent {
+	e := &ProcessStartEvent{
+		Category:               processEventCategory,
+		Summary:                "Process started",
+		ProcessEventAttributes: eventAttributes(s),
+	}
+	e.Type(infrastructureEventType)
+	return e
+}
+
+func newProcessExitEvent(s *types.ProcessSample) *ProcessExitEvent {
+	e := &ProcessExitEvent{
+		Category:               processEventCategory,
+		Summary:                "Process exited",
+		ProcessEventAttributes: eventAttributes(s),
+	}
+	e.Type(infrastructureEventType)
+	return e
+}
+
+func eventAttributes(s *types.ProcessSample) ProcessEventAttributes {
+	return ProcessEventAttributes{
+		ProcessID:          s.ProcessID,
+		ParentProcessID:    s.ParentProcessID,
+		ProcessDisplayName: s.ProcessDisplayName,
+		CommandName:        s.CommandName,
+		CmdLine:            s.CmdLine,
+		User:               s.User,
+		ContainerID:        s.ContainerID,
+	}
+}
+
+// lifecycleTracker diffs the process snapshots of consecutive sampling cycles, reporting the start and exit of
+// the processes matching the configured expressions. Nothing is reported on the first cycle, as the processes
+// running when the agent starts are not new.
+type lifecycleTracker struct {
+	matcher sampler.MatcherChain
+	last    map[int32]*types.ProcessSample
+}
+
+func newLifecycleTracker(cfg config.ProcessLifecycleConfig) *lifecycleTracker {
+	return &lifecycleTracker{matcher: sampler.NewMatcherChain(cfg.Match)}
+}
+
+func (lt *lifecycleTracker) matches(s *types.ProcessSample) bool {
+	return !lt.matcher.Enabled || lt.matcher.Evaluate(s)
+}
+
+// events returns the lifecycle events given the running pids and the samples harvested for them. Processes that
+// couldn't be harvested in this cycle keep their last sample, so they are not considered as exited. A pid reused
+// by a different command is reported as an exit followed by a start.
+func (lt *lifecycleTracker) events(pids []int32, current map[int32]*types.ProcessSample) (events []sample.Event) {
+	alive := make(map[int32]*types.ProcessSample, len(pids))
+	for _, pid := range pids {
+		if s, ok := current[pid]; ok {
+			alive[pid] = s
+		} else if s, ok := lt.last[pid]; ok {
+			alive[pid] = s
+		}
+	}
+
+	if lt.last != nil {
+		for _, pid := range sortedPids(lt.last) {
+			last := lt.last[pid]
+			s, ok := alive[pid]
+			if ok && s.CommandName == last.CommandName {
+				continue
+			}
+			if lt.matches(last) {
+				events = append(events, newProcessExitEvent(last))
+			}
+			if ok && lt.matches(s) {
+				events = append(events, newProcessStartEvent(s))
+			}
+		}
+		for _, pid := range sortedPids(alive) {
+			if _, seen := lt.last[pid]; !seen && lt.matches(alive[pid]) {
+				events = append(events, newProcessStartEvent(alive[pid]))
+			}
+		}
+	}
+
+	lt.last = alive
+	return events
+}
+
+func sortedPids(samples map[int32]*types.ProcessSample) []int32 {
+	pids := make([]int32, 0, len(samples))
+	for pid := range samples {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	return pids
+}
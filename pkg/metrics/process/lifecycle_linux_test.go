@@ -0,0 +1,97 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+func processSamples(samples ...*types.ProcessSample) ([]int32, map[int32]*types.ProcessSample) {
+	pids := make([]int32, 0, len(samples))
+	current := map[int32]*types.ProcessSample{}
+	for _, s := range samples {
+		pids = append(pids, s.ProcessID)
+		current[s.ProcessID] = s
+	}
+	return pids, current
+}
+
+func TestLifecycleTracker_Events(t *testing.T) {
+	sshd := &types.ProcessSample{ProcessID: 10, CommandName: "sshd", ProcessDisplayName: "sshd"}
+	java := &types.ProcessSample{ProcessID: 20, CommandName: "java", ProcessDisplayName: "java", User: "app"}
+	cron := &types.ProcessSample{ProcessID: 30, CommandName: "cron", ProcessDisplayName: "cron"}
+	lt := newLifecycleTracker(config.ProcessLifecycleConfig{Enabled: true})
+
+	// GIVEN a first snapshot, which is not reported
+	assert.Empty(t, lt.events(processSamples(sshd, java)))
+
+	// WHEN a process exits and another one starts
+	events := lt.events(processSamples(sshd, cron))
+
+	// THEN both are reported
+	require.Len(t, events, 2)
+	exit, ok := events[0].(*ProcessExitEvent)
+	require.True(t, ok)
+	assert.Equal(t, "InfrastructureEvent", exit.EventType)
+	assert.Equal(t, "process", exit.Category)
+	assert.Equal(t, "Process exited", exit.Summary)
+	assert.Equal(t, int32(20), exit.ProcessID)
+	assert.Equal(t, "app", exit.User)
+	start, ok := events[1].(*ProcessStartEvent)
+	require.True(t, ok)
+	assert.Equal(t, "Process started", start.Summary)
+	assert.Equal(t, "cron", start.CommandName)
+}
+
+func TestLifecycleTracker_NotHarvestedProcessIsNotExited(t *testing.T) {
+	sshd := &types.ProcessSample{ProcessID: 10, CommandName: "sshd"}
+	lt := newLifecycleTracker(config.ProcessLifecycleConfig{Enabled: true})
+	lt.events(processSamples(sshd))
+
+	// GIVEN a running process whose sample couldn't be harvested
+	events := lt.events([]int32{10}, map[int32]*types.ProcessSample{})
+	assert.Empty(t, events)
+
+	// WHEN it exits later
+	events = lt.events(nil, nil)
+
+	// THEN its exit is reported from its last sample
+	require.Len(t, events, 1)
+	assert.Equal(t, "sshd", events[0].(*ProcessExitEvent).CommandName)
+}
+
+func TestLifecycleTracker_ReusedPid(t *testing.T) {
+	lt := newLifecycleTracker(config.ProcessLifecycleConfig{Enabled: true})
+	lt.events(processSamples(&types.ProcessSample{ProcessID: 10, CommandName: "sh"}))
+
+	events := lt.events(processSamples(&types.ProcessSample{ProcessID: 10, CommandName: "python"}))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "sh", events[0].(*ProcessExitEvent).CommandName)
+	assert.Equal(t, "python", events[1].(*ProcessStartEvent).CommandName)
+}
+
+func TestLifecycleTracker_Match(t *testing.T) {
+	// GIVEN a tracker only matching java processes
+	lt := newLifecycleTracker(config.ProcessLifecycleConfig{
+		Enabled: true,
+		Match:   config.IncludeMetricsMap{"process.name": []string{`regex "^java"`}},
+	})
+	lt.events(processSamples(&types.ProcessSample{ProcessID: 10, ProcessDisplayName: "sshd"}))
+
+	// WHEN java and cron start and sshd exits
+	events := lt.events(processSamples(
+		&types.ProcessSample{ProcessID: 20, ProcessDisplayName: "java"},
+		&types.ProcessSample{ProcessID: 30, ProcessDisplayName: "cron"},
+	))
+
+	// THEN only java is reported
+	require.Len(t, events, 1)
+	assert.Equal(t, int32(20), events[0].(*ProcessStartEvent).ProcessID)
+}
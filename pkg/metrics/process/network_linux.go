@@ -0,0 +1,168 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+const (
+	tcpEstablished = "01"
+	tcpListen      = "0A"
+	udpUnconnected = "07"
+
+	socketLinkPrefix = "socket:["
+)
+
+// socket is an entry of the /proc/net/{tcp,udp}[6] tables.
+type socket struct {
+	protocol string
+	port     uint64
+	state    string
+}
+
+func (s socket) listening() bool {
+	return (s.protocol == "TCP" && s.state == tcpListen) || (s.protocol == "UDP" && s.state == udpUnconnected)
+}
+
+// processNetwork populates the process samples with their listening ports and connections, matching the
+// socket inodes of their file descriptors against the host socket tables. The socket tables are read once per
+// sampling cycle, and the number of file descriptors inspected per cycle is bounded by maxFds.
+type processNetwork struct {
+	maxFds    int
+	remaining int
+	sockets   map[uint64]socket
+}
+
+func newProcessNetwork(maxFds int) *processNetwork {
+	return &processNetwork{maxFds: maxFds}
+}
+
+// reset starts a new sampling cycle.
+func (pn *processNetwork) reset() {
+	pn.remaining = pn.maxFds
+	pn.sockets = map[uint64]socket{}
+	for _, file := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		protocol := strings.ToUpper(strings.TrimSuffix(file, "6"))
+		// IPv6 tables are missing when it's disabled
+		if err := readSocketTable(helpers.HostProc("net", file), protocol, pn.sockets); err != nil && !os.IsNotExist(err) {
+			mplog.WithError(err).WithField("file", file).Debug("Can't read sockets table.")
+		}
+	}
+}
+
+// populate adds the network attributes to the sample. They are omitted when the file descriptors budget for the
+// current cycle has been exhausted, which is warned once per cycle.
+func (pn *processNetwork) populate(sample *types.ProcessSample) {
+	if pn.remaining <= 0 || len(pn.sockets) == 0 {
+		return
+	}
+
+	fdDir := helpers.HostProc(strconv.Itoa(int(sample.ProcessID)), "fd")
+	f, err := os.Open(fdDir)
+	if err != nil {
+		return
+	}
+	fds, err := f.Readdirnames(pn.remaining + 1)
+	_ = f.Close()
+	if err != nil && len(fds) == 0 {
+		return
+	}
+	if len(fds) > pn.remaining {
+		mplog.WithField("processID", sample.ProcessID).WithField("maxFds", pn.maxFds).
+			Warn("Process network metrics file descriptors limit reached, omitting them for the remaining processes of the sampling cycle. Consider increasing process_network_metrics_max_fds.")
+		pn.remaining = 0
+		return
+	}
+	pn.remaining -= len(fds)
+
+	ports := map[string]struct{}{}
+	var tcpConns, established int32
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd))
+		if err != nil || !strings.HasPrefix(link, socketLinkPrefix) {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, socketLinkPrefix), "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		s, ok := pn.sockets[inode]
+		if !ok {
+			continue
+		}
+		switch {
+		case s.listening():
+			ports[fmt.Sprintf("%s:%d", s.protocol, s.port)] = struct{}{}
+		case s.protocol == "TCP":
+			tcpConns++
+			if s.state == tcpEstablished {
+				established++
+			}
+		}
+	}
+
+	sample.ListeningPorts = joinPorts(ports)
+	sample.TCPConnectionCount = &tcpConns
+	sample.EstablishedConnectionCount = &established
+}
+
+// joinPorts sorts the ports by protocol and number
+func joinPorts(ports map[string]struct{}) string {
+	list := make([]string, 0, len(ports))
+	for port := range ports {
+		list = append(list, port)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		pi, pj := strings.SplitN(list[i], ":", 2), strings.SplitN(list[j], ":", 2)
+		if pi[0] != pj[0] {
+			return pi[0] < pj[0]
+		}
+		ni, _ := strconv.Atoi(pi[1])
+		nj, _ := strconv.Atoi(pj[1])
+		return ni < nj
+	})
+	return strings.Join(list, ",")
+}
+
+// readSocketTable adds the sockets of a /proc/net/{tcp,udp}[6] file, keyed by inode:
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//	 0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20160 ...
+func readSocketTable(path, protocol string, sockets map[uint64]socket) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		// inode 0 is used by sockets without an owner, as in TIME_WAIT state
+		if err != nil || inode == 0 {
+			continue
+		}
+		local := strings.Split(fields[1], ":")
+		port, err := strconv.ParseUint(local[len(local)-1], 16, 16)
+		if err != nil {
+			continue
+		}
+		sockets[inode] = socket{protocol: protocol, port: port, state: fields[3]}
+	}
+	return scanner.Err()
+}
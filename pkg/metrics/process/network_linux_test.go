@@ -0,0 +1,116 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+func TestLinuxHarvester_NetworkMetrics(t *testing.T) {
+	// Given a listening socket and an established connection owned by the current process
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			_, _ = ioutil.ReadAll(conn)
+		}
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// And a process harvester with the network metrics enabled
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{
+		RunMode:                     config.ModeRoot,
+		EnableProcessNetworkMetrics: true,
+		ProcessNetworkMetricsMaxFds: 1000,
+	})
+	ctx.On("GetServiceForPid", mock.Anything).Return("", false)
+	cache := newCache()
+	h := newHarvester(ctx, &cache)
+
+	// When the process is sampled in a new cycle
+	h.StartCycle()
+	sample, err := h.Do(int32(os.Getpid()), 0)
+	require.NoError(t, err)
+
+	// Then its listening port and connections are reported
+	port := listener.Addr().(*net.TCPAddr).Port
+	assert.Contains(t, strings.Split(sample.ListeningPorts, ","), fmt.Sprintf("TCP:%d", port))
+	require.NotNil(t, sample.TCPConnectionCount)
+	assert.GreaterOrEqual(t, *sample.TCPConnectionCount, int32(2))
+	require.NotNil(t, sample.EstablishedConnectionCount)
+	assert.GreaterOrEqual(t, *sample.EstablishedConnectionCount, int32(2))
+}
+
+func TestLinuxHarvester_NetworkMetricsDisabled(t *testing.T) {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{RunMode: config.ModeRoot})
+	ctx.On("GetServiceForPid", mock.Anything).Return("", false)
+	cache := newCache()
+	h := newHarvester(ctx, &cache)
+
+	h.StartCycle()
+	sample, err := h.Do(int32(os.Getpid()), 0)
+	require.NoError(t, err)
+
+	assert.Empty(t, sample.ListeningPorts)
+	assert.Nil(t, sample.TCPConnectionCount)
+}
+
+func TestProcessNetwork_FdsLimit(t *testing.T) {
+	// Given a file descriptors budget lower than the fds of the current process
+	pn := newProcessNetwork(1)
+	pn.reset()
+	require.NotEmpty(t, pn.sockets)
+
+	// When the process is sampled
+	sample := &types.ProcessSample{ProcessID: int32(os.Getpid())}
+	pn.populate(sample)
+
+	// Then the network attributes are omitted for this and the next processes of the cycle
+	assert.Nil(t, sample.TCPConnectionCount)
+	assert.Zero(t, pn.remaining)
+}
+
+func TestReadSocketTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tcp6")
+	content := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 19877 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000F02000A:01BB 0000000000000000FFFF00000202000A:D1C2 01 00000000:00000000 00:00000000 00000000     0        0 53535 1 0000000000000000 20 4 30 10 -1
+   2: 0000000000000000FFFF00000F02000A:01BB 0000000000000000FFFF00000202000A:D1C4 06 00000000:00000000 03:00001770 00000000     0        0 0 3 0000000000000000
+`
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0o644))
+
+	sockets := map[uint64]socket{}
+	require.NoError(t, readSocketTable(path, "TCP", sockets))
+
+	assert.Equal(t, map[uint64]socket{
+		19877: {protocol: "TCP", port: 22, state: tcpListen},
+		53535: {protocol: "TCP", port: 443, state: tcpEstablished},
+	}, sockets)
+}
+
+func TestJoinPorts(t *testing.T) {
+	ports := map[string]struct{}{"UDP:53": {}, "TCP:8080": {}, "TCP:22": {}, "TCP:443": {}}
+
+	assert.Equal(t, "TCP:22,TCP:443,TCP:8080,UDP:53", joinPorts(ports))
+}
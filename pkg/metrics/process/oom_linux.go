@@ -0,0 +1,169 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const kmsgPath = "/dev/kmsg"
+
+// killedProcessRegex matches the OOM killer victim message, which may be prefixed as "Out of memory: " or
+// "Memory cgroup out of memory: ", i.e.
+// "Killed process 1234 (java) total-vm:264564kB, anon-rss:261880kB, file-rss:1008kB, shmem-rss:0kB, UID:0 ..."
+var killedProcessRegex = regexp.MustCompile(`Killed process (\d+) \((.*?)\)(?:.*?anon-rss:(\d+)kB)?`)
+
+// OomKillEvent is reported when a process is killed by the kernel OOM killer.
+type OomKillEvent struct {
+	sample.BaseEvent
+	Category    string `json:"category"`
+	Summary     string `json:"summary"`
+	ProcessID   int32  `json:"processId"`
+	CommandName string `json:"commandName"`
+	// Cgroup of the killed process, as "/system.slice/java.service"
+	Cgroup string `json:"cgroup,omitempty"`
+	// Cgroup whose memory limit was reached, when the kill was constrained to a memory cgroup
+	OomCgroup string `json:"oomCgroup,omitempty"`
+	// Constraint that triggered the OOM killer, as "CONSTRAINT_NONE" or "CONSTRAINT_MEMCG"
+	Constraint   string  `json:"constraint,omitempty"`
+	AnonRSSBytes *uint64 `json:"anonRssBytes,omitempty"`
+}
+
+// oomParser builds the OomKillEvent from the kernel messages. Since 4.19 kernels report the victim cgroup in a
+// message preceding the victim one:
+//
+//	oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/a,task_memcg=/a,task=java,pid=1234,uid=0
+//	Memory cgroup out of memory: Killed process 1234 (java) total-vm:264564kB, anon-rss:261880kB, ...
+type oomParser struct {
+	pending map[string]string
+}
+
+// parse returns the OomKillEvent for the victim message, or nil for any other message.
+func (p *oomParser) parse(message string) *OomKillEvent {
+	if strings.HasPrefix(message, "oom-kill:") {
+		p.pending = map[string]string{}
+		for _, field := range strings.Split(strings.TrimPrefix(message, "oom-kill:"), ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) == 2 {
+				p.pending[kv[0]] = kv[1]
+			}
+		}
+		return nil
+	}
+
+	match := killedProcessRegex.FindStringSubmatch(message)
+	if match == nil {
+		return nil
+	}
+	pid, err := strconv.ParseInt(match[1], 10, 32)
+	if err != nil {
+		return nil
+	}
+	e := &OomKillEvent{
+		Category:    processEventCategory,
+		Summary:     "Process killed by the OOM killer",
+		ProcessID:   int32(pid),
+		CommandName: match[2],
+	}
+	e.Type(infrastructureEventType)
+	if match[3] != "" {
+		if kb, err := strconv.ParseUint(match[3], 10, 64); err == nil {
+			bytes := kb * 1024
+			e.AnonRSSBytes = &bytes
+		}
+	}
+	if p.pending != nil && p.pending["pid"] == match[1] {
+		e.Cgroup = p.pending["task_memcg"]
+		e.OomCgroup = p.pending["oom_memcg"]
+		e.Constraint = p.pending["constraint"]
+	}
+	p.pending = nil
+	return e
+}
+
+// kmsgMessage returns the message of a /dev/kmsg record, formatted as "<prio>,<seq>,<usec>,<flags>;<message>".
+// Continuation lines, which hold the record dictionary, start with a space and are ignored.
+func kmsgMessage(record string) (string, bool) {
+	if strings.HasPrefix(record, " ") {
+		return "", false
+	}
+	i := strings.Index(record, ";")
+	if i < 0 {
+		return "", false
+	}
+	return record[i+1:], true
+}
+
+// oomWatcher reports an OomKillEvent through the agent event sender for every OOM kill logged by the kernel
+// since the watcher started.
+type oomWatcher struct {
+	ctx agent.AgentContext
+}
+
+// run watches the kernel messages until the context is done.
+func (w *oomWatcher) run(ctx context.Context) {
+	f, err := os.Open(kmsgPath)
+	if err != nil {
+		mplog.WithError(err).Warn("Cannot read kernel messages, OOM kill events won't be reported.")
+		return
+	}
+	// only the messages logged from now on are reported
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		mplog.WithError(err).Debug("Cannot skip the buffered kernel messages.")
+	}
+	w.follow(ctx, f)
+}
+
+// follow watches the kernel messages from the reader, closing it when the context is done to unblock the
+// pending read.
+func (w *oomWatcher) follow(ctx context.Context, rc io.ReadCloser) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = rc.Close()
+	}()
+
+	if err := w.watch(rc); err != nil && ctx.Err() == nil {
+		mplog.WithError(err).Warn("Stopped reading kernel messages, OOM kill events won't be reported.")
+	}
+}
+
+// watch reads kernel message records until the reader is exhausted.
+func (w *oomWatcher) watch(r io.Reader) error {
+	parser := &oomParser{}
+	for {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			message, ok := kmsgMessage(scanner.Text())
+			if !ok {
+				continue
+			}
+			if e := parser.parse(message); e != nil {
+				e.Timestamp(time.Now().Unix())
+				w.ctx.SendEvent(e, entity.EmptyKey)
+			}
+		}
+		// EPIPE is returned when the reader falls behind and messages are overwritten, reading can go on
+		if err := scanner.Err(); !errors.Is(err, syscall.EPIPE) {
+			return err
+		}
+		mplog.Debug("Some kernel messages were overwritten before being read.")
+	}
+}
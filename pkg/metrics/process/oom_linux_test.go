@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+)
+
+func TestOomParser_Parse(t *testing.T) {
+	p := &oomParser{}
+
+	// GIVEN the cgroup message logged before the victim one
+	assert.Nil(t, p.parse("oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/system.slice/app.service,task_memcg=/system.slice/app.service/worker,task=java,pid=1234,uid=1000"))
+
+	// WHEN the victim message is parsed
+	e := p.parse("Memory cgroup out of memory: Killed process 1234 (java) total-vm:264564kB, anon-rss:261880kB, file-rss:1008kB, shmem-rss:0kB, UID:1000 pgtables:560kB oom_score_adj:0")
+
+	// THEN the event includes the victim and its cgroup
+	require.NotNil(t, e)
+	assert.Equal(t, "InfrastructureEvent", e.EventType)
+	assert.Equal(t, "process", e.Category)
+	assert.Equal(t, int32(1234), e.ProcessID)
+	assert.Equal(t, "java", e.CommandName)
+	assert.Equal(t, "/system.slice/app.service/worker", e.Cgroup)
+	assert.Equal(t, "/system.slice/app.service", e.OomCgroup)
+	assert.Equal(t, "CONSTRAINT_MEMCG", e.Constraint)
+	assert.Equal(t, uint64(261880*1024), *e.AnonRSSBytes)
+}
+
+func TestOomParser_ParseOldKernel(t *testing.T) {
+	p := &oomParser{}
+
+	assert.Nil(t, p.parse("Out of memory: Kill process 99 (stress) score 900 or sacrifice child"))
+	e := p.parse("Killed process 99 (stress) total-vm:1024kB, anon-rss:512kB, file-rss:0kB")
+
+	require.NotNil(t, e)
+	assert.Equal(t, int32(99), e.ProcessID)
+	assert.Equal(t, "stress", e.CommandName)
+	assert.Empty(t, e.Cgroup)
+	assert.Equal(t, uint64(512*1024), *e.AnonRSSBytes)
+}
+
+func TestOomWatcher_Watch(t *testing.T) {
+	ctx := new(mocks.AgentContext)
+	var sent []*OomKillEvent
+	ctx.On("SendEvent", mock.Anything, entity.EmptyKey).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(0).(*OomKillEvent))
+	})
+	kmsg := strings.Join([]string{
+		"6,1000,100,-;eth0: link up",
+		"3,1001,200,-;oom-kill:constraint=CONSTRAINT_NONE,nodemask=(null),cpuset=/,mems_allowed=0,global_oom,task_memcg=/user.slice,task=node,pid=77,uid=1000",
+		" SUBSYSTEM=memory",
+		"3,1002,201,-;Out of memory: Killed process 77 (node) total-vm:100kB, anon-rss:80kB, file-rss:0kB",
+	}, "\n")
+
+	require.NoError(t, (&oomWatcher{ctx: ctx}).watch(strings.NewReader(kmsg)))
+
+	require.Len(t, sent, 1)
+	assert.Equal(t, "/user.slice", sent[0].Cgroup)
+	assert.Equal(t, "CONSTRAINT_NONE", sent[0].Constraint)
+	assert.NotZero(t, sent[0].Timestmp)
+}
+
+func TestOomWatcher_FollowStopsWithContext(t *testing.T) {
+	// GIVEN a watcher blocked reading kernel messages
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		(&oomWatcher{ctx: new(mocks.AgentContext)}).follow(ctx, r)
+		close(stopped)
+	}()
+
+	// WHEN the context is cancelled
+	cancel()
+
+	// THEN the watcher stops
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("watcher not stopped")
+	}
+}
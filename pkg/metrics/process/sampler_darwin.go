@@ -81,6 +81,7 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 	elapsedSeconds = float64(elapsedMs) / 1000
 	ps.lastRun = now
 
+	ps.harvest.StartCycle()
 	pids, err := ps.harvest.Pids()
 	if err != nil {
 		return nil, err
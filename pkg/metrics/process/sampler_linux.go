@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
@@ -26,6 +27,9 @@ type processSampler struct {
 	hasAlreadyRun    bool
 	interval         time.Duration
 	cache            *cache
+	lifecycle        *lifecycleTracker
+	oomWatcher       *oomWatcher
+	startOnce        sync.Once
 }
 
 var (
@@ -50,15 +54,31 @@ func NewProcessSampler(ctx agent.AgentContext) sampler.Sampler {
 	harvest := newHarvester(ctx, &cache)
 	dockerSampler := metrics.NewDockerSampler(time.Duration(ttlSecs)*time.Second, apiVersion)
 
-	return &processSampler{
+	ps := &processSampler{
 		harvest:          harvest,
 		containerSampler: dockerSampler,
 		cache:            &cache,
 		interval:         time.Second * time.Duration(interval),
 	}
+	if hasConfig {
+		if cfg := ctx.Config().ProcessLifecycleEvents; cfg.Enabled {
+			ps.lifecycle = newLifecycleTracker(cfg)
+		}
+		if ctx.Config().ProcessLifecycleEvents.OomKill {
+			ps.oomWatcher = &oomWatcher{ctx: ctx}
+		}
+	}
+	return ps
 }
 
-func (ps *processSampler) OnStartup() {}
+// OnStartup starts watching the OOM kills until the agent stops, once, as the sampler may be restarted.
+func (ps *processSampler) OnStartup() {
+	if ps.oomWatcher != nil {
+		ps.startOnce.Do(func() {
+			go ps.oomWatcher.run(ps.oomWatcher.ctx.Context())
+		})
+	}
+}
 
 func (ps *processSampler) Name() string {
 	return "ProcessSampler"
@@ -83,6 +103,7 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 	elapsedSeconds = float64(elapsedMs) / 1000
 	ps.lastRun = now
 
+	ps.harvest.StartCycle()
 	pids, err := ps.harvest.Pids()
 	if err != nil {
 		return nil, err
@@ -106,6 +127,11 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 		}
 	}
 
+	var lifecycleSamples map[int32]*types.ProcessSample
+	if ps.lifecycle != nil {
+		lifecycleSamples = make(map[int32]*types.ProcessSample, len(pids))
+	}
+
 	for _, pid := range pids {
 		var processSample *types.ProcessSample
 		var err error
@@ -125,9 +151,17 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 			dockerDecorator.Decorate(processSample)
 		}
 
+		if lifecycleSamples != nil {
+			lifecycleSamples[pid] = processSample
+		}
+
 		results = append(results, ps.normalizeSample(processSample))
 	}
 
+	if ps.lifecycle != nil {
+		results = append(results, ps.lifecycle.events(pids, lifecycleSamples)...)
+	}
+
 	ps.cache.items.RemoveUntilLen(len(pids))
 	ps.hasAlreadyRun = true
 	return results, nil
@@ -65,8 +65,41 @@ func TestProcessSampler_DockerDecorator(t *testing.T) {
 	}
 }
 
+func TestProcessSampler_LifecycleEvents(t *testing.T) {
+	// Given a Process Sampler with the lifecycle events enabled
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{
+		ProcessLifecycleEvents: config.ProcessLifecycleConfig{Enabled: true},
+	})
+	ps := NewProcessSampler(ctx).(*processSampler)
+	harvester := &harvesterMock{samples: map[int32]*types.ProcessSample{
+		1: {ProcessID: 1, CommandName: "init"},
+	}}
+	ps.harvest = harvester
+	ps.containerSampler = &fakeContainerSampler{}
+	_, err := ps.Sample()
+	require.NoError(t, err)
+
+	// When a process starts between two samples
+	harvester.samples[2] = &types.ProcessSample{ProcessID: 2, CommandName: "cron"}
+	results, err := ps.Sample()
+	require.NoError(t, err)
+
+	// Then a start event is returned along with the process samples
+	assert.Equal(t, 2, harvester.cycles)
+	require.Len(t, results, 3)
+	start, ok := results[2].(*ProcessStartEvent)
+	require.True(t, ok)
+	assert.Equal(t, "cron", start.CommandName)
+}
+
 type harvesterMock struct {
 	samples map[int32]*types.ProcessSample
+	cycles  int
+}
+
+func (hm *harvesterMock) StartCycle() {
+	hm.cycles++
 }
 
 func (hm *harvesterMock) Pids() ([]int32, error) {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/fflag"
@@ -19,11 +20,22 @@ import (
 var (
 	mlog = log.WithComponent("SamplerMatcher")
 
-	// typesToEvaluate is map that contains the samples we want to filter on
-	typesToEvaluate = map[string]bool{
-		"ProcessSample":     true, // Normal process sample
-		"FlatProcessSample": true, // Process sample combined with Docker process data
+	// sampleTypesByDimension maps the metric dimension prefix (i.e. "process" for "process.name") to the
+	// samples it can filter on
+	sampleTypesByDimension = map[string][]string{
+		"process": {
+			"ProcessSample",     // Normal process sample
+			"FlatProcessSample", // Process sample combined with Docker process data
+		},
+		"storage": {"StorageSample"},
+		"network": {"NetworkSample"},
+		"system":  {"SystemSample"},
+		"cgroup":  {"CgroupSample"},
+		"sensor":  {"SensorSample"},
 	}
+
+	// comparisonOperators supported by numeric expressions, longer operators go first so ">=" is not parsed as ">"
+	comparisonOperators = []string{">=", "<=", "==", "!=", ">", "<"}
 )
 
 // IncludeSampleMatchFn func that returns whether an event/sample should be included, it satisfies
@@ -45,6 +57,8 @@ type regexCompiledCache map[string]*regexp.Regexp
 
 var regexCache regexCompiledCache
 
+// attrCache holds the dimensions not matching the name of the sample attribute they refer to. Any other
+// dimension is looked up by the attribute name, e.g. "process.cpuPercent" or "storage.mountPoint".
 func init() {
 	attrCache = attributeCache{
 		"process.name": []string{
@@ -55,6 +69,10 @@ func init() {
 			"CmdLine",     // Field name from ProcessSample
 			"commandLine", // Field name from FlatProcessSample (i.e. the map key name)
 		},
+		"process.user": []string{
+			"User",     // Field name from ProcessSample
+			"userName", // Field name from FlatProcessSample (i.e. the map key name)
+		},
 	}
 	regexCache = regexCompiledCache{}
 }
@@ -66,10 +84,6 @@ type matcher struct {
 }
 
 func (p matcher) Evaluate(event interface{}) bool {
-	if skipSample(event, typesToEvaluate) {
-		return true
-	}
-
 	actualValue := getFieldValue(event, p.PropertyName)
 	if actualValue == nil {
 		return false
@@ -106,9 +120,7 @@ func getFieldValue(object interface{}, fieldNames []string) interface{} {
 			WithField(config.TracesFieldName, config.FeatureTrace).
 			Tracef("Searching Struct for fields %v", fieldNames)
 		for i := range fieldNames {
-			fv := v.FieldByName(fieldNames[i])
-			if fv.IsValid() && fv.CanInterface() {
-				fieldValue := fv.Interface()
+			if fieldValue, ok := structFieldValue(v, fieldNames[i]); ok {
 				return fieldValue
 			}
 		}
@@ -126,19 +138,151 @@ func getFieldValue(object interface{}, fieldNames []string) interface{} {
 	}
 }
 
-// determine is this is a sample that should be evaluated
-// we are only checking ProcessSamples at this point, so only those should be evaluated
-func skipSample(sample interface{}, typesToEvaluate map[string]bool) bool {
-	v := reflect.TypeOf(sample)
+// structFieldValue returns the value of the exported field with the passed Go name or JSON name, also
+// looking into embedded structs. Nil pointers are considered as a missing value.
+func structFieldValue(v reflect.Value, name string) (interface{}, bool) {
+	t := v.Type()
+	// fields on the outer struct take precedence over the embedded ones
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous || f.PkgPath != "" {
+			continue
+		}
+		if f.Name == name || jsonName(f) == name {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					return nil, false
+				}
+				fv = fv.Elem()
+			}
+			return fv.Interface(), true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() != reflect.Struct {
+			continue
+		}
+		if value, ok := structFieldValue(fv, name); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func jsonName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// sampleType returns the event type of the sample, falling back to its Go type name when not set.
+func sampleType(sample interface{}) string {
+	v := reflect.ValueOf(sample)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
-	// skip if not "registered"
-	return !typesToEvaluate[v.Name()]
+	switch v.Interface().(type) {
+	case types.FlatProcessSample:
+		return "FlatProcessSample"
+	}
+	if v.Kind() != reflect.Struct {
+		return v.Type().Name()
+	}
+	if et := v.FieldByName("EventType"); et.IsValid() && et.Kind() == reflect.String && et.String() != "" {
+		return et.String()
+	}
+	// storage samples are defined per OS as storage.Sample
+	if strings.HasSuffix(v.Type().PkgPath(), "/metrics/storage") {
+		return "StorageSample"
+	}
+	return v.Type().Name()
+}
+
+// sampleTypesFor returns the samples a dimension can filter on. Non "registered" dimensions are only
+// evaluated against process samples, as they were before filtering other samples was supported.
+func sampleTypesFor(dimensionName string) map[string]bool {
+	prefix := strings.SplitN(dimensionName, ".", 2)[0]
+	sampleTypes, found := sampleTypesByDimension[prefix]
+	if !found {
+		sampleTypes = sampleTypesByDimension["process"]
+	}
+	result := make(map[string]bool, len(sampleTypes))
+	for _, st := range sampleTypes {
+		result[st] = true
+	}
+	return result
 }
 
 func literalExpressionEvaluator(expected interface{}, actual interface{}) bool {
-	return expected == actual
+	return expected == actual || expected == fmt.Sprintf("%v", actual)
+}
+
+func notLiteralExpressionEvaluator(expected interface{}, actual interface{}) bool {
+	return !literalExpressionEvaluator(expected, actual)
+}
+
+// numericComparison holds the operator and operand of a numeric expression, e.g. "> 5".
+type numericComparison struct {
+	operator string
+	operand  float64
+}
+
+func numericExpressionEvaluator(expected interface{}, actual interface{}) bool {
+	cmp := expected.(numericComparison)
+	value, ok := toFloat(actual)
+	if !ok {
+		return false
+	}
+	switch cmp.operator {
+	case ">":
+		return value > cmp.operand
+	case ">=":
+		return value >= cmp.operand
+	case "<":
+		return value < cmp.operand
+	case "<=":
+		return value <= cmp.operand
+	case "==":
+		return value == cmp.operand
+	case "!=":
+		return value != cmp.operand
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// comparisonExpression splits expressions starting with a comparison operator, e.g. ">= 5".
+func comparisonExpression(expr string) (operator string, operand string, ok bool) {
+	for _, op := range comparisonOperators {
+		if strings.HasPrefix(expr, op) {
+			return op, strings.TrimSpace(strings.Trim(strings.TrimSpace(expr[len(op):]), `"`)), true
+		}
+	}
+	return "", "", false
 }
 
 func regularExpressionEvaluator(expected interface{}, actual interface{}) bool {
@@ -158,14 +302,35 @@ func build(dimensionName string, expr string) ExpressionMatcher {
 	// so this matcher basically get's ignored in the current implementation
 	mappedAttributeName, found := attrCache[dimensionName]
 	if !found {
-		return constantMatcher{value: false}
+		parts := strings.SplitN(dimensionName, ".", 2)
+		if _, registered := sampleTypesByDimension[parts[0]]; !registered || len(parts) < 2 || parts[1] == "" {
+			return constantMatcher{value: false}
+		}
+		mappedAttributeName = []string{parts[1]}
 	}
 
 	eval := matcher{
 		PropertyName: mappedAttributeName,
 	}
 
-	if strings.HasPrefix(expr, "regex") {
+	expr = strings.TrimSpace(expr)
+	if operator, operand, isComparison := comparisonExpression(expr); isComparison {
+		number, err := strconv.ParseFloat(operand, 64)
+		switch {
+		case err == nil:
+			eval.ExpectedValue = numericComparison{operator: operator, operand: number}
+			eval.Evaluator = numericExpressionEvaluator
+		case operator == "==":
+			eval.ExpectedValue = operand
+			eval.Evaluator = literalExpressionEvaluator
+		case operator == "!=":
+			eval.ExpectedValue = operand
+			eval.Evaluator = notLiteralExpressionEvaluator
+		default:
+			mlog.WithError(err).Error(fmt.Sprintf("could not intitilize expression matcher for the provided configuration: '%s'", expr))
+			return constantMatcher{value: false}
+		}
+	} else if strings.HasPrefix(expr, "regex") {
 		regex := strings.Trim(strings.TrimSpace(strings.TrimLeft(expr, "regex")), `"`)
 		if err := cacheRegex(regex); err != nil {
 			mlog.WithError(err).Error(fmt.Sprintf("could not intitilize expression matcher for the provided configuration: '%s'", expr))
@@ -205,17 +370,22 @@ func cacheRegex(pattern string) error {
 //   - "/bin/test"
 //   - regex "^/opt/newrelic/"
 //
-// will create an evaluator chain with 2 entries. The first one will have 1 evaluator. The second 2 evaluators
+// - storage.diskUsedPercent
+//   - "> 90"
+//
+// will create an evaluator chain with 3 entries. The first one will have 1 evaluator. The second 2 evaluators
+// Each attribute is only evaluated against the samples its prefix refers to (i.e. "storage" for StorageSample).
 type MatcherChain struct {
-	Matchers map[string][]ExpressionMatcher
-	Enabled  bool
+	Matchers    map[string][]ExpressionMatcher
+	Enabled     bool
+	sampleTypes map[string]map[string]bool
 }
 
 // NewMatcherChain creates a new chain of matchers.
 // Each expression will generate an matcher that gets added to the chain
 // While the chain will be matched for each "sample", it terminates as soon as 1 match is matched (result = true)
 func NewMatcherChain(expressions config.IncludeMetricsMap) MatcherChain {
-	chain := MatcherChain{Matchers: map[string][]ExpressionMatcher{}, Enabled: false}
+	chain := MatcherChain{Matchers: map[string][]ExpressionMatcher{}, Enabled: false, sampleTypes: map[string]map[string]bool{}}
 
 	// no matchers means the chain will be disabled
 	if len(expressions) == 0 {
@@ -228,6 +398,7 @@ func NewMatcherChain(expressions config.IncludeMetricsMap) MatcherChain {
 			chain.Matchers[prop] = []ExpressionMatcher{}
 		}
 
+		chain.sampleTypes[prop] = sampleTypesFor(prop)
 		evs := chain.Matchers[prop]
 		for _, expr := range exprs {
 			e := newExpressionMatcher(prop, expr)
@@ -244,10 +415,14 @@ func NewMatcherChain(expressions config.IncludeMetricsMap) MatcherChain {
 //   - true, if event match with evaluator criteria chain
 //   - false, if event do not match with evaluator criteria chain
 //
-// If there is no matchers will return true.
+// If there is no matchers for the event sample type will return true.
 func (ec MatcherChain) Evaluate(event interface{}) bool {
 	var result = true
-	for _, es := range ec.Matchers {
+	st := sampleType(event)
+	for prop, es := range ec.Matchers {
+		if !ec.sampleTypes[prop][st] {
+			continue
+		}
 		for _, e := range es {
 			result = e.Evaluate(event)
 			if result {
@@ -258,6 +433,22 @@ func (ec MatcherChain) Evaluate(event interface{}) bool {
 	return result
 }
 
+// Matches returns true only when the event sample type has matchers and any of them matches.
+func (ec MatcherChain) Matches(event interface{}) bool {
+	return ec.appliesTo(event) && ec.Evaluate(event)
+}
+
+// appliesTo returns whether the chain has matchers for the event sample type.
+func (ec MatcherChain) appliesTo(event interface{}) bool {
+	st := sampleType(event)
+	for prop, es := range ec.Matchers {
+		if len(es) > 0 && ec.sampleTypes[prop][st] {
+			return true
+		}
+	}
+	return false
+}
+
 type constantMatcher struct {
 	value bool
 }
@@ -270,17 +461,38 @@ func (ne constantMatcher) String() string {
 }
 
 // NewSampleMatchFn creates new includeSampleMatchFn func, enableProcessMetrics might be nil when
-// value was not set.
-func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.IncludeMetricsMap, ffRetriever feature_flags.Retriever) IncludeSampleMatchFn {
+// value was not set. Samples matching any of the exclude matchers are always dropped.
+func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.IncludeMetricsMap, excludeMetricsMatchers config.IncludeMetricsMap, ffRetriever feature_flags.Retriever) IncludeSampleMatchFn {
+	includeFn := newIncludeSampleMatchFn(enableProcessMetrics, includeMetricsMatchers, ffRetriever)
+
+	exclude := NewMatcherChain(excludeMetricsMatchers)
+	if !exclude.Enabled {
+		return includeFn
+	}
+
+	mlog.
+		WithField(config.TracesFieldName, config.FeatureTrace).
+		Trace("Exclude rules ARE defined, matching metrics will be DISABLED")
+	return func(sample interface{}) bool {
+		return includeFn(sample) && !exclude.Matches(sample)
+	}
+}
+
+func newIncludeSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.IncludeMetricsMap, ffRetriever feature_flags.Retriever) IncludeSampleMatchFn {
+	ec := NewMatcherChain(includeMetricsMatchers)
+
 	// configuration option always takes precedence over FF and matchers configuration
 	if enableProcessMetrics == nil {
 		// if config option is not set, check if we have rules defined. those take precedence over the FF
-		ec := NewMatcherChain(includeMetricsMatchers)
+		// for the samples they apply to
 		if ec.Enabled {
 			mlog.
 				WithField(config.TracesFieldName, config.FeatureTrace).
-				Tracef("EnableProcessMetrics is EMPTY and rules ARE defined, process metrics will be ENABLED for matching processes")
+				Tracef("EnableProcessMetrics is EMPTY and rules ARE defined, metrics will be ENABLED for matching samples")
 			return func(sample interface{}) bool {
+				if isProcessSample(sample) && !ec.appliesTo(sample) {
+					return processMetricsFFEnabled(ffRetriever)
+				}
 				return ec.Evaluate(sample)
 			}
 		}
@@ -288,15 +500,11 @@ func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.
 		// configuration option is not defined and feature flag is present, FF determines, otherwise
 		// all process samples will be excluded
 		return func(sample interface{}) bool {
-			_, isProcessSample := sample.(*types.ProcessSample)
-			_, isFlatProcessSample := sample.(*types.FlatProcessSample)
-
-			if !isProcessSample && !isFlatProcessSample {
+			if !isProcessSample(sample) {
 				return true
 			}
 
-			enabled, exists := ffRetriever.GetFeatureFlag(fflag.FlagFullProcess)
-			return exists && enabled
+			return processMetricsFFEnabled(ffRetriever)
 		}
 	}
 
@@ -322,17 +530,16 @@ func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.
 				mlog.
 					WithField(config.TracesFieldName, config.FeatureTrace).
 					Tracef("Got a sample of type '%s' that should not be excluded.", reflect.TypeOf(sample).String())
-				// other samples are included
-				return true
+				// other samples are included unless they don't match their rules
+				return ec.Evaluate(sample)
 			}
 		}
 	}
 
-	ec := NewMatcherChain(includeMetricsMatchers)
 	if ec.Enabled {
 		mlog.
 			WithField(config.TracesFieldName, config.FeatureTrace).
-			Trace("EnableProcessMetrics is TRUE and rules ARE defined, process metrics will be ENABLED for matching processes")
+			Trace("EnableProcessMetrics is TRUE and rules ARE defined, metrics will be ENABLED for matching samples")
 		return func(sample interface{}) bool {
 			return ec.Evaluate(sample)
 		}
@@ -347,6 +554,17 @@ func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.
 	}
 }
 
+func isProcessSample(sample interface{}) bool {
+	_, isProcessSample := sample.(*types.ProcessSample)
+	_, isFlatProcessSample := sample.(*types.FlatProcessSample)
+	return isProcessSample || isFlatProcessSample
+}
+
+func processMetricsFFEnabled(ffRetriever feature_flags.Retriever) bool {
+	enabled, exists := ffRetriever.GetFeatureFlag(fflag.FlagFullProcess)
+	return exists && enabled
+}
+
 func excludeProcessMetrics(enableProcessMetrics *bool) bool {
 	if enableProcessMetrics == nil || *enableProcessMetrics {
 		return false
@@ -741,8 +741,247 @@ func TestNewSampleMatchFn(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matchFn := sampler.NewSampleMatchFn(tt.args.enableProcessMetrics, tt.args.includeMetricsMatchers, tt.args.ffRetriever)
+			matchFn := sampler.NewSampleMatchFn(tt.args.enableProcessMetrics, tt.args.includeMetricsMatchers, nil, tt.args.ffRetriever)
 			assert.Equal(t, tt.include, matchFn(tt.args.sample))
 		})
 	}
 }
+
+func Test_EvaluatorChain_AnyAttribute(t *testing.T) {
+	fdCount := int32(512)
+	usedPercent := 95.5
+	receiveBytes := 1024.0
+
+	type testCase struct {
+		name  string
+		input interface{}
+		rules map[string][]string
+		want  bool
+	}
+
+	cases := []testCase{
+		{
+			name:  "ProcessUser_IsLiteralMatch",
+			input: &types.ProcessSample{User: "postgres"},
+			rules: map[string][]string{"process.user": {"postgres"}},
+			want:  true,
+		},
+		{
+			name:  "FlatProcessUser_IsLiteralMatch",
+			input: &types.FlatProcessSample{"userName": "postgres"},
+			rules: map[string][]string{"process.user": {"postgres"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessAttributeByJSONName",
+			input: &types.ProcessSample{CommandName: "java"},
+			rules: map[string][]string{"process.commandName": {"regex ^ja"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessNumericAttribute_IsLiteralMatch",
+			input: &types.ProcessSample{ProcessID: 1234},
+			rules: map[string][]string{"process.processId": {"1234"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessCPU_IsGreaterThan",
+			input: &types.ProcessSample{CPUPercent: 5.1},
+			rules: map[string][]string{"process.cpuPercent": {"> 5"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessCPU_IsNotGreaterThan",
+			input: &types.ProcessSample{CPUPercent: 5},
+			rules: map[string][]string{"process.cpuPercent": {"> 5"}},
+			want:  false,
+		},
+		{
+			name:  "ProcessCPU_IsGreaterOrEqual",
+			input: &types.ProcessSample{CPUPercent: 5},
+			rules: map[string][]string{"process.cpuPercent": {">=5"}},
+			want:  true,
+		},
+		{
+			name:  "FlatProcessCPU_IsLessThan",
+			input: &types.FlatProcessSample{"cpuPercent": 0.5},
+			rules: map[string][]string{"process.cpuPercent": {"< 1"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessPointerAttribute_IsLessOrEqual",
+			input: &types.ProcessSample{FdCount: &fdCount},
+			rules: map[string][]string{"process.fileDescriptorCount": {"<= 512"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessNilAttribute_NotMatch",
+			input: &types.ProcessSample{},
+			rules: map[string][]string{"process.fileDescriptorCount": {"<= 512"}},
+			want:  false,
+		},
+		{
+			name:  "ProcessState_IsNotEqual",
+			input: &types.ProcessSample{Status: "R"},
+			rules: map[string][]string{"process.state": {"!= Z"}},
+			want:  true,
+		},
+		{
+			name:  "ProcessCPU_InvalidComparison",
+			input: &types.ProcessSample{CPUPercent: 50},
+			rules: map[string][]string{"process.cpuPercent": {"> lots"}},
+			want:  false,
+		},
+		{
+			name:  "StorageMountPoint_IsRegexMatch",
+			input: &storage.BaseSample{MountPoint: "/snap/core/123"},
+			rules: map[string][]string{"storage.mountPoint": {"regex ^/snap"}},
+			want:  true,
+		},
+		{
+			name:  "StorageUsedPercent_IsGreaterThan",
+			input: &storage.BaseSample{UsedPercent: &usedPercent},
+			rules: map[string][]string{"storage.diskUsedPercent": {"> 90"}},
+			want:  true,
+		},
+		{
+			name:  "NetworkInterfaceName_IsLiteralNotMatch",
+			input: &network.NetworkSample{InterfaceName: "lo"},
+			rules: map[string][]string{"network.interfaceName": {"eth0"}},
+			want:  false,
+		},
+		{
+			name:  "NetworkReceiveBytes_IsGreaterThan",
+			input: &network.NetworkSample{InterfaceName: "eth0", ReceiveBytesPerSec: &receiveBytes},
+			rules: map[string][]string{"network.receiveBytesPerSecond": {"> 1000"}},
+			want:  true,
+		},
+		{
+			name:  "SystemEmbeddedAttribute_IsGreaterThan",
+			input: &metrics.SystemSample{CPUSample: &metrics.CPUSample{CPUPercent: 50}},
+			rules: map[string][]string{"system.cpuPercent": {"> 40"}},
+			want:  true,
+		},
+		{
+			name:  "SystemNilEmbeddedAttribute_NotMatch",
+			input: &metrics.SystemSample{},
+			rules: map[string][]string{"system.cpuPercent": {"> 40"}},
+			want:  false,
+		},
+		{
+			name:  "OtherSampleRulesDoNotApply",
+			input: &network.NetworkSample{InterfaceName: "lo"},
+			rules: map[string][]string{"storage.mountPoint": {"/"}},
+			want:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ec := sampler.NewMatcherChain(tc.rules)
+			assert.EqualValues(t, tc.want, ec.Evaluate(tc.input))
+		})
+	}
+}
+
+func Test_EvaluatorChain_UsesEventType(t *testing.T) {
+	s := &storage.BaseSample{MountPoint: "/"}
+	s.Type("StorageSample")
+
+	ec := sampler.NewMatcherChain(map[string][]string{"storage.mountPoint": {"/boot"}})
+
+	assert.False(t, ec.Evaluate(s))
+	assert.True(t, ec.Evaluate(&network.NetworkSample{InterfaceName: "lo"}))
+}
+
+func TestNewSampleMatchFn_Exclude(t *testing.T) {
+	trueVar := true
+	falseVar := false
+
+	exclude := config.IncludeMetricsMap{
+		"process.cpuPercent":    {"< 1"},
+		"storage.mountPoint":    {"regex ^/snap"},
+		"network.interfaceName": {"lo"},
+	}
+
+	tests := []struct {
+		name                 string
+		enableProcessMetrics *bool
+		include              config.IncludeMetricsMap
+		sample               interface{}
+		want                 bool
+	}{
+		{
+			name:                 "idle processes are excluded",
+			enableProcessMetrics: &trueVar,
+			sample:               &types.ProcessSample{CPUPercent: 0.5},
+			want:                 false,
+		},
+		{
+			name:                 "busy processes are included",
+			enableProcessMetrics: &trueVar,
+			sample:               &types.ProcessSample{CPUPercent: 10},
+			want:                 true,
+		},
+		{
+			name:                 "exclude rules apply after include ones",
+			enableProcessMetrics: &trueVar,
+			include:              config.IncludeMetricsMap{"process.name": {"java"}},
+			sample:               &types.ProcessSample{ProcessDisplayName: "java", CPUPercent: 0.5},
+			want:                 false,
+		},
+		{
+			name:                 "excluded process metrics are not included back",
+			enableProcessMetrics: &falseVar,
+			sample:               &types.ProcessSample{CPUPercent: 10},
+			want:                 false,
+		},
+		{
+			name:   "matching storage samples are excluded",
+			sample: &storage.BaseSample{MountPoint: "/snap/core/123"},
+			want:   false,
+		},
+		{
+			name:   "not matching storage samples are included",
+			sample: &storage.BaseSample{MountPoint: "/"},
+			want:   true,
+		},
+		{
+			name:   "matching network samples are excluded",
+			sample: &network.NetworkSample{InterfaceName: "lo"},
+			want:   false,
+		},
+		{
+			name:   "samples without rules are included",
+			sample: &metrics.SystemSample{},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchFn := sampler.NewSampleMatchFn(tt.enableProcessMetrics, tt.include, exclude, testFF.EmptyFFRetriever)
+			assert.Equal(t, tt.want, matchFn(tt.sample))
+		})
+	}
+}
+
+func TestNewSampleMatchFn_NonProcessRules(t *testing.T) {
+	falseVar := false
+	include := config.IncludeMetricsMap{"storage.mountPoint": {"/"}}
+
+	// GIVEN only storage rules and process metrics not being enabled
+	matchFn := sampler.NewSampleMatchFn(nil, include, nil, testFF.EmptyFFRetriever)
+
+	// THEN process samples are still determined by the feature flag
+	assert.False(t, matchFn(&fixture.ProcessSample))
+	assert.True(t, sampler.NewSampleMatchFn(nil, include, nil, &enabledFFRetriever{})(&fixture.ProcessSample))
+	// AND storage samples by the rules
+	assert.True(t, matchFn(&storage.BaseSample{MountPoint: "/"}))
+	assert.False(t, matchFn(&storage.BaseSample{MountPoint: "/boot"}))
+
+	// WHEN process metrics are disabled storage rules still apply
+	matchFn = sampler.NewSampleMatchFn(&falseVar, include, nil, testFF.EmptyFFRetriever)
+	assert.False(t, matchFn(&fixture.ProcessSample))
+	assert.False(t, matchFn(&storage.BaseSample{MountPoint: "/boot"}))
+	assert.True(t, matchFn(&fixture.NetworkSample))
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sensor
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var sslog = log.WithComponent("SensorSampler")
+
+// Sensor types
+const (
+	TypeTemperature = "temperature"
+	TypeFan         = "fan"
+	TypeVoltage     = "voltage"
+)
+
+// Sample reports the readings of a hardware sensor. Thresholds not exposed by the driver are omitted.
+type Sample struct {
+	sample.BaseEvent
+
+	// Chip name, as "coretemp" or "nct6775", or "thermal" for the thermal zones
+	Chip string `json:"chipName"`
+	// Sensor label, as "Package id 0", its name when the driver has no labels, as "temp1", or the thermal
+	// zone type, as "x86_pkg_temp"
+	Sensor string `json:"sensorName"`
+	// One of temperature, fan or voltage
+	SensorType string `json:"sensorType"`
+	// Sysfs device, as "hwmon0" or "thermal_zone0", to tell apart sensors with the same name
+	Device string `json:"device"`
+	// Whether the driver reports the sensor in alarm
+	Alarm *bool `json:"alarm,omitempty"`
+
+	TemperatureCelsius         *float64 `json:"temperatureCelsius,omitempty"`
+	TemperatureMaxCelsius      *float64 `json:"temperatureMaxCelsius,omitempty"`
+	TemperatureCriticalCelsius *float64 `json:"temperatureCriticalCelsius,omitempty"`
+
+	FanSpeedRPM *float64 `json:"fanSpeedRpm,omitempty"`
+	FanMinRPM   *float64 `json:"fanMinRpm,omitempty"`
+
+	VoltageVolts    *float64 `json:"voltageVolts,omitempty"`
+	VoltageMinVolts *float64 `json:"voltageMinVolts,omitempty"`
+	VoltageMaxVolts *float64 `json:"voltageMaxVolts,omitempty"`
+}
+
+// Sampler reports a SensorSample for each temperature, fan and voltage sensor of the host.
+type Sampler struct {
+	sampleRate time.Duration
+	enabled    bool
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	cfg := config.NewSensorConfig()
+	if context != nil {
+		cfg = context.Config().SensorMetrics
+	}
+	sampleRateSec := cfg.SampleRate
+	if sampleRateSec < config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && sampleRateSec > config.FREQ_DISABLE_SAMPLING {
+		sampleRateSec = config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
+	}
+
+	return &Sampler{
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		enabled:    cfg.Enabled,
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "SensorSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING || !s.enabled
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in sensor.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+	samples := populateSensors()
+	if len(samples) == 0 {
+		sslog.Debug("No hardware sensors found.")
+	}
+	for _, ss := range samples {
+		ss.Type("SensorSample")
+		eventBatch = append(eventBatch, ss)
+	}
+	return eventBatch, nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package sensor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const thermalChip = "thermal"
+
+var (
+	// hwmon sensor readings are exposed as <type><index>_input, i.e. temp1_input
+	hwmonInputRegex = regexp.MustCompile(`^(temp|fan|in)(\d+)_input$`)
+	tripPointRegex  = regexp.MustCompile(`^trip_point_(\d+)_type$`)
+
+	sensorTypes = map[string]string{
+		"temp": TypeTemperature,
+		"fan":  TypeFan,
+		"in":   TypeVoltage,
+	}
+	// hwmon temperatures are reported in millidegree Celsius, voltages in millivolts and fans in RPM
+	hwmonScale = map[string]float64{
+		"temp": 1000,
+		"fan":  1,
+		"in":   1000,
+	}
+)
+
+func populateSensors() []*Sample {
+	samples := populateHwmon(helpers.HostSys("class", "hwmon"))
+	return append(samples, populateThermalZones(helpers.HostSys("class", "thermal"))...)
+}
+
+// populateHwmon reads the sensors of every /sys/class/hwmon/hwmon* device.
+func populateHwmon(classDir string) []*Sample {
+	devices, err := os.ReadDir(classDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			sslog.WithError(err).Debug("Cannot read hwmon devices.")
+		}
+		return nil
+	}
+
+	var samples []*Sample
+	for _, device := range devices {
+		if !strings.HasPrefix(device.Name(), "hwmon") {
+			continue
+		}
+		dir := filepath.Join(classDir, device.Name())
+		// legacy drivers expose the attributes in the device directory
+		if _, err := os.Stat(filepath.Join(dir, "name")); err != nil {
+			dir = filepath.Join(dir, "device")
+		}
+		chip, err := readString(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			match := hwmonInputRegex.FindStringSubmatch(file.Name())
+			if match == nil {
+				continue
+			}
+			s, ok := readHwmonSensor(dir, match[1], match[1]+match[2])
+			if !ok {
+				continue
+			}
+			s.Chip = chip
+			s.Device = device.Name()
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// readHwmonSensor reads the <prefix>_* attributes of a sensor, where prefix is the sensor name, i.e. "temp1".
+// Sensors whose input can't be read, as disconnected ones, are skipped.
+func readHwmonSensor(dir, kind, prefix string) (*Sample, bool) {
+	attr := func(name string) *float64 {
+		v, err := readFloat(filepath.Join(dir, prefix+"_"+name))
+		if err != nil {
+			return nil
+		}
+		v /= hwmonScale[kind]
+		return &v
+	}
+
+	input := attr("input")
+	if input == nil {
+		return nil, false
+	}
+
+	s := &Sample{
+		Sensor:     prefix,
+		SensorType: sensorTypes[kind],
+	}
+	if label, err := readString(filepath.Join(dir, prefix+"_label")); err == nil && label != "" {
+		s.Sensor = label
+	}
+
+	for _, alarm := range []string{"alarm", "min_alarm", "max_alarm", "crit_alarm"} {
+		v, err := readFloat(filepath.Join(dir, prefix+"_"+alarm))
+		if err != nil {
+			continue
+		}
+		inAlarm := v != 0 || (s.Alarm != nil && *s.Alarm)
+		s.Alarm = &inAlarm
+	}
+
+	switch kind {
+	case "temp":
+		s.TemperatureCelsius = input
+		s.TemperatureMaxCelsius = attr("max")
+		s.TemperatureCriticalCelsius = attr("crit")
+	case "fan":
+		s.FanSpeedRPM = input
+		s.FanMinRPM = attr("min")
+	case "in":
+		s.VoltageVolts = input
+		s.VoltageMinVolts = attr("min")
+		s.VoltageMaxVolts = attr("max")
+	}
+	return s, true
+}
+
+// populateThermalZones reads the temperature of every /sys/class/thermal/thermal_zone* device, taking the
+// thresholds from its "hot" and "critical" trip points.
+func populateThermalZones(classDir string) []*Sample {
+	zones, err := os.ReadDir(classDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			sslog.WithError(err).Debug("Cannot read thermal zones.")
+		}
+		return nil
+	}
+
+	var samples []*Sample
+	for _, zone := range zones {
+		if !strings.HasPrefix(zone.Name(), "thermal_zone") {
+			continue
+		}
+		dir := filepath.Join(classDir, zone.Name())
+		temp, err := readFloat(filepath.Join(dir, "temp"))
+		if err != nil {
+			continue
+		}
+		temp /= hwmonScale["temp"]
+		zoneType, err := readString(filepath.Join(dir, "type"))
+		if err != nil {
+			zoneType = zone.Name()
+		}
+		s := &Sample{
+			Chip:               thermalChip,
+			Sensor:             zoneType,
+			SensorType:         TypeTemperature,
+			Device:             zone.Name(),
+			TemperatureCelsius: &temp,
+		}
+
+		// zones without trip points are still reported
+		files, _ := os.ReadDir(dir)
+		for _, file := range files {
+			match := tripPointRegex.FindStringSubmatch(file.Name())
+			if match == nil {
+				continue
+			}
+			tripType, err := readString(filepath.Join(dir, file.Name()))
+			if err != nil {
+				continue
+			}
+			tripTemp, err := readFloat(filepath.Join(dir, "trip_point_"+match[1]+"_temp"))
+			if err != nil {
+				continue
+			}
+			tripTemp /= hwmonScale["temp"]
+			switch tripType {
+			case "hot":
+				s.TemperatureMaxCelsius = &tripTemp
+			case "critical":
+				s.TemperatureCriticalCelsius = &tripTemp
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func readFloat(path string) (float64, error) {
+	str, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(str, 64)
+}
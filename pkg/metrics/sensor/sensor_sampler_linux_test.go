@@ -0,0 +1,87 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sensor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler_Sample(t *testing.T) {
+	// GIVEN sysfs fixtures with hwmon chips and thermal zones
+	t.Setenv("HOST_SYS", filepath.Join("fixtures", "sys"))
+
+	// WHEN sampled
+	results, err := NewSampler(nil).Sample()
+	require.NoError(t, err)
+
+	// THEN a sample is reported for each readable sensor, keyed by chip and sensor name
+	samples := map[string]*Sample{}
+	for _, r := range results {
+		s := r.(*Sample)
+		assert.Equal(t, "SensorSample", s.EventType)
+		samples[s.Chip+"/"+s.Sensor] = s
+	}
+	require.Len(t, samples, 7)
+
+	pkg := samples["coretemp/Package id 0"]
+	require.NotNil(t, pkg)
+	assert.Equal(t, "hwmon0", pkg.Device)
+	assert.Equal(t, TypeTemperature, pkg.SensorType)
+	assert.Equal(t, 45.0, *pkg.TemperatureCelsius)
+	assert.Equal(t, 80.0, *pkg.TemperatureMaxCelsius)
+	assert.Equal(t, 100.0, *pkg.TemperatureCriticalCelsius)
+	assert.False(t, *pkg.Alarm)
+
+	core := samples["coretemp/Core 0"]
+	require.NotNil(t, core)
+	assert.Equal(t, 101.5, *core.TemperatureCelsius)
+	assert.Nil(t, core.TemperatureMaxCelsius)
+	assert.True(t, *core.Alarm)
+
+	fan := samples["nct6775/fan1"]
+	require.NotNil(t, fan)
+	assert.Equal(t, TypeFan, fan.SensorType)
+	assert.Equal(t, 1200.0, *fan.FanSpeedRPM)
+	assert.Equal(t, 300.0, *fan.FanMinRPM)
+	assert.Nil(t, fan.TemperatureCelsius)
+
+	voltage := samples["nct6775/in0"]
+	require.NotNil(t, voltage)
+	assert.Equal(t, TypeVoltage, voltage.SensorType)
+	assert.Equal(t, 1.104, *voltage.VoltageVolts)
+	assert.Equal(t, 1.0, *voltage.VoltageMinVolts)
+	assert.Equal(t, 1.2, *voltage.VoltageMaxVolts)
+	assert.Nil(t, voltage.Alarm)
+
+	// AND legacy drivers exposing the attributes in the device directory are read
+	legacy := samples["it87/temp1"]
+	require.NotNil(t, legacy)
+	assert.Equal(t, "hwmon2", legacy.Device)
+	assert.Equal(t, 38.0, *legacy.TemperatureCelsius)
+
+	// AND thermal zones take their thresholds from the hot and critical trip points
+	zone := samples["thermal/x86_pkg_temp"]
+	require.NotNil(t, zone)
+	assert.Equal(t, "thermal_zone0", zone.Device)
+	assert.Equal(t, 52.0, *zone.TemperatureCelsius)
+	assert.Equal(t, 98.0, *zone.TemperatureMaxCelsius)
+	assert.Equal(t, 105.0, *zone.TemperatureCriticalCelsius)
+
+	acpi := samples["thermal/acpitz"]
+	require.NotNil(t, acpi)
+	assert.Equal(t, 27.8, *acpi.TemperatureCelsius)
+	assert.Nil(t, acpi.TemperatureCriticalCelsius)
+}
+
+func TestSampler_SampleNoSensors(t *testing.T) {
+	t.Setenv("HOST_SYS", t.TempDir())
+
+	results, err := NewSampler(nil).Sample()
+
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
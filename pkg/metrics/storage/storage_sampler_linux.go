@@ -12,6 +12,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -58,6 +59,17 @@ type Sample struct {
 	InodesFree        *uint64  `json:"inodesFree,omitempty"`
 	InodesTotal       *uint64  `json:"inodesTotal,omitempty"`
 	InodesUsedPercent *float64 `json:"inodesUsedPercent,omitempty"`
+	// Average time, including the queue time, of the operations completed during the sampling interval
+	AvgReadLatencyMs  *float64 `json:"avgReadLatencyMs,omitempty"`
+	AvgWriteLatencyMs *float64 `json:"avgWriteLatencyMs,omitempty"`
+	// Average number of operations queued or in progress during the sampling interval
+	AvgQueueSize *float64 `json:"avgQueueSize,omitempty"`
+	// Average size of the read and write operations completed during the sampling interval
+	AvgRequestSizeBytes *float64 `json:"avgRequestSizeBytes,omitempty"`
+	// Discard stats, only reported by 4.18+ kernels
+	DiscardsPerSec      *float64 `json:"discardIoPerSecond,omitempty"`
+	DiscardBytesPerSec  *float64 `json:"discardBytesPerSecond,omitempty"`
+	AvgDiscardLatencyMs *float64 `json:"avgDiscardLatencyMs,omitempty"`
 }
 
 // Enhanced from GOPSUtil, Adding Utilization
@@ -72,6 +84,11 @@ type LinuxIoCountersStat struct {
 	WriteTime               uint64 `json:"writeTime"`
 	IopsInProgress          uint64 `json:"iopsInProgress"`
 	IoTime                  uint64 `json:"ioTime"`
+	WeightedIoTime          uint64 `json:"weightedIoTime"`
+	HasDiscards             bool   `json:"hasDiscards"`
+	DiscardCount            uint64 `json:"discardCount"`
+	DiscardBytes            uint64 `json:"discardBytes"`
+	DiscardTime             uint64 `json:"discardTime"`
 	Name                    string `json:"name"`
 	SerialNumber            string `json:"serialNumber"`
 	TotalUtilizationPercent uint64 `json:"totalUtilizationPercent"`
@@ -123,6 +140,47 @@ type MountInfoStat struct {
 	FSType      string
 	MountSource string
 	Opts        string
+	// Btrfs subvolume, from the "subvol" super option
+	Subvolume string
+}
+
+// mountFilter selects the mounts to sample: those with a supported filesystem or a mount point matching any of
+// the include patterns, as tmpfs or overlay mounts, and not matching any of the exclude patterns.
+type mountFilter struct {
+	include []string
+	exclude []string
+}
+
+func newMountFilter(include, exclude []string) mountFilter {
+	return mountFilter{include: validMountPatterns(include), exclude: validMountPatterns(exclude)}
+}
+
+func (f mountFilter) accepts(mi MountInfoStat) bool {
+	if matchesMountPattern(f.exclude, mi.MountPoint) {
+		return false
+	}
+	return isSupportedFs(mi.FSType) || matchesMountPattern(f.include, mi.MountPoint)
+}
+
+// validMountPatterns discards the malformed glob patterns.
+func validMountPatterns(patterns []string) (valid []string) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			sslog.WithError(err).WithField("pattern", pattern).Warn("Ignoring invalid mount point pattern.")
+			continue
+		}
+		valid = append(valid, pattern)
+	}
+	return valid
+}
+
+func matchesMountPattern(patterns []string, mountPoint string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, mountPoint); matched {
+			return true
+		}
+	}
+	return false
 }
 
 // BlockDevice represents a linux fixed-sized blocks device
@@ -142,7 +200,7 @@ func NewStorageSampleWrapper(cfg *config.Config) SampleWrapper {
 		partitions: PartitionsCache{
 			ttl:             ttl,
 			isContainerized: cfg != nil && cfg.IsContainerized,
-			partitionsFunc:  fetchPartitions,
+			partitionsFunc:  fetchPartitions(newMountFilter(cfg.StorageIncludeMountPatterns, cfg.StorageExcludeMountPatterns)),
 		},
 	}
 	return &ssw
@@ -150,7 +208,14 @@ func NewStorageSampleWrapper(cfg *config.Config) SampleWrapper {
 
 // populateSampleOS complements the populateSample function by copying into the destinations the fields from the source
 // that are exclusive of Linux Storage Samples
-func populateSampleOS(_, _ *Sample) {
+func populateSampleOS(source, dest *Sample) {
+	dest.AvgReadLatencyMs = asValidFloatPtr(source.AvgReadLatencyMs)
+	dest.AvgWriteLatencyMs = asValidFloatPtr(source.AvgWriteLatencyMs)
+	dest.AvgQueueSize = asValidFloatPtr(source.AvgQueueSize)
+	dest.AvgRequestSizeBytes = asValidFloatPtr(source.AvgRequestSizeBytes)
+	dest.DiscardsPerSec = asValidFloatPtr(source.DiscardsPerSec)
+	dest.DiscardBytesPerSec = asValidFloatPtr(source.DiscardBytesPerSec)
+	dest.AvgDiscardLatencyMs = asValidFloatPtr(source.AvgDiscardLatencyMs)
 }
 
 // populateUsage copies the Usage Stats inside the destination sample, for those metrics that are exclusive of Linux
@@ -209,9 +274,57 @@ func CalculateSampleValues(ioCounter IOCountersStat, ioLastStats IOCountersStat,
 	result.WriteTimeDelta = writeTimeDelta
 	result.ReadCountDelta = readCountDelta
 	result.WriteCountDelta = writeCountDelta
+
+	calculateLatencyValues(counter, lastStats, elapsedMs, result)
 	return result
 }
 
+// calculateLatencyValues adds the iostat-like latency, queue and request size values, which are
+// averaged over the operations completed between the two samples.
+func calculateLatencyValues(counter, lastStats *LinuxIoCountersStat, elapsedMs int64, result *Sample) {
+	readCount := safeDelta(counter.ReadCount, lastStats.ReadCount)
+	writeCount := safeDelta(counter.WriteCount, lastStats.WriteCount)
+
+	result.AvgReadLatencyMs = average(safeDelta(counter.ReadTime, lastStats.ReadTime), readCount)
+	result.AvgWriteLatencyMs = average(safeDelta(counter.WriteTime, lastStats.WriteTime), writeCount)
+	result.AvgRequestSizeBytes = average(
+		safeDelta(counter.ReadBytes, lastStats.ReadBytes)+safeDelta(counter.WriteBytes, lastStats.WriteBytes),
+		readCount+writeCount)
+
+	if elapsedMs > 0 {
+		queueSize := float64(safeDelta(counter.WeightedIoTime, lastStats.WeightedIoTime)) / float64(elapsedMs)
+		result.AvgQueueSize = &queueSize
+	}
+
+	if counter.HasDiscards && lastStats.HasDiscards {
+		elapsedSeconds := float64(elapsedMs) / 1000
+		discardsPerSec := acquire.CalculateSafeDelta(counter.DiscardCount, lastStats.DiscardCount, elapsedSeconds)
+		discardBytesPerSec := acquire.CalculateSafeDelta(counter.DiscardBytes, lastStats.DiscardBytes, elapsedSeconds)
+		result.DiscardsPerSec = &discardsPerSec
+		result.DiscardBytesPerSec = &discardBytesPerSec
+		result.AvgDiscardLatencyMs = average(
+			safeDelta(counter.DiscardTime, lastStats.DiscardTime),
+			safeDelta(counter.DiscardCount, lastStats.DiscardCount))
+	}
+}
+
+// safeDelta returns 0 instead of overflowing when a counter has been reset.
+func safeDelta(current, previous uint64) uint64 {
+	if previous > current {
+		return 0
+	}
+	return current - previous
+}
+
+// average returns 0 when there are no operations, as iostat does.
+func average(total, count uint64) *float64 {
+	avg := float64(0)
+	if count > 0 {
+		avg = float64(total) / float64(count)
+	}
+	return &avg
+}
+
 func parseMountFile(filename string, line string) (mi MountInfoStat, err error) {
 	switch filename {
 	case mountInfo:
@@ -284,6 +397,9 @@ func parseMountInfo(line string) (mi MountInfoStat, err error) {
 				}
 				mi.Opts += superOpt
 			}
+			if strings.HasPrefix(superOpt, "subvol=") {
+				mi.Subvolume = strings.TrimPrefix(superOpt, "subvol=")
+			}
 		}
 	}
 
@@ -395,7 +511,7 @@ func partitionsInfo() (devices []BlockDevice) {
 // use /etc/mtab because /proc/<pid>/mounts doesn't display properly lvm
 // devices, making it impossible to match against io counters.
 // The same logic is applied in fetchPartitions.
-func deviceMapperInfo(isContainerized bool) (mounts []MountInfoStat) {
+func deviceMapperInfo(isContainerized bool, filter mountFilter) (mounts []MountInfoStat) {
 	var mountsFile string
 	var mountsFilePath string
 
@@ -424,7 +540,7 @@ func deviceMapperInfo(isContainerized bool) (mounts []MountInfoStat) {
 			continue
 		}
 		// could be optimized to not create the struct in the first place
-		if !isSupportedFs(mountInfo.FSType) {
+		if !filter.accepts(mountInfo) {
 			unsupportedMountPoints = append(unsupportedMountPoints, log.Fields{
 				"mountsFile": mountsFile,
 				"lineno":     lineno,
@@ -454,7 +570,7 @@ func deviceMapperInfo(isContainerized bool) (mounts []MountInfoStat) {
 // with the above pattern of VolGroup-LogVol. If we find ourselves in this situation we have to refactor this a lot more and use
 // other tools to make this mapping instead of relying in the simple mount files
 func CalculateDeviceMapping(activeDevices map[string]bool, isContainerized bool) (devToFullDevicePath map[string]string) {
-	allMounts := deviceMapperInfo(isContainerized)
+	allMounts := deviceMapperInfo(isContainerized, mountFilter{})
 	devToFullDevicePath = make(map[string]string)
 
 	for deviceName := range activeDevices {
@@ -534,25 +650,51 @@ func getMountsSource(pid string) (string, string) {
 	}
 }
 
-func fetchPartitions(isContainerized bool) ([]PartitionStat, error) {
+// fetchPartitions returns the function retrieving the partitions accepted by the filter. Bind mounts of the same
+// device and subvolume are reported once, preferring the mount of the filesystem root.
+func fetchPartitions(filter mountFilter) func(bool) ([]PartitionStat, error) {
+	return func(isContainerized bool) ([]PartitionStat, error) {
+		mountedDevices := deviceMapperInfo(isContainerized, filter)
+		if mountedDevices == nil {
+			return nil, errors.New("failed to get mounted devices/partitions")
+		}
+		mountedDevices = dedupBindMounts(mountedDevices)
+
+		partitions := make([]PartitionStat, 0, len(mountedDevices))
+		for _, m := range mountedDevices {
+			d := PartitionStat{
+				Device:     m.Device,
+				Mountpoint: m.MountPoint,
+				Fstype:     m.FSType,
+				Opts:       m.Opts,
+			}
+			partitions = append(partitions, d)
+		}
 
-	mountedDevices := deviceMapperInfo(isContainerized)
-	if mountedDevices == nil {
-		return nil, errors.New("failed to get mounted devices/partitions")
+		return partitions, nil
 	}
+}
 
-	partitions := make([]PartitionStat, 0, len(mountedDevices))
-	for _, m := range mountedDevices {
-		d := PartitionStat{
-			Device:     m.Device,
-			Mountpoint: m.MountPoint,
-			Fstype:     m.FSType,
-			Opts:       m.Opts,
+// dedupBindMounts keeps a single mount for each device and subvolume. The device numbers are only known when the
+// mounts are read from the mountinfo file, otherwise no mount is discarded.
+func dedupBindMounts(mounts []MountInfoStat) []MountInfoStat {
+	deduped := make([]MountInfoStat, 0, len(mounts))
+	index := map[string]int{}
+	for _, m := range mounts {
+		if m.MajMin == "" {
+			deduped = append(deduped, m)
+			continue
+		}
+		key := m.MajMin + ":" + m.Subvolume
+		i, found := index[key]
+		if !found {
+			index[key] = len(deduped)
+			deduped = append(deduped, m)
+		} else if deduped[i].Root != "/" && m.Root == "/" {
+			deduped[i] = m
 		}
-		partitions = append(partitions, d)
 	}
-
-	return partitions, nil
+	return deduped
 }
 
 func fetchIoCounters() (map[string]IOCountersStat, error) {
@@ -613,6 +755,10 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 		if err != nil {
 			return ret, err
 		}
+		weightedIotime, err := strconv.ParseUint(fields[13], 10, 64)
+		if err != nil {
+			return ret, err
+		}
 		d := LinuxIoCountersStat{
 			ReadBytes:        rbytes * SectorSize,
 			WriteBytes:       wbytes * SectorSize,
@@ -624,10 +770,24 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 			WriteTime:        wtime,
 			IopsInProgress:   iopsInProgress,
 			IoTime:           iotime,
+			WeightedIoTime:   weightedIotime,
 		}
 		if d == empty {
 			continue
 		}
+		// discard stats are appended by 4.18+ kernels:
+		// discards, merged discards, sectors discarded and milliseconds spent discarding
+		if len(fields) >= 18 {
+			discards, errCount := strconv.ParseUint(fields[14], 10, 64)
+			dbytes, errBytes := strconv.ParseUint(fields[16], 10, 64)
+			dtime, errTime := strconv.ParseUint(fields[17], 10, 64)
+			if errCount == nil && errBytes == nil && errTime == nil {
+				d.HasDiscards = true
+				d.DiscardCount = discards
+				d.DiscardBytes = dbytes * SectorSize
+				d.DiscardTime = dtime
+			}
+		}
 		d.Name = name
 
 		d.SerialNumber = GetDiskSerialNumber(name)
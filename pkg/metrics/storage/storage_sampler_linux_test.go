@@ -17,6 +17,7 @@ import (
 	"github.com/shirou/gopsutil/v3/disk"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDeviceRegexp(t *testing.T) {
@@ -60,7 +61,7 @@ func TestLvmRegex_ExtractsDeviceName(t *testing.T) {
 }
 
 func TestLinuxPartitions(t *testing.T) {
-	partitions, err := fetchPartitions(false)
+	partitions, err := fetchPartitions(mountFilter{})(false)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, partitions)
 	for _, partition := range partitions {
@@ -70,6 +71,61 @@ func TestLinuxPartitions(t *testing.T) {
 	}
 }
 
+func TestLinuxPartitions_MountPatterns(t *testing.T) {
+	// GIVEN a host with tmpfs, overlay and bind mounts
+	t.Setenv("HOST_PROC", filepath.Join("fixtures", "overlay", "proc1"))
+	filter := newMountFilter(
+		[]string{"/dev/shm", "/var/lib/docker/overlay2/*/merged", "["},
+		[]string{"/var/lib/docker/overlay2/0f30b6*/merged", "/var/lib/kubelet/plugins/*/*/mounts/aws/*/vol-0479300f6a6f4322c"})
+
+	// WHEN the partitions are fetched
+	partitions, err := fetchPartitions(filter)(false)
+	require.NoError(t, err)
+
+	// THEN the included mounts are reported regardless of the filesystem, once per device
+	mountPoints := map[string]string{}
+	for _, p := range partitions {
+		mountPoints[p.Mountpoint] = p.Fstype
+	}
+	assert.Equal(t, "tmpfs", mountPoints["/dev/shm"])
+	assert.Equal(t, "overlay", mountPoints["/var/lib/docker/overlay2/3319cb18f0783d494067bbf4c7bc5144813363f202b6a5694d6fe64d253ca12d/merged"])
+	assert.NotContains(t, mountPoints, "/var/lib/docker/overlay2/0f30b678714aca1247babb7168a50da1818711837d16693aad15d65972d8c33a/merged")
+	assert.NotContains(t, mountPoints, "/run")
+	// the root mount is preferred to the bind mount of one of its files
+	assert.Equal(t, "xfs", mountPoints["/"])
+	assert.NotContains(t, mountPoints, "/var/lib/kubelet/pods/04907770-1153-11ea-9885-0a0ea6db8a61/volume-subpaths/config/newrelic-infrastructure/0")
+	// the excluded mount is not reported, but the bind mount of the same device is
+	assert.Equal(t, "ext4", mountPoints["/var/lib/kubelet/pods/8cd9191d-1784-11ea-9885-0a0ea6db8a61/volumes/kubernetes.io~aws-ebs/pvc-83091421-c4ec-11e9-a256-0ad73bdc90e0"])
+	assert.NotContains(t, mountPoints, "/var/lib/kubelet/plugins/kubernetes.io/aws-ebs/mounts/aws/us-east-1b/vol-0479300f6a6f4322c")
+	// of the two mounts of the same device, only the first one is reported
+	assert.Contains(t, mountPoints, "/var/lib/kubelet/plugins/kubernetes.io/aws-ebs/mounts/aws/us-east-1b/vol-0aeaa5eec95574285")
+	assert.NotContains(t, mountPoints, "/var/lib/kubelet/pods/ad7a726b-1784-11ea-9885-0a0ea6db8a61/volumes/kubernetes.io~aws-ebs/pvc-c344c1bb-c4ec-11e9-a256-0ad73bdc90e0")
+}
+
+func TestDedupBindMounts_Subvolumes(t *testing.T) {
+	mounts := []MountInfoStat{
+		{MajMin: "0:40", Root: "/@", MountPoint: "/", Subvolume: "/@"},
+		{MajMin: "0:40", Root: "/@home", MountPoint: "/home", Subvolume: "/@home"},
+		{MajMin: "0:40", Root: "/@home/user", MountPoint: "/srv/user", Subvolume: "/@home"},
+		{MountPoint: "/data"},
+		{MountPoint: "/data"},
+	}
+
+	deduped := dedupBindMounts(mounts)
+
+	require.Len(t, deduped, 4)
+	assert.Equal(t, "/", deduped[0].MountPoint)
+	assert.Equal(t, "/home", deduped[1].MountPoint)
+}
+
+func TestParseMountInfo_Subvolume(t *testing.T) {
+	mi, err := parseMountInfo("29 1 0:27 /@home /home rw,relatime shared:2 - btrfs /dev/sda2 rw,ssd,subvolid=257,subvol=/@home")
+
+	require.NoError(t, err)
+	assert.Equal(t, "/@home", mi.Subvolume)
+	assert.Equal(t, "rw,relatime,rw", mi.Opts)
+}
+
 func TestCalculateBytesRate(t *testing.T) {
 	lastStats := &LinuxIoCountersStat{
 		Name:       "nameeee",
@@ -153,6 +209,47 @@ func TestDiskIOCounters(t *testing.T) {
 	}
 }
 
+func TestCalculateLatencyValues(t *testing.T) {
+	// GIVEN two diskstats snapshots taken 10 seconds apart, from a disk with discard stats
+	// and a device of an older kernel without them
+	t.Setenv("HOST_PROC", filepath.Join("fixtures", "diskstats", "proc1"))
+	last, err := fetchIoCounters()
+	require.NoError(t, err)
+	t.Setenv("HOST_PROC", filepath.Join("fixtures", "diskstats", "proc2"))
+	current, err := fetchIoCounters()
+	require.NoError(t, err)
+
+	// AND devices without activity are ignored
+	assert.Len(t, current, 2)
+
+	// WHEN calculating the sample values
+	sda := CalculateSampleValues(current["sda"], last["sda"], 10000)
+	dm := CalculateSampleValues(current["dm-0"], last["dm-0"], 10000)
+
+	// THEN the iostat-like values are reported
+	assert.Equal(t, 5.0, *sda.AvgReadLatencyMs)
+	assert.Equal(t, 10.0, *sda.AvgWriteLatencyMs)
+	assert.Equal(t, 2.0, *sda.AvgQueueSize)
+	assert.Equal(t, 8192.0, *sda.AvgRequestSizeBytes)
+	assert.Equal(t, 5.0, *sda.DiscardsPerSec)
+	assert.Equal(t, 40960.0, *sda.DiscardBytesPerSec)
+	assert.Equal(t, 2.0, *sda.AvgDiscardLatencyMs)
+
+	// AND averages are zero without operations, and discards are omitted when not supported
+	assert.Equal(t, 2.0, *dm.AvgReadLatencyMs)
+	assert.Equal(t, 0.0, *dm.AvgWriteLatencyMs)
+	assert.Equal(t, 0.02, *dm.AvgQueueSize)
+	assert.Equal(t, 10240.0, *dm.AvgRequestSizeBytes)
+	assert.Nil(t, dm.DiscardsPerSec)
+	assert.Nil(t, dm.AvgDiscardLatencyMs)
+
+	// AND the values are copied into the reported sample
+	dest := &Sample{}
+	populateSample(sda, dest)
+	assert.Equal(t, 5.0, *dest.AvgReadLatencyMs)
+	assert.Equal(t, 40960.0, *dest.DiscardBytesPerSec)
+}
+
 func TestParseMtab(t *testing.T) {
 
 	var lines = []string{
@@ -29,29 +29,32 @@ type SystemSample struct {
 	*MemorySample
 	*DiskSample
 	*HostSample
+	*PressureSample
 }
 
 type SystemSampler struct {
-	CpuMonitor     *CPUMonitor
-	DiskMonitor    *DiskMonitor
-	LoadMonitor    *LoadMonitor
-	MemoryMonitor  *MemoryMonitor
-	HostMonitor    *HostMonitor
-	context        agent.AgentContext
-	stopChannel    chan bool
-	waitForCleanup *sync.WaitGroup
+	CpuMonitor      *CPUMonitor
+	DiskMonitor     *DiskMonitor
+	LoadMonitor     *LoadMonitor
+	MemoryMonitor   *MemoryMonitor
+	HostMonitor     *HostMonitor
+	PressureMonitor *PressureMonitor
+	context         agent.AgentContext
+	stopChannel     chan bool
+	waitForCleanup  *sync.WaitGroup
 }
 
 func NewSystemSampler(context agent.AgentContext, storageSampler *storage.Sampler, ntpMonitor NtpMonitor) *SystemSampler {
 	cfg := context.Config()
 	return &SystemSampler{
-		CpuMonitor:     NewCPUMonitor(context),
-		DiskMonitor:    NewDiskMonitor(storageSampler),
-		LoadMonitor:    NewLoadMonitor(),
-		MemoryMonitor:  NewMemoryMonitor(cfg.IgnoreReclaimable),
-		HostMonitor:    NewHostMonitor(ntpMonitor),
-		context:        context,
-		waitForCleanup: &sync.WaitGroup{},
+		CpuMonitor:      NewCPUMonitor(context),
+		DiskMonitor:     NewDiskMonitor(storageSampler),
+		LoadMonitor:     NewLoadMonitor(),
+		MemoryMonitor:   NewMemoryMonitor(cfg.IgnoreReclaimable),
+		HostMonitor:     NewHostMonitor(ntpMonitor),
+		PressureMonitor: NewPressureMonitor(),
+		context:         context,
+		waitForCleanup:  &sync.WaitGroup{},
 	}
 }
 
@@ -155,6 +158,14 @@ func (s *SystemSampler) Sample() (results sample.EventBatch, err error) {
 	sysSample.HostSample = hostSample
 	seg.End()
 
+	// Collect Pressure Stall Information. Failing to read it doesn't discard the rest of the sample.
+	pressureSample, err := s.PressureMonitor.Sample()
+	if err != nil {
+		syslog.WithError(err).Debug("Cannot sample pressure stall information.")
+		err = nil
+	}
+	sysSample.PressureSample = pressureSample
+
 	helpers.LogStructureDetails(syslog, sysSample, "SystemSample", "final", nil)
 	results = append(results, sysSample)
 
@@ -0,0 +1,98 @@
+// Copyright 2021 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/sys/unix"
+)
+
+// kernelTimeStatus is the kernel clock discipline status, as reported by adjtimex.
+type kernelTimeStatus struct {
+	synced         bool
+	estimatedError time.Duration
+	maxError       time.Duration
+}
+
+// LocalTimeSync reports the clock synchronization status maintained by the host time daemon, reading the kernel
+// clock status and, when configured, the chronyd tracking report. It doesn't send any request outside the host.
+type LocalTimeSync struct {
+	chrony       *chronyClient
+	kernelStatus func() (kernelTimeStatus, error)
+}
+
+// NewLocalTimeSync creates a LocalTimeSync, chronyd is queried only when chronyAddress is not empty.
+func NewLocalTimeSync(chronyAddress string) *LocalTimeSync {
+	l := &LocalTimeSync{kernelStatus: adjtimexStatus}
+	if chronyAddress != "" {
+		l.chrony = newChronyClient(chronyAddress)
+	}
+	return l
+}
+
+// SyncStatus returns the kernel clock errors and synchronization status. The chronyd view of the synchronization
+// status takes precedence when available, adding the stratum and the offset.
+func (l *LocalTimeSync) SyncStatus() (*TimeSyncStatus, error) {
+	status := &TimeSyncStatus{}
+	var errs error
+
+	kernel, err := l.kernelStatus()
+	if err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("cannot read kernel clock status: %w", err))
+	} else {
+		status.Synced = &kernel.synced
+		status.EstimatedError = &kernel.estimatedError
+		status.MaxError = &kernel.maxError
+	}
+
+	if l.chrony != nil {
+		tracking, err := l.chrony.tracking()
+		if err != nil {
+			errs = multierr.Append(errs, err)
+		} else {
+			synced := tracking.synced()
+			status.Synced = &synced
+			status.Stratum = &tracking.stratum
+			status.Offset = &tracking.currentCorrection
+		}
+	}
+
+	if status.Synced == nil {
+		return nil, errs
+	}
+	if errs != nil {
+		syslog.WithError(errs).Debug("time sync status is incomplete")
+	}
+	return status, nil
+}
+
+// Offset returns the clock offset reported by chronyd.
+func (l *LocalTimeSync) Offset() (time.Duration, error) {
+	status, err := l.SyncStatus()
+	if err != nil {
+		return 0, multierr.Append(ErrGettingNtpOffset, err)
+	}
+	if status.Offset == nil {
+		return 0, ErrGettingNtpOffset
+	}
+	return *status.Offset, nil
+}
+
+// adjtimexStatus reads the kernel clock status without modifying it. The clock is unsynchronized when the time
+// daemon set the STA_UNSYNC flag or the clock state is TIME_ERROR.
+func adjtimexStatus() (kernelTimeStatus, error) {
+	var tx unix.Timex
+	state, err := unix.Adjtimex(&tx)
+	if err != nil {
+		return kernelTimeStatus{}, err
+	}
+	return kernelTimeStatus{
+		synced:         state != unix.TIME_ERROR && tx.Status&unix.STA_UNSYNC == 0,
+		estimatedError: time.Duration(tx.Esterror) * time.Microsecond,
+		maxError:       time.Duration(tx.Maxerror) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func kernelStatus(synced bool) func() (kernelTimeStatus, error) {
+	return func() (kernelTimeStatus, error) {
+		return kernelTimeStatus{
+			synced:         synced,
+			estimatedError: 1500 * time.Microsecond,
+			maxError:       250 * time.Millisecond,
+		}, nil
+	}
+}
+
+func TestLocalTimeSync_Kernel(t *testing.T) {
+	l := NewLocalTimeSync("")
+	l.kernelStatus = kernelStatus(true)
+
+	status, err := l.SyncStatus()
+
+	require.NoError(t, err)
+	assert.True(t, *status.Synced)
+	assert.Equal(t, 1500*time.Microsecond, *status.EstimatedError)
+	assert.Equal(t, 250*time.Millisecond, *status.MaxError)
+	assert.Nil(t, status.Stratum)
+	assert.Nil(t, status.Offset)
+
+	_, err = l.Offset()
+	assert.ErrorIs(t, err, ErrGettingNtpOffset)
+}
+
+func TestLocalTimeSync_Chrony(t *testing.T) {
+	// GIVEN a synced kernel clock and chronyd reporting it is unsynchronised
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	serveTracking(t, conn, 3, chronyLeapUnsynchronised, 0.5)
+
+	l := NewLocalTimeSync(conn.LocalAddr().String())
+	l.kernelStatus = kernelStatus(true)
+
+	// WHEN the status is read
+	status, err := l.SyncStatus()
+
+	// THEN chronyd values take precedence
+	require.NoError(t, err)
+	assert.False(t, *status.Synced)
+	assert.Equal(t, uint16(3), *status.Stratum)
+	assert.Equal(t, 500*time.Millisecond, *status.Offset)
+	assert.Equal(t, 1500*time.Microsecond, *status.EstimatedError)
+}
+
+func TestLocalTimeSync_Errors(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	l := NewLocalTimeSync(conn.LocalAddr().String())
+	l.chrony.timeout = 50 * time.Millisecond
+	l.kernelStatus = func() (kernelTimeStatus, error) {
+		return kernelTimeStatus{}, errors.New("not permitted")
+	}
+
+	_, err = l.SyncStatus()
+
+	assert.Error(t, err)
+}
+
+func TestHostMonitor_TimeSyncStatus(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	serveTracking(t, conn, 2, 0, -0.002)
+
+	l := NewLocalTimeSync(conn.LocalAddr().String())
+	l.kernelStatus = kernelStatus(true)
+
+	sample, err := NewHostMonitor(l).Sample()
+
+	require.NoError(t, err)
+	assert.True(t, *sample.NtpSynced)
+	assert.Equal(t, uint16(2), *sample.NtpStratum)
+	assert.InDelta(t, -0.002, *sample.NtpOffset, 1e-9)
+	assert.InDelta(t, 1.5, *sample.ClockEstimatedErrorMs, 1e-9)
+	assert.InDelta(t, 250, *sample.ClockMaxErrorMs, 1e-9)
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build linux
+// +build linux
+
+package topology
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// nodeStats holds the numastat counters needed to calculate rates between samples.
+type nodeStats struct {
+	time     time.Time
+	numastat map[string]uint64
+}
+
+// populateNodes samples the NUMA nodes under the host /sys/devices/system/node, also returning the node of
+// each CPU.
+func (s *Sampler) populateNodes() ([]*NumaNodeSample, map[int]int, error) {
+	root := helpers.HostSys("devices", "system", "node")
+	dirs, err := filepath.Glob(filepath.Join(root, "node[0-9]*"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, nil, fmt.Errorf("no NUMA nodes found under %s", root)
+	}
+
+	var samples []*NumaNodeSample
+	cpuNodes := map[int]int{}
+	current := map[int]nodeStats{}
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		ns, st := readNode(dir, id, s.lastNodes[id])
+		if cpus, err := readCPUList(filepath.Join(dir, "cpulist")); err == nil {
+			ns.CPUCount = len(cpus)
+			for _, cpu := range cpus {
+				cpuNodes[cpu] = id
+			}
+		}
+		samples = append(samples, ns)
+		current[id] = st
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].NodeID < samples[j].NodeID })
+	s.lastNodes = current
+	return samples, cpuNodes, nil
+}
+
+// readNode samples the node meminfo and numastat files, calculating the rates against the last stats.
+func readNode(dir string, id int, last nodeStats) (*NumaNodeSample, nodeStats) {
+	ns := &NumaNodeSample{NodeID: id}
+	st := nodeStats{time: time.Now()}
+
+	if meminfo, err := readNodeMeminfo(filepath.Join(dir, "meminfo")); err == nil {
+		value := func(key string) *uint64 {
+			v, ok := meminfo[key]
+			if !ok {
+				return nil
+			}
+			return &v
+		}
+		ns.MemoryTotalBytes = value("MemTotal")
+		ns.MemoryFreeBytes = value("MemFree")
+		ns.MemoryUsedBytes = value("MemUsed")
+		ns.MemoryFilePagesBytes = value("FilePages")
+		ns.MemoryAnonPagesBytes = value("AnonPages")
+		ns.MemorySlabBytes = value("Slab")
+		ns.HugePagesTotal = value("HugePages_Total")
+		ns.HugePagesFree = value("HugePages_Free")
+		if ns.MemoryTotalBytes != nil && ns.MemoryUsedBytes != nil && *ns.MemoryTotalBytes > 0 {
+			usedPercent := float64(*ns.MemoryUsedBytes) / float64(*ns.MemoryTotalBytes) * 100
+			ns.MemoryUsedPercent = &usedPercent
+		}
+	}
+
+	if numastat, err := readNumastat(filepath.Join(dir, "numastat")); err == nil {
+		st.numastat = numastat
+		if last.numastat != nil {
+			elapsedSeconds := st.time.Sub(last.time).Seconds()
+			rate := func(key string) *float64 {
+				if _, ok := numastat[key]; !ok {
+					return nil
+				}
+				r := acquire.CalculateSafeDelta(numastat[key], last.numastat[key], elapsedSeconds)
+				return &r
+			}
+			ns.NumaHitPerSec = rate("numa_hit")
+			ns.NumaMissPerSec = rate("numa_miss")
+			ns.NumaForeignPerSec = rate("numa_foreign")
+		}
+	}
+	return ns, st
+}
+
+// readNodeMeminfo parses the node meminfo file, returning the sizes in bytes and the huge pages as a count:
+//
+//	Node 0 MemTotal:       16318412 kB
+//	Node 0 HugePages_Total:     0
+func readNodeMeminfo(path string) (map[string]uint64, error) {
+	lines, err := acquire.ReadLines(path)
+	// EOF is returned after reading the whole file
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	values := map[string]uint64{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "Node" {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) == 5 && fields[4] == "kB" {
+			v *= 1024
+		}
+		values[strings.TrimSuffix(fields[2], ":")] = v
+	}
+	return values, nil
+}
+
+// readNumastat parses the "<key> <value>" lines of the node numastat file.
+func readNumastat(path string) (map[string]uint64, error) {
+	lines, err := acquire.ReadLines(path)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	values := map[string]uint64{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, nil
+}
+
+// readCPUList parses a CPU list file, as "0-3,8-11".
+func readCPUList(path string) ([]int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUList(strings.TrimSpace(string(content)))
+}
+
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	if list == "" {
+		return cpus, nil
+	}
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
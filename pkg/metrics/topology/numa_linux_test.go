@@ -0,0 +1,71 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package topology
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler_PopulateNodes(t *testing.T) {
+	// GIVEN sysfs fixtures with two NUMA nodes
+	t.Setenv("HOST_SYS", filepath.Join("fixtures", "sys"))
+	s := NewSampler(nil)
+
+	// WHEN sampled twice
+	_, _, err := s.populateNodes()
+	require.NoError(t, err)
+	nodes, cpuNodes, err := s.populateNodes()
+	require.NoError(t, err)
+
+	// THEN the memory of each node is reported in bytes, with the rates from the second sample
+	require.Len(t, nodes, 2)
+	node := nodes[1]
+	assert.Equal(t, 1, node.NodeID)
+	assert.Equal(t, 4, node.CPUCount)
+	assert.Equal(t, uint64(16318412*1024), *node.MemoryTotalBytes)
+	assert.Equal(t, uint64(12238809*1024), *node.MemoryUsedBytes)
+	assert.Equal(t, uint64(500000*1024), *node.MemorySlabBytes)
+	assert.Equal(t, uint64(0), *node.HugePagesTotal)
+	assert.InDelta(t, 75, *node.MemoryUsedPercent, 0.1)
+	assert.Equal(t, float64(0), *node.NumaMissPerSec)
+	assert.Equal(t, map[int]int{0: 0, 1: 0, 4: 0, 5: 0, 2: 1, 3: 1, 6: 1, 7: 1}, cpuNodes)
+}
+
+func TestSampler_Sample(t *testing.T) {
+	t.Setenv("HOST_SYS", filepath.Join("fixtures", "sys"))
+	s := NewSampler(nil)
+	user := 0.0
+	s.cpuTimes = func(bool) ([]cpu.TimesStat, error) {
+		user += 10
+		return []cpu.TimesStat{{CPU: "cpu6", User: user, Idle: 10}}, nil
+	}
+
+	_, err := s.Sample()
+	require.NoError(t, err)
+	results, err := s.Sample()
+	require.NoError(t, err)
+
+	require.Len(t, results, 3)
+	core := results[0].(*CPUCoreSample)
+	assert.Equal(t, "CpuCoreSample", core.BaseEvent.EventType)
+	assert.Equal(t, 1, *core.NumaNode)
+	assert.Equal(t, "NumaNodeSample", results[1].(*NumaNodeSample).BaseEvent.EventType)
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-2,8,10-11")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 8, 10, 11}, cpus)
+
+	cpus, err = parseCPUList("")
+	require.NoError(t, err)
+	assert.Empty(t, cpus)
+
+	_, err = parseCPUList("0-a")
+	assert.Error(t, err)
+}
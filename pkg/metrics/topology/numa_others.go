@@ -0,0 +1,15 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build !linux
+// +build !linux
+
+package topology
+
+import "errors"
+
+// nodeStats is not used on platforms without NUMA nodes information.
+type nodeStats struct{}
+
+func (s *Sampler) populateNodes() ([]*NumaNodeSample, map[int]int, error) {
+	return nil, nil, errors.New("NUMA nodes are only reported on Linux")
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package topology
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var tplog = log.WithComponent("CPUTopologySampler")
+
+// CPUCoreSample reports the usage of a logical CPU, as percentages of its time since the previous sample.
+type CPUCoreSample struct {
+	sample.BaseEvent
+
+	// Logical CPU name, as "cpu0"
+	Core   string `json:"core"`
+	CoreID *int   `json:"coreId,omitempty"`
+	// NUMA node the CPU belongs to, when known
+	NumaNode *int `json:"numaNode,omitempty"`
+
+	CPUPercent float64 `json:"cpuPercent"`
+	// User time includes the niced processes time
+	CPUUserPercent    float64 `json:"cpuUserPercent"`
+	CPUSystemPercent  float64 `json:"cpuSystemPercent"`
+	CPUIOWaitPercent  float64 `json:"cpuIOWaitPercent"`
+	CPUIrqPercent     float64 `json:"cpuIrqPercent"`
+	CPUSoftIrqPercent float64 `json:"cpuSoftIrqPercent"`
+	CPUStealPercent   float64 `json:"cpuStealPercent"`
+	CPUIdlePercent    float64 `json:"cpuIdlePercent"`
+}
+
+// NumaNodeSample reports the memory usage of a NUMA node. Values not exposed by the kernel are omitted, as well
+// as the rates on the first sample.
+type NumaNodeSample struct {
+	sample.BaseEvent
+
+	NodeID   int `json:"nodeId"`
+	CPUCount int `json:"cpuCount"`
+
+	MemoryTotalBytes     *uint64  `json:"memoryTotalBytes,omitempty"`
+	MemoryFreeBytes      *uint64  `json:"memoryFreeBytes,omitempty"`
+	MemoryUsedBytes      *uint64  `json:"memoryUsedBytes,omitempty"`
+	MemoryUsedPercent    *float64 `json:"memoryUsedPercent,omitempty"`
+	MemoryFilePagesBytes *uint64  `json:"memoryFilePagesBytes,omitempty"`
+	MemoryAnonPagesBytes *uint64  `json:"memoryAnonPagesBytes,omitempty"`
+	MemorySlabBytes      *uint64  `json:"memorySlabBytes,omitempty"`
+	HugePagesTotal       *uint64  `json:"hugePagesTotal,omitempty"`
+	HugePagesFree        *uint64  `json:"hugePagesFree,omitempty"`
+
+	// Pages allocated in the node as intended, and in this node although intended for another one
+	NumaHitPerSec  *float64 `json:"numaHitPerSecond,omitempty"`
+	NumaMissPerSec *float64 `json:"numaMissPerSecond,omitempty"`
+	// Pages intended for this node but allocated in another one
+	NumaForeignPerSec *float64 `json:"numaForeignPerSecond,omitempty"`
+}
+
+// Sampler reports a CpuCoreSample for each logical CPU and a NumaNodeSample for each NUMA node.
+type Sampler struct {
+	sampleRate time.Duration
+	enabled    bool
+	cpuTimes   func(bool) ([]cpu.TimesStat, error)
+	lastTimes  map[string]cpu.TimesStat
+	lastNodes  map[int]nodeStats
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	cfg := config.NewCPUTopologyConfig()
+	if context != nil {
+		cfg = context.Config().CPUTopologyMetrics
+	}
+	sampleRateSec := cfg.SampleRate
+	if sampleRateSec < config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && sampleRateSec > config.FREQ_DISABLE_SAMPLING {
+		sampleRateSec = config.FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
+	}
+
+	return &Sampler{
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		enabled:    cfg.Enabled,
+		cpuTimes:   cpu.Times,
+		lastNodes:  map[int]nodeStats{},
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "CPUTopologySampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING || !s.enabled
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in topology.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	nodes, cpuNodes, err := s.populateNodes()
+	if err != nil {
+		tplog.WithError(err).Debug("Unable to retrieve NUMA nodes.")
+	}
+
+	times, err := s.cpuTimes(true)
+	if err != nil {
+		tplog.WithError(err).Warn("Unable to retrieve per CPU times.")
+	}
+	for _, cs := range s.coreSamples(times, cpuNodes) {
+		cs.Type("CpuCoreSample")
+		eventBatch = append(eventBatch, cs)
+	}
+	for _, ns := range nodes {
+		ns.Type("NumaNodeSample")
+		eventBatch = append(eventBatch, ns)
+	}
+	return eventBatch, nil
+}
+
+// coreSamples calculates the usage of each CPU since the last times. Nothing is reported for the CPUs without
+// previous times, as on the first sample or when a CPU is brought online.
+func (s *Sampler) coreSamples(times []cpu.TimesStat, cpuNodes map[int]int) []*CPUCoreSample {
+	var samples []*CPUCoreSample
+	current := make(map[string]cpu.TimesStat, len(times))
+	for _, t := range times {
+		current[t.CPU] = t
+		last, ok := s.lastTimes[t.CPU]
+		if !ok {
+			continue
+		}
+		cs := coreSample(t, last)
+		if id, err := strconv.Atoi(strings.TrimPrefix(t.CPU, "cpu")); err == nil {
+			cs.CoreID = &id
+			if node, ok := cpuNodes[id]; ok {
+				cs.NumaNode = &node
+			}
+		}
+		samples = append(samples, cs)
+	}
+	s.lastTimes = current
+	return samples
+}
+
+func coreSample(current, last cpu.TimesStat) *CPUCoreSample {
+	// counters may go backwards, as the steal time during migrations of some paravirtualized guests
+	delta := func(cur, prev float64) float64 {
+		if cur < prev {
+			return 0
+		}
+		return cur - prev
+	}
+	user := delta(current.User, last.User) + delta(current.Nice, last.Nice)
+	system := delta(current.System, last.System)
+	ioWait := delta(current.Iowait, last.Iowait)
+	irq := delta(current.Irq, last.Irq)
+	softIrq := delta(current.Softirq, last.Softirq)
+	steal := delta(current.Steal, last.Steal)
+	idle := delta(current.Idle, last.Idle)
+
+	cs := &CPUCoreSample{Core: current.CPU}
+	total := user + system + ioWait + irq + softIrq + steal + idle
+	if total == 0 {
+		cs.CPUIdlePercent = 100
+		return cs
+	}
+	cs.CPUUserPercent = user / total * 100
+	cs.CPUSystemPercent = system / total * 100
+	cs.CPUIOWaitPercent = ioWait / total * 100
+	cs.CPUIrqPercent = irq / total * 100
+	cs.CPUSoftIrqPercent = softIrq / total * 100
+	cs.CPUStealPercent = steal / total * 100
+	cs.CPUIdlePercent = idle / total * 100
+	cs.CPUPercent = 100 - cs.CPUIdlePercent
+	return cs
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package topology
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoreSample(t *testing.T) {
+	last := cpu.TimesStat{CPU: "cpu1", User: 100, Nice: 10, System: 50, Iowait: 5, Irq: 1, Softirq: 2, Steal: 3, Idle: 500}
+	current := cpu.TimesStat{CPU: "cpu1", User: 140, Nice: 20, System: 60, Iowait: 10, Irq: 3, Softirq: 4, Steal: 2, Idle: 531}
+
+	cs := coreSample(current, last)
+
+	// 100 seconds elapsed, where the steal time going backwards counts as zero
+	assert.Equal(t, "cpu1", cs.Core)
+	assert.InDelta(t, 50, cs.CPUUserPercent, 1e-9)
+	assert.InDelta(t, 10, cs.CPUSystemPercent, 1e-9)
+	assert.InDelta(t, 5, cs.CPUIOWaitPercent, 1e-9)
+	assert.InDelta(t, 2, cs.CPUIrqPercent, 1e-9)
+	assert.InDelta(t, 2, cs.CPUSoftIrqPercent, 1e-9)
+	assert.InDelta(t, 0, cs.CPUStealPercent, 1e-9)
+	assert.InDelta(t, 31, cs.CPUIdlePercent, 1e-9)
+	assert.InDelta(t, 69, cs.CPUPercent, 1e-9)
+}
+
+func TestCoreSamples(t *testing.T) {
+	// GIVEN per CPU times where a CPU is brought online between samples
+	times := [][]cpu.TimesStat{
+		{{CPU: "cpu0", User: 10, Idle: 10}},
+		{{CPU: "cpu0", User: 20, Idle: 20}, {CPU: "cpu1", User: 5, Idle: 5}},
+		{{CPU: "cpu0", User: 30, Idle: 30}, {CPU: "cpu1", User: 5, Idle: 15}},
+	}
+	s := NewSampler(nil)
+	call := 0
+	s.cpuTimes = func(perCPU bool) ([]cpu.TimesStat, error) {
+		assert.True(t, perCPU)
+		call++
+		return times[call-1], nil
+	}
+	cpuNodes := map[int]int{0: 0, 1: 1}
+
+	// WHEN sampled three times
+	first, err := s.cpuTimes(true)
+	require.NoError(t, err)
+	assert.Empty(t, s.coreSamples(first, cpuNodes))
+	second, _ := s.cpuTimes(true)
+	secondSamples := s.coreSamples(second, cpuNodes)
+	third, _ := s.cpuTimes(true)
+	thirdSamples := s.coreSamples(third, nil)
+
+	// THEN each CPU is reported from its second sample on
+	require.Len(t, secondSamples, 1)
+	assert.Equal(t, 0, *secondSamples[0].CoreID)
+	assert.Equal(t, 0, *secondSamples[0].NumaNode)
+	assert.InDelta(t, 50, secondSamples[0].CPUPercent, 1e-9)
+	require.Len(t, thirdSamples, 2)
+	assert.Equal(t, 1, *thirdSamples[1].CoreID)
+	assert.Nil(t, thirdSamples[1].NumaNode)
+	assert.InDelta(t, 0, thirdSamples[1].CPUPercent, 1e-9)
+}
@@ -41,6 +41,10 @@ type ProcessSample struct {
 	IOTotalWriteCount     *uint64  `json:"ioTotalWriteCount,omitempty"`
 	IOTotalReadBytes      *uint64  `json:"ioTotalReadBytes,omitempty"`
 	IOTotalWriteBytes     *uint64  `json:"ioTotalWriteBytes,omitempty"`
+	// Comma separated list of the TCP and UDP ports the process listens to, as "TCP:22,UDP:53"
+	ListeningPorts             string `json:"listeningPorts,omitempty"`
+	TCPConnectionCount         *int32 `json:"tcpConnectionCount,omitempty"`
+	EstablishedConnectionCount *int32 `json:"establishedConnectionCount,omitempty"`
 	// Auxiliary values, not to be reported
 	LastIOCounters  *process.IOCountersStat `json:"-"`
 	ContainerLabels map[string]string       `json:"-"`
@@ -4,19 +4,26 @@
 package plugins
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/sirupsen/logrus"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
@@ -24,6 +31,11 @@ import (
 
 const (
 	EXTERNAL_DIR = "external.d"
+	// fileTypeSymlink is the file type of the symbolic links, whose target content is monitored
+	fileTypeSymlink = "symlink"
+	// maxDiffFileSize is the size of the largest file whose content is kept for reporting diffs
+	maxDiffFileSize = 1024 * 1024
+	redactedText    = "[REDACTED]"
 )
 
 var monitoredFiles map[string]bool
@@ -37,10 +49,35 @@ type ExternalDFile struct {
 
 type ConfigFilePlugin struct {
 	agent.PluginCommon
-	externalDDir  string
-	watcher       *fsnotify.Watcher
-	flushInterval time.Duration
-	logger        log.Entry
+	externalDDir   string
+	watcher        *fsnotify.Watcher
+	flushInterval  time.Duration
+	logger         log.Entry
+	diffMaxSize    int
+	redactPatterns []*regexp.Regexp
+	// contents of the monitored files on the last flush, nil until the first one
+	contents map[string]fileContent
+}
+
+// fileContent is the state of a monitored file compared between flushes. The content is only kept for the text
+// files up to maxDiffFileSize.
+type fileContent struct {
+	hash     string
+	data     []byte
+	noDiff   bool
+	metadata map[string]string
+	// unreadable files keep their last state, so they are not reported as modified nor removed
+	unreadable bool
+}
+
+func newFileContent(hash string, data []byte, kept bool) fileContent {
+	content := fileContent{hash: hash, data: data, noDiff: !kept}
+	// binary files aren't diffed
+	if bytes.IndexByte(data, 0) >= 0 {
+		content.data = nil
+		content.noDiff = true
+	}
+	return content
 }
 
 func NewConfigFilePlugin(id ids.PluginID, ctx agent.AgentContext) (plugin *ConfigFilePlugin) {
@@ -50,12 +87,24 @@ func NewConfigFilePlugin(id ids.PluginID, ctx agent.AgentContext) (plugin *Confi
 	if err != nil {
 		logger.WithError(err).Error("can't instantiate file watcher")
 	}
+	cfg := ctx.Config()
+	var redactPatterns []*regexp.Regexp
+	for _, pattern := range cfg.FilesConfigRedactPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.WithError(err).WithField("pattern", pattern).Warn("Ignoring invalid redact pattern.")
+			continue
+		}
+		redactPatterns = append(redactPatterns, re)
+	}
 	return &ConfigFilePlugin{
-		agent.PluginCommon{ID: id, Context: ctx},
-		path.Join(ctx.Config().AgentDir, EXTERNAL_DIR),
-		watcher,
-		time.Second * 15,
-		logger,
+		PluginCommon:   agent.PluginCommon{ID: id, Context: ctx},
+		externalDDir:   path.Join(cfg.AgentDir, EXTERNAL_DIR),
+		watcher:        watcher,
+		flushInterval:  time.Second * 15,
+		logger:         logger,
+		diffMaxSize:    cfg.FilesConfigDiffMaxSize,
+		redactPatterns: redactPatterns,
 	}
 }
 
@@ -133,7 +182,7 @@ func fileTypeString(fi os.FileInfo) (fileType string) {
 	case mode.IsDir():
 		fileType = "directory"
 	case mode&os.ModeSymlink == os.ModeSymlink:
-		fileType = "symlink"
+		fileType = fileTypeSymlink
 	case mode&os.ModeDevice == os.ModeDevice:
 		fileType = "device"
 	case mode&os.ModeNamedPipe == os.ModeNamedPipe:
@@ -147,25 +196,192 @@ func fileTypeString(fi os.FileInfo) (fileType string) {
 	return fileType
 }
 
-func getPluginDataset() (dataset agent.PluginInventoryDataset, err error) {
+// getPluginDataset returns the inventory of the monitored files, along with their contents to report their
+// changes, which are not kept for the log files. Every file is read once.
+func getPluginDataset() (dataset agent.PluginInventoryDataset, contents map[string]fileContent) {
+	contents = make(map[string]fileContent, len(monitoredFiles))
 	for filename := range monitoredFiles {
-		var d FileData
-		if d, err = getFileData(filename); err != nil {
-			// return the error if it's anything other than the file not existing
+		d, content, err := getFileData(filename)
+		if err != nil {
+			// if the file was simply not found, ignore the error, means it's just gone
 			if !os.IsNotExist(err) {
-				// if the file was simply not found, ignore the error, means it's just gone
 				slog.WithError(err).WithField(
 					"file", filename,
 				).Error("error collecting data for file")
+				contents[filename] = fileContent{unreadable: true}
 			}
-			err = nil
 			continue
 		}
 		dataset = append(dataset, d)
+		if isLogFile(filename) {
+			continue
+		}
+		if d.FileType == fileTypeSymlink {
+			linked, err := readFileContent(filename)
+			if os.IsNotExist(err) {
+				// dangling links are reported as removed
+				continue
+			}
+			if err != nil {
+				slog.WithError(err).WithField("file", filename).Debug("Cannot read linked file content.")
+				linked.unreadable = true
+			}
+			linked.metadata = content.metadata
+			content = linked
+		}
+		contents[filename] = content
 	}
 	return
 }
 
+// hashFile returns the MD5 and SHA-256 hashes of a file, reading it once. Its content is also returned when
+// keepContent is set.
+func hashFile(filename string, keepContent bool) (md5Hash, sha256Hash string, data []byte, err error) {
+	var f *os.File
+	if f, err = os.Open(filename); err != nil {
+		return
+	}
+	defer helpers.CloseQuietly(f)
+
+	md5h, sha256h := md5.New(), sha256.New()
+	var buf bytes.Buffer
+	w := io.MultiWriter(md5h, sha256h)
+	if keepContent {
+		w = io.MultiWriter(md5h, sha256h, &buf)
+	}
+	if _, err = io.Copy(w, f); err != nil {
+		return
+	}
+	return fmt.Sprintf("%x", md5h.Sum(nil)), fmt.Sprintf("%x", sha256h.Sum(nil)), buf.Bytes(), nil
+}
+
+// readFileContent reads the content of the file a link points to.
+func readFileContent(filename string) (content fileContent, err error) {
+	var stat os.FileInfo
+	if stat, err = os.Stat(filename); err != nil {
+		return
+	}
+	keep := stat.Size() <= maxDiffFileSize
+	_, hash, data, err := hashFile(filename, keep)
+	if err != nil {
+		return
+	}
+	return newFileContent(hash, data, keep), nil
+}
+
+// emitChangeEvents emits an event for every monitored file modified, removed, or whose owner or mode changed
+// since the last flush. Nothing is emitted on the first flush, nor for the files being monitored since the last
+// one.
+func (self *ConfigFilePlugin) emitChangeEvents(contents map[string]fileContent) {
+	for filename, current := range contents {
+		if last, ok := self.contents[filename]; ok && current.unreadable {
+			contents[filename] = last
+		}
+	}
+
+	if self.contents != nil {
+		for filename, last := range self.contents {
+			current, ok := contents[filename]
+			switch {
+			case !ok && monitoredFiles[filename]:
+				self.emitChangeEvent(filename, "removed", last, fileContent{})
+			case !ok || last.unreadable || current.unreadable:
+			case current.hash != last.hash:
+				self.emitChangeEvent(filename, "modified", last, current)
+			case metadataChanged(last.metadata, current.metadata):
+				self.emitChangeEvent(filename, "metadata", last, current)
+			}
+		}
+	}
+	self.contents = contents
+}
+
+func metadataChanged(last, current map[string]string) bool {
+	for key, value := range current {
+		if last[key] != value {
+			return true
+		}
+	}
+	return false
+}
+
+var changeSummaries = map[string]string{
+	"modified": "Config file modified",
+	"removed":  "Config file removed",
+	"metadata": "Config file owner or mode modified",
+}
+
+func (self *ConfigFilePlugin) emitChangeEvent(filename, change string, last, current fileContent) {
+	event := map[string]interface{}{
+		"eventType":      "InfrastructureEvent",
+		"category":       "files",
+		"summary":        changeSummaries[change],
+		"path":           filename,
+		"change":         change,
+		"previousSha256": last.hash,
+	}
+	if current.hash != "" {
+		event["sha256"] = current.hash
+	}
+	// the changed metadata is reported along with its previous value, as "mode" and "previousMode"
+	for key, value := range current.metadata {
+		if previous := last.metadata[key]; previous != value {
+			event[key] = value
+			event["previous"+strings.ToUpper(key[:1])+key[1:]] = previous
+		}
+	}
+	if change != "metadata" && self.diffMaxSize > 0 && !last.noDiff && !current.noDiff {
+		diff, truncated, err := self.diff(filename, last.data, current.data)
+		if err != nil {
+			self.logger.WithError(err).WithField("file", filename).Debug("Cannot diff file.")
+		} else {
+			event["diff"] = diff
+			event["diffTruncated"] = truncated
+		}
+	}
+	self.EmitEvent(event, entity.EmptyKey)
+}
+
+// diff returns the unified diff between both contents, redacted and truncated to the last line fitting in the
+// maximum size.
+func (self *ConfigFilePlugin) diff(filename string, last, current []byte) (diff string, truncated bool, err error) {
+	diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(last),
+		B:        splitLines(current),
+		FromFile: filename,
+		ToFile:   filename,
+		Context:  3,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	for _, re := range self.redactPatterns {
+		diff = re.ReplaceAllString(diff, redactedText)
+	}
+	if len(diff) > self.diffMaxSize {
+		diff = diff[:self.diffMaxSize]
+		if i := strings.LastIndexByte(diff, '\n'); i >= 0 {
+			diff = diff[:i+1]
+		}
+		truncated = true
+	}
+	return diff, truncated, nil
+}
+
+// splitLines splits the content in lines keeping their line ends, which are added to the last line if missing.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + "\n"
+	}
+	return lines
+}
+
 var logFilePattern = regexp.MustCompile(`(.+?\.log$|/syslog$|/messages$|\/log\/|\bmotd$)`)
 
 func isLogFile(fp string) (result bool) {
@@ -274,10 +490,8 @@ func (self *ConfigFilePlugin) Run() {
 			if flushNeeded {
 				flushTimer.Stop()
 				flushTimer = time.NewTicker(self.flushInterval)
-				dataset, err := getPluginDataset()
-				if err != nil {
-					self.logger.WithError(err).Error("Fetching external data set")
-				}
+				dataset, contents := getPluginDataset()
+				self.emitChangeEvents(contents)
 				self.EmitInventory(dataset, entity.NewFromNameWithoutID(self.Context.EntityKey()))
 				flushNeeded = false
 
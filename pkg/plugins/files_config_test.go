@@ -6,16 +6,25 @@
 package plugins
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 
 	. "gopkg.in/check.v1"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
 )
 
 type FilesConfigSuite struct{}
@@ -46,9 +55,9 @@ func (s *FilesConfigSuite) TestGetPluginDataset(c *C) {
 
 	monitoredFiles, err = parseExternalD("fixtures/files_config/external.d/existing.json")
 	c.Assert(err, IsNil)
-	dataset, err := getPluginDataset()
-	c.Assert(err, IsNil)
+	dataset, contents := getPluginDataset()
 	c.Assert(len(dataset), Equals, 1)
+	c.Assert(contents, HasLen, 1)
 	log.Info(dataset)
 	c.Check(strings.Contains(dataset[0].(FileData).Name, "/etc/fstab"), Equals, true)
 }
@@ -59,9 +68,13 @@ func (s *FilesConfigSuite) TestGetFileData(c *C) {
 	c.Assert(err, IsNil)
 	err = ioutil.WriteFile(tmp.Name(), buf, 0644)
 	c.Assert(err, IsNil)
-	fileData, err := getFileData(tmp.Name())
+	fileData, content, err := getFileData(tmp.Name())
 	c.Assert(err, IsNil)
 	c.Check(fileData.HashMd5, Equals, "a0a6e1a375117c58d77221f10c5ce12e")
+	c.Check(content.hash, Equals, fileData.HashSha256)
+	c.Check(string(content.data), Equals, string(buf))
+	c.Check(fileData.HashSha256, Equals, "2f72cc11a6fcd0271ecef8c61056ee1eb1243be3805bf9a9df98f92f7636b05c")
+	c.Check(fileData.ModTime, Not(Equals), "")
 	c.Check(fileData.Name, Equals, tmp.Name())
 	c.Check(fileData.Mode, Equals, "-rw-------")
 	c.Check(fileData.UID, Equals, strconv.FormatUint(uint64(os.Getuid()), 10))
@@ -71,7 +84,7 @@ func (s *FilesConfigSuite) TestGetFileData(c *C) {
 }
 
 func (s *FilesConfigSuite) TestGetFileDataNonRegFile(c *C) {
-	fileData, err := getFileData("/dev/null")
+	fileData, _, err := getFileData("/dev/null")
 	c.Assert(err, IsNil)
 	c.Check(fileData.HashMd5, Equals, "")
 	c.Check(fileData.Name, Equals, "/dev/null")
@@ -122,3 +135,108 @@ func (s *FilesConfigSuite) TestIgnoreDirectories(c *C) {
 	c.Check(shouldBeIgnored(tmpDir), Equals, true)
 	c.Check(shouldBeIgnored(path), Equals, false)
 }
+
+func newTestConfigFilePlugin(c *C, events *[]map[string]interface{}) *ConfigFilePlugin {
+	ctx := new(mocks.AgentContext)
+	cfg := config.NewConfig()
+	cfg.FilesConfigDiffMaxSize = 200
+	ctx.On("Config").Return(cfg)
+	ctx.On("SendEvent", mock.Anything, entity.EmptyKey).Run(func(args mock.Arguments) {
+		var event map[string]interface{}
+		data, err := json.Marshal(args.Get(0).(sample.Event))
+		c.Assert(err, IsNil)
+		c.Assert(json.Unmarshal(data, &event), IsNil)
+		*events = append(*events, event)
+	})
+	return NewConfigFilePlugin(*ids.NewPluginID("files", "config"), ctx)
+}
+
+func (s *FilesConfigSuite) TestEmitChangeEvents(c *C) {
+	// GIVEN monitored text, binary and removable files
+	dir := c.MkDir()
+	hosts := filepath.Join(dir, "hosts")
+	removed := filepath.Join(dir, "removed.conf")
+	binary := filepath.Join(dir, "binary")
+	c.Assert(ioutil.WriteFile(hosts, []byte("127.0.0.1 localhost\ndb_password = hunter2\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(removed, []byte("foo\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(binary, []byte{0, 1}, 0644), IsNil)
+	monitoredFiles = map[string]bool{hosts: true, removed: true, binary: true}
+	var events []map[string]interface{}
+	p := newTestConfigFilePlugin(c, &events)
+
+	// WHEN they change after the first flush
+	_, contents := getPluginDataset()
+	p.emitChangeEvents(contents)
+	c.Assert(events, HasLen, 0)
+	c.Assert(ioutil.WriteFile(hosts, []byte("127.0.0.1 localhost\ndb_password = s3cr3t\n10.0.0.1 db\n"), 0644), IsNil)
+	c.Assert(os.Remove(removed), IsNil)
+	c.Assert(ioutil.WriteFile(binary, []byte{0, 2}, 0644), IsNil)
+	_, contents = getPluginDataset()
+	p.emitChangeEvents(contents)
+
+	// THEN an event is emitted for every change, with the redacted diff of the text files
+	c.Assert(events, HasLen, 3)
+	sort.Slice(events, func(i, j int) bool { return events[i]["path"].(string) < events[j]["path"].(string) })
+	c.Check(events[0]["path"], Equals, binary)
+	c.Check(events[0]["change"], Equals, "modified")
+	c.Check(events[0]["diff"], IsNil)
+	c.Check(events[1]["eventType"], Equals, "InfrastructureEvent")
+	c.Check(events[1]["category"], Equals, "files")
+	c.Check(events[1]["path"], Equals, hosts)
+	c.Check(events[1]["diff"], Equals, "--- "+hosts+"\n+++ "+hosts+"\n@@ -1,2 +1,3 @@\n"+
+		" 127.0.0.1 localhost\n-db_[REDACTED]\n+db_[REDACTED]\n+10.0.0.1 db\n")
+	c.Check(events[1]["diffTruncated"], Equals, false)
+	c.Check(events[1]["sha256"], Not(Equals), events[1]["previousSha256"])
+	c.Check(events[2]["path"], Equals, removed)
+	c.Check(events[2]["change"], Equals, "removed")
+	c.Check(events[2]["diff"], Equals, "--- "+removed+"\n+++ "+removed+"\n@@ -1 +0,0 @@\n-foo\n")
+}
+
+func (s *FilesConfigSuite) TestEmitChangeEvents_Metadata(c *C) {
+	// GIVEN a monitored file and a link to another one
+	dir := c.MkDir()
+	hosts := filepath.Join(dir, "hosts")
+	target := filepath.Join(dir, "target.conf")
+	link := filepath.Join(dir, "link.conf")
+	c.Assert(ioutil.WriteFile(hosts, []byte("127.0.0.1 localhost\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(target, []byte("foo\n"), 0644), IsNil)
+	c.Assert(os.Symlink(target, link), IsNil)
+	monitoredFiles = map[string]bool{hosts: true, link: true}
+	var events []map[string]interface{}
+	p := newTestConfigFilePlugin(c, &events)
+	_, contents := getPluginDataset()
+	p.emitChangeEvents(contents)
+
+	// WHEN the mode of the file changes, without changing its content, and the linked file is modified
+	c.Assert(os.Chmod(hosts, 0600), IsNil)
+	c.Assert(ioutil.WriteFile(target, []byte("bar\n"), 0644), IsNil)
+	_, contents = getPluginDataset()
+	p.emitChangeEvents(contents)
+
+	// THEN an event is emitted with the previous and current mode of the file
+	c.Assert(events, HasLen, 2)
+	sort.Slice(events, func(i, j int) bool { return events[i]["path"].(string) < events[j]["path"].(string) })
+	c.Check(events[0]["path"], Equals, hosts)
+	c.Check(events[0]["change"], Equals, "metadata")
+	c.Check(events[0]["summary"], Equals, "Config file owner or mode modified")
+	c.Check(events[0]["previousMode"], Equals, "-rw-r--r--")
+	c.Check(events[0]["mode"], Equals, "-rw-------")
+	c.Check(events[0]["ownerUser"], IsNil)
+	c.Check(events[0]["diff"], IsNil)
+	// AND the link is reported as modified with the diff of the linked file
+	c.Check(events[1]["path"], Equals, link)
+	c.Check(events[1]["change"], Equals, "modified")
+	c.Check(events[1]["diff"], Equals, "--- "+link+"\n+++ "+link+"\n@@ -1 +1 @@\n-foo\n+bar\n")
+}
+
+func (s *FilesConfigSuite) TestDiffTruncation(c *C) {
+	var events []map[string]interface{}
+	p := newTestConfigFilePlugin(c, &events)
+	p.diffMaxSize = 30
+
+	diff, truncated, err := p.diff("/etc/hosts", nil, []byte("127.0.0.1 localhost\n10.0.0.1 db\n"))
+
+	c.Assert(err, IsNil)
+	c.Check(truncated, Equals, true)
+	c.Check(diff, Equals, "--- /etc/hosts\n+++ /etc/hosts\n")
+}
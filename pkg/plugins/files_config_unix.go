@@ -6,29 +6,42 @@
 package plugins
 
 import (
-	"fmt"
 	"os"
+	"os/user"
 	"strconv"
 	"syscall"
-
-	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"time"
 )
 
 type FileData struct {
-	Name     string `json:"id"`
-	Size     string `json:"file_size"`
-	Mode     string `json:"mode"`
-	UID      string `json:"owner_user"`
-	GID      string `json:"owner_group"`
-	HashMd5  string `json:"md5_hash"`
-	FileType string `json:"file_type"`
+	Name       string `json:"id"`
+	Size       string `json:"file_size"`
+	Mode       string `json:"mode"`
+	UID        string `json:"owner_user"`
+	GID        string `json:"owner_group"`
+	UserName   string `json:"owner_user_name,omitempty"`
+	GroupName  string `json:"owner_group_name,omitempty"`
+	ModTime    string `json:"mtime"`
+	HashMd5    string `json:"md5_hash"`
+	HashSha256 string `json:"sha256_hash,omitempty"`
+	FileType   string `json:"file_type"`
 }
 
 func (self FileData) SortKey() string {
 	return self.Name
 }
 
-func getFileData(filename string) (d FileData, err error) {
+// fileMetadata returns the file attributes whose changes are reported, as the content ones.
+func fileMetadata(d FileData) map[string]string {
+	return map[string]string{
+		"mode":       d.Mode,
+		"ownerUser":  d.UID,
+		"ownerGroup": d.GID,
+	}
+}
+
+// getFileData returns the inventory data of the file, along with its content when it's a regular file.
+func getFileData(filename string) (d FileData, content fileContent, err error) {
 	var stat os.FileInfo
 	stat, err = os.Lstat(filename)
 	if err != nil {
@@ -39,18 +52,28 @@ func getFileData(filename string) (d FileData, err error) {
 	d.Mode = stat.Mode().String()
 	d.UID = strconv.FormatUint(uint64(stat.Sys().(*syscall.Stat_t).Uid), 10)
 	d.GID = strconv.FormatUint(uint64(stat.Sys().(*syscall.Stat_t).Gid), 10)
+	// names are only reported when the ids are known by the host
+	if u, err := user.LookupId(d.UID); err == nil {
+		d.UserName = u.Username
+	}
+	if g, err := user.LookupGroupId(d.GID); err == nil {
+		d.GroupName = g.Name
+	}
+	d.ModTime = stat.ModTime().UTC().Format(time.RFC3339)
 	d.FileType = fileTypeString(stat)
 
-	var hash []byte
 	if stat.Mode().IsRegular() {
-		hash, err = helpers.FileMD5(filename)
-		if err != nil {
-			slog.WithError(err).WithField("file", filename).Error("Could not compute hash for file")
-			d.HashMd5 = "unknown"
-			err = nil
+		keep := stat.Size() <= maxDiffFileSize
+		md5Hash, sha256Hash, data, hashErr := hashFile(filename, keep)
+		if hashErr != nil {
+			slog.WithError(hashErr).WithField("file", filename).Error("Could not compute hash for file")
+			d.HashMd5, d.HashSha256 = "unknown", "unknown"
+			content.unreadable = true
 		} else {
-			d.HashMd5 = fmt.Sprintf("%x", hash)
+			d.HashMd5, d.HashSha256 = md5Hash, sha256Hash
+			content = newFileContent(sha256Hash, data, keep)
 		}
 	}
+	content.metadata = fileMetadata(d)
 	return
 }
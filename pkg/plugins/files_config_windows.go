@@ -3,25 +3,31 @@
 package plugins
 
 import (
-	"fmt"
 	"os"
 	"strconv"
-
-	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"time"
 )
 
 type FileData struct {
-	Name     string `json:"id"`
-	Size     string `json:"file_size"`
-	HashMd5  string `json:"md5_hash"`
-	FileType string `json:"file_type"`
+	Name       string `json:"id"`
+	Size       string `json:"file_size"`
+	ModTime    string `json:"mtime"`
+	HashMd5    string `json:"md5_hash"`
+	HashSha256 string `json:"sha256_hash,omitempty"`
+	FileType   string `json:"file_type"`
 }
 
 func (self FileData) SortKey() string {
 	return self.Name
 }
 
-func getFileData(filename string) (d FileData, err error) {
+// fileMetadata returns no attributes, as ownership and mode changes aren't reported on Windows.
+func fileMetadata(FileData) map[string]string {
+	return nil
+}
+
+// getFileData returns the inventory data of the file, along with its content when it's a regular file.
+func getFileData(filename string) (d FileData, content fileContent, err error) {
 	var stat os.FileInfo
 	stat, err = os.Lstat(filename)
 	if err != nil {
@@ -29,18 +35,21 @@ func getFileData(filename string) (d FileData, err error) {
 	}
 	d.Name = filename
 	d.Size = strconv.FormatInt(stat.Size(), 10)
+	d.ModTime = stat.ModTime().UTC().Format(time.RFC3339)
 	d.FileType = fileTypeString(stat)
 
-	var hash []byte
 	if stat.Mode().IsRegular() {
-		hash, err = helpers.FileMD5(filename)
-		if err != nil {
-			slog.WithError(err).WithField("file", filename).Error("Could not compute hash for file")
-			d.HashMd5 = "unknown"
-			err = nil
+		keep := stat.Size() <= maxDiffFileSize
+		md5Hash, sha256Hash, data, hashErr := hashFile(filename, keep)
+		if hashErr != nil {
+			slog.WithError(hashErr).WithField("file", filename).Error("Could not compute hash for file")
+			d.HashMd5, d.HashSha256 = "unknown", "unknown"
+			content.unreadable = true
 		} else {
-			d.HashMd5 = fmt.Sprintf("%x", hash)
+			d.HashMd5, d.HashSha256 = md5Hash, sha256Hash
+			content = newFileContent(sha256Hash, data, keep)
 		}
 	}
+	content.metadata = fileMetadata(d)
 	return
 }
@@ -3,17 +3,22 @@
 package plugins
 
 import (
+	"os"
+
 	agnt "github.com/newrelic/infrastructure-agent/internal/agent"
 	"github.com/newrelic/infrastructure-agent/internal/plugins/common"
 	pluginsLinux "github.com/newrelic/infrastructure-agent/internal/plugins/linux"
 	config2 "github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/cgroup"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/network"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/process"
 	metricsSender "github.com/newrelic/infrastructure-agent/pkg/metrics/sender"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/sensor"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage/nfs"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/topology"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/proxy"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/cloud"
@@ -71,6 +76,11 @@ func RegisterPlugins(agent *agnt.Agent) error {
 			agent.RegisterPlugin(NewConfigFilePlugin(ids.PluginID{"files", "config"}, agent.Context))
 		}
 		agent.RegisterPlugin(pluginsLinux.NewUsersPlugin(agent.Context))
+		agent.RegisterPlugin(pluginsLinux.NewCertificatesPlugin(ids.PluginID{"files", "certificates"}, agent.Context))
+		if config.EnableLanguagePackagesPlugin {
+			agent.RegisterPlugin(pluginsLinux.NewPipPlugin(ids.PluginID{"packages", "pip"}, agent.Context))
+			agent.RegisterPlugin(pluginsLinux.NewNpmPlugin(ids.PluginID{"packages", "npm"}, agent.Context))
+		}
 		agent.RegisterPlugin(pluginsLinux.NewDaemontoolsPlugin(ids.PluginID{"services", "daemontools"}, agent.Context))
 		agent.RegisterPlugin(pluginsLinux.NewSupervisorPlugin(ids.PluginID{"services", "supervisord"}, agent.Context))
 		agent.RegisterPlugin(NewNetworkInterfacePlugin(ids.PluginID{"system", "network_interfaces"}, agent.Context))
@@ -100,11 +110,26 @@ func RegisterPlugins(agent *agnt.Agent) error {
 			case helpers.LINUX_REDHAT, helpers.LINUX_AWS_REDHAT, helpers.LINUX_SUSE:
 				slog.Debug("Registering RPM plugins.")
 				agent.RegisterPlugin(pluginsLinux.NewRpmPlugin(agent.Context))
+
+			case helpers.LINUX_ALPINE:
+				slog.Debug("Registering Alpine plugins.")
+				agent.RegisterPlugin(pluginsLinux.NewApkPlugin(ids.PluginID{"packages", "apk"}, agent.Context))
+			}
+
+			// distro agnostic package managers
+			if _, err := os.Stat(helpers.HostVar(pluginsLinux.SnapdStateFile)); err == nil {
+				agent.RegisterPlugin(pluginsLinux.NewSnapPlugin(ids.PluginID{"packages", "snap"}, agent.Context))
+			}
+			if _, err := os.Stat(helpers.HostVar(pluginsLinux.FlatpakSystemDir)); err == nil {
+				agent.RegisterPlugin(pluginsLinux.NewFlatpakPlugin(ids.PluginID{"packages", "flatpak"}, agent.Context))
 			}
 		}
 
 		if config.RunMode == config2.ModeRoot {
 			agent.RegisterPlugin(pluginsLinux.NewSELinuxPlugin(ids.PluginID{"config", "selinux"}, agent.Context))
+			if config.EnableFirewallPlugin {
+				agent.RegisterPlugin(pluginsLinux.NewFirewallPlugin(ids.PluginID{"config", "firewall"}, agent.Context))
+			}
 		}
 
 		if agent.GetCloudHarvester().GetCloudType() == cloud.TypeAWS {
@@ -117,9 +142,15 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	storageSampler := storage.NewSampler(agent.Context)
 	nfsSampler := nfs.NewSampler(agent.Context)
 	networkSampler := network.NewNetworkSampler(agent.Context)
+	connectionSampler := network.NewConnectionSampler(agent.Context)
+	cgroupSampler := cgroup.NewSampler(agent.Context)
+	sensorSampler := sensor.NewSampler(agent.Context)
+	topologySampler := topology.NewSampler(agent.Context)
 
 	var ntpMonitor metrics.NtpMonitor
-	if config.NtpMetrics.Enabled {
+	if config.NtpMetrics.Enabled && config.NtpMetrics.Mode == config2.NtpModeLocal {
+		ntpMonitor = metrics.NewLocalTimeSync(config.NtpMetrics.ChronyAddress)
+	} else if config.NtpMetrics.Enabled {
 		ntpMonitor = metrics.NewNtp(config.NtpMetrics.Pool, config.NtpMetrics.Timeout, config.NtpMetrics.Interval)
 	}
 	systemSampler := metrics.NewSystemSampler(agent.Context, storageSampler, ntpMonitor)
@@ -144,6 +175,10 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	sender.RegisterSampler(storageSampler)
 	sender.RegisterSampler(nfsSampler)
 	sender.RegisterSampler(networkSampler)
+	sender.RegisterSampler(connectionSampler)
+	sender.RegisterSampler(cgroupSampler)
+	sender.RegisterSampler(sensorSampler)
+	sender.RegisterSampler(topologySampler)
 	sender.RegisterSampler(procSampler)
 
 	agent.RegisterMetricsSender(sender)
@@ -7,7 +7,6 @@ import (
 	"compress/gzip"
 	"net/http"
 	"os"
-	"path/filepath"
 	"runtime"
 	"time"
 
@@ -16,9 +15,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/fflag"
 	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
 	"github.com/newrelic/infrastructure-agent/internal/instrumentation"
-	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
-	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/v3legacy"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/track"
@@ -116,7 +113,7 @@ func (ae *Emulator) RunAgent() error {
 
 	// track stoppable integrations
 	tracker := track.NewTracker(dmEmitter)
-	il := newInstancesLookup(ae.integrationCfg)
+	il := v4.NewInstancesLookup(ae.integrationCfg)
 	integrationEmitter := emitter.NewIntegrationEmittor(ae.agent, dmEmitter, ffManager)
 	integrationManager := v4.NewManager(
 		ae.integrationCfg,
@@ -143,21 +140,3 @@ func (ae *Emulator) RunAgent() error {
 
 	return nil
 }
-
-func newInstancesLookup(cfg v4.ManagerConfig) integration.InstancesLookup {
-	const executablesSubFolder = "bin"
-
-	var execFolders []string
-	for _, df := range cfg.DefinitionFolders {
-		execFolders = append(execFolders, df)
-		execFolders = append(execFolders, filepath.Join(df, executablesSubFolder))
-	}
-	legacyDefinedCommands := v3legacy.NewDefinitionsRepo(v3legacy.LegacyConfig{
-		DefinitionFolders: cfg.DefinitionFolders,
-		Verbose:           cfg.Verbose,
-	})
-	return integration.InstancesLookup{
-		Legacy: legacyDefinedCommands.NewDefinitionCommand,
-		ByName: files.Executables{Folders: execFolders}.Path,
-	}
-}